@@ -0,0 +1,13 @@
+// Command backup writes a snapshot of the store; it is zatgpt backup under
+// its original name.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Run("backup", os.Args[1:])
+}
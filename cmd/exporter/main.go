@@ -0,0 +1,13 @@
+// Command exporter writes the conversations matching a query; it is zatgpt
+// export under its original name.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Run("export", os.Args[1:])
+}
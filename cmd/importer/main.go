@@ -1,40 +1,13 @@
+// Command importer loads exports into the store; it is zatgpt import under
+// its original name.
 package main
 
 import (
-    "flag"
-    "fmt"
-    "log"
+    "os"
 
-    "zatGPT/internal/importer"
-    "zatGPT/internal/storage"
+    "zatGPT/internal/cli"
 )
 
 func main() {
-    filePath := flag.String("file", "conversations.json", "path to ChatGPT export JSON")
-    dataPath := flag.String("data", "data/conversations_store.json", "destination persistence file")
-    flag.Parse()
-
-    items, err := importer.LoadAndConvert(*filePath)
-    if err != nil {
-        log.Fatalf("failed to parse export: %v", err)
-    }
-
-    store, err := storage.New(*dataPath)
-    if err != nil {
-        log.Fatalf("failed to open store: %v", err)
-    }
-
-    var created, updated int
-    for _, item := range items {
-        if _, err := store.Get(item.ID); err == nil {
-            updated++
-        } else {
-            created++
-        }
-        if err := store.Upsert(item); err != nil {
-            log.Fatalf("failed to persist conversation %s: %v", item.ID, err)
-        }
-    }
-
-    fmt.Printf("Imported %d conversations (%d new, %d updated)\n", len(items), created, updated)
+    cli.Run("import", os.Args[1:])
 }
@@ -0,0 +1,13 @@
+// Command report compiles a year in review; it is zatgpt report under its
+// original name.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Run("report", os.Args[1:])
+}
@@ -0,0 +1,13 @@
+// Command restore restores or merges a snapshot; it is zatgpt restore under
+// its original name.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Run("restore", os.Args[1:])
+}
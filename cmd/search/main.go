@@ -0,0 +1,13 @@
+// Command search full-text searches the store; it is zatgpt search under its
+// original name.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Run("search", os.Args[1:])
+}
@@ -0,0 +1,14 @@
+// Command zatgpt is the single binary for every task: serve, import,
+// export, search, stats, purge, compact, report, backup and restore. Run
+// zatgpt help for the list.
+package main
+
+import (
+    "os"
+
+    "zatGPT/internal/cli"
+)
+
+func main() {
+    cli.Main(os.Args[1:])
+}
@@ -8,9 +8,20 @@ const startEl = document.querySelector("#conversation-start");
 const endEl = document.querySelector("#conversation-end");
 const remoteLinkEl = document.querySelector("#conversation-remote");
 const messageListEl = document.querySelector("#message-list");
+const loadMoreButton = document.querySelector("#load-more-messages");
+const allRolesToggle = document.querySelector("#all-roles-toggle");
+const showAllRoles = document.querySelector("#show-all-roles");
 const errorDialog = document.querySelector("#error-dialog");
 const errorMessageEl = document.querySelector("#error-message");
 
+// Long transcripts are loaded a page at a time from /messages. System and
+// tool messages, such as Code Interpreter runs or those kept by imports with
+// -all-roles, are hidden unless asked for.
+const MESSAGE_PAGE_SIZE = 100;
+const VISIBLE_ROLES = "user,assistant";
+let audioByMessage = new Map();
+let lastMessageId = null;
+
 init();
 
 async function init() {
@@ -19,14 +30,77 @@ async function init() {
     return;
   }
 
+  loadMoreButton.addEventListener("click", loadMessages);
+  showAllRoles.addEventListener("change", reloadMessages);
   try {
-    const conversation = await fetchJSON(`${API_BASE}/conversations/${encodeURIComponent(conversationId)}`);
+    const conversation = await fetchJSON(`${API_BASE}/conversations/${encodeURIComponent(conversationId)}?include=meta`);
     renderConversation(conversation);
+    await loadMessages();
+    await jumpToMessage();
   } catch (error) {
     showError(`Unable to load conversation: ${error?.message ?? "Unknown error"}`);
   }
 }
 
+// A #message-<id> anchor, as built from a /api/search?hits=messages
+// result, scrolls to that message, loading pages until it is reached.
+async function jumpToMessage() {
+  if (!window.location.hash.startsWith("#message-")) {
+    return;
+  }
+  const id = decodeURIComponent(window.location.hash.slice(1));
+  let target = document.getElementById(id);
+  while (!target && !loadMoreButton.hidden) {
+    const previous = lastMessageId;
+    await loadMessages();
+    if (lastMessageId === previous) {
+      break;
+    }
+    target = document.getElementById(id);
+  }
+  if (!target && !showAllRoles.checked && !allRolesToggle.hidden) {
+    // The hit may be a system or tool message.
+    showAllRoles.checked = true;
+    await reloadMessages();
+    return jumpToMessage();
+  }
+  if (target) {
+    target.classList.add("is-target");
+    target.scrollIntoView({ block: "center" });
+  }
+}
+
+async function loadMessages() {
+  const query = new URLSearchParams({ limit: String(MESSAGE_PAGE_SIZE) });
+  if (!showAllRoles.checked) {
+    query.set("roles", VISIBLE_ROLES);
+  }
+  if (lastMessageId) {
+    query.set("after", lastMessageId);
+  }
+  loadMoreButton.disabled = true;
+  try {
+    const page = await fetchJSON(`${API_BASE}/conversations/${encodeURIComponent(conversationId)}/messages?${query}`);
+    renderMessages(page.messages || [], page.messageWindow.offset);
+    const { offset, count, total, hidden = 0 } = page.messageWindow;
+    if (hidden > 0) {
+      allRolesToggle.hidden = false;
+    }
+    loadMoreButton.hidden = offset + count >= total;
+    loadMoreButton.textContent = `Load more messages (${total - offset - count} left)`;
+  } catch (error) {
+    showError(`Unable to load messages: ${error?.message ?? "Unknown error"}`);
+  } finally {
+    loadMoreButton.disabled = false;
+  }
+}
+
+async function reloadMessages() {
+  messageListEl.innerHTML = "";
+  lastMessageId = null;
+  await loadMessages();
+}
+
 function renderConversation(conversation) {
   titleEl.textContent = conversation.title || "Conversation";
   summaryEl.textContent = conversation.summary || "";
@@ -45,12 +119,19 @@ function renderConversation(conversation) {
     remoteLinkEl.classList.add("is-disabled");
   }
 
-  renderMessages(conversation.messages || []);
+  audioByMessage = new Map();
+  (conversation.attachments || [])
+    .filter((attachment) => attachment.stored && (attachment.mimeType || "").startsWith("audio/"))
+    .forEach((attachment) => {
+      const list = audioByMessage.get(attachment.messageId) ?? [];
+      list.push(attachment);
+      audioByMessage.set(attachment.messageId, list);
+    });
+  messageListEl.innerHTML = "";
 }
 
-function renderMessages(messages) {
-  messageListEl.innerHTML = "";
-  if (!messages.length) {
+function renderMessages(messages, offset) {
+  if (!messages.length && offset === 0) {
     const emptyState = document.createElement("p");
     emptyState.className = "empty-state";
     emptyState.textContent = "No transcript available for this conversation.";
@@ -62,6 +143,9 @@ function renderMessages(messages) {
     const item = document.createElement("article");
     const roleClass = (message.author || "unknown").toLowerCase();
     item.className = `message message-${roleClass}`;
+    if (message.id) {
+      item.id = `message-${message.id}`;
+    }
 
     const header = document.createElement("header");
     header.className = "message-header";
@@ -72,35 +156,116 @@ function renderMessages(messages) {
 
     const timestamp = document.createElement("time");
     timestamp.className = "message-timestamp";
-    timestamp.textContent = formatDateTime(message.createdAt, index);
+    timestamp.textContent = formatDateTime(message.createdAt, offset + index);
 
     header.appendChild(role);
     header.appendChild(timestamp);
 
     const body = document.createElement("div");
     body.className = "message-content";
-    body.textContent = message.content || "";
+    if (message.parts?.length) {
+      message.parts.forEach((part) => body.appendChild(renderPart(part)));
+    } else {
+      body.textContent = message.content || "";
+    }
 
     item.appendChild(header);
+    (audioByMessage.get(message.id) ?? []).forEach((attachment) => {
+      const player = document.createElement("audio");
+      player.className = "message-audio";
+      player.controls = true;
+      player.preload = "none";
+      player.src = `${API_BASE}/attachments/${encodeURIComponent(attachment.ref)}`;
+      item.appendChild(player);
+    });
     item.appendChild(body);
     messageListEl.appendChild(item);
   });
+  if (messages.length) {
+    lastMessageId = messages[messages.length - 1].id;
+  }
+}
+
+// renderPart renders code the assistant ran, or what running it printed,
+// as a preformatted block labelled with the language or "Output".
+function renderPart(part) {
+  const figure = document.createElement("figure");
+  figure.className = `message-part message-part-${part.type}`;
+
+  const label = document.createElement("figcaption");
+  label.className = "message-part-label";
+  const pre = document.createElement("pre");
+  const code = document.createElement("code");
+  if (part.type === "code") {
+    label.textContent = part.language || "Code";
+    code.textContent = part.source || "";
+    if (part.language) {
+      code.className = `language-${part.language}`;
+    }
+  } else {
+    label.textContent = part.type === "error" ? "Error" : "Output";
+    code.textContent = part.output || "";
+  }
+  pre.appendChild(code);
+  figure.appendChild(label);
+  figure.appendChild(pre);
+  return figure;
+}
+
+// Servers started with -api-key answer 401 until the key is supplied; it
+// is kept in localStorage for fetches, and the server answers the first
+// fetch that sends it with an HttpOnly cookie for media elements.
+const API_KEY_STORAGE = "zatgpt-api-key";
+
+function withAPIKey(options) {
+  const key = localStorage.getItem(API_KEY_STORAGE);
+  if (!key) {
+    return options;
+  }
+  return { ...options, headers: { ...options.headers, Authorization: `Bearer ${key}` } };
+}
+
+function askForAPIKey() {
+  const key = window.prompt("This archive requires an API key:");
+  if (!key) {
+    return false;
+  }
+  localStorage.setItem(API_KEY_STORAGE, key);
+  return true;
+}
+
+// The API shows conversation dates in the zone the Time-Zone header names;
+// ask for the browser's, so a late-night chat lands on the right day.
+const TIME_ZONE = Intl.DateTimeFormat().resolvedOptions().timeZone;
+
+function withTimeZone(options) {
+  if (!TIME_ZONE) {
+    return options;
+  }
+  return { ...options, headers: { ...options.headers, "Time-Zone": TIME_ZONE } };
 }
 
 async function fetchJSON(url, options = {}) {
-  const response = await fetch(url, options);
+  options = withTimeZone(options);
+  let response = await fetch(url, withAPIKey(options));
+  if (response.status === 401 && askForAPIKey()) {
+    response = await fetch(url, withAPIKey(options));
+  }
   if (!response.ok) {
     let message = `${response.status} ${response.statusText}`;
+    let code;
     try {
       const data = await response.json();
       if (data && data.error) {
-        message = data.error;
+        message = data.error.message ?? data.error;
+        code = data.error.code;
       }
     } catch (error) {
       // swallow
     }
     const error = new Error(message);
     error.status = response.status;
+    error.code = code;
     throw error;
   }
   return response.json();
@@ -119,10 +284,13 @@ function formatDate(value) {
   if (!value) return "–";
   const date = new Date(value);
   if (Number.isNaN(date.valueOf())) return value;
+  // Dates arrive as the day in the browser's zone; a bare YYYY-MM-DD
+  // parses as UTC midnight, so format it in UTC to keep the day.
   return date.toLocaleDateString(undefined, {
     year: "numeric",
     month: "short",
     day: "numeric",
+    timeZone: "UTC",
   });
 }
 
@@ -0,0 +1,272 @@
+package api
+
+import (
+    "archive/zip"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+
+    "zatGPT/internal/export"
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/thumbnail"
+)
+
+// downloadAttachmentsZip streams every stored attachment of a conversation as
+// a single ZIP archive. Attachments the export referenced but did not ship
+// are skipped.
+func (s *Server) downloadAttachmentsZip(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    stored := make([]models.Attachment, 0, len(convo.Attachments))
+    for _, att := range convo.Attachments {
+        if att.Stored {
+            stored = append(stored, att)
+        }
+    }
+    if len(stored) == 0 {
+        writeErrorString(w, http.StatusNotFound, "conversation has no stored attachments")
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Slug(convo.Title, convo.ID)+"-attachments.zip"))
+    w.WriteHeader(http.StatusOK)
+
+    archive := zip.NewWriter(w)
+    used := make(map[string]int)
+    for _, att := range stored {
+        file, err := s.store.OpenAttachment(att.Ref)
+        if err != nil {
+            continue
+        }
+        entry, err := archive.Create(uniqueName(used, attachmentFilename(att)))
+        if err == nil {
+            _, err = io.Copy(entry, file)
+        }
+        file.Close()
+        if err != nil {
+            return
+        }
+    }
+    _ = archive.Close()
+}
+
+func attachmentFilename(att models.Attachment) string {
+    name := path.Base(strings.ReplaceAll(att.Name, "\\", "/"))
+    if name == "" || name == "." || name == "/" {
+        return att.Ref
+    }
+    return name
+}
+
+// uniqueName suffixes repeated names (`a.png`, `a-2.png`) so ZIP entries
+// never collide.
+func uniqueName(used map[string]int, name string) string {
+    used[name]++
+    if used[name] == 1 {
+        return name
+    }
+    ext := path.Ext(name)
+    return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), used[name], ext)
+}
+
+// maxAttachmentUpload bounds one uploaded attachment blob.
+const maxAttachmentUpload = 512 << 20
+
+// handleAttachment serves /api/attachments/{ref} (the original blob) and
+// /api/attachments/{ref}/thumb?w= (a cached JPEG thumbnail). PUT on the blob
+// path uploads it, for remote imports.
+func (s *Server) handleAttachment(w http.ResponseWriter, r *http.Request) {
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/attachments/"), "/")
+    ref, sub, _ := strings.Cut(rest, "/")
+
+    if r.Method == http.MethodPut && sub == "" {
+        s.uploadAttachment(w, r, ref)
+        return
+    }
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        methodNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodPut)
+        return
+    }
+
+    att, err := s.store.FindAttachment(ref)
+    if err != nil {
+        writeErrorString(w, http.StatusNotFound, "attachment not found")
+        return
+    }
+
+    switch sub {
+    case "":
+        s.serveAttachment(w, r, att)
+    case "thumb":
+        s.serveThumbnail(w, r, att)
+    default:
+        writeNotFound(w)
+    }
+}
+
+// uploadAttachment stores the request body as the blob for ref, replacing
+// any earlier copy. The conversation referencing it arrives separately via
+// the batch endpoint.
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request, ref string) {
+    body := http.MaxBytesReader(w, r.Body, maxAttachmentUpload)
+    defer body.Close()
+
+    size, err := s.store.SaveAttachment(ref, body)
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            writeErrorString(w, http.StatusRequestEntityTooLarge, "attachment exceeds the upload limit")
+            return
+        }
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, map[string]any{"ref": ref, "size": size})
+}
+
+func (s *Server) serveAttachment(w http.ResponseWriter, r *http.Request, att models.Attachment) {
+    file, err := s.store.OpenAttachment(att.Ref)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeErrorString(w, http.StatusNotFound, "attachment was not included in the export")
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    defer file.Close()
+
+    info, err := file.Stat()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    // Attachments come from exports and uploads, so nothing in one may run
+    // as part of the app: the type is never sniffed, scripts and embedded
+    // content are off even when a file is opened directly, and only types
+    // a browser merely displays are shown inline.
+    mimeType := "application/octet-stream"
+    if att.MimeType != "" {
+        mimeType = att.MimeType
+    }
+    disposition := "attachment"
+    if inlineAttachment(mimeType) {
+        disposition = "inline"
+    }
+    w.Header().Set("Content-Type", mimeType)
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, attachmentFilename(att)))
+    http.ServeContent(w, r, attachmentFilename(att), info.ModTime(), file)
+}
+
+// inlineMimeTypes are the attachment types served inline: images other
+// than SVG, audio and PDF. Everything else is downloaded.
+var inlineMimeTypes = map[string]bool{
+    "image/png":       true,
+    "image/jpeg":      true,
+    "image/gif":       true,
+    "image/webp":      true,
+    "image/avif":      true,
+    "image/bmp":       true,
+    "audio/mpeg":      true,
+    "audio/mp4":       true,
+    "audio/aac":       true,
+    "audio/ogg":       true,
+    "audio/opus":      true,
+    "audio/wav":       true,
+    "audio/wave":      true,
+    "audio/x-wav":     true,
+    "audio/webm":      true,
+    "audio/flac":      true,
+    "application/pdf": true,
+}
+
+// inlineAttachment reports whether an attachment of mimeType, parameters
+// and all, may be shown inline.
+func inlineAttachment(mimeType string) bool {
+    mediaType, _, err := mime.ParseMediaType(mimeType)
+    return err == nil && inlineMimeTypes[mediaType]
+}
+
+func (s *Server) serveThumbnail(w http.ResponseWriter, r *http.Request, att models.Attachment) {
+    width := thumbnail.DefaultWidth
+    if raw := r.URL.Query().Get("w"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil {
+            writeErrorString(w, http.StatusBadRequest, "w must be an integer")
+            return
+        }
+        width = parsed
+    }
+    width = thumbnail.ClampWidth(width)
+
+    cached, err := s.store.OpenThumbnail(att.Ref, width)
+    if err == storage.ErrNotFound {
+        if err := s.generateThumbnail(att.Ref, width); err != nil {
+            switch err {
+            case storage.ErrNotFound:
+                writeErrorString(w, http.StatusNotFound, "attachment was not included in the export")
+            case thumbnail.ErrUnsupported:
+                writeError(w, http.StatusUnsupportedMediaType, err)
+            case thumbnail.ErrTooLarge:
+                writeError(w, http.StatusUnprocessableEntity, err)
+            default:
+                writeError(w, http.StatusInternalServerError, err)
+            }
+            return
+        }
+        cached, err = s.store.OpenThumbnail(att.Ref, width)
+    }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    defer cached.Close()
+
+    info, err := cached.Stat()
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "image/jpeg")
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    http.ServeContent(w, r, "", info.ModTime(), cached)
+}
+
+func (s *Server) generateThumbnail(ref string, width int) error {
+    src, err := s.store.OpenAttachment(ref)
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+
+    var buf bytes.Buffer
+    if err := thumbnail.Generate(&buf, src, width); err != nil {
+        return err
+    }
+    return s.store.SaveThumbnail(ref, width, &buf)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+    "crypto/subtle"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// RequireToken guards every API request that changes data (anything but
+// GET, HEAD and OPTIONS under /api/) with a bearer token. Reads and static
+// files stay open. An empty token disables the check.
+func RequireToken(token string, next http.Handler) http.Handler {
+    if token == "" {
+        return next
+    }
+    want := []byte("Bearer " + token)
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodGet, http.MethodHead, http.MethodOptions:
+            next.ServeHTTP(w, r)
+            return
+        }
+        if !strings.HasPrefix(r.URL.Path, "/api/") {
+            next.ServeHTTP(w, r)
+            return
+        }
+        got := []byte(r.Header.Get("Authorization"))
+        if subtle.ConstantTimeCompare(got, want) != 1 {
+            unauthorized(w, "a valid bearer token is required to modify the archive")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// APIKeyCookie carries the API key for requests that cannot set headers,
+// such as the bundled UI's <img> and <audio> elements.
+const APIKeyCookie = "zatgpt_api_key"
+
+// RequireAPIKey guards every request under /api/, reads included, with
+// Authorization: Bearer and one of keys, or the key in the APIKeyCookie
+// cookie. A request that sends a valid key in the header gets the cookie
+// set, so the browser's media requests carry it too. CORS preflights and
+// static files stay open. Keys that are empty are ignored; with none left
+// the check is disabled.
+func RequireAPIKey(keys []string, next http.Handler) http.Handler {
+    var accepted [][]byte
+    for _, key := range keys {
+        if key != "" {
+            accepted = append(accepted, []byte(key))
+        }
+    }
+    if len(accepted) == 0 {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodOptions || !strings.HasPrefix(r.URL.Path, "/api/") {
+            next.ServeHTTP(w, r)
+            return
+        }
+        cookie, _ := r.Cookie(APIKeyCookie)
+        got, fromHeader := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !fromHeader && cookie != nil {
+            got, _ = url.QueryUnescape(cookie.Value)
+        }
+        for _, key := range accepted {
+            if subtle.ConstantTimeCompare([]byte(got), key) == 1 {
+                if fromHeader && (cookie == nil || cookie.Value != url.QueryEscape(got)) {
+                    setAPIKeyCookie(w, r, got)
+                }
+                next.ServeHTTP(w, r)
+                return
+            }
+        }
+        unauthorized(w, "a valid API key is required")
+    })
+}
+
+// setAPIKeyCookie stores key in the APIKeyCookie cookie, out of reach of
+// scripts, sent back only by same-site requests, and only over HTTPS when
+// the server is serving it.
+func setAPIKeyCookie(w http.ResponseWriter, r *http.Request, key string) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     APIKeyCookie,
+        Value:    url.QueryEscape(key),
+        Path:     "/",
+        HttpOnly: true,
+        Secure:   r.TLS != nil,
+        SameSite: http.SameSiteStrictMode,
+    })
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+    w.Header().Set("WWW-Authenticate", `Bearer realm="zatgpt"`)
+    writeErrorBody(w, http.StatusUnauthorized, errorBody{
+        Code:    CodeUnauthorized,
+        Message: message,
+    })
+}
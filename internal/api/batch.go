@@ -0,0 +1,72 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "zatGPT/internal/importer"
+    "zatGPT/internal/models"
+)
+
+// maxBatchSize bounds the conversations accepted by one batch request.
+const maxBatchSize = 1000
+
+// maxBatchBody bounds the size of one batch request.
+const maxBatchBody = 256 << 20
+
+// batchRequest is the body of POST /api/conversations/batch.
+type batchRequest struct {
+    Conversations []models.Conversation `json:"conversations"`
+}
+
+// handleBatch serves POST /api/conversations/batch: it upserts fully
+// converted conversations (messages, attachments and tree included) in a
+// single save, the way the importer does locally, and returns what changed.
+// Remote imports use it so only the server writes the store file.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    var payload batchRequest
+    if err := decodeJSONLimit(r.Body, &payload, maxBatchBody); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var fields []fieldError
+    switch n := len(payload.Conversations); {
+    case n == 0:
+        fields = append(fields, fieldError{Field: "conversations", Message: "must not be empty"})
+    case n > maxBatchSize:
+        fields = append(fields, fieldError{Field: "conversations", Message: "must hold at most " + strconv.Itoa(maxBatchSize) + " items"})
+    }
+    seen := make(map[string]bool, len(payload.Conversations))
+    for i, convo := range payload.Conversations {
+        field := "conversations[" + strconv.Itoa(i) + "]"
+        id := strings.TrimSpace(convo.ID)
+        switch {
+        case id == "":
+            fields = append(fields, fieldError{Field: field + ".id", Message: "is required"})
+        case seen[id]:
+            fields = append(fields, fieldError{Field: field + ".id", Message: "is repeated in the batch"})
+        }
+        seen[id] = true
+        if len(fields) >= 20 {
+            break
+        }
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    result, err := importer.Merge(s.store, payload.Conversations)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+
+    "zatGPT/internal/storage"
+    "zatGPT/internal/telemetry"
+)
+
+// bulkRequest is the body of POST /api/conversations/bulk. Add and Remove
+// apply to the tag action; Archived, default true, to archive.
+type bulkRequest struct {
+    Action   string   `json:"action"`
+    IDs      []string `json:"ids"`
+    Add      []string `json:"add,omitempty"`
+    Remove   []string `json:"remove,omitempty"`
+    Archived *bool    `json:"archived,omitempty"`
+}
+
+// handleBulk serves POST /api/conversations/bulk: one action (delete, tag,
+// archive or export) applied to a list of IDs in a single store write, with
+// an outcome per ID.
+//
+//    {"action": "tag", "ids": ["a", "b"], "add": ["work"], "remove": ["todo"]}
+//    {"action": "archive", "ids": ["a"], "archived": false}
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    var payload bulkRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var fields []fieldError
+    action := strings.ToLower(strings.TrimSpace(payload.Action))
+    if !slices.Contains(storage.BulkActions, action) {
+        fields = append(fields, fieldError{Field: "action", Message: "must be " + strings.Join(storage.BulkActions, ", ")})
+    }
+    switch n := len(payload.IDs); {
+    case n == 0:
+        fields = append(fields, fieldError{Field: "ids", Message: "must not be empty"})
+    case n > maxBatchSize:
+        fields = append(fields, fieldError{Field: "ids", Message: "must hold at most " + strconv.Itoa(maxBatchSize) + " items"})
+    }
+    seen := make(map[string]bool, len(payload.IDs))
+    for i, id := range payload.IDs {
+        field := "ids[" + strconv.Itoa(i) + "]"
+        id = strings.TrimSpace(id)
+        switch {
+        case id == "":
+            fields = append(fields, fieldError{Field: field, Message: "is required"})
+        case seen[id]:
+            fields = append(fields, fieldError{Field: field, Message: "is repeated"})
+        }
+        seen[id] = true
+        payload.IDs[i] = id
+        if len(fields) >= 20 {
+            break
+        }
+    }
+    if action == storage.BulkTagging && len(payload.Add) == 0 && len(payload.Remove) == 0 {
+        fields = append(fields, fieldError{Field: "add", Message: "or remove must name at least one tag"})
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    opts := storage.BulkOptions{Add: payload.Add, Remove: payload.Remove, Archived: true}
+    if payload.Archived != nil {
+        opts.Archived = *payload.Archived
+    }
+
+    _, span := telemetry.Start(r.Context(), "store.Bulk")
+    result, err := s.store.Bulk(action, payload.IDs, opts)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
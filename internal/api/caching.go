@@ -0,0 +1,106 @@
+package api
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "zatGPT/internal/models"
+)
+
+// notModified stamps the response with Last-Modified and, when etag is
+// set, ETag. For GET and HEAD requests it then answers 304 and reports true
+// so the caller can stop, when If-None-Match lists etag or, without
+// If-None-Match, when If-Modified-Since is not older than modified. A zero
+// modified time and an empty etag disable caching.
+func notModified(w http.ResponseWriter, r *http.Request, modified time.Time, etag string) bool {
+    if modified.IsZero() && etag == "" {
+        return false
+    }
+    // HTTP dates have one-second resolution.
+    modified = modified.UTC().Truncate(time.Second)
+    if !modified.IsZero() {
+        w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+    }
+    if etag != "" {
+        w.Header().Set("ETag", etag)
+    }
+    w.Header().Set("Cache-Control", "no-cache")
+    // The same URL shows other dates to a client in another zone.
+    w.Header().Add("Vary", TimezoneHeader)
+
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        return false
+    }
+    if match := r.Header.Get("If-None-Match"); match != "" {
+        if etag == "" || !etagMatches(match, etag) {
+            return false
+        }
+    } else {
+        since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+        if err != nil || modified.IsZero() || modified.After(since) {
+            return false
+        }
+    }
+    w.Header().Del("Content-Type")
+    w.WriteHeader(http.StatusNotModified)
+    return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, compared
+// weakly as RFC 9110 asks for GET.
+func etagMatches(header, etag string) bool {
+    etag = strings.TrimPrefix(etag, "W/")
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+            return true
+        }
+    }
+    return false
+}
+
+// storeETag names the store revision that whole-archive responses derive
+// from.
+func storeETag(revision uint64) string {
+    return `"r` + strconv.FormatUint(revision, 36) + `"`
+}
+
+// conversationETag fingerprints one conversation by its ID and UpdatedAt,
+// which every edit bumps, and its topics, which the server fills in the
+// background without touching UpdatedAt.
+func conversationETag(convo models.Conversation) string {
+    parts := append([]string{convo.ID, convo.UpdatedAt.UTC().Format(time.RFC3339Nano)}, convo.Topics...)
+    sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+    return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// lastModified wraps read-only handlers whose responses derive from the
+// whole archive with the store-wide Last-Modified time and revision ETag.
+func (s *Server) lastModified(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodGet || r.Method == http.MethodHead {
+            if notModified(w, r, s.store.LastModified(), storeETag(s.store.Revision())) {
+                return
+            }
+        }
+        next(w, r)
+    }
+}
+
+// conversationNotModified applies per-conversation caching from the
+// conversation's UpdatedAt. Unknown ids fall through so the handler can
+// report them.
+func (s *Server) conversationNotModified(w http.ResponseWriter, r *http.Request, id string) bool {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        return false
+    }
+    convo, err := s.store.Get(id)
+    if err != nil {
+        return false
+    }
+    return notModified(w, r, convo.UpdatedAt, conversationETag(convo))
+}
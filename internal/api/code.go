@@ -0,0 +1,72 @@
+package api
+
+import (
+    "net/http"
+
+    "zatGPT/internal/snippets"
+    "zatGPT/internal/storage"
+)
+
+func (s *Server) conversationCode(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    items := snippets.Extract(convo, r.URL.Query().Get("lang"))
+    if items == nil {
+        items = []snippets.Snippet{}
+    }
+    writeJSON(w, http.StatusOK, map[string]any{
+        "snippets": items,
+    })
+}
+
+// handleCode lists code blocks across the whole archive, optionally
+// restricted by ?lang= and capped by ?limit=.
+func (s *Server) handleCode(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    limit := 0
+    if r.URL.Query().Get("limit") != "" {
+        parsed, err := parseLimit(r.URL.Query().Get("limit"))
+        if err != nil {
+            writeError(w, http.StatusBadRequest, err)
+            return
+        }
+        limit = parsed
+    }
+
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    lang := r.URL.Query().Get("lang")
+    items := []snippets.Snippet{}
+    for _, convo := range conversations {
+        items = append(items, snippets.Extract(convo, lang)...)
+        if limit > 0 && len(items) >= limit {
+            items = items[:limit]
+            break
+        }
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "snippets": items,
+    })
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/compare"
+    "zatGPT/internal/storage"
+)
+
+// handleCompare aligns two conversations given as ?a= and ?b=.
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    idA := strings.TrimSpace(r.URL.Query().Get("a"))
+    idB := strings.TrimSpace(r.URL.Query().Get("b"))
+    if idA == "" || idB == "" {
+        writeErrorString(w, http.StatusBadRequest, "both a and b conversation ids are required")
+        return
+    }
+
+    a, err := s.store.Get(idA)
+    if err != nil {
+        writeLookupError(w, r, err)
+        return
+    }
+    b, err := s.store.Get(idB)
+    if err != nil {
+        writeLookupError(w, r, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "a":      conversationRef(a.ID, a.Title),
+        "b":      conversationRef(b.ID, b.Title),
+        "result": compare.Align(a.Messages, b.Messages),
+    })
+}
+
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error) {
+    if err == storage.ErrNotFound {
+        writeError(w, http.StatusNotFound, err)
+        return
+    }
+    writeError(w, http.StatusInternalServerError, err)
+}
+
+func conversationRef(id, title string) map[string]string {
+    return map[string]string{"id": id, "title": title}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/hex"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    "zatGPT/internal/storage"
+    "zatGPT/internal/telemetry"
+)
+
+// deleteAllWindow is how long a DELETE /api/conversations confirmation
+// token stays valid.
+const deleteAllWindow = 60 * time.Second
+
+// CodeConfirmationInvalid rejects a DELETE /api/conversations whose confirm
+// token is unknown, used or expired.
+const CodeConfirmationInvalid = "confirmation_invalid"
+
+// deleteAllTokens holds the confirmation tokens handed out by the first
+// step of DELETE /api/conversations, each until it expires or is used.
+type deleteAllTokens struct {
+    mu     sync.Mutex
+    tokens map[string]time.Time
+}
+
+func newDeleteAllTokens() *deleteAllTokens {
+    return &deleteAllTokens{tokens: make(map[string]time.Time)}
+}
+
+// issue returns a new token and when it expires, dropping expired ones.
+func (t *deleteAllTokens) issue(now time.Time) (string, time.Time, error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", time.Time{}, err
+    }
+    token := hex.EncodeToString(buf)
+    expires := now.Add(deleteAllWindow)
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    for old, at := range t.tokens {
+        if !now.Before(at) {
+            delete(t.tokens, old)
+        }
+    }
+    t.tokens[token] = expires
+    return token, expires, nil
+}
+
+// redeem reports whether token was issued and has not expired, and uses it
+// up either way.
+func (t *deleteAllTokens) redeem(token string, now time.Time) bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    for issued, expires := range t.tokens {
+        if subtle.ConstantTimeCompare([]byte(issued), []byte(token)) == 1 {
+            delete(t.tokens, issued)
+            return now.Before(expires)
+        }
+    }
+    return false
+}
+
+// SetBackupDir sets where DELETE /api/conversations writes the snapshot it
+// takes before emptying the archive. Empty uses a backups directory next
+// to the store file.
+func (s *Server) SetBackupDir(dir string) {
+    s.backupDir = dir
+}
+
+// deleteAllConfirmation is the body of the first DELETE
+// /api/conversations: nothing is deleted until the token comes back.
+type deleteAllConfirmation struct {
+    ConfirmToken  string    `json:"confirmToken"`
+    ExpiresAt     time.Time `json:"expiresAt"`
+    Conversations int       `json:"conversations"`
+    Message       string    `json:"message"`
+}
+
+// deleteAllResult is the body of a confirmed DELETE /api/conversations.
+type deleteAllResult struct {
+    Deleted int    `json:"deleted"`
+    Backup  string `json:"backup"`
+}
+
+// deleteAll moves every conversation to the trash in two steps. Without
+// ?confirm= it only answers 202 with a token; sending that token back as
+// ?confirm= within deleteAllWindow writes a snapshot of the store to the
+// backup directory and then empties the archive. A failed backup deletes
+// nothing.
+func (s *Server) deleteAll(w http.ResponseWriter, r *http.Request) {
+    now := time.Now().UTC()
+    token := r.URL.Query().Get("confirm")
+    if token == "" {
+        token, expires, err := s.deleteTokens.issue(now)
+        if err != nil {
+            writeError(w, http.StatusInternalServerError, err)
+            return
+        }
+        count := s.store.Count()
+        writeJSON(w, http.StatusAccepted, deleteAllConfirmation{
+            ConfirmToken:  token,
+            ExpiresAt:     expires,
+            Conversations: count,
+            Message:       fmt.Sprintf("nothing was deleted; send DELETE /api/conversations?confirm=%s within %d seconds to move all %d conversations to the trash", token, int(deleteAllWindow.Seconds()), count),
+        })
+        return
+    }
+    if !s.deleteTokens.redeem(token, now) {
+        writeErrorBody(w, http.StatusConflict, errorBody{
+            Code:    CodeConfirmationInvalid,
+            Message: "the confirmation token is unknown, used or expired; send DELETE /api/conversations without ?confirm= for a new one",
+        })
+        return
+    }
+
+    extendDeadlines(w, longRequestTimeout)
+    dir := s.backupDir
+    if dir == "" {
+        dir = filepath.Join(s.store.Dir(), "backups")
+    }
+    // The token is spent and never issued twice, so it keeps two deletions
+    // within the same second from writing their backups to one name.
+    path := filepath.Join(dir, "zatgpt-before-delete-all-"+now.Format("20060102-150405")+"-"+token+".zip")
+    _, span := telemetry.Start(r.Context(), "store.SaveSnapshot")
+    err := os.MkdirAll(dir, 0o755)
+    var manifest storage.SnapshotManifest
+    if err == nil {
+        manifest, err = s.store.SaveSnapshot(path)
+    }
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to write backup, nothing was deleted: %w", err))
+        return
+    }
+
+    _, span = telemetry.Start(r.Context(), "store.DeleteAll")
+    err = s.store.DeleteAll()
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, deleteAllResult{Deleted: manifest.Conversations, Backup: path})
+}
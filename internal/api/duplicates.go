@@ -0,0 +1,100 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "zatGPT/internal/duplicates"
+    "zatGPT/internal/telemetry"
+)
+
+// handleDuplicates serves GET /api/duplicates: groups of conversations that
+// share most of their messages, largest conversation first. ?minOverlap=
+// (0-1, default 0.5) is the share of the smaller conversation's messages the
+// other must hold too.
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    minOverlap := duplicates.DefaultMinOverlap
+    if raw := strings.TrimSpace(r.URL.Query().Get("minOverlap")); raw != "" {
+        value, err := strconv.ParseFloat(raw, 64)
+        if err != nil || value <= 0 || value > 1 {
+            writeValidationError(w, fieldError{Field: "minOverlap", Message: "must be a number above 0 and at most 1"})
+            return
+        }
+        minOverlap = value
+    }
+
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    _, span := telemetry.Start(r.Context(), "duplicates.Detect")
+    groups := duplicates.Detect(conversations, minOverlap)
+    telemetry.End(span, nil)
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "groups": groups,
+        "total":  len(groups),
+    })
+}
+
+// mergeRequest is the body of POST /api/conversations/merge.
+type mergeRequest struct {
+    IDs []string `json:"ids"`
+}
+
+// handleMerge serves POST /api/conversations/merge: {"ids": [...]} folds
+// every listed conversation into the first, which keeps the union of their
+// messages, tags and attachments, and moves the rest to the trash.
+func (s *Server) handleMerge(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    var payload mergeRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var fields []fieldError
+    switch n := len(payload.IDs); {
+    case n < 2:
+        fields = append(fields, fieldError{Field: "ids", Message: "must name at least two conversations"})
+    case n > maxBatchSize:
+        fields = append(fields, fieldError{Field: "ids", Message: "must hold at most " + strconv.Itoa(maxBatchSize) + " items"})
+    }
+    seen := make(map[string]bool, len(payload.IDs))
+    for i, id := range payload.IDs {
+        field := "ids[" + strconv.Itoa(i) + "]"
+        id = strings.TrimSpace(id)
+        switch {
+        case id == "":
+            fields = append(fields, fieldError{Field: field, Message: "is required"})
+        case seen[id]:
+            fields = append(fields, fieldError{Field: field, Message: "is repeated"})
+        }
+        seen[id] = true
+        payload.IDs[i] = id
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    _, span := telemetry.Start(r.Context(), "store.Merge")
+    result, err := s.store.Merge(payload.IDs)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/ids"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/thumbnail"
+)
+
+// Error codes returned in the "code" field of every error response. Clients
+// should branch on these rather than on the human-readable message.
+const (
+    CodeBadRequest       = "bad_request"
+    CodeInvalidJSON      = "invalid_json"
+    CodeValidation       = "validation_failed"
+    CodeInvalidCursor    = "invalid_cursor"
+    CodeInvalidRef       = "invalid_ref"
+    CodeNotFound         = "not_found"
+    CodeMethodNotAllowed = "method_not_allowed"
+    CodeUnauthorized     = "unauthorized"
+    CodeUnsupportedMedia = "unsupported_media_type"
+    CodeQuotaExceeded    = "quota_exceeded"
+    CodeNoSpace          = "insufficient_storage"
+    CodeStale            = "store_stale"
+    CodeInternal         = "internal_error"
+
+    CodeIdempotencyMismatch = "idempotency_key_reused"
+    CodeIdempotencyInFlight = "idempotency_key_in_flight"
+
+    CodeSummarizerFailed = "summarizer_failed"
+    CodeTitlerFailed     = "titler_failed"
+    CodeEmbeddingsFailed = "embeddings_failed"
+    CodeNotEnabled       = "not_enabled"
+
+    CodeCanceled = "request_canceled"
+)
+
+// RequestIDHeader carries the per-request ID echoed in error bodies.
+const RequestIDHeader = "X-Request-ID"
+
+// errorEnvelope is the body of every non-2xx JSON response:
+// {"error": {"code": "...", "message": "...", "fields": [...], "requestId": "..."}}.
+type errorEnvelope struct {
+    Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+    Code      string       `json:"code"`
+    Message   string       `json:"message"`
+    Fields    []fieldError `json:"fields,omitempty"`
+    RequestID string       `json:"requestId,omitempty"`
+}
+
+type fieldError struct {
+    Field   string `json:"field"`
+    Message string `json:"message"`
+}
+
+// RequestID assigns every request an ID (reusing a client-supplied
+// X-Request-ID when present) and echoes it in the response headers, where
+// the error writers pick it up.
+func RequestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := strings.TrimSpace(r.Header.Get(RequestIDHeader))
+        if id == "" || len(id) > 128 {
+            id = newRequestID()
+        }
+        w.Header().Set(RequestIDHeader, id)
+        next.ServeHTTP(w, r)
+    })
+}
+
+func newRequestID() string {
+    buf := make([]byte, 8)
+    if _, err := rand.Read(buf); err != nil {
+        return ids.New()
+    }
+    return hex.EncodeToString(buf)
+}
+
+// writeError reports err with status, or as 413 when err is a request
+// body running past its limit, whatever status the handler picked.
+func writeError(w http.ResponseWriter, status int, err error) {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        writeErrorString(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d MB", tooLarge.Limit>>20))
+        return
+    }
+    writeErrorBody(w, status, errorBody{Code: codeFor(status, err), Message: err.Error()})
+}
+
+func writeErrorString(w http.ResponseWriter, status int, msg string) {
+    writeErrorBody(w, status, errorBody{Code: codeFor(status, nil), Message: msg})
+}
+
+func writeValidationError(w http.ResponseWriter, fields ...fieldError) {
+    parts := make([]string, len(fields))
+    for i, f := range fields {
+        parts[i] = f.Field + " " + f.Message
+    }
+    writeErrorBody(w, http.StatusBadRequest, errorBody{
+        Code:    CodeValidation,
+        Message: strings.Join(parts, "; "),
+        Fields:  fields,
+    })
+}
+
+func writeNotFound(w http.ResponseWriter) {
+    writeErrorString(w, http.StatusNotFound, "resource not found")
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+    w.Header().Set("Allow", strings.Join(allowed, ", "))
+    writeErrorString(w, http.StatusMethodNotAllowed, "method not allowed; use "+strings.Join(allowed, ", "))
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body errorBody) {
+    body.RequestID = w.Header().Get(RequestIDHeader)
+    writeJSON(w, status, errorEnvelope{Error: body})
+}
+
+// statusFor maps well-known errors to HTTP statuses, defaulting to 500.
+func statusFor(err error) int {
+    switch {
+    case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrImportNotFound), errors.Is(err, storage.ErrShareNotFound), errors.Is(err, storage.ErrMessageNotFound), errors.Is(err, storage.ErrSavedSearchNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, storage.ErrMessageExists), errors.Is(err, storage.ErrStale):
+        return http.StatusConflict
+    case errors.Is(err, storage.ErrInvalidCursor), errors.Is(err, storage.ErrInvalidRef):
+        return http.StatusBadRequest
+    case errors.Is(err, thumbnail.ErrUnsupported):
+        return http.StatusUnsupportedMediaType
+    case errors.Is(err, thumbnail.ErrTooLarge):
+        return http.StatusUnprocessableEntity
+    case errors.Is(err, storage.ErrQuotaExceeded):
+        return http.StatusRequestEntityTooLarge
+    case errors.Is(err, storage.ErrNoSpace):
+        return http.StatusInsufficientStorage
+    case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+        return http.StatusServiceUnavailable
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+// codeFor picks the most specific code for err, falling back to one derived
+// from the status.
+func codeFor(status int, err error) string {
+    var syntaxErr *json.SyntaxError
+    var typeErr *json.UnmarshalTypeError
+    switch {
+    case err == nil:
+    case errors.Is(err, storage.ErrInvalidCursor):
+        return CodeInvalidCursor
+    case errors.Is(err, storage.ErrInvalidRef):
+        return CodeInvalidRef
+    case errors.Is(err, storage.ErrQuotaExceeded):
+        return CodeQuotaExceeded
+    case errors.Is(err, storage.ErrNoSpace):
+        return CodeNoSpace
+    case errors.Is(err, storage.ErrStale):
+        return CodeStale
+    case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+        return CodeCanceled
+    case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+        return CodeInvalidJSON
+    case status == http.StatusBadRequest && strings.HasPrefix(err.Error(), "json: unknown field"):
+        return CodeInvalidJSON
+    }
+
+    switch status {
+    case http.StatusBadRequest:
+        return CodeBadRequest
+    case http.StatusUnauthorized:
+        return CodeUnauthorized
+    case http.StatusNotFound:
+        return CodeNotFound
+    case http.StatusMethodNotAllowed:
+        return CodeMethodNotAllowed
+    case http.StatusUnsupportedMediaType:
+        return CodeUnsupportedMedia
+    default:
+        if status >= 500 {
+            return CodeInternal
+        }
+        return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+    }
+}
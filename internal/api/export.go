@@ -0,0 +1,221 @@
+package api
+
+import (
+    "archive/zip"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "zatGPT/internal/export"
+    "zatGPT/internal/models"
+    "zatGPT/internal/query"
+    "zatGPT/internal/storage"
+)
+
+// handleExport streams every conversation matching ?q= (search syntax) in the
+// requested ?format=. POST exports a chosen list as a ZIP bundle instead.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+    if r.Method == http.MethodPost {
+        s.exportBundle(w, r)
+        return
+    }
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet, http.MethodPost)
+        return
+    }
+
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    format, err := export.ParseFormat(r.URL.Query().Get("format"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    q, err := query.Parse(r.URL.Query().Get("q"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    items, err := s.find(r, q.Match)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    w.Header().Set("Content-Type", format.ContentType())
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "conversations"+format.Extension()))
+    w.WriteHeader(http.StatusOK)
+    _ = export.Write(w, format, inZone(items, loc))
+}
+
+func (s *Server) exportConversation(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    format, err := export.ParseFormat(r.URL.Query().Get("format"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", format.ContentType())
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(convo, format)))
+    w.WriteHeader(http.StatusOK)
+    _ = export.WriteConversation(w, format, convo.InZone(loc))
+}
+
+// bundleManifest is the manifest.json at the root of a ZIP bundle.
+type bundleManifest struct {
+    ExportedAt    time.Time     `json:"exportedAt"`
+    Format        export.Format `json:"format"`
+    Count         int           `json:"count"`
+    Conversations []bundleEntry `json:"conversations"`
+}
+
+// bundleEntry describes one conversation of a bundle and the file holding it.
+type bundleEntry struct {
+    ID          string    `json:"id"`
+    Title       string    `json:"title"`
+    File        string    `json:"file"`
+    DateStarted string    `json:"dateStarted,omitempty"`
+    DateEnded   string    `json:"dateEnded,omitempty"`
+    Tags        []string  `json:"tags,omitempty"`
+    Messages    int       `json:"messages"`
+    UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// bundleRequest is the body of POST /api/export.
+type bundleRequest struct {
+    IDs    []string `json:"ids"`
+    Format string   `json:"format,omitempty"`
+}
+
+// exportBundle serves POST /api/export: the listed conversations as a ZIP
+// with one file per conversation, in the order given, and a manifest.json
+// describing them. Every ID must name a conversation outside the trash, as
+// nothing can be reported once the archive has started streaming.
+//
+//    {"ids": ["a", "b"], "format": "markdown"}
+func (s *Server) exportBundle(w http.ResponseWriter, r *http.Request) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    var payload bundleRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var fields []fieldError
+    format, err := export.ParseFormat(payload.Format)
+    if err != nil {
+        fields = append(fields, fieldError{Field: "format", Message: "must be json, markdown, html or pdf"})
+    }
+    switch n := len(payload.IDs); {
+    case n == 0:
+        fields = append(fields, fieldError{Field: "ids", Message: "must not be empty"})
+    case n > maxBatchSize:
+        fields = append(fields, fieldError{Field: "ids", Message: "must hold at most " + strconv.Itoa(maxBatchSize) + " items"})
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    conversations := make([]models.Conversation, 0, len(payload.IDs))
+    seen := make(map[string]bool, len(payload.IDs))
+    for i, id := range payload.IDs {
+        field := "ids[" + strconv.Itoa(i) + "]"
+        id = strings.TrimSpace(id)
+        switch {
+        case id == "":
+            fields = append(fields, fieldError{Field: field, Message: "is required"})
+            continue
+        case seen[id]:
+            fields = append(fields, fieldError{Field: field, Message: "is repeated"})
+            continue
+        }
+        seen[id] = true
+        convo, err := s.store.Get(id)
+        switch {
+        case err == storage.ErrNotFound:
+            fields = append(fields, fieldError{Field: field, Message: "matches no conversation"})
+        case err != nil:
+            writeError(w, statusFor(err), err)
+            return
+        default:
+            conversations = append(conversations, convo.InZone(loc))
+        }
+        if len(fields) >= 20 {
+            break
+        }
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    manifest := bundleManifest{ExportedAt: time.Now().UTC(), Format: format, Count: len(conversations)}
+    used := map[string]int{"manifest.json": 1}
+    for _, convo := range conversations {
+        manifest.Conversations = append(manifest.Conversations, bundleEntry{
+            ID:          convo.ID,
+            Title:       convo.Title,
+            File:        uniqueName(used, export.Filename(convo, format)),
+            DateStarted: convo.DateStarted,
+            DateEnded:   convo.DateEnded,
+            Tags:        convo.Tags,
+            Messages:    len(convo.Messages),
+            UpdatedAt:   convo.UpdatedAt,
+        })
+    }
+
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "conversations.zip"))
+    w.WriteHeader(http.StatusOK)
+
+    archive := zip.NewWriter(w)
+    entry, err := archive.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.ExportedAt})
+    if err != nil {
+        return
+    }
+    encoder := json.NewEncoder(entry)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(&manifest); err != nil {
+        return
+    }
+    for i, convo := range conversations {
+        entry, err := archive.CreateHeader(&zip.FileHeader{Name: manifest.Conversations[i].File, Method: zip.Deflate, Modified: convo.UpdatedAt})
+        if err != nil {
+            return
+        }
+        if err := export.WriteConversation(entry, format, convo); err != nil {
+            return
+        }
+    }
+    _ = archive.Close()
+}
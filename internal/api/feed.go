@@ -0,0 +1,149 @@
+package api
+
+import (
+    "encoding/xml"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/query"
+)
+
+// feedPath is where the Atom feed is served.
+const feedPath = "/api/feed.atom"
+
+// atomFeed and the types below are the parts of RFC 4287 the feed uses.
+type atomFeed struct {
+    XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+    ID      string      `xml:"id"`
+    Title   string      `xml:"title"`
+    Updated string      `xml:"updated"`
+    Author  atomPerson  `xml:"author"`
+    Links   []atomLink  `xml:"link"`
+    Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+    Name string `xml:"name"`
+}
+
+type atomLink struct {
+    Rel  string `xml:"rel,attr,omitempty"`
+    Type string `xml:"type,attr,omitempty"`
+    Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+    ID         string         `xml:"id"`
+    Title      string         `xml:"title"`
+    Updated    string         `xml:"updated"`
+    Published  string         `xml:"published,omitempty"`
+    Link       atomLink       `xml:"link"`
+    Summary    string         `xml:"summary,omitempty"`
+    Categories []atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+    Term string `xml:"term,attr"`
+}
+
+// handleFeed serves GET /api/feed.atom: the most recently updated
+// conversations outside the archive as an Atom feed, with their summaries,
+// tags and links to their pages, so a feed reader picks up what imports
+// add. ?q= narrows it with the search syntax and ?limit= sets its length.
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    values := r.URL.Query()
+    limit, err := parseLimit(values.Get("limit"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "limit", Message: "must be a positive integer"})
+        return
+    }
+    q, err := query.Parse(values.Get("q"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    items, err := s.find(r, func(convo models.Conversation) bool {
+        return !convo.Archived && q.Match(convo)
+    })
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    if len(items) > limit {
+        items = items[:limit]
+    }
+
+    origin := requestOrigin(r)
+    self := origin + feedPath
+    if len(values) > 0 {
+        self += "?" + values.Encode()
+    }
+    feed := atomFeed{
+        ID:     self,
+        Title:  "zatGPT: recent conversations",
+        Author: atomPerson{Name: "zatGPT"},
+        Links: []atomLink{
+            {Rel: "self", Type: "application/atom+xml", Href: self},
+            {Rel: "alternate", Type: "text/html", Href: origin + "/"},
+        },
+        Updated: atomTime(s.store.LastModified()),
+    }
+    for _, convo := range items {
+        entry := atomEntry{
+            ID:      "urn:zatgpt:conversation:" + url.PathEscape(convo.ID),
+            Title:   convo.Title,
+            Updated: atomTime(convo.UpdatedAt),
+            Link:    atomLink{Rel: "alternate", Type: "text/html", Href: origin + "/conversation.html?id=" + url.QueryEscape(convo.ID)},
+            Summary: convo.Summary,
+        }
+        if !convo.CreatedAt.IsZero() {
+            entry.Published = atomTime(convo.CreatedAt)
+        }
+        for _, tag := range convo.Tags {
+            entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+        }
+        feed.Entries = append(feed.Entries, entry)
+    }
+
+    w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write([]byte(xml.Header))
+    encoder := xml.NewEncoder(w)
+    encoder.Indent("", "  ")
+    _ = encoder.Encode(&feed)
+}
+
+// atomTime formats t as Atom dates are written; a zero t, which a feed
+// must not carry, stands in as the Unix epoch.
+func atomTime(t time.Time) string {
+    if t.IsZero() {
+        t = time.Unix(0, 0)
+    }
+    return t.UTC().Format(time.RFC3339)
+}
+
+// requestOrigin is the scheme and host the client reached the server on,
+// honouring the X-Forwarded-Proto and X-Forwarded-Host a reverse proxy
+// sets, for links that must be absolute.
+func requestOrigin(r *http.Request) string {
+    scheme := "http"
+    if r.TLS != nil {
+        scheme = "https"
+    }
+    if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+        scheme = proto
+    }
+    host := r.Host
+    if forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-Host")); forwarded != "" {
+        host, _, _ = strings.Cut(forwarded, ",")
+    }
+    return scheme + "://" + strings.TrimSpace(host)
+}
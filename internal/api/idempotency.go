@@ -0,0 +1,180 @@
+package api
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    // IdempotencyKeyHeader lets clients retry a POST without repeating its
+    // side effects.
+    IdempotencyKeyHeader = "Idempotency-Key"
+
+    idempotencyWindow     = 24 * time.Hour
+    maxIdempotencyKeyLen  = 255
+    maxIdempotencyEntries = 10000
+)
+
+// idempotencyCache remembers the responses to keyed POST requests for
+// idempotencyWindow so a retry gets the original answer instead of running
+// the handler again.
+type idempotencyCache struct {
+    mu      sync.Mutex
+    entries map[string]*idempotentResponse
+}
+
+type idempotentResponse struct {
+    fingerprint [sha256.Size]byte
+    done        bool
+    status      int
+    header      http.Header
+    body        []byte
+    expires     time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+    return &idempotencyCache{entries: make(map[string]*idempotentResponse)}
+}
+
+// idempotent wraps a handler so POSTs carrying an Idempotency-Key are
+// executed at most once per key. A retry with the same key and body replays
+// the stored response; the same key with a different body is rejected, as is
+// a retry that arrives while the first request is still running. Server
+// errors are not cached so the client can retry them. The body is read into
+// memory to fingerprint it, so it may be at most limit bytes.
+func (s *Server) idempotent(limit int64, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+        if r.Method != http.MethodPost || key == "" {
+            next(w, r)
+            return
+        }
+        if len(key) > maxIdempotencyKeyLen {
+            writeErrorString(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+            return
+        }
+
+        body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+        r.Body.Close()
+        if err != nil {
+            writeError(w, http.StatusBadRequest, err)
+            return
+        }
+        r.Body = io.NopCloser(bytes.NewReader(body))
+
+        cacheKey := r.URL.Path + "\x00" + key
+        fingerprint := sha256.Sum256(append([]byte(r.URL.RawQuery+"\x00"), body...))
+
+        entry, existing := s.idem.claim(cacheKey, fingerprint)
+        if existing != nil {
+            switch {
+            case existing.fingerprint != fingerprint:
+                writeErrorBody(w, http.StatusUnprocessableEntity, errorBody{
+                    Code:    CodeIdempotencyMismatch,
+                    Message: "Idempotency-Key was already used with a different request",
+                })
+            case !existing.done:
+                writeErrorBody(w, http.StatusConflict, errorBody{
+                    Code:    CodeIdempotencyInFlight,
+                    Message: "a request with this Idempotency-Key is still being processed",
+                })
+            default:
+                existing.replay(w)
+            }
+            return
+        }
+
+        rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+        next(rec, r)
+        s.idem.finish(cacheKey, entry, rec)
+    }
+}
+
+// claim reserves key for a new request. When the key is already known it
+// returns a snapshot of the existing entry instead.
+func (c *idempotencyCache) claim(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, *idempotentResponse) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    now := time.Now()
+    if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+        snapshot := *entry
+        return nil, &snapshot
+    }
+
+    c.pruneLocked(now)
+    entry := &idempotentResponse{fingerprint: fingerprint, expires: now.Add(idempotencyWindow)}
+    c.entries[key] = entry
+    return entry, nil
+}
+
+func (c *idempotencyCache) finish(key string, entry *idempotentResponse, rec *responseRecorder) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if rec.status >= 500 {
+        delete(c.entries, key)
+        return
+    }
+    entry.done = true
+    entry.status = rec.status
+    entry.header = rec.Header().Clone()
+    entry.body = rec.body.Bytes()
+}
+
+func (c *idempotencyCache) pruneLocked(now time.Time) {
+    for key, entry := range c.entries {
+        if !now.Before(entry.expires) {
+            delete(c.entries, key)
+        }
+    }
+    // Still full of live keys: drop the ones closest to expiring.
+    for len(c.entries) >= maxIdempotencyEntries {
+        var oldest string
+        for key, entry := range c.entries {
+            if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+                oldest = key
+            }
+        }
+        delete(c.entries, oldest)
+    }
+}
+
+func (e *idempotentResponse) replay(w http.ResponseWriter) {
+    for name, values := range e.header {
+        if name == http.CanonicalHeaderKey(RequestIDHeader) {
+            continue
+        }
+        w.Header()[name] = values
+    }
+    w.Header().Set("Idempotent-Replayed", "true")
+    w.WriteHeader(e.status)
+    _, _ = w.Write(e.body)
+}
+
+// responseRecorder tees a handler's response so it can be cached.
+type responseRecorder struct {
+    http.ResponseWriter
+    status int
+    body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+    r.status = status
+    r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+    r.body.Write(p)
+    return r.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the connection underneath.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+    return r.ResponseWriter
+}
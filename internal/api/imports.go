@@ -0,0 +1,105 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/importer"
+    "zatGPT/internal/models"
+)
+
+// handleImports serves the import history: GET /api/imports lists every
+// import with change counts (?hash= finds earlier imports of one file), and
+// GET /api/imports/{id} returns one with its full change report
+// (?format=text for the printable version). POST /api/imports appends a
+// record written by a remote importer.
+func (s *Server) handleImports(w http.ResponseWriter, r *http.Request) {
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/imports"), "/")
+    if r.Method == http.MethodPost && id == "" {
+        s.recordImport(w, r)
+        return
+    }
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet, http.MethodPost)
+        return
+    }
+
+    if id == "" {
+        hash := r.URL.Query().Get("hash")
+        history := []models.ImportRecord{}
+        for _, record := range s.store.Imports() {
+            if hash != "" && record.Hash != hash {
+                continue
+            }
+            record.Changes = changeCounts(record.Changes)
+            history = append(history, record)
+        }
+        writeJSON(w, http.StatusOK, map[string]any{"imports": history})
+        return
+    }
+
+    var (
+        record models.ImportRecord
+        err    error
+    )
+    if id == "latest" {
+        history := s.store.Imports()
+        if len(history) == 0 {
+            writeErrorString(w, http.StatusNotFound, "the import history is empty")
+            return
+        }
+        record = history[0]
+    } else if record, err = s.store.Import(id); err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    if r.URL.Query().Get("format") == "text" {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        _ = importer.WriteReport(w, record)
+        return
+    }
+    writeJSON(w, http.StatusOK, record)
+}
+
+// changeCounts keeps only the totals of a change report.
+func changeCounts(changes *models.ImportChanges) *models.ImportChanges {
+    if changes == nil {
+        return nil
+    }
+    return &models.ImportChanges{
+        NewCount:     changes.NewCount,
+        GrownCount:   changes.GrownCount,
+        RenamedCount: changes.RenamedCount,
+    }
+}
+
+// recordImport appends an import history entry sent by an importer that
+// pushed its conversations through the batch endpoint.
+func (s *Server) recordImport(w http.ResponseWriter, r *http.Request) {
+    var record models.ImportRecord
+    if err := decodeJSON(r.Body, &record); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var fields []fieldError
+    if strings.TrimSpace(record.ID) == "" {
+        fields = append(fields, fieldError{Field: "id", Message: "is required"})
+    } else if _, err := s.store.Import(record.ID); err == nil {
+        fields = append(fields, fieldError{Field: "id", Message: "is already in the import history"})
+    }
+    if record.StartedAt.IsZero() {
+        fields = append(fields, fieldError{Field: "startedAt", Message: "is required"})
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
+        return
+    }
+
+    if err := s.store.RecordImport(record); err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, record)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+    "net/http"
+)
+
+// handleIntegrity serves GET /api/admin/integrity, which checks the store
+// and its attachment directory for damage (see storage.CheckIntegrity), and
+// POST on the same path, which also repairs what it finds. Both answer with
+// the report; a repair lists where what it took out of the store went.
+func (s *Server) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+    var repair bool
+    switch r.Method {
+    case http.MethodGet, http.MethodHead:
+    case http.MethodPost:
+        repair = true
+    default:
+        methodNotAllowed(w, http.MethodGet, http.MethodPost)
+        return
+    }
+    report, err := s.store.CheckIntegrity(repair)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, report)
+}
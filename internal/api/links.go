@@ -0,0 +1,57 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/links"
+)
+
+// handleLinks lists every URL mentioned in the archive. ?domain=, ?q=
+// (substring of the URL) and ?status= (ok, dead, error, or unchecked) narrow
+// the result.
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    domain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("domain")), "www."))
+    needle := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+    status := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status")))
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    checks := s.store.LinkChecks()
+
+    items := []links.Link{}
+    for _, link := range links.Collect(conversations) {
+        if check, ok := checks[link.URL]; ok {
+            link.Check = &check
+        }
+        switch {
+        case status == "":
+        case status == "unchecked":
+            if link.Check != nil {
+                continue
+            }
+        default:
+            if link.Check == nil || link.Check.Status != status {
+                continue
+            }
+        }
+        if domain != "" && link.Domain != domain && !strings.HasSuffix(link.Domain, "."+domain) {
+            continue
+        }
+        if needle != "" && !strings.Contains(strings.ToLower(link.URL), needle) {
+            continue
+        }
+        items = append(items, link)
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "links": items,
+    })
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+    "cmp"
+    "io"
+    "net/http"
+    "net/url"
+    "slices"
+    "strings"
+    "time"
+
+    "zatGPT/internal/ids"
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/telemetry"
+)
+
+// conversationMessages serves /api/conversations/{id}/messages: GET pages
+// through the transcript and POST adds a message. Under
+// /messages/{messageId} it serves one message.
+func (s *Server) conversationMessages(w http.ResponseWriter, r *http.Request, id, messageID string) {
+    if messageID != "" {
+        s.conversationMessage(w, r, id, messageID)
+        return
+    }
+    switch r.Method {
+    case http.MethodGet:
+        s.listMessages(w, r, id)
+    case http.MethodPost:
+        s.addMessage(w, r, id)
+    default:
+        methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+// listMessages serves GET /api/conversations/{id}/messages, one page of a
+// transcript at a time: the first ?limit= messages, or the ones
+// right ?before= or ?after= a message ID. messageWindow tells where the
+// page sits, so a client keeps loading with before= its first message while
+// offset > 0, and after= its last while offset+count < total.
+//
+// ?roles=user,assistant pages through the messages of those authors only,
+// as the web UI does to hide system and tool messages; messageWindow then
+// counts those alone, and hidden how many others there are.
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request, id string) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    query := r.URL.Query()
+    before := strings.TrimSpace(query.Get("before"))
+    after := strings.TrimSpace(query.Get("after"))
+    var problems []fieldError
+    if before != "" && after != "" {
+        problems = append(problems, fieldError{Field: "before", Message: "cannot be combined with after"})
+    }
+    limit, err := parseLimit(query.Get("limit"))
+    if err != nil {
+        problems = append(problems, fieldError{Field: "limit", Message: "must be a positive integer"})
+    }
+    roles, ok := parseRoles(query.Get("roles"))
+    if !ok {
+        problems = append(problems, fieldError{Field: "roles", Message: "must be a comma-separated list of " + strings.Join(messageAuthors, ", ")})
+    }
+    if len(problems) > 0 {
+        writeValidationError(w, problems...)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    messages := convo.InZone(loc).Messages
+    if roles != nil {
+        messages = slices.DeleteFunc(slices.Clone(messages), func(msg models.Message) bool { return !roles[msg.Author] })
+    }
+
+    start, end := 0, min(limit, len(messages))
+    if anchor := before + after; anchor != "" {
+        index := messageIndex(messages, anchor)
+        if index < 0 {
+            writeErrorString(w, http.StatusNotFound, "message not found")
+            return
+        }
+        if before != "" {
+            start, end = max(index-limit, 0), index
+        } else {
+            start, end = index+1, min(index+1+limit, len(messages))
+        }
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "conversationId": convo.ID,
+        "messages":       messages[start:end],
+        "messageWindow": messageWindowInfo{
+            Offset: start,
+            Count:  end - start,
+            Total:  len(messages),
+            Hidden: len(convo.Messages) - len(messages),
+        },
+    })
+}
+
+// messageRequest is the body of POST /api/conversations/{id}/messages, a
+// message written by hand or taken from another source. Author and Content
+// are required; ID defaults to a new one and CreatedAt to now. After puts
+// the message right after the one with that ID instead of at the end.
+type messageRequest struct {
+    ID        string    `json:"id,omitempty"`
+    Author    string    `json:"author"`
+    Content   string    `json:"content"`
+    Model     string    `json:"model,omitempty"`
+    CreatedAt time.Time `json:"createdAt,omitzero"`
+    After     string    `json:"after,omitempty"`
+}
+
+// messagePatch is the body of PATCH
+// /api/conversations/{id}/messages/{messageId}; fields left out are kept.
+type messagePatch struct {
+    Author  *string `json:"author,omitempty"`
+    Content *string `json:"content,omitempty"`
+    Model   *string `json:"model,omitempty"`
+}
+
+// addMessage serves POST /api/conversations/{id}/messages. The message
+// is answered with its tokens counted, and the version of the conversation
+// it replaces is kept as a revision.
+func (s *Server) addMessage(w http.ResponseWriter, r *http.Request, id string) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    var payload messageRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    var problems []fieldError
+    payload.ID = strings.TrimSpace(payload.ID)
+    author := strings.ToLower(strings.TrimSpace(payload.Author))
+    if !slices.Contains(messageAuthors, author) {
+        problems = append(problems, fieldError{Field: "author", Message: "must be one of " + strings.Join(messageAuthors, ", ")})
+    }
+    if strings.TrimSpace(payload.Content) == "" {
+        problems = append(problems, fieldError{Field: "content", Message: "is required"})
+    }
+    if len(problems) > 0 {
+        writeValidationError(w, problems...)
+        return
+    }
+
+    message := models.Message{
+        ID:        cmp.Or(payload.ID, ids.New()),
+        Author:    author,
+        Content:   payload.Content,
+        Model:     strings.TrimSpace(payload.Model),
+        CreatedAt: payload.CreatedAt.UTC(),
+    }
+    if message.CreatedAt.IsZero() {
+        message.CreatedAt = time.Now().UTC()
+    }
+
+    _, span := telemetry.Start(r.Context(), "store.AddMessage")
+    convo, err := s.store.AddMessage(id, message, strings.TrimSpace(payload.After))
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    w.Header().Set("Location", "/api/conversations/"+url.PathEscape(id)+"/messages/"+url.PathEscape(message.ID))
+    writeJSON(w, http.StatusCreated, findMessage(convo.InZone(loc), message.ID))
+}
+
+// conversationMessage serves GET, PATCH and DELETE
+// /api/conversations/{id}/messages/{messageId}: one message, read, edited
+// or removed. Edits and removals keep the version of the conversation they
+// replace as a revision; removing a message also removes the attachments
+// that point at it.
+func (s *Server) conversationMessage(w http.ResponseWriter, r *http.Request, id, messageID string) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    var convo models.Conversation
+    var err error
+    switch r.Method {
+    case http.MethodGet:
+        convo, err = s.store.Get(id)
+        if err == nil && messageIndex(convo.Messages, messageID) < 0 {
+            err = storage.ErrMessageNotFound
+        }
+    case http.MethodPatch:
+        var payload messagePatch
+        if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
+            writeError(w, http.StatusBadRequest, err)
+            return
+        }
+        if payload.Author != nil {
+            author := strings.ToLower(strings.TrimSpace(*payload.Author))
+            if !slices.Contains(messageAuthors, author) {
+                writeValidationError(w, fieldError{Field: "author", Message: "must be one of " + strings.Join(messageAuthors, ", ")})
+                return
+            }
+            payload.Author = &author
+        }
+        if payload.Content != nil && strings.TrimSpace(*payload.Content) == "" {
+            writeValidationError(w, fieldError{Field: "content", Message: "cannot be empty"})
+            return
+        }
+        _, span := telemetry.Start(r.Context(), "store.EditMessage")
+        convo, err = s.store.EditMessage(id, messageID, func(message *models.Message) {
+            if payload.Author != nil {
+                message.Author = *payload.Author
+            }
+            if payload.Content != nil {
+                message.Content = *payload.Content
+                // The parts were the code and output of the old content.
+                message.Parts = nil
+            }
+            if payload.Model != nil {
+                message.Model = strings.TrimSpace(*payload.Model)
+            }
+        })
+        telemetry.End(span, err)
+    case http.MethodDelete:
+        _, span := telemetry.Start(r.Context(), "store.DeleteMessage")
+        _, err = s.store.DeleteMessage(id, messageID)
+        telemetry.End(span, err)
+        if err == nil {
+            w.WriteHeader(http.StatusNoContent)
+            return
+        }
+    default:
+        methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+        return
+    }
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, findMessage(convo.InZone(loc), messageID))
+}
+
+// findMessage returns the message of convo with the given ID, which the
+// caller knows it holds.
+func findMessage(convo models.Conversation, id string) models.Message {
+    return convo.Messages[messageIndex(convo.Messages, id)]
+}
+
+func messageIndex(messages []models.Message, id string) int {
+    for i, msg := range messages {
+        if msg.ID == id {
+            return i
+        }
+    }
+    return -1
+}
+
+// messageAuthors are the values ?roles= accepts.
+var messageAuthors = []string{models.AuthorUser, models.AuthorAssistant, models.AuthorSystem, models.AuthorTool}
+
+// parseRoles reads a ?roles= list into a set; nil means every role.
+func parseRoles(raw string) (map[string]bool, bool) {
+    if strings.TrimSpace(raw) == "" {
+        return nil, true
+    }
+    roles := make(map[string]bool)
+    for _, role := range strings.Split(raw, ",") {
+        role = strings.ToLower(strings.TrimSpace(role))
+        if !slices.Contains(messageAuthors, role) {
+            return nil, false
+        }
+        roles[role] = true
+    }
+    return roles, true
+}
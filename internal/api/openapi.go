@@ -0,0 +1,551 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+
+    "zatGPT/internal/chatsync"
+    "zatGPT/internal/compare"
+    "zatGPT/internal/duplicates"
+    "zatGPT/internal/importer"
+    "zatGPT/internal/links"
+    "zatGPT/internal/models"
+    "zatGPT/internal/snippets"
+    "zatGPT/internal/stats"
+    "zatGPT/internal/storage"
+)
+
+// operation describes one route for the OpenAPI document. Request and
+// Response are zero values of the Go types the handler decodes and encodes,
+// so their schemas are derived from the same struct tags. Responses listed
+// in oneOf replace Response when the shape depends on the query. Media is
+// set for routes that answer with something other than JSON.
+type operation struct {
+    method     string
+    path       string
+    tag        string
+    summary    string
+    params     []parameter
+    request    any
+    status     int
+    response   any
+    oneOf      []any
+    media      []string
+    idempotent bool
+}
+
+type parameter struct {
+    name        string
+    kind        string
+    description string
+}
+
+func queryParam(name, kind, description string) parameter {
+    return parameter{name: name, kind: kind, description: description}
+}
+
+// Response shapes the handlers build as maps, named here for the document.
+type (
+    conversationList struct {
+        Conversations []models.Conversation `json:"conversations"`
+        Total         int                   `json:"total"`
+        NextCursor    string                `json:"nextCursor,omitempty"`
+        NextOffset    int                   `json:"nextOffset,omitempty"`
+    }
+    searchResults struct {
+        Results    []storage.SearchHit `json:"results"`
+        Total      int                 `json:"total"`
+        NextOffset int                 `json:"nextOffset,omitempty"`
+    }
+    messageSearchResults struct {
+        Results    []storage.MessageHit `json:"results"`
+        Total      int                  `json:"total"`
+        NextOffset int                  `json:"nextOffset,omitempty"`
+    }
+    messagePage struct {
+        ConversationID string            `json:"conversationId"`
+        Messages       []models.Message  `json:"messages"`
+        MessageWindow  messageWindowInfo `json:"messageWindow"`
+    }
+    conversationTagList struct {
+        ID   string   `json:"id"`
+        Tags []string `json:"tags"`
+    }
+    treeResponse struct {
+        ConversationID string     `json:"conversationId"`
+        BranchPoints   int        `json:"branchPoints"`
+        Nodes          []treeNode `json:"nodes"`
+    }
+    branchList struct {
+        ConversationID string       `json:"conversationId"`
+        Branches       []branchInfo `json:"branches"`
+    }
+    snippetList struct {
+        Snippets []snippets.Snippet `json:"snippets"`
+    }
+    importHistory struct {
+        Imports []models.ImportRecord `json:"imports"`
+    }
+    duplicateGroups struct {
+        Groups []duplicates.Group `json:"groups"`
+        Total  int                `json:"total"`
+    }
+    tagList struct {
+        Tags []storage.TagCount `json:"tags"`
+    }
+    linkList struct {
+        Links []links.Link `json:"links"`
+    }
+    comparison struct {
+        A      map[string]string `json:"a"`
+        B      map[string]string `json:"b"`
+        Result compare.Result    `json:"result"`
+    }
+    syncStatus struct {
+        Status  chatsync.Status       `json:"status"`
+        History []models.ImportRecord `json:"history"`
+    }
+    storedAttachment struct {
+        Ref  string `json:"ref"`
+        Size int64  `json:"size"`
+    }
+    purgeResult struct {
+        Purged int `json:"purged"`
+    }
+)
+
+var (
+    listParams = []parameter{
+        queryParam("limit", "integer", "page size (default 50, at most 500); switches to a paged response"),
+        queryParam("offset", "integer", "skip this many conversations"),
+        queryParam("cursor", "string", "continue after the page that returned this nextCursor"),
+        queryParam("sort", "string", strings.Join(storage.SortKeys, ", ")),
+        queryParam("order", "string", "asc or desc"),
+        queryParam("tag", "string", "only conversations with every listed tag (repeat or comma-separate)"),
+        queryParam("archived", "boolean", "only archived (true) or unarchived (false) conversations"),
+        queryParam("starred", "boolean", "only starred or unstarred conversations"),
+        queryParam("from", "string", "started on or after this date (YYYY-MM-DD)"),
+        queryParam("to", "string", "started on or before this date (YYYY-MM-DD)"),
+        queryParam("updatedFrom", "string", "updated at or after this time (RFC 3339) or on or after this date"),
+        queryParam("updatedTo", "string", "updated before this time (RFC 3339) or on or before this date"),
+        queryParam("hasRole", "string", "holding at least one message by this author role"),
+        queryParam("minMessages", "integer", "holding at least this many messages"),
+        queryParam("include", "string", "messages embeds the first messages of each conversation"),
+        queryParam("messageLimit", "integer", "with include=messages, how many (default 3, at most 50)"),
+        queryParam("fields", "string", "only these fields of each conversation, comma-separated (e.g. id,title,updatedAt)"),
+        tzParam,
+    }
+    tzParam     = queryParam("tz", "string", "IANA time zone to show dates and times in; default the Time-Zone header's, then the server's -timezone (UTC)")
+    formatParam = queryParam("format", "string", "json (default), markdown, html or pdf")
+    exportMedia = []string{"application/json", "text/markdown", "text/html", "application/pdf"}
+)
+
+// operations lists every route of the API, in the order the document
+// presents them.
+var operations = []operation{
+    {method: "GET", path: "/api/conversations", tag: "conversations", summary: "List conversations, newest first", params: listParams, response: conversationList{}},
+    {method: "POST", path: "/api/conversations", tag: "conversations", summary: "Create a conversation", params: []parameter{tzParam}, request: createRequest{}, status: http.StatusCreated, response: models.Conversation{}, idempotent: true},
+    {method: "DELETE", path: "/api/conversations", tag: "conversations", summary: "Move every conversation to the trash: without confirm, answers 202 with a token to send back within 60 seconds; with it, backs the store up and deletes", params: []parameter{
+        queryParam("confirm", "string", "the confirmToken of the first call"),
+    }, oneOf: []any{deleteAllConfirmation{}, deleteAllResult{}}},
+    {method: "GET", path: "/api/conversations/{id}", tag: "conversations", summary: "Fetch a conversation with its transcript", params: []parameter{
+        queryParam("include", "string", "meta leaves the messages out and adds messageCount"),
+        queryParam("messageOffset", "integer", "start of a message window"),
+        queryParam("messageLimit", "integer", "size of a message window (at most 1000)"),
+        queryParam("around", "string", "center a message window on this message ID"),
+        queryParam("context", "integer", "with around, messages on each side (default 20)"),
+        tzParam,
+    }, oneOf: []any{models.Conversation{}, conversationMeta{}, windowedConversation{}}},
+    {method: "PATCH", path: "/api/conversations/{id}", tag: "conversations", summary: "Update a conversation's title, summary, dates, tags or flags", params: []parameter{tzParam}, request: patchRequest{}, response: models.Conversation{}},
+    {method: "DELETE", path: "/api/conversations/{id}", tag: "conversations", summary: "Move a conversation to the trash", status: http.StatusNoContent},
+    {method: "GET", path: "/api/conversations/{id}/messages", tag: "conversations", summary: "Page through a transcript", params: []parameter{
+        queryParam("limit", "integer", "page size (default 50)"),
+        queryParam("before", "string", "messages right before this message ID"),
+        queryParam("after", "string", "messages right after this message ID"),
+        queryParam("roles", "string", "comma-separated authors to include (user, assistant, system, tool); default all"),
+        tzParam,
+    }, response: messagePage{}},
+    {method: "POST", path: "/api/conversations/{id}/messages", tag: "conversations", summary: "Add a message, at the end or after another", params: []parameter{tzParam}, request: messageRequest{}, status: http.StatusCreated, response: models.Message{}},
+    {method: "GET", path: "/api/conversations/{id}/messages/{messageId}", tag: "conversations", summary: "One message of a conversation", params: []parameter{tzParam}, response: models.Message{}},
+    {method: "PATCH", path: "/api/conversations/{id}/messages/{messageId}", tag: "conversations", summary: "Edit a message's author, content or model", params: []parameter{tzParam}, request: messagePatch{}, response: models.Message{}},
+    {method: "DELETE", path: "/api/conversations/{id}/messages/{messageId}", tag: "conversations", summary: "Remove a message", status: http.StatusNoContent},
+    {method: "GET", path: "/api/conversations/{id}/tree", tag: "conversations", summary: "The edit and regeneration graph", response: treeResponse{}},
+    {method: "GET", path: "/api/conversations/{id}/branches", tag: "conversations", summary: "List a conversation's branches", response: branchList{}},
+    {method: "GET", path: "/api/conversations/{id}/branches/{nodeId}", tag: "conversations", summary: "The conversation along the branch through a message", response: branchConversation{}},
+    {method: "GET", path: "/api/conversations/{id}/summary", tag: "conversations", summary: "Generate or fetch the cached summary", params: []parameter{
+        queryParam("method", "string", "heuristic, first-message, first-sentences[:N] or longest-message instead of the server's summarizer"),
+        queryParam("refresh", "boolean", "generate a new summary"),
+    }, response: models.Summary{}},
+    {method: "POST", path: "/api/conversations/{id}/summarize", tag: "conversations", summary: "Replace the one-line summary with a generated one", params: []parameter{tzParam}, request: summarizeRequest{}, response: models.Conversation{}},
+    {method: "POST", path: "/api/conversations/{id}/retitle", tag: "conversations", summary: "Replace the title with a generated one", params: []parameter{tzParam}, request: retitleRequest{}, response: models.Conversation{}},
+    {method: "GET", path: "/api/conversations/{id}/revisions", tag: "conversations", summary: "Versions of a conversation replaced by re-imports, ending with the current one", response: revisionList{}},
+    {method: "GET", path: "/api/conversations/{id}/revisions/{number}", tag: "conversations", summary: "One version with the diff to the next (number may be current)", params: []parameter{
+        queryParam("against", "string", "diff against this revision number, or current, instead"),
+        queryParam("format", "string", "text for a printable diff"),
+    }, response: revisionDetail{}, media: []string{"application/json", "text/plain"}},
+    {method: "POST", path: "/api/conversations/{id}/share", tag: "shares", summary: "Create a public read-only link to a conversation", request: shareRequest{}, status: http.StatusCreated, response: shareInfo{}},
+    {method: "POST", path: "/api/conversations/{id}/redact", tag: "conversations", summary: "Replace personal data and secrets in a conversation with placeholders", params: []parameter{tzParam}, request: redactRequest{}, response: redactResponse{}},
+    {method: "GET", path: "/api/conversations/{id}/tags", tag: "tags", summary: "A conversation's tags", response: conversationTagList{}},
+    {method: "POST", path: "/api/conversations/{id}/tags", tag: "tags", summary: "Add tags to a conversation", request: tagsRequest{}, response: conversationTagList{}},
+    {method: "DELETE", path: "/api/conversations/{id}/tags", tag: "tags", summary: "Remove tags from a conversation", request: tagsRequest{}, response: conversationTagList{}},
+    {method: "DELETE", path: "/api/conversations/{id}/tags/{tag}", tag: "tags", summary: "Remove one tag from a conversation", response: conversationTagList{}},
+    {method: "GET", path: "/api/conversations/{id}/code", tag: "conversations", summary: "Code blocks of a conversation", params: []parameter{queryParam("lang", "string", "only blocks in this language")}, response: snippetList{}},
+    {method: "GET", path: "/api/conversations/{id}/export", tag: "export", summary: "Export one conversation", params: []parameter{formatParam, tzParam}, media: exportMedia},
+    {method: "GET", path: "/api/conversations/{id}/attachments.zip", tag: "attachments", summary: "Download a conversation's stored attachments", media: []string{"application/zip"}},
+    {method: "POST", path: "/api/conversations/batch", tag: "import", summary: "Upsert converted conversations in one save, as remote imports do", request: batchRequest{}, response: importer.MergeResult{}, idempotent: true},
+    {method: "POST", path: "/api/conversations/bulk", tag: "conversations", summary: "Delete, tag, archive or export a list of conversations", request: bulkRequest{}, response: storage.BulkResult{}, idempotent: true},
+    {method: "POST", path: "/api/conversations/bulk-tag", tag: "tags", summary: "Add and remove tags on conversations picked by ID or query", request: bulkTagRequest{}, response: storage.BulkTagResult{}, idempotent: true},
+    {method: "POST", path: "/api/conversations/merge", tag: "conversations", summary: "Merge duplicates into the first listed conversation", request: mergeRequest{}, response: storage.MergeResult{}, idempotent: true},
+    {method: "POST", path: "/api/conversations/retitle", tag: "conversations", summary: "Title every untitled conversation", request: retitleRequest{}, response: retitleResult{}},
+    {method: "GET", path: "/api/duplicates", tag: "conversations", summary: "Groups of probable duplicate conversations", params: []parameter{queryParam("minOverlap", "number", "share of the smaller conversation's messages the other must hold (default 0.5)")}, response: duplicateGroups{}},
+    {method: "GET", path: "/api/search", tag: "search", summary: "Full-text search, best matches first", params: []parameter{
+        queryParam("q", "string", "words, \"quoted phrases\" and tag:, lang:, after:, before: filters (required)"),
+        queryParam("limit", "integer", "page size (default 50)"),
+        queryParam("hits", "string", "conversations (the default) or messages, for one result per matching message"),
+        queryParam("fuzzy", "boolean", "match the words in titles and summaries only, allowing a typo or two per word"),
+        queryParam("offset", "integer", "skip this many results"),
+        tzParam,
+    }, oneOf: []any{searchResults{}, messageSearchResults{}}},
+    {method: "GET", path: "/api/search/semantic", tag: "search", summary: "Conversations ranked by embedding similarity to a query (needs -embeddings)", params: []parameter{
+        queryParam("q", "string", "text to find conversations about (required)"),
+        queryParam("limit", "integer", "page size (default 50)"),
+        queryParam("offset", "integer", "skip this many results"),
+        tzParam,
+    }, response: semanticResults{}},
+    {method: "GET", path: "/api/saved-searches", tag: "search", summary: "Saved searches, by name", response: savedSearchList{}},
+    {method: "POST", path: "/api/saved-searches", tag: "search", summary: "Save a search under a name", request: savedSearchRequest{}, status: http.StatusCreated, response: models.SavedSearch{}},
+    {method: "GET", path: "/api/saved-searches/{id}", tag: "search", summary: "One saved search", response: models.SavedSearch{}},
+    {method: "PATCH", path: "/api/saved-searches/{id}", tag: "search", summary: "Rename a saved search or change its query or filters", request: savedSearchRequest{}, response: models.SavedSearch{}},
+    {method: "DELETE", path: "/api/saved-searches/{id}", tag: "search", summary: "Delete a saved search", status: http.StatusNoContent},
+    {method: "GET", path: "/api/saved-searches/{id}/results", tag: "search", summary: "What a saved search finds: best matches first, or the newest when its query has no words", params: []parameter{
+        queryParam("limit", "integer", "page size (default 50)"),
+        queryParam("offset", "integer", "skip this many results"),
+        tzParam,
+    }, response: savedSearchResults{}},
+    {method: "GET", path: "/api/export", tag: "export", summary: "Export every conversation matching a query", params: []parameter{queryParam("q", "string", "search syntax; empty exports everything"), formatParam, tzParam}, media: exportMedia},
+    {method: "POST", path: "/api/export", tag: "export", summary: "Export chosen conversations as a ZIP with a manifest.json", params: []parameter{tzParam}, request: bundleRequest{}, media: []string{"application/zip"}},
+    {method: "GET", path: "/api/feed.atom", tag: "export", summary: "An Atom feed of recently updated conversations", params: []parameter{queryParam("q", "string", "search syntax; empty lists everything outside the archive"), queryParam("limit", "integer", "number of entries (default 50)")}, media: []string{"application/atom+xml"}},
+    {method: "POST", path: "/api/import", tag: "import", summary: "Upload export files (multipart field file) and import them", params: []parameter{queryParam("force", "boolean", "import files already in the history again"), queryParam("allRoles", "boolean", "keep system and tool messages"), queryParam("redact", "string", "replace personal data with placeholders: all, or some of email, phone, api-key, credit-card, comma-separated")}, request: multipartUpload{}, response: uploadReport{}},
+    {method: "GET", path: "/api/imports", tag: "import", summary: "The import history with change counts", params: []parameter{queryParam("hash", "string", "only imports of the file with this SHA-256")}, response: importHistory{}},
+    {method: "POST", path: "/api/imports", tag: "import", summary: "Record an import run elsewhere", request: models.ImportRecord{}, status: http.StatusCreated, response: models.ImportRecord{}},
+    {method: "GET", path: "/api/imports/{id}", tag: "import", summary: "One import with its full change report (id may be latest)", params: []parameter{queryParam("format", "string", "text for the printable report")}, response: models.ImportRecord{}, media: []string{"application/json", "text/plain"}},
+    {method: "GET", path: "/api/attachments/{ref}", tag: "attachments", summary: "An attachment's file, with range support", media: []string{"application/octet-stream"}},
+    {method: "PUT", path: "/api/attachments/{ref}", tag: "attachments", summary: "Upload an attachment's file for a remote import", request: []byte{}, status: http.StatusCreated, response: storedAttachment{}},
+    {method: "GET", path: "/api/attachments/{ref}/thumb", tag: "attachments", summary: "A JPEG thumbnail of an image attachment", params: []parameter{queryParam("w", "integer", "width in pixels (default 256)")}, media: []string{"image/jpeg"}},
+    {method: "GET", path: "/api/tags", tag: "tags", summary: "Every tag with its conversation count", response: tagList{}},
+    {method: "GET", path: "/api/trash", tag: "trash", summary: "Conversations in the trash", params: []parameter{tzParam}, response: conversationList{}},
+    {method: "DELETE", path: "/api/trash", tag: "trash", summary: "Empty the trash", response: purgeResult{}},
+    {method: "POST", path: "/api/trash/{id}/restore", tag: "trash", summary: "Put a conversation back in the list", response: models.Conversation{}},
+    {method: "DELETE", path: "/api/trash/{id}", tag: "trash", summary: "Delete a trashed conversation for good", status: http.StatusNoContent},
+    {method: "GET", path: "/api/shares", tag: "shares", summary: "Public links, newest first", params: []parameter{queryParam("conversation", "string", "only the links to this conversation")}, response: shareList{}},
+    {method: "PATCH", path: "/api/shares/{token}", tag: "shares", summary: "Change when a link expires (no expiresIn: never)", request: shareRequest{}, response: shareInfo{}},
+    {method: "DELETE", path: "/api/shares/{token}", tag: "shares", summary: "Revoke a link", status: http.StatusNoContent},
+    {method: "GET", path: "/api/code", tag: "analysis", summary: "Code blocks across the archive", params: []parameter{queryParam("lang", "string", "only blocks in this language"), queryParam("limit", "integer", "at most this many")}, response: snippetList{}},
+    {method: "GET", path: "/api/links", tag: "analysis", summary: "URLs mentioned in the archive", params: []parameter{
+        queryParam("domain", "string", "only this domain and its subdomains"),
+        queryParam("q", "string", "substring of the URL"),
+        queryParam("status", "string", "ok, dead, error or unchecked"),
+    }, response: linkList{}},
+    {method: "GET", path: "/api/compare", tag: "analysis", summary: "Align the messages of two conversations", params: []parameter{queryParam("a", "string", "first conversation ID"), queryParam("b", "string", "second conversation ID")}, response: comparison{}},
+    {method: "GET", path: "/api/stats", tag: "analysis", summary: "Archive totals", response: stats.Overview{}},
+    {method: "GET", path: "/api/stats/export.csv", tag: "analysis", summary: "Monthly activity, models and tags as CSV", params: []parameter{queryParam("report", "string", "months, models and/or tags, comma-separated")}, media: []string{"text/csv"}},
+    {method: "GET", path: "/api/stats/terms", tag: "analysis", summary: "Most frequent words of a period", params: []parameter{
+        queryParam("from", "string", "first day (YYYY-MM-DD)"),
+        queryParam("to", "string", "last day (YYYY-MM-DD)"),
+        queryParam("role", "string", "user or assistant"),
+        queryParam("top", "integer", "number of terms"),
+    }, response: termsResponse{}},
+    {method: "GET", path: "/api/stats/tokens", tag: "analysis", summary: "Token counts and the API-equivalent cost per model", response: stats.TokenReport{}},
+    {method: "GET", path: "/api/stats/review", tag: "analysis", summary: "Year in review", params: []parameter{queryParam("year", "integer", "default the current year"), queryParam("format", "string", "json (default), markdown or html")}, response: stats.Review{}, media: []string{"application/json", "text/markdown", "text/html"}},
+    {method: "GET", path: "/api/stats/usage", tag: "analysis", summary: "Store size and configured limits", response: storage.Usage{}},
+    {method: "GET", path: "/api/admin/integrity", tag: "admin", summary: "Check the store and its attachments for damage", response: storage.IntegrityReport{}},
+    {method: "POST", path: "/api/admin/integrity", tag: "admin", summary: "Check the store and repair what is found, quarantining what is taken out", response: storage.IntegrityReport{}},
+    {method: "GET", path: "/api/topics", tag: "analysis", summary: "Topics most common across the archive, with conversation counts", params: []parameter{queryParam("top", "integer", "number of topics (default 50)")}, response: topicsResponse{}},
+    {method: "GET", path: "/api/sync/status", tag: "import", summary: "ChatGPT sync schedule and recent runs", response: syncStatus{}},
+}
+
+// multipartUpload documents the form POST /api/import reads.
+type multipartUpload struct {
+    File []byte `json:"file"`
+}
+
+var (
+    openAPIOnce sync.Once
+    openAPIDoc  []byte
+)
+
+// handleOpenAPI serves GET /api/openapi.json, the OpenAPI 3 description of
+// every route, built from operations on first use.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    openAPIOnce.Do(func() {
+        openAPIDoc, _ = json.MarshalIndent(buildOpenAPI(), "", "  ")
+    })
+    w.Header().Set("Content-Type", "application/json")
+    _, _ = w.Write(openAPIDoc)
+}
+
+// docsPage is the Swagger UI served at /api/docs. Its scripts come from
+// unpkg.com, so the page needs internet access even though the API does not.
+const docsPage = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>zatGPT API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.ui = SwaggerUIBundle({url: "/api/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+// SetDocs turns the Swagger UI at /api/docs on or off; the OpenAPI document
+// itself is always served.
+func (s *Server) SetDocs(enabled bool) {
+    s.docs = enabled
+}
+
+// handleDocs serves GET /api/docs when SetDocs enabled it.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+    if !s.docs {
+        writeNotFound(w)
+        return
+    }
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    _, _ = w.Write([]byte(docsPage))
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func buildOpenAPI() map[string]any {
+    gen := &schemaGen{schemas: map[string]any{}, names: map[reflect.Type]string{}, taken: map[string]reflect.Type{}}
+    errorRef := gen.schema(reflect.TypeOf(errorEnvelope{}))
+
+    paths := map[string]any{}
+    for _, op := range operations {
+        item, _ := paths[op.path].(map[string]any)
+        if item == nil {
+            item = map[string]any{}
+            paths[op.path] = item
+        }
+
+        var params []any
+        for _, match := range pathParam.FindAllStringSubmatch(op.path, -1) {
+            params = append(params, map[string]any{"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+        }
+        for _, p := range op.params {
+            params = append(params, map[string]any{"name": p.name, "in": "query", "description": p.description, "schema": map[string]any{"type": p.kind}})
+        }
+        if op.idempotent {
+            params = append(params, map[string]any{"name": "Idempotency-Key", "in": "header", "description": "replay the first response for repeated requests with this key", "schema": map[string]any{"type": "string"}})
+        }
+
+        status := op.status
+        if status == 0 {
+            status = http.StatusOK
+        }
+        response := map[string]any{"description": http.StatusText(status)}
+        if content := gen.content(op); len(content) > 0 {
+            response["content"] = content
+        }
+        entry := map[string]any{
+            "summary":     op.summary,
+            "tags":        []string{op.tag},
+            "operationId": operationID(op),
+            "responses": map[string]any{
+                strconv.Itoa(status): response,
+                "default":         map[string]any{"description": "error", "content": map[string]any{"application/json": map[string]any{"schema": errorRef}}},
+            },
+        }
+        if len(params) > 0 {
+            entry["parameters"] = params
+        }
+        switch body := op.request.(type) {
+        case nil:
+        case []byte:
+            entry["requestBody"] = map[string]any{"required": true, "content": map[string]any{"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}}
+        case multipartUpload:
+            entry["requestBody"] = map[string]any{"required": true, "content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{
+                "type":       "object",
+                "properties": map[string]any{"file": map[string]any{"type": "array", "items": map[string]any{"type": "string", "format": "binary"}}},
+            }}}}
+        default:
+            entry["requestBody"] = map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": gen.schema(reflect.TypeOf(body))}}}
+        }
+        item[strings.ToLower(op.method)] = entry
+    }
+
+    return map[string]any{
+        "openapi": "3.0.3",
+        "info": map[string]any{
+            "title":       "zatGPT API",
+            "version":     "1",
+            "description": "Local archive of ChatGPT, Claude, Bard and local-LLM conversations. Errors share one envelope; clients should branch on error.code.",
+        },
+        "paths": paths,
+        "components": map[string]any{
+            "schemas": gen.schemas,
+            "securitySchemes": map[string]any{
+                "bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "the server's -api-key, or its -token for requests that change data"},
+            },
+        },
+        "security": []any{map[string]any{}, map[string]any{"bearer": []string{}}},
+    }
+}
+
+// content lists the media types a route answers with.
+func (g *schemaGen) content(op operation) map[string]any {
+    content := map[string]any{}
+    var schema map[string]any
+    switch {
+    case op.oneOf != nil:
+        var refs []any
+        for _, shape := range op.oneOf {
+            refs = append(refs, g.schema(reflect.TypeOf(shape)))
+        }
+        schema = map[string]any{"oneOf": refs}
+    case op.response != nil:
+        schema = g.schema(reflect.TypeOf(op.response))
+    }
+    if schema != nil {
+        content["application/json"] = map[string]any{"schema": schema}
+    }
+    for _, media := range op.media {
+        if _, ok := content[media]; !ok {
+            content[media] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+        }
+    }
+    return content
+}
+
+// operationID names an operation for generated clients: GET
+// /api/conversations/{id}/messages becomes getConversationsIdMessages.
+func operationID(op operation) string {
+    var b strings.Builder
+    b.WriteString(strings.ToLower(op.method))
+    for _, part := range strings.FieldsFunc(strings.TrimPrefix(op.path, "/api/"), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    }) {
+        b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+    }
+    return b.String()
+}
+
+// schemaGen derives JSON schemas from Go types, collecting named structs
+// under components/schemas.
+type schemaGen struct {
+    schemas map[string]any
+    names   map[reflect.Type]string
+    taken   map[string]reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+    for t.Kind() == reflect.Pointer {
+        t = t.Elem()
+    }
+    switch {
+    case t == timeType:
+        return map[string]any{"type": "string", "format": "date-time"}
+    case t == reflect.TypeOf(json.RawMessage{}):
+        return map[string]any{}
+    }
+
+    switch t.Kind() {
+    case reflect.String:
+        return map[string]any{"type": "string"}
+    case reflect.Bool:
+        return map[string]any{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return map[string]any{"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+        return map[string]any{"type": "number"}
+    case reflect.Slice, reflect.Array:
+        if t.Elem().Kind() == reflect.Uint8 {
+            return map[string]any{"type": "string", "format": "byte"}
+        }
+        return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+    case reflect.Map:
+        return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+    case reflect.Struct:
+        if t.Name() == "" {
+            return g.object(t)
+        }
+        name, ok := g.names[t]
+        if !ok {
+            name = g.name(t)
+            g.names[t] = name
+            g.schemas[name] = g.object(t)
+        }
+        return map[string]any{"$ref": "#/components/schemas/" + name}
+    }
+    return map[string]any{}
+}
+
+// name is the exported type name, prefixed with its package when another
+// package already uses it.
+func (g *schemaGen) name(t reflect.Type) string {
+    name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+    if other, ok := g.taken[name]; ok && other != t {
+        pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+        name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+    }
+    g.taken[name] = t
+    return name
+}
+
+// object describes a struct the way encoding/json writes it. Embedded
+// structs become an allOf with the struct's own fields.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+    properties := map[string]any{}
+    var required []string
+    var embedded []any
+    for i := range t.NumField() {
+        field := t.Field(i)
+        tag := field.Tag.Get("json")
+        if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+            continue
+        }
+        name, options, _ := strings.Cut(tag, ",")
+        if field.Anonymous && name == "" {
+            embedded = append(embedded, g.schema(field.Type))
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        properties[name] = g.schema(field.Type)
+        if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") && field.Type.Kind() != reflect.Pointer {
+            required = append(required, name)
+        }
+    }
+
+    own := map[string]any{"type": "object", "properties": properties}
+    if len(required) > 0 {
+        own["required"] = required
+    }
+    if len(embedded) == 0 {
+        return own
+    }
+    return map[string]any{"allOf": append(embedded, own)}
+}
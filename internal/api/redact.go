@@ -0,0 +1,66 @@
+package api
+
+import (
+    "io"
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/redact"
+)
+
+// redactRequest is the body of POST /api/conversations/{id}/redact. Kinds
+// picks the built-in kinds to look for, every one when left out; Patterns
+// adds regular expressions, each "name=regexp" or bare.
+type redactRequest struct {
+    Kinds    []string `json:"kinds"`
+    Patterns []string `json:"patterns,omitempty"`
+}
+
+// redactResponse is the redacted conversation with what was replaced this
+// time; the conversation's Redactions add up every pass.
+type redactResponse struct {
+    Conversation models.Conversation `json:"conversation"`
+    Redacted     []models.Redaction  `json:"redacted"`
+}
+
+// conversationRedact serves POST /api/conversations/{id}/redact: it
+// replaces email addresses, phone numbers, API keys, card numbers and any
+// given patterns in the conversation with placeholders, for good, so it
+// can be shared. An empty body looks for every built-in kind.
+func (s *Server) conversationRedact(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    var payload redactRequest
+    if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+    for _, kind := range payload.Kinds {
+        if !redact.ValidKind(kind) {
+            writeValidationError(w, fieldError{Field: "kinds", Message: "must each be one of " + strings.Join(redact.Kinds, ", ")})
+            return
+        }
+    }
+    redactor, err := redact.New(payload.Kinds, payload.Patterns)
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "patterns", Message: err.Error()})
+        return
+    }
+
+    convo, found, err := s.store.Redact(id, redactor)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    if found == nil {
+        found = []models.Redaction{}
+    }
+    writeJSON(w, http.StatusOK, redactResponse{Conversation: convo.InZone(loc), Redacted: found})
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/summary"
+)
+
+// retitleBatch is how many titles the pass over every untitled
+// conversation makes between saves, so a slow titler's work is kept as it
+// goes.
+const retitleBatch = 50
+
+// SetTitler replaces the heuristic titler used by
+// /api/conversations/{id}/retitle and /api/conversations/retitle.
+func (s *Server) SetTitler(titler summary.Titler) {
+    if titler != nil {
+        s.titler = titler
+    }
+}
+
+// retitleRequest is the body of both retitle endpoints. Method picks the
+// titler: heuristic, or the server's LLM by its method name; left out, the
+// server's titler.
+type retitleRequest struct {
+    Method string `json:"method,omitempty"`
+}
+
+// retitled is one conversation the pass over untitled conversations named.
+type retitled struct {
+    ID            string `json:"id"`
+    Title         string `json:"title"`
+    PreviousTitle string `json:"previousTitle"`
+}
+
+// retitleFailure is one conversation the titler failed on.
+type retitleFailure struct {
+    ID    string `json:"id"`
+    Error string `json:"error"`
+}
+
+// retitleResult answers POST /api/conversations/retitle.
+type retitleResult struct {
+    Status   string           `json:"status"`
+    Retitled []retitled       `json:"retitled"`
+    Failed   []retitleFailure `json:"failed,omitempty"`
+}
+
+// retitleJobs runs at most one titling per conversation, or per pass over
+// every untitled one, and method at a time; concurrent requests wait on
+// the same job.
+type retitleJobs struct {
+    mu      sync.Mutex
+    running map[string]*retitleJob
+}
+
+// retitleJob is a titling under way. failed is the titler's own error,
+// err any other.
+type retitleJob struct {
+    done   chan struct{}
+    convo  models.Conversation
+    result retitleResult
+    failed error
+    err    error
+}
+
+func newRetitleJobs() *retitleJobs {
+    return &retitleJobs{running: make(map[string]*retitleJob)}
+}
+
+// start returns the job running under key, or launches run as one. The
+// job outlives the request that started it so a slow titler still gets to
+// save its titles.
+func (j *retitleJobs) start(key string, run func(job *retitleJob)) *retitleJob {
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if job, ok := j.running[key]; ok {
+        return job
+    }
+    job := &retitleJob{done: make(chan struct{})}
+    j.running[key] = job
+
+    go func() {
+        run(job)
+
+        j.mu.Lock()
+        delete(j.running, key)
+        j.mu.Unlock()
+        close(job.done)
+    }()
+    return job
+}
+
+// waitRetitle waits for job as the summary endpoints wait for theirs: up to
+// summaryWait, after which the request gets 202 with Retry-After. It
+// reports whether the job finished and the request can be answered.
+func (s *Server) waitRetitle(w http.ResponseWriter, r *http.Request, job *retitleJob, pending map[string]string) bool {
+    timer := time.NewTimer(summaryWait)
+    defer timer.Stop()
+
+    select {
+    case <-job.done:
+        return true
+    case <-timer.C:
+        w.Header().Set("Retry-After", summaryRetry)
+        writeJSON(w, http.StatusAccepted, pending)
+        return false
+    case <-r.Context().Done():
+        return false
+    }
+}
+
+// conversationRetitle serves POST /api/conversations/{id}/retitle: it
+// replaces the conversation's title, titled or not, with one the titler
+// makes of it, and answers with the conversation. A slow titler is waited
+// on as summaries are, with 202 and Retry-After.
+func (s *Server) conversationRetitle(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    titler, ok := s.pickTitler(w, r)
+    if !ok {
+        return
+    }
+    convo, err := s.store.Get(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    job := s.retitles.start(id+"\x00"+titler.Method(), func(job *retitleJob) {
+        ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+        defer cancel()
+
+        title, err := titler.Title(ctx, convo)
+        if err != nil {
+            job.failed = err
+            return
+        }
+        if title = strings.TrimSpace(title); title == "" || title == convo.Title {
+            job.convo, job.err = s.store.Get(id)
+            return
+        }
+        job.convo, job.err = s.store.UpdateTitle(id, title)
+    })
+    if !s.waitRetitle(w, r, job, map[string]string{"status": "pending", "conversationId": id}) {
+        return
+    }
+    if job.failed != nil {
+        writeErrorBody(w, http.StatusBadGateway, errorBody{Code: CodeTitlerFailed, Message: job.failed.Error()})
+        return
+    }
+    if job.err != nil {
+        writeError(w, statusFor(job.err), job.err)
+        return
+    }
+    writeJSON(w, http.StatusOK, job.convo.InZone(loc))
+}
+
+// handleRetitle serves POST /api/conversations/retitle: it titles every
+// untitled conversation, archived ones included (see
+// models.Conversation.Untitled), and answers with what it renamed and the
+// conversations the titler failed on. A pass that outlasts summaryWait
+// carries on in the background and the request gets 202 with
+// Retry-After; posting again once it is done runs a new pass over what is
+// still untitled.
+func (s *Server) handleRetitle(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    titler, ok := s.pickTitler(w, r)
+    if !ok {
+        return
+    }
+
+    job := s.retitles.start("\x00"+titler.Method(), func(job *retitleJob) {
+        job.result, job.err = s.retitleUntitled(titler)
+    })
+    if !s.waitRetitle(w, r, job, map[string]string{"status": "pending"}) {
+        return
+    }
+    if job.err != nil {
+        writeError(w, statusFor(job.err), job.err)
+        return
+    }
+    writeJSON(w, http.StatusOK, job.result)
+}
+
+// retitleUntitled titles the untitled conversations, saving them
+// retitleBatch at a time.
+func (s *Server) retitleUntitled(titler summary.Titler) (retitleResult, error) {
+    result := retitleResult{Status: "done", Retitled: []retitled{}}
+    items, err := s.store.Find(context.Background(), models.Conversation.Untitled)
+    if err != nil {
+        return result, err
+    }
+
+    var batch []retitled
+    flush := func() error {
+        titles := make(map[string]string, len(batch))
+        for _, item := range batch {
+            titles[item.ID] = item.Title
+        }
+        changed, err := s.store.TitleUntitled(titles)
+        if err != nil {
+            return err
+        }
+        saved := make(map[string]bool, len(changed))
+        for _, convo := range changed {
+            saved[convo.ID] = true
+        }
+        for _, item := range batch {
+            if saved[item.ID] {
+                result.Retitled = append(result.Retitled, item)
+            }
+        }
+        batch = batch[:0]
+        return nil
+    }
+
+    for _, convo := range items {
+        ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+        title, err := titler.Title(ctx, convo)
+        cancel()
+        if err != nil {
+            result.Failed = append(result.Failed, retitleFailure{ID: convo.ID, Error: err.Error()})
+            continue
+        }
+        if title = strings.TrimSpace(title); title == "" || title == convo.Title {
+            continue
+        }
+        if batch = append(batch, retitled{ID: convo.ID, Title: title, PreviousTitle: convo.Title}); len(batch) == retitleBatch {
+            if err := flush(); err != nil {
+                return result, err
+            }
+        }
+    }
+    if len(batch) > 0 {
+        if err := flush(); err != nil {
+            return result, err
+        }
+    }
+    return result, nil
+}
+
+// pickTitler resolves the method a retitle request asks for: the server's
+// titler when empty or named, otherwise the heuristic. An unknown method
+// is answered with a validation error and ok is false.
+func (s *Server) pickTitler(w http.ResponseWriter, r *http.Request) (summary.Titler, bool) {
+    var payload retitleRequest
+    if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
+        writeError(w, http.StatusBadRequest, err)
+        return nil, false
+    }
+    method := strings.TrimSpace(payload.Method)
+    switch method {
+    case "", s.titler.Method():
+        return s.titler, true
+    case (summary.HeuristicTitle{}).Method():
+        return summary.HeuristicTitle{}, true
+    }
+    allowed := "must be heuristic"
+    if strings.HasPrefix(s.titler.Method(), "llm:") {
+        allowed += " or " + s.titler.Method()
+    }
+    writeValidationError(w, fieldError{Field: "method", Message: allowed})
+    return nil, false
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+)
+
+// revisionInfo describes one version of a conversation without its
+// messages.
+type revisionInfo struct {
+    Number       int       `json:"number"`
+    Title        string    `json:"title"`
+    MessageCount int       `json:"messageCount"`
+    UpdatedAt    time.Time `json:"updatedAt"`
+    ReplacedAt   time.Time `json:"replacedAt,omitzero"`
+    Current      bool      `json:"current,omitempty"`
+}
+
+type revisionList struct {
+    ConversationID string         `json:"conversationId"`
+    Revisions      []revisionInfo `json:"revisions"`
+}
+
+// revisionDetail is one version with its messages and what the version
+// compared against changed.
+type revisionDetail struct {
+    Revision models.Revision      `json:"revision"`
+    Diff     storage.RevisionDiff `json:"diff"`
+}
+
+// conversationRevisions serves GET /api/conversations/{id}/revisions, the
+// versions of a conversation that re-imports replaced, oldest first and
+// ending with the current one, and GET .../revisions/{n}: version n with
+// its messages and the diff from it to the next version (or to the version
+// in ?against=). ?format=text renders the diff as text.
+func (s *Server) conversationRevisions(w http.ResponseWriter, r *http.Request, id, rest string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    revisions, err := s.store.Revisions(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    if rest == "" {
+        list := revisionList{ConversationID: id, Revisions: make([]revisionInfo, len(revisions))}
+        for i, revision := range revisions {
+            list.Revisions[i] = revisionInfo{
+                Number:       revision.Number,
+                Title:        revision.Title,
+                MessageCount: len(revision.Messages),
+                UpdatedAt:    revision.UpdatedAt,
+                ReplacedAt:   revision.ReplacedAt,
+                Current:      i == len(revisions)-1,
+            }
+        }
+        writeJSON(w, http.StatusOK, list)
+        return
+    }
+
+    from, ok := findRevision(revisions, rest)
+    if !ok {
+        writeNotFound(w)
+        return
+    }
+    to := revisions[len(revisions)-1]
+    for i, revision := range revisions[:len(revisions)-1] {
+        if revision.Number == from.Number {
+            to = revisions[i+1]
+        }
+    }
+    if raw := r.URL.Query().Get("against"); raw != "" {
+        if to, ok = findRevision(revisions, raw); !ok {
+            writeValidationError(w, fieldError{Field: "against", Message: "must be the number of a kept revision or current"})
+            return
+        }
+    }
+
+    diff := storage.DiffRevisions(from, to)
+    if r.URL.Query().Get("format") == "text" {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        _ = writeRevisionDiff(w, diff)
+        return
+    }
+    writeJSON(w, http.StatusOK, revisionDetail{Revision: from, Diff: diff})
+}
+
+// findRevision looks up a revision by number, or "current" for the last.
+func findRevision(revisions []models.Revision, raw string) (models.Revision, bool) {
+    if raw == "current" {
+        return revisions[len(revisions)-1], true
+    }
+    number, err := strconv.Atoi(raw)
+    if err != nil {
+        return models.Revision{}, false
+    }
+    for _, revision := range revisions {
+        if revision.Number == number {
+            return revision, true
+        }
+    }
+    return models.Revision{}, false
+}
+
+// writeRevisionDiff prints diff in the style of a unified diff: removed
+// lines start with "-" and added ones with "+".
+func writeRevisionDiff(w io.Writer, diff storage.RevisionDiff) error {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Revision %d -> %d\n", diff.From, diff.To)
+    if diff.TitleChanged {
+        fmt.Fprintf(&b, "\nTitle:\n- %s\n+ %s\n", diff.OldTitle, diff.NewTitle)
+    }
+    for _, change := range diff.Changed {
+        fmt.Fprintf(&b, "\nChanged %s message %s:\n", change.Author, change.ID)
+        writeLines(&b, "- ", change.Before)
+        writeLines(&b, "+ ", change.After)
+    }
+    for _, msg := range diff.Removed {
+        fmt.Fprintf(&b, "\nRemoved %s message %s:\n", msg.Author, msg.ID)
+        writeLines(&b, "- ", msg.Content)
+    }
+    for _, msg := range diff.Added {
+        fmt.Fprintf(&b, "\nAdded %s message %s:\n", msg.Author, msg.ID)
+        writeLines(&b, "+ ", msg.Content)
+    }
+    if !diff.TitleChanged && len(diff.Changed)+len(diff.Removed)+len(diff.Added) == 0 {
+        b.WriteString("\nNo changes.\n")
+    }
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+func writeLines(b *strings.Builder, prefix, text string) {
+    for _, line := range strings.Split(text, "\n") {
+        b.WriteString(prefix)
+        b.WriteString(line)
+        b.WriteByte('\n')
+    }
+}
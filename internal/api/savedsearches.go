@@ -0,0 +1,275 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/query"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/telemetry"
+)
+
+// savedSearchRequest is the body of POST /api/saved-searches and PATCH
+// /api/saved-searches/{id}. A PATCH changes only the fields it sets, and
+// replaces the filters whole.
+type savedSearchRequest struct {
+    Name    *string               `json:"name,omitempty"`
+    Query   *string               `json:"query,omitempty"`
+    Filters *models.SearchFilters `json:"filters,omitempty"`
+}
+
+type savedSearchList struct {
+    SavedSearches []models.SavedSearch `json:"savedSearches"`
+}
+
+// savedSearchResults is a page of what a saved search finds, shaped like
+// the results of GET /api/search.
+type savedSearchResults struct {
+    SavedSearch models.SavedSearch  `json:"savedSearch"`
+    Results     []storage.SearchHit `json:"results"`
+    Total       int                 `json:"total"`
+    NextOffset  int                 `json:"nextOffset,omitempty"`
+}
+
+// handleSavedSearches serves GET /api/saved-searches, every saved search
+// by name, POST /api/saved-searches, which saves one, GET, PATCH and
+// DELETE /api/saved-searches/{id}, and GET
+// /api/saved-searches/{id}/results.
+func (s *Server) handleSavedSearches(w http.ResponseWriter, r *http.Request) {
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/saved-searches"), "/")
+    id, sub, _ := strings.Cut(rest, "/")
+
+    switch {
+    case id == "":
+        switch r.Method {
+        case http.MethodGet, http.MethodHead:
+            writeJSON(w, http.StatusOK, savedSearchList{SavedSearches: s.store.SavedSearches()})
+        case http.MethodPost:
+            s.createSavedSearch(w, r)
+        default:
+            methodNotAllowed(w, http.MethodGet, http.MethodPost)
+        }
+        return
+    case sub == "results":
+        s.savedSearchResults(w, r, id)
+        return
+    case sub != "":
+        writeNotFound(w)
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet, http.MethodHead:
+        search, err := s.store.SavedSearch(id)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        writeJSON(w, http.StatusOK, search)
+    case http.MethodPatch:
+        s.patchSavedSearch(w, r, id)
+    case http.MethodDelete:
+        if err := s.store.DeleteSavedSearch(id); err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+    }
+}
+
+func (s *Server) createSavedSearch(w http.ResponseWriter, r *http.Request) {
+    var payload savedSearchRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+    var search models.SavedSearch
+    applySavedSearchRequest(&search, payload)
+    if problems := validateSavedSearch(&search); len(problems) > 0 {
+        writeValidationError(w, problems...)
+        return
+    }
+    search, err := s.store.CreateSavedSearch(search)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    w.Header().Set("Location", "/api/saved-searches/"+search.ID)
+    writeJSON(w, http.StatusCreated, search)
+}
+
+func (s *Server) patchSavedSearch(w http.ResponseWriter, r *http.Request, id string) {
+    var payload savedSearchRequest
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+    search, err := s.store.SavedSearch(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    applySavedSearchRequest(&search, payload)
+    if problems := validateSavedSearch(&search); len(problems) > 0 {
+        writeValidationError(w, problems...)
+        return
+    }
+    search, err = s.store.UpdateSavedSearch(search)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, search)
+}
+
+func applySavedSearchRequest(search *models.SavedSearch, payload savedSearchRequest) {
+    if payload.Name != nil {
+        search.Name = *payload.Name
+    }
+    if payload.Query != nil {
+        search.Query = *payload.Query
+    }
+    if payload.Filters != nil {
+        search.Filters = *payload.Filters
+    }
+}
+
+// validateSavedSearch tidies search and reports what is wrong with it,
+// checking the filters as GET /api/conversations checks its parameters.
+func validateSavedSearch(search *models.SavedSearch) []fieldError {
+    search.Name = strings.TrimSpace(search.Name)
+    search.Query = strings.TrimSpace(search.Query)
+    filters := &search.Filters
+    filters.From = strings.TrimSpace(filters.From)
+    filters.To = strings.TrimSpace(filters.To)
+    filters.HasRole = strings.ToLower(strings.TrimSpace(filters.HasRole))
+
+    var problems []fieldError
+    if search.Name == "" {
+        problems = append(problems, fieldError{Field: "name", Message: "is required"})
+    }
+    if _, err := query.Parse(search.Query); err != nil {
+        problems = append(problems, fieldError{Field: "query", Message: err.Error()})
+    }
+    var from, to time.Time
+    var err error
+    if filters.From != "" {
+        if from, err = time.Parse(time.DateOnly, filters.From); err != nil {
+            problems = append(problems, fieldError{Field: "filters.from", Message: "must be a date (YYYY-MM-DD)"})
+        }
+    }
+    if filters.To != "" {
+        if to, err = time.Parse(time.DateOnly, filters.To); err != nil {
+            problems = append(problems, fieldError{Field: "filters.to", Message: "must be a date (YYYY-MM-DD)"})
+        }
+    }
+    if !from.IsZero() && !to.IsZero() && to.Before(from) {
+        problems = append(problems, fieldError{Field: "filters.to", Message: "must not be before from"})
+    }
+    if filters.MinMessages < 0 {
+        problems = append(problems, fieldError{Field: "filters.minMessages", Message: "must be a non-negative integer"})
+    }
+    return problems
+}
+
+// savedSearchOptions turns the filters of a validated saved search into
+// list options.
+func savedSearchOptions(filters models.SearchFilters) storage.ListOptions {
+    opts := storage.ListOptions{
+        Tags:        filters.Tags,
+        Archived:    filters.Archived,
+        Starred:     filters.Starred,
+        HasRole:     filters.HasRole,
+        MinMessages: filters.MinMessages,
+    }
+    if from, err := time.Parse(time.DateOnly, filters.From); err == nil {
+        opts.From = from
+    }
+    if to, err := time.Parse(time.DateOnly, filters.To); err == nil {
+        opts.To = to.AddDate(0, 0, 1)
+    }
+    return opts
+}
+
+// savedSearchResults serves GET /api/saved-searches/{id}/results: what the
+// saved search finds now. A query with words is a full-text search, best
+// matches first, as GET /api/search runs it; one without is a filtered
+// list, most recently updated first, whose results carry no score or
+// snippets. ?limit= and ?offset= page through the results.
+func (s *Server) savedSearchResults(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    values := r.URL.Query()
+    limit, err := parseLimit(values.Get("limit"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "limit", Message: "must be a positive integer"})
+        return
+    }
+    offset, err := parseOffset(values.Get("offset"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "offset", Message: "must be a non-negative integer"})
+        return
+    }
+    search, err := s.store.SavedSearch(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    q, err := query.Parse(search.Query)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    opts := savedSearchOptions(search.Filters)
+    filters := q
+    filters.Terms = nil
+    result := savedSearchResults{SavedSearch: search}
+    if len(q.Terms) == 0 {
+        if !filters.IsEmpty() {
+            opts.Where = filters.Match
+        }
+        opts.Offset, opts.Limit = offset, limit
+        _, span := telemetry.Start(r.Context(), "store.ListPage")
+        page, err := s.store.ListPage(opts)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        result.Results = make([]storage.SearchHit, len(page.Conversations))
+        for i, convo := range page.Conversations {
+            result.Results[i] = storage.SearchHit{Conversation: convo, MessageIDs: []string{}, Snippets: []storage.SearchSnippet{}}
+        }
+        result.Total = page.Total
+    } else {
+        match := func(convo models.Conversation) bool {
+            return filters.Match(convo) && opts.Match(convo)
+        }
+        ctx, span := telemetry.Start(r.Context(), "store.Search")
+        hits, total, err := s.store.Search(ctx, q.Terms, match, offset, limit)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        result.Results, result.Total = hits, total
+    }
+    for i := range result.Results {
+        result.Results[i].Conversation = result.Results[i].Conversation.InZone(loc)
+    }
+    if next := offset + len(result.Results); next < result.Total {
+        result.NextOffset = next
+    }
+    writeJSON(w, http.StatusOK, result)
+}
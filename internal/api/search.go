@@ -0,0 +1,125 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/query"
+    "zatGPT/internal/telemetry"
+)
+
+// handleSearch serves GET /api/search?q=: full-text search over titles,
+// summaries and message content, best matches first. q takes the usual
+// search syntax; its words and "quoted phrases" are looked up in the
+// store's index, while tag:, lang:, after: and before: filter the results.
+// Each result carries the IDs of its matching messages and highlighted
+// snippets. With ?hits=messages the results are the matching messages
+// instead, each with its conversation, position and the character offset of
+// its first match, for jumping straight to it. ?fuzzy=true instead looks
+// for the words in titles and summaries alone, forgiving typos. ?limit=
+// and ?offset= page through the results.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    values := r.URL.Query()
+    raw := strings.TrimSpace(values.Get("q"))
+    if raw == "" {
+        writeValidationError(w, fieldError{Field: "q", Message: "is required"})
+        return
+    }
+    q, err := query.Parse(raw)
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "q", Message: err.Error()})
+        return
+    }
+    if len(q.Terms) == 0 {
+        writeValidationError(w, fieldError{Field: "q", Message: "must contain at least one word to search for"})
+        return
+    }
+    limit, err := parseLimit(values.Get("limit"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "limit", Message: "must be a positive integer"})
+        return
+    }
+    offset, err := parseOffset(values.Get("offset"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "offset", Message: "must be a non-negative integer"})
+        return
+    }
+    perMessage := false
+    switch values.Get("hits") {
+    case "", "conversations":
+    case "messages":
+        perMessage = true
+    default:
+        writeValidationError(w, fieldError{Field: "hits", Message: "must be conversations or messages"})
+        return
+    }
+    fuzzy := false
+    if raw := values.Get("fuzzy"); raw != "" {
+        parsed, err := strconv.ParseBool(raw)
+        if err != nil {
+            writeValidationError(w, fieldError{Field: "fuzzy", Message: "must be true or false"})
+            return
+        }
+        fuzzy = parsed
+    }
+    if fuzzy && perMessage {
+        writeValidationError(w, fieldError{Field: "fuzzy", Message: "cannot be combined with hits=messages; it only searches titles and summaries"})
+        return
+    }
+
+    var match func(models.Conversation) bool
+    filters := q
+    filters.Terms = nil
+    if !filters.IsEmpty() {
+        match = filters.Match
+    }
+
+    var (
+        results any
+        count   int
+        total   int
+    )
+    if perMessage {
+        ctx, span := telemetry.Start(r.Context(), "store.SearchMessages")
+        hits, n, err := s.store.SearchMessages(ctx, q.Terms, match, offset, limit)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        results, count, total = hits, len(hits), n
+    } else {
+        search, name := s.store.Search, "store.Search"
+        if fuzzy {
+            search, name = s.store.FuzzySearch, "store.FuzzySearch"
+        }
+        ctx, span := telemetry.Start(r.Context(), name)
+        hits, n, err := search(ctx, q.Terms, match, offset, limit)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        for i := range hits {
+            hits[i].Conversation = hits[i].Conversation.InZone(loc)
+        }
+        results, count, total = hits, len(hits), n
+    }
+
+    payload := map[string]any{"results": results, "total": total}
+    if next := offset + count; next < total {
+        payload["nextOffset"] = next
+    }
+    writeJSON(w, http.StatusOK, payload)
+}
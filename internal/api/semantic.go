@@ -0,0 +1,130 @@
+package api
+
+import (
+    "errors"
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/embeddings"
+    "zatGPT/internal/models"
+    "zatGPT/internal/telemetry"
+)
+
+// excerptLen bounds the message excerpts in semantic search results.
+const excerptLen = 240
+
+// semanticResults is the body of GET /api/search/semantic.
+type semanticResults struct {
+    Results    []semanticHit     `json:"results"`
+    Total      int               `json:"total"`
+    NextOffset int               `json:"nextOffset,omitempty"`
+    Index      embeddings.Status `json:"index"`
+}
+
+// semanticHit is a matching conversation, without its messages, and the
+// messages closest to the query.
+type semanticHit struct {
+    Conversation models.Conversation `json:"conversation"`
+    Score        float64             `json:"score"`
+    Messages     []semanticMessage   `json:"messages"`
+}
+
+type semanticMessage struct {
+    ID      string  `json:"id"`
+    Author  string  `json:"author"`
+    Score   float64 `json:"score"`
+    Excerpt string  `json:"excerpt"`
+}
+
+// SetEmbeddings enables GET /api/search/semantic with indexer, which the
+// server keeps up to date in the background.
+func (s *Server) SetEmbeddings(indexer *embeddings.Indexer) {
+    s.embeddings = indexer
+}
+
+// handleSemanticSearch serves GET /api/search/semantic?q=: conversations
+// ranked by the cosine similarity of their embeddings to the query's, for
+// topics worded differently from the query. Conversations the background
+// indexer has not reached yet are missing; "index" reports how many those
+// are. ?limit= and ?offset= page through the results.
+func (s *Server) handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    if s.embeddings == nil {
+        writeErrorBody(w, http.StatusNotImplemented, errorBody{Code: CodeNotEnabled, Message: "semantic search is not enabled; start the server with -embeddings"})
+        return
+    }
+
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    values := r.URL.Query()
+    q := strings.TrimSpace(values.Get("q"))
+    if q == "" {
+        writeValidationError(w, fieldError{Field: "q", Message: "is required"})
+        return
+    }
+    limit, err := parseLimit(values.Get("limit"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "limit", Message: "must be a positive integer"})
+        return
+    }
+    offset, err := parseOffset(values.Get("offset"))
+    if err != nil {
+        writeValidationError(w, fieldError{Field: "offset", Message: "must be a non-negative integer"})
+        return
+    }
+
+    ctx, span := telemetry.Start(r.Context(), "embeddings.Search")
+    hits, total, err := s.embeddings.Search(ctx, q, offset, limit)
+    telemetry.End(span, err)
+    switch {
+    case errors.Is(err, embeddings.ErrEmptyQuery):
+        writeValidationError(w, fieldError{Field: "q", Message: "is required"})
+        return
+    case err != nil && r.Context().Err() != nil:
+        writeError(w, statusFor(r.Context().Err()), r.Context().Err())
+        return
+    case err != nil:
+        writeErrorBody(w, http.StatusBadGateway, errorBody{Code: CodeEmbeddingsFailed, Message: err.Error()})
+        return
+    }
+
+    payload := semanticResults{Results: make([]semanticHit, 0, len(hits)), Total: total, Index: s.embeddings.Status()}
+    for _, hit := range hits {
+        convo, err := s.store.Get(hit.ConversationID)
+        if err != nil {
+            continue
+        }
+        result := semanticHit{Score: hit.Score, Messages: []semanticMessage{}}
+        for _, scored := range hit.Messages {
+            for _, msg := range convo.Messages {
+                if msg.ID == scored.MessageID {
+                    result.Messages = append(result.Messages, semanticMessage{ID: msg.ID, Author: msg.Author, Score: scored.Score, Excerpt: excerpt(msg.Content)})
+                    break
+                }
+            }
+        }
+        convo.Messages = nil
+        convo.Tree = nil
+        convo.Stats = nil
+        result.Conversation = convo.InZone(loc)
+        payload.Results = append(payload.Results, result)
+    }
+    if next := offset + len(hits); next < total {
+        payload.NextOffset = next
+    }
+    writeJSON(w, http.StatusOK, payload)
+}
+
+// excerpt is the opening of a message, whitespace collapsed.
+func excerpt(content string) string {
+    runes := []rune(strings.Join(strings.Fields(content), " "))
+    if len(runes) <= excerptLen {
+        return string(runes)
+    }
+    return strings.TrimSpace(string(runes[:excerptLen-1])) + "…"
+}
@@ -1,33 +1,104 @@
 package api
 
 import (
-    "crypto/rand"
-    "encoding/hex"
     "encoding/json"
     "fmt"
     "io"
     "net/http"
+    "net/url"
+    "reflect"
+    "slices"
+    "strconv"
     "strings"
     "time"
 
+    "go.opentelemetry.io/otel/attribute"
+
+    "zatGPT/internal/chatsync"
+    "zatGPT/internal/embeddings"
+    "zatGPT/internal/ids"
     "zatGPT/internal/models"
+    "zatGPT/internal/stats"
     "zatGPT/internal/storage"
+    "zatGPT/internal/summary"
+    "zatGPT/internal/telemetry"
 )
 
 // Server wraps the HTTP handlers for the conversations API.
 type Server struct {
     store *storage.Store
+    idem  *idempotencyCache
+    sync  *chatsync.Scheduler
+
+    summarizer summary.Summarizer
+    summaries  *summaryJobs
+    titler     summary.Titler
+    retitles   *retitleJobs
+    embeddings *embeddings.Indexer
+    docs       bool
+    prices     map[string]stats.Price
+    location   *time.Location
+
+    // deleteTokens and backupDir serve the two-step DELETE
+    // /api/conversations.
+    deleteTokens *deleteAllTokens
+    backupDir    string
 }
 
 // New creates a new Server instance.
 func New(store *storage.Store) *Server {
-    return &Server{store: store}
+    return &Server{
+        store:      store,
+        idem:       newIdempotencyCache(),
+        summarizer: summary.Heuristic{},
+        summaries:  newSummaryJobs(),
+        titler:     summary.HeuristicTitle{},
+        retitles:   newRetitleJobs(),
+
+        deleteTokens: newDeleteAllTokens(),
+    }
 }
 
 // Register wires the API routes onto the supplied mux.
 func (s *Server) Register(mux *http.ServeMux) {
-    mux.HandleFunc("/api/conversations", s.handleConversations)
+    mux.HandleFunc("/api/conversations", s.idempotent(maxJSONBody, s.lastModified(s.handleConversations)))
     mux.HandleFunc("/api/conversations/", s.handleConversationByID)
+    mux.HandleFunc("/api/conversations/bulk-tag", s.idempotent(maxJSONBody, s.handleBulkTag))
+    mux.HandleFunc("/api/conversations/batch", longRequest(s.idempotent(maxBatchBody, s.handleBatch)))
+    mux.HandleFunc("/api/conversations/bulk", s.idempotent(maxJSONBody, s.handleBulk))
+    mux.HandleFunc("/api/conversations/merge", s.idempotent(maxJSONBody, s.handleMerge))
+    mux.HandleFunc("/api/conversations/retitle", s.handleRetitle)
+    mux.HandleFunc("/api/duplicates", s.lastModified(s.handleDuplicates))
+    mux.HandleFunc("/api/export", longRequest(s.lastModified(s.handleExport)))
+    mux.HandleFunc(feedPath, s.lastModified(s.handleFeed))
+    mux.HandleFunc("/api/search", s.lastModified(s.handleSearch))
+    mux.HandleFunc("/api/search/semantic", s.handleSemanticSearch)
+    mux.HandleFunc("/api/saved-searches", s.handleSavedSearches)
+    mux.HandleFunc("/api/saved-searches/", s.handleSavedSearches)
+    mux.HandleFunc("/api/attachments/", longRequest(s.handleAttachment))
+    mux.HandleFunc("/api/tags", s.lastModified(s.handleTags))
+    mux.HandleFunc("/api/trash", s.lastModified(s.handleTrash))
+    mux.HandleFunc("/api/trash/", s.handleTrash)
+    mux.HandleFunc("/api/code", s.lastModified(s.handleCode))
+    mux.HandleFunc("/api/links", s.lastModified(s.handleLinks))
+    mux.HandleFunc("/api/compare", s.lastModified(s.handleCompare))
+    mux.HandleFunc("/api/stats", s.lastModified(s.handleStats))
+    mux.HandleFunc("/api/stats/export.csv", s.lastModified(s.handleStatsCSV))
+    mux.HandleFunc("/api/stats/terms", s.lastModified(s.handleStatsTerms))
+    mux.HandleFunc("/api/stats/tokens", s.lastModified(s.handleStatsTokens))
+    mux.HandleFunc("/api/stats/review", s.lastModified(s.handleStatsReview))
+    mux.HandleFunc("/api/stats/usage", s.handleStatsUsage)
+    mux.HandleFunc("/api/topics", s.lastModified(s.handleTopics))
+    mux.HandleFunc("/api/shares", s.handleShares)
+    mux.HandleFunc("/api/shares/", s.handleShares)
+    mux.HandleFunc(sharePath, s.handleSharePage)
+    mux.HandleFunc("/api/sync/status", s.handleSyncStatus)
+    mux.HandleFunc("/api/import", s.handleImportUpload)
+    mux.HandleFunc("/api/imports", s.lastModified(s.handleImports))
+    mux.HandleFunc("/api/imports/", s.lastModified(s.handleImports))
+    mux.HandleFunc("/api/admin/integrity", s.handleIntegrity)
+    mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+    mux.HandleFunc("/api/docs", s.handleDocs)
 }
 
 func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
@@ -44,10 +115,21 @@ func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleConversationByID(w http.ResponseWriter, r *http.Request) {
-    id := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
-    id = strings.Trim(id, "/")
+    rest := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
+    rest = strings.Trim(rest, "/")
+    id, sub, _ := strings.Cut(rest, "/")
     if id == "" {
-        http.NotFound(w, r)
+        writeNotFound(w)
+        return
+    }
+
+    // Summaries carry their own generation time and are not cached here.
+    if sub != "summary" && s.conversationNotModified(w, r, id) {
+        return
+    }
+
+    if sub != "" {
+        s.handleConversationSubresource(w, r, id, sub)
         return
     }
 
@@ -63,20 +145,357 @@ func (s *Server) handleConversationByID(w http.ResponseWriter, r *http.Request)
     }
 }
 
-func (s *Server) listConversations(w http.ResponseWriter, _ *http.Request) {
-    writeJSON(w, http.StatusOK, map[string]any{
-        "conversations": s.store.List(),
-    })
+func (s *Server) handleConversationSubresource(w http.ResponseWriter, r *http.Request, id, sub string) {
+    switch name, rest, _ := strings.Cut(sub, "/"); name {
+    case "branches":
+        s.conversationBranches(w, r, id, rest)
+        return
+    case "tags":
+        s.conversationTags(w, r, id, rest)
+        return
+    case "revisions":
+        s.conversationRevisions(w, r, id, rest)
+        return
+    case "messages":
+        s.conversationMessages(w, r, id, rest)
+        return
+    }
+
+    switch sub {
+    case "export":
+        extendDeadlines(w, longRequestTimeout)
+        s.exportConversation(w, r, id)
+    case "attachments.zip":
+        extendDeadlines(w, longRequestTimeout)
+        s.downloadAttachmentsZip(w, r, id)
+    case "code":
+        s.conversationCode(w, r, id)
+    case "tree":
+        s.conversationTree(w, r, id)
+    case "summary":
+        s.conversationSummary(w, r, id)
+    case "summarize":
+        s.conversationSummarize(w, r, id)
+    case "retitle":
+        s.conversationRetitle(w, r, id)
+    case "share":
+        s.conversationShare(w, r, id)
+    case "redact":
+        s.conversationRedact(w, r, id)
+    default:
+        writeNotFound(w)
+    }
+}
+
+func (s *Server) listConversations(w http.ResponseWriter, r *http.Request) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    query := r.URL.Query()
+    messageLimit, invalid := parseInclude(query)
+    if invalid != nil {
+        writeValidationError(w, *invalid)
+        return
+    }
+    fields, invalid := parseFields(query)
+    if invalid != nil {
+        writeValidationError(w, *invalid)
+        return
+    }
+    if len(fields) > 0 && !slices.Contains(fields, "messages") {
+        messageLimit = 0
+    }
+
+    opts := storage.ListOptions{
+        Sort:   strings.TrimSpace(query.Get("sort")),
+        Order:  strings.ToLower(strings.TrimSpace(query.Get("order"))),
+        Cursor: strings.TrimSpace(query.Get("cursor")),
+        Tags:   splitList(query["tag"]),
+    }
+    var problems []fieldError
+    var err error
+    if opts.Archived, err = parseFlag(query.Get("archived")); err != nil {
+        problems = append(problems, fieldError{Field: "archived", Message: "must be true or false"})
+    }
+    if opts.Starred, err = parseFlag(query.Get("starred")); err != nil {
+        problems = append(problems, fieldError{Field: "starred", Message: "must be true or false"})
+    }
+    if raw := query.Get("from"); raw != "" {
+        if opts.From, err = time.Parse("2006-01-02", raw); err != nil {
+            problems = append(problems, fieldError{Field: "from", Message: "must be a date (YYYY-MM-DD)"})
+        }
+    }
+    if raw := query.Get("to"); raw != "" {
+        to, err := time.Parse("2006-01-02", raw)
+        if err != nil {
+            problems = append(problems, fieldError{Field: "to", Message: "must be a date (YYYY-MM-DD)"})
+        } else {
+            opts.To = to.AddDate(0, 0, 1)
+        }
+    }
+    if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+        problems = append(problems, fieldError{Field: "to", Message: "must not be before from"})
+    }
+    if raw := query.Get("updatedFrom"); raw != "" {
+        if opts.UpdatedFrom, err = parseUpdatedBound(raw, false); err != nil {
+            problems = append(problems, fieldError{Field: "updatedFrom", Message: "must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+        }
+    }
+    if raw := query.Get("updatedTo"); raw != "" {
+        if opts.UpdatedTo, err = parseUpdatedBound(raw, true); err != nil {
+            problems = append(problems, fieldError{Field: "updatedTo", Message: "must be a date (YYYY-MM-DD) or an RFC 3339 time"})
+        }
+    }
+    if !opts.UpdatedFrom.IsZero() && !opts.UpdatedTo.IsZero() && !opts.UpdatedFrom.Before(opts.UpdatedTo) {
+        problems = append(problems, fieldError{Field: "updatedTo", Message: "must be after updatedFrom"})
+    }
+    opts.HasRole = strings.ToLower(strings.TrimSpace(query.Get("hasRole")))
+    if raw := query.Get("minMessages"); raw != "" {
+        if opts.MinMessages, err = strconv.Atoi(raw); err != nil || opts.MinMessages < 0 {
+            problems = append(problems, fieldError{Field: "minMessages", Message: "must be a non-negative integer"})
+        }
+    }
+    filtered := len(opts.Tags) > 0 || opts.Archived != nil || opts.Starred != nil || !opts.From.IsZero() || !opts.To.IsZero() || !opts.UpdatedFrom.IsZero() || !opts.UpdatedTo.IsZero() || opts.HasRole != "" || opts.MinMessages > 0
+    if len(problems) == 0 && opts.Cursor == "" && query.Get("limit") == "" && query.Get("offset") == "" && opts.Sort == "" && opts.Order == "" && !filtered {
+        _, span := telemetry.Start(r.Context(), "store.List")
+        items := s.store.List()
+        span.End()
+        writeJSON(w, http.StatusOK, map[string]any{
+            "conversations": selectFields(inZone(s.withMessages(items, messageLimit), loc), fields),
+            "total":         len(items),
+        })
+        return
+    }
+
+    if !storage.ValidSort(opts.Sort) {
+        problems = append(problems, fieldError{Field: "sort", Message: "must be one of " + strings.Join(storage.SortKeys, ", ")})
+    }
+    if opts.Order != "" && opts.Order != storage.OrderAsc && opts.Order != storage.OrderDesc {
+        problems = append(problems, fieldError{Field: "order", Message: "must be asc or desc"})
+    }
+    offset, err := parseOffset(query.Get("offset"))
+    if err != nil {
+        problems = append(problems, fieldError{Field: "offset", Message: "must be a non-negative integer"})
+    } else if offset > 0 && opts.Cursor != "" {
+        problems = append(problems, fieldError{Field: "offset", Message: "cannot be combined with cursor"})
+    }
+    // Without a limit or cursor, a sorted or offset request returns the rest
+    // of the list.
+    if opts.Cursor != "" || query.Get("limit") != "" {
+        opts.Limit, err = parseLimit(query.Get("limit"))
+        if err != nil {
+            problems = append(problems, fieldError{Field: "limit", Message: "must be a positive integer"})
+        }
+    }
+    if len(problems) > 0 {
+        writeValidationError(w, problems...)
+        return
+    }
+    opts.Offset = offset
+
+    _, span := telemetry.Start(r.Context(), "store.ListPage")
+    page, err := s.store.ListPage(opts)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    page.Conversations = inZone(s.withMessages(page.Conversations, messageLimit), loc)
+    payload := pagePayload(page)
+    payload["conversations"] = selectFields(page.Conversations, fields)
+    if next := offset + len(page.Conversations); opts.Cursor == "" && next < page.Total {
+        payload["nextOffset"] = next
+    }
+    writeJSON(w, http.StatusOK, payload)
+}
+
+const (
+    defaultMessageLimit = 3
+    maxMessageLimit     = 50
+)
+
+// parseInclude reads ?include=messages&messageLimit=N, returning how many
+// leading messages to embed per conversation (0 when not requested).
+func parseInclude(query url.Values) (int, *fieldError) {
+    include := false
+    for _, part := range strings.Split(query.Get("include"), ",") {
+        switch strings.TrimSpace(part) {
+        case "":
+        case "messages":
+            include = true
+        default:
+            return 0, &fieldError{Field: "include", Message: fmt.Sprintf("unknown value %q (supported: messages)", part)}
+        }
+    }
+
+    raw := strings.TrimSpace(query.Get("messageLimit"))
+    if !include {
+        if raw != "" {
+            return 0, &fieldError{Field: "messageLimit", Message: "requires include=messages"}
+        }
+        return 0, nil
+    }
+    if raw == "" {
+        return defaultMessageLimit, nil
+    }
+    limit, err := strconv.Atoi(raw)
+    if err != nil || limit <= 0 {
+        return 0, &fieldError{Field: "messageLimit", Message: "must be a positive integer"}
+    }
+    if limit > maxMessageLimit {
+        limit = maxMessageLimit
+    }
+    return limit, nil
+}
+
+// conversationJSON describes a conversation's fields as encoding/json
+// writes them; conversationFields are their names, which ?fields= picks
+// from.
+var (
+    conversationJSON   = jsonFields(reflect.TypeOf(models.Conversation{}))
+    conversationFields = jsonNames(conversationJSON)
+)
+
+// jsonField is a struct field with the name it has in JSON and whether it
+// is left out when empty (omitempty) or zero (omitzero).
+type jsonField struct {
+    name      string
+    index     int
+    omitEmpty bool
+    omitZero  bool
+}
+
+func jsonFields(t reflect.Type) []jsonField {
+    var fields []jsonField
+    for i := range t.NumField() {
+        field := t.Field(i)
+        name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+        if field.IsExported() && name != "-" && name != "" {
+            fields = append(fields, jsonField{
+                name:      name,
+                index:     i,
+                omitEmpty: slices.Contains(strings.Split(options, ","), "omitempty"),
+                omitZero:  slices.Contains(strings.Split(options, ","), "omitzero"),
+            })
+        }
+    }
+    return fields
+}
+
+func jsonNames(fields []jsonField) []string {
+    names := make([]string, len(fields))
+    for i, field := range fields {
+        names[i] = field.name
+    }
+    return names
+}
+
+// omitted reports whether encoding/json would leave value, the field's
+// value, out.
+func (f jsonField) omitted(value reflect.Value) bool {
+    if f.omitZero {
+        if zeroer, ok := value.Interface().(interface{ IsZero() bool }); ok {
+            return zeroer.IsZero()
+        }
+        if value.IsZero() {
+            return true
+        }
+    }
+    if !f.omitEmpty {
+        return false
+    }
+    switch value.Kind() {
+    case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+        return value.Len() == 0
+    case reflect.Struct:
+        return false
+    }
+    return value.IsZero()
+}
+
+// parseFields reads ?fields=id,title,updatedAt, the fields each listed
+// conversation is cut down to; none keeps them all.
+func parseFields(query url.Values) ([]string, *fieldError) {
+    var fields []string
+    for _, part := range strings.Split(query.Get("fields"), ",") {
+        name := strings.TrimSpace(part)
+        switch {
+        case name == "", slices.Contains(fields, name):
+        case slices.Contains(conversationFields, name):
+            fields = append(fields, name)
+        default:
+            return nil, &fieldError{Field: "fields", Message: fmt.Sprintf("unknown field %q (supported: %s)", name, strings.Join(conversationFields, ", "))}
+        }
+    }
+    return fields, nil
+}
+
+// selectFields cuts each conversation down to fields, leaving out those it
+// would leave out anyway when empty. Without fields the conversations are
+// returned as they are.
+func selectFields(items []models.Conversation, fields []string) any {
+    if len(fields) == 0 {
+        return items
+    }
+    selected := make([]jsonField, 0, len(fields))
+    for _, field := range conversationJSON {
+        if slices.Contains(fields, field.name) {
+            selected = append(selected, field)
+        }
+    }
+    out := make([]map[string]any, len(items))
+    for i := range items {
+        item := reflect.ValueOf(&items[i]).Elem()
+        out[i] = make(map[string]any, len(selected))
+        for _, field := range selected {
+            if value := item.Field(field.index); !field.omitted(value) {
+                out[i][field.name] = value.Interface()
+            }
+        }
+    }
+    return out
+}
+
+// withMessages attaches up to limit leading messages to each list entry.
+func (s *Server) withMessages(items []models.Conversation, limit int) []models.Conversation {
+    if limit == 0 {
+        return items
+    }
+    ids := make([]string, len(items))
+    for i, item := range items {
+        ids[i] = item.ID
+    }
+    stored := s.store.GetMany(ids)
+    for i := range items {
+        full, ok := stored[items[i].ID]
+        if !ok {
+            continue
+        }
+        messages := full.Messages
+        if len(messages) > limit {
+            messages = messages[:limit]
+        }
+        items[i].Messages = messages
+    }
+    return items
+}
+
+// createRequest is the body of POST /api/conversations.
+type createRequest struct {
+    Title       string `json:"title"`
+    Summary     string `json:"summary"`
+    DateStarted string `json:"dateStarted,omitempty"`
+    DateEnded   string `json:"dateEnded,omitempty"`
+    SourceID    string `json:"sourceId,omitempty"`
 }
 
 func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
-    var payload struct {
-        Title       string `json:"title"`
-        Summary     string `json:"summary"`
-        DateStarted string `json:"dateStarted"`
-        DateEnded   string `json:"dateEnded"`
-        SourceID    string `json:"sourceId"`
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
     }
+    var payload createRequest
 
     if err := decodeJSON(r.Body, &payload); err != nil {
         writeError(w, http.StatusBadRequest, err)
@@ -89,13 +508,20 @@ func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
     payload.DateEnded = strings.TrimSpace(payload.DateEnded)
     payload.SourceID = strings.TrimSpace(payload.SourceID)
 
-    if payload.Title == "" || payload.Summary == "" {
-        writeErrorString(w, http.StatusBadRequest, "title and summary are required")
+    var fields []fieldError
+    if payload.Title == "" {
+        fields = append(fields, fieldError{Field: "title", Message: "is required"})
+    }
+    if payload.Summary == "" {
+        fields = append(fields, fieldError{Field: "summary", Message: "is required"})
+    }
+    if len(fields) > 0 {
+        writeValidationError(w, fields...)
         return
     }
 
     convo := models.Conversation{
-        ID:          newID(),
+        ID:          ids.New(),
         Title:       payload.Title,
         Summary:     payload.Summary,
         DateStarted: payload.DateStarted,
@@ -103,34 +529,205 @@ func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
         SourceID:    payload.SourceID,
     }
 
-    if err := s.store.Upsert(convo); err != nil {
-        writeError(w, http.StatusInternalServerError, err)
+    _, span := telemetry.Start(r.Context(), "store.Upsert")
+    err := s.store.Upsert(convo)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
         return
     }
 
-    writeJSON(w, http.StatusCreated, convo)
+    writeJSON(w, http.StatusCreated, convo.InZone(loc))
+}
+
+// find runs Store.Find inside a span, since it scans every message.
+// The scan stops early when the client goes away or the server shuts down.
+func (s *Server) find(r *http.Request, match func(models.Conversation) bool) ([]models.Conversation, error) {
+    ctx, span := telemetry.Start(r.Context(), "store.Find")
+    items, err := s.store.Find(ctx, match)
+    span.SetAttributes(attribute.Int("store.results", len(items)))
+    telemetry.End(span, err)
+    return items, err
 }
 
-func (s *Server) getConversation(w http.ResponseWriter, _ *http.Request, id string) {
+func (s *Server) getConversation(w http.ResponseWriter, r *http.Request, id string) {
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    query := r.URL.Query()
+    window, invalid := parseMessageWindow(query)
+    if invalid != nil {
+        writeValidationError(w, *invalid)
+        return
+    }
+    metaOnly := false
+    switch include := strings.TrimSpace(query.Get("include")); include {
+    case "":
+    case "meta":
+        if window != nil {
+            writeValidationError(w, fieldError{Field: "include", Message: "meta cannot be combined with a message window"})
+            return
+        }
+        metaOnly = true
+    default:
+        writeValidationError(w, fieldError{Field: "include", Message: "must be meta"})
+        return
+    }
+
     convo, err := s.store.Get(id)
     if err != nil {
         if err == storage.ErrNotFound {
-            http.NotFound(w, nil)
+            writeError(w, http.StatusNotFound, err)
             return
         }
         writeError(w, http.StatusInternalServerError, err)
         return
     }
-    writeJSON(w, http.StatusOK, convo)
+    // The branch graph can dwarf the transcript; it is served from /tree.
+    convo.Tree = nil
+    convo = convo.InZone(loc)
+
+    if metaOnly {
+        // Messages are paged in from /messages.
+        total := len(convo.Messages)
+        convo.Messages = nil
+        writeJSON(w, http.StatusOK, conversationMeta{Conversation: convo, MessageCount: total})
+        return
+    }
+
+    if window == nil {
+        writeJSON(w, http.StatusOK, convo)
+        return
+    }
+
+    start, end, ok := window.bounds(convo.Messages)
+    if !ok {
+        writeErrorString(w, http.StatusNotFound, "message not found")
+        return
+    }
+    total := len(convo.Messages)
+    convo.Messages = convo.Messages[start:end]
+    writeJSON(w, http.StatusOK, windowedConversation{
+        Conversation: convo,
+        MessageWindow: messageWindowInfo{
+            Offset: start,
+            Count:  end - start,
+            Total:  total,
+        },
+    })
+}
+
+const (
+    defaultMessageContext = 20
+    maxMessageWindow      = 1000
+)
+
+// messageWindow selects part of a transcript, either by position
+// (?messageOffset=&messageLimit=) or around one message (?around=&context=).
+type messageWindow struct {
+    offset  int
+    limit   int
+    around  string
+    context int
+}
+
+type messageWindowInfo struct {
+    Offset int `json:"offset"`
+    Count  int `json:"count"`
+    Total  int `json:"total"`
+    // Hidden counts the messages left out by /messages?roles=.
+    Hidden int `json:"hidden,omitempty"`
+}
+
+type conversationMeta struct {
+    models.Conversation
+    MessageCount int `json:"messageCount"`
+}
+
+type windowedConversation struct {
+    models.Conversation
+    MessageWindow messageWindowInfo `json:"messageWindow"`
+}
+
+func parseMessageWindow(query url.Values) (*messageWindow, *fieldError) {
+    rawOffset := strings.TrimSpace(query.Get("messageOffset"))
+    rawLimit := strings.TrimSpace(query.Get("messageLimit"))
+    around := strings.TrimSpace(query.Get("around"))
+    rawContext := strings.TrimSpace(query.Get("context"))
+
+    if around != "" {
+        if rawOffset != "" || rawLimit != "" {
+            return nil, &fieldError{Field: "around", Message: "cannot be combined with messageOffset/messageLimit"}
+        }
+        context := defaultMessageContext
+        if rawContext != "" {
+            n, err := strconv.Atoi(rawContext)
+            if err != nil || n < 0 {
+                return nil, &fieldError{Field: "context", Message: "must be a non-negative integer"}
+            }
+            context = min(n, maxMessageWindow/2)
+        }
+        return &messageWindow{around: around, context: context}, nil
+    }
+    if rawContext != "" {
+        return nil, &fieldError{Field: "context", Message: "requires around"}
+    }
+    if rawOffset == "" && rawLimit == "" {
+        return nil, nil
+    }
+
+    window := &messageWindow{limit: maxMessageWindow}
+    if rawOffset != "" {
+        n, err := strconv.Atoi(rawOffset)
+        if err != nil || n < 0 {
+            return nil, &fieldError{Field: "messageOffset", Message: "must be a non-negative integer"}
+        }
+        window.offset = n
+    }
+    if rawLimit != "" {
+        n, err := strconv.Atoi(rawLimit)
+        if err != nil || n <= 0 {
+            return nil, &fieldError{Field: "messageLimit", Message: "must be a positive integer"}
+        }
+        window.limit = min(n, maxMessageWindow)
+    }
+    return window, nil
+}
+
+// bounds resolves the window against messages; ok is false when the
+// around message does not exist.
+func (w *messageWindow) bounds(messages []models.Message) (start, end int, ok bool) {
+    if w.around == "" {
+        start = min(w.offset, len(messages))
+        return start, min(start+w.limit, len(messages)), true
+    }
+    for i, msg := range messages {
+        if msg.ID == w.around {
+            return max(i-w.context, 0), min(i+w.context+1, len(messages)), true
+        }
+    }
+    return 0, 0, false
+}
+
+// patchRequest is the body of PATCH /api/conversations/{id}; fields left
+// out are not changed.
+type patchRequest struct {
+    Title       *string   `json:"title,omitempty"`
+    Summary     *string   `json:"summary,omitempty"`
+    DateStarted *string   `json:"dateStarted,omitempty"`
+    DateEnded   *string   `json:"dateEnded,omitempty"`
+    Tags        *[]string `json:"tags,omitempty"`
+    Archived    *bool     `json:"archived,omitempty"`
+    Starred     *bool     `json:"starred,omitempty"`
 }
 
 func (s *Server) patchConversation(w http.ResponseWriter, r *http.Request, id string) {
-    var payload struct {
-        Title       *string `json:"title"`
-        Summary     *string `json:"summary"`
-        DateStarted *string `json:"dateStarted"`
-        DateEnded   *string `json:"dateEnded"`
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
     }
+    var payload patchRequest
 
     if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
         writeError(w, http.StatusBadRequest, err)
@@ -140,7 +737,7 @@ func (s *Server) patchConversation(w http.ResponseWriter, r *http.Request, id st
     convo, err := s.store.Get(id)
     if err != nil {
         if err == storage.ErrNotFound {
-            http.NotFound(w, r)
+            writeError(w, http.StatusNotFound, err)
             return
         }
         writeError(w, http.StatusInternalServerError, err)
@@ -150,7 +747,7 @@ func (s *Server) patchConversation(w http.ResponseWriter, r *http.Request, id st
     if payload.Title != nil {
         title := strings.TrimSpace(*payload.Title)
         if title == "" {
-            writeErrorString(w, http.StatusBadRequest, "title cannot be empty")
+            writeValidationError(w, fieldError{Field: "title", Message: "cannot be empty"})
             return
         }
         convo.Title = title
@@ -159,34 +756,66 @@ func (s *Server) patchConversation(w http.ResponseWriter, r *http.Request, id st
     if payload.Summary != nil {
         summary := strings.TrimSpace(*payload.Summary)
         if summary == "" {
-            writeErrorString(w, http.StatusBadRequest, "summary cannot be empty")
+            writeValidationError(w, fieldError{Field: "summary", Message: "cannot be empty"})
             return
         }
         convo.Summary = summary
     }
 
+    // Keep the span through the UpdatedAt bump below, and drop the half
+    // of it whose date the client changed. A date sent back as the client
+    // was shown it, in its own zone, is no change.
+    convo.StartedAt, convo.EndedAt = convo.Span()
+    shown := convo.InZone(loc)
     if payload.DateStarted != nil {
-        convo.DateStarted = strings.TrimSpace(*payload.DateStarted)
+        if date := strings.TrimSpace(*payload.DateStarted); date != shown.DateStarted {
+            convo.DateStarted = date
+            convo.StartedAt = time.Time{}
+        }
     }
 
     if payload.DateEnded != nil {
-        convo.DateEnded = strings.TrimSpace(*payload.DateEnded)
+        if date := strings.TrimSpace(*payload.DateEnded); date != shown.DateEnded {
+            convo.DateEnded = date
+            convo.EndedAt = time.Time{}
+        }
+    }
+
+    if payload.Tags != nil {
+        convo.Tags = storage.NormalizeTags(*payload.Tags)
+        if len(convo.Tags) == 0 {
+            convo.Tags = nil
+        }
+    }
+
+    if payload.Archived != nil {
+        convo.Archived = *payload.Archived
+    }
+
+    if payload.Starred != nil {
+        convo.Starred = *payload.Starred
     }
 
     convo.UpdatedAt = time.Now().UTC()
 
-    if err := s.store.Upsert(convo); err != nil {
-        writeError(w, http.StatusInternalServerError, err)
+    _, span := telemetry.Start(r.Context(), "store.Upsert")
+    err = s.store.Upsert(convo)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
         return
     }
 
-    writeJSON(w, http.StatusOK, convo)
+    writeJSON(w, http.StatusOK, convo.InZone(loc))
 }
 
-func (s *Server) deleteConversation(w http.ResponseWriter, _ *http.Request, id string) {
-    if err := s.store.Delete(id); err != nil {
+func (s *Server) deleteConversation(w http.ResponseWriter, r *http.Request, id string) {
+    _, span := telemetry.Start(r.Context(), "store.Delete")
+    err := s.store.Delete(id)
+    telemetry.End(span, err)
+    if err != nil {
         if err == storage.ErrNotFound {
-            http.NotFound(w, nil)
+            writeError(w, http.StatusNotFound, err)
             return
         }
         writeError(w, http.StatusInternalServerError, err)
@@ -195,14 +824,6 @@ func (s *Server) deleteConversation(w http.ResponseWriter, _ *http.Request, id s
     w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *Server) deleteAll(w http.ResponseWriter, _ *http.Request) {
-    if err := s.store.DeleteAll(); err != nil {
-        writeError(w, http.StatusInternalServerError, err)
-        return
-    }
-    w.WriteHeader(http.StatusNoContent)
-}
-
 func writeJSON(w http.ResponseWriter, status int, payload any) {
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(status)
@@ -210,30 +831,115 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
     _ = enc.Encode(payload)
 }
 
-func writeError(w http.ResponseWriter, status int, err error) {
-    writeErrorString(w, status, err.Error())
+const (
+    defaultPageLimit = 50
+    maxPageLimit     = 500
+)
+
+func parseLimit(raw string) (int, error) {
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+        return defaultPageLimit, nil
+    }
+    limit, err := strconv.Atoi(raw)
+    if err != nil || limit <= 0 {
+        return 0, fmt.Errorf("limit must be a positive integer")
+    }
+    if limit > maxPageLimit {
+        limit = maxPageLimit
+    }
+    return limit, nil
 }
 
-func writeErrorString(w http.ResponseWriter, status int, msg string) {
-    writeJSON(w, status, map[string]string{"error": msg})
+// parseOffset reads ?offset=, which defaults to 0.
+func parseOffset(raw string) (int, error) {
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+        return 0, nil
+    }
+    offset, err := strconv.Atoi(raw)
+    if err != nil || offset < 0 {
+        return 0, fmt.Errorf("offset must be a non-negative integer")
+    }
+    return offset, nil
+}
+
+// parseFlag reads a boolean filter such as ?archived=false; empty means
+// the filter is not applied.
+func parseFlag(raw string) (*bool, error) {
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+        return nil, nil
+    }
+    value, err := strconv.ParseBool(raw)
+    if err != nil {
+        return nil, err
+    }
+    return &value, nil
+}
+
+// parseUpdatedBound reads ?updatedFrom= or ?updatedTo=, an RFC 3339 time
+// or a day in UTC. A day given as the end of the range includes all of it.
+func parseUpdatedBound(raw string, end bool) (time.Time, error) {
+    raw = strings.TrimSpace(raw)
+    if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+        return t, nil
+    }
+    day, err := time.Parse("2006-01-02", raw)
+    if err != nil {
+        return time.Time{}, err
+    }
+    if end {
+        day = day.AddDate(0, 0, 1)
+    }
+    return day, nil
+}
+
+func pagePayload(page storage.Page) map[string]any {
+    payload := map[string]any{
+        "conversations": page.Conversations,
+        "total":         page.Total,
+    }
+    if page.NextCursor != "" {
+        payload["nextCursor"] = page.NextCursor
+    }
+    return payload
 }
 
-func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
-    w.Header().Set("Allow", strings.Join(allowed, ", "))
-    w.WriteHeader(http.StatusMethodNotAllowed)
+// maxJSONBody bounds the body of a JSON request, but for a batch.
+const maxJSONBody = 16 << 20
+
+// longRequestTimeout replaces the server's 15-second read and write
+// timeouts for requests that move a lot of data: a batch of conversations,
+// an export, an attachment, or the backup taken before deleting everything.
+const longRequestTimeout = 30 * time.Minute
+
+// longRequest wraps a handler whose requests may need longRequestTimeout.
+func longRequest(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        extendDeadlines(w, longRequestTimeout)
+        next(w, r)
+    }
 }
 
+// extendDeadlines gives the rest of a request timeout to read its body and
+// write its response.
+func extendDeadlines(w http.ResponseWriter, timeout time.Duration) {
+    deadline := time.Now().Add(timeout)
+    controller := http.NewResponseController(w)
+    _ = controller.SetReadDeadline(deadline)
+    _ = controller.SetWriteDeadline(deadline)
+}
+
+// decodeJSON decodes a request body of at most maxJSONBody bytes into dest.
 func decodeJSON(body io.ReadCloser, dest any) error {
+    return decodeJSONLimit(body, dest, maxJSONBody)
+}
+
+// decodeJSONLimit is decodeJSON for a body of at most limit bytes.
+func decodeJSONLimit(body io.ReadCloser, dest any, limit int64) error {
     defer body.Close()
-    decoder := json.NewDecoder(body)
+    decoder := json.NewDecoder(http.MaxBytesReader(nil, body, limit))
     decoder.DisallowUnknownFields()
     return decoder.Decode(dest)
 }
-
-func newID() string {
-    buf := make([]byte, 16)
-    if _, err := rand.Read(buf); err != nil {
-        return fmt.Sprintf("%d", time.Now().UTC().UnixNano())
-    }
-    return hex.EncodeToString(buf)
-}
@@ -0,0 +1,169 @@
+package api
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "slices"
+    "strings"
+    "time"
+
+    "zatGPT/internal/export"
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+)
+
+// sharePath is where the public page of a share is served; it sits outside
+// /api/ so neither the API key nor the write token guards it.
+const sharePath = "/share/"
+
+// shareRequest is the body of POST /api/conversations/{id}/share and PATCH
+// /api/shares/{token}. ExpiresIn is a Go duration such as "72h"; left out,
+// the link never expires.
+type shareRequest struct {
+    ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+// shareInfo is a share with the path of its public page.
+type shareInfo struct {
+    models.Share
+    Path    string `json:"path"`
+    Expired bool   `json:"expired,omitempty"`
+}
+
+type shareList struct {
+    Shares []shareInfo `json:"shares"`
+}
+
+func newShareInfo(share models.Share, now time.Time) shareInfo {
+    return shareInfo{Share: share, Path: sharePath + share.Token, Expired: share.Expired(now)}
+}
+
+// decodeShareRequest reads the expiry of a share request; an empty body
+// asks for a link that never expires. It writes the error response itself
+// and reports false when the body is invalid.
+func decodeShareRequest(w http.ResponseWriter, r *http.Request, now time.Time) (time.Time, bool) {
+    var payload shareRequest
+    if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
+        writeError(w, http.StatusBadRequest, err)
+        return time.Time{}, false
+    }
+    raw := strings.TrimSpace(payload.ExpiresIn)
+    if raw == "" {
+        return time.Time{}, true
+    }
+    d, err := time.ParseDuration(raw)
+    if err != nil || d <= 0 {
+        writeValidationError(w, fieldError{Field: "expiresIn", Message: "must be a positive duration such as 24h or 30m"})
+        return time.Time{}, false
+    }
+    return now.Add(d), true
+}
+
+// conversationShare serves POST /api/conversations/{id}/share: it creates
+// a public link to the conversation, optionally expiring.
+func (s *Server) conversationShare(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    now := time.Now().UTC()
+    expiresAt, ok := decodeShareRequest(w, r, now)
+    if !ok {
+        return
+    }
+    share, err := s.store.Share(id, expiresAt)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    w.Header().Set("Location", sharePath+share.Token)
+    writeJSON(w, http.StatusCreated, newShareInfo(share, now))
+}
+
+// handleShares serves GET /api/shares, every share (or, with
+// ?conversation=, one conversation's) newest first, PATCH
+// /api/shares/{token}, which moves a share's expiry, and DELETE
+// /api/shares/{token}, which revokes it.
+func (s *Server) handleShares(w http.ResponseWriter, r *http.Request) {
+    token := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/shares"), "/")
+    now := time.Now().UTC()
+
+    if token == "" {
+        if r.Method != http.MethodGet && r.Method != http.MethodHead {
+            methodNotAllowed(w, http.MethodGet)
+            return
+        }
+        shares := s.store.Shares(strings.TrimSpace(r.URL.Query().Get("conversation")))
+        list := shareList{Shares: make([]shareInfo, len(shares))}
+        for i, share := range shares {
+            list.Shares[i] = newShareInfo(share, now)
+        }
+        writeJSON(w, http.StatusOK, list)
+        return
+    }
+
+    switch r.Method {
+    case http.MethodPatch:
+        expiresAt, ok := decodeShareRequest(w, r, now)
+        if !ok {
+            return
+        }
+        share, err := s.store.SetShareExpiry(token, expiresAt)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        writeJSON(w, http.StatusOK, newShareInfo(share, now))
+    case http.MethodDelete:
+        if err := s.store.Unshare(token); err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        methodNotAllowed(w, http.MethodPatch, http.MethodDelete)
+    }
+}
+
+// handleSharePage serves GET /share/{token}: the shared conversation as a
+// standalone, read-only HTML page, the same as its HTML export. Tags and
+// system messages (Custom Instructions) stay private. An unknown or revoked
+// token gets 404 and an expired one 410.
+func (s *Server) handleSharePage(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", "GET, HEAD")
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    // The token is the only secret here: keep it out of Referer headers,
+    // caches and search engines.
+    w.Header().Set("Referrer-Policy", "no-referrer")
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+    token := strings.Trim(strings.TrimPrefix(r.URL.Path, sharePath), "/")
+    _, convo, err := s.store.SharedConversation(token, time.Now().UTC())
+    switch {
+    case errors.Is(err, storage.ErrShareNotFound):
+        http.Error(w, "This link does not exist or has been revoked.", http.StatusNotFound)
+        return
+    case errors.Is(err, storage.ErrShareExpired):
+        http.Error(w, "This link has expired.", http.StatusGone)
+        return
+    case err != nil:
+        http.Error(w, "internal error", http.StatusInternalServerError)
+        return
+    }
+
+    convo.Tags = nil
+    convo.Messages = slices.DeleteFunc(slices.Clone(convo.Messages), func(msg models.Message) bool {
+        return msg.Author == models.AuthorSystem
+    })
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+    if r.Method == http.MethodHead {
+        return
+    }
+    _ = export.WriteConversation(w, export.FormatHTML, convo)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "zatGPT/internal/stats"
+    "zatGPT/internal/telemetry"
+)
+
+// handleStats serves the archive-wide totals: conversations, messages by
+// role, activity per month, the busiest days and the model mix.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    ctx, span := telemetry.Start(r.Context(), "store.Stats")
+    overview, err := s.store.Stats(ctx)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, overview)
+}
+
+// SetTokenPrices replaces the API prices /api/stats/tokens estimates costs
+// with (stats.DefaultPrices by default).
+func (s *Server) SetTokenPrices(prices map[string]stats.Price) {
+    s.prices = prices
+}
+
+// handleStatsTokens serves the archive's token counts per role, model and
+// month with the API-equivalent cost of each model's replies.
+func (s *Server) handleStatsTokens(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    ctx, span := telemetry.Start(r.Context(), "store.TokenStats")
+    report, err := s.store.TokenStats(ctx, s.prices)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, report)
+}
+
+// handleStatsCSV serves the analytics report as CSV. ?report= picks sections
+// (months, models, tags; comma-separated), defaulting to all three.
+func (s *Server) handleStatsCSV(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    var sections []string
+    if raw := strings.TrimSpace(r.URL.Query().Get("report")); raw != "" {
+        for _, section := range strings.Split(raw, ",") {
+            section = strings.ToLower(strings.TrimSpace(section))
+            switch section {
+            case "months", "models", "tags":
+                sections = append(sections, section)
+            default:
+                writeErrorString(w, http.StatusBadRequest, "report must be months, models, or tags")
+                return
+            }
+        }
+    }
+
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    report := stats.Compute(conversations)
+
+    w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+    w.Header().Set("Content-Disposition", `attachment; filename="stats.csv"`)
+    w.WriteHeader(http.StatusOK)
+    _ = stats.WriteCSV(w, report, sections...)
+}
+
+const (
+    defaultTermLimit = 100
+    maxTermLimit     = 1000
+)
+
+// termsResponse is the body of GET /api/stats/terms.
+type termsResponse struct {
+    From string `json:"from,omitempty"`
+    To   string `json:"to,omitempty"`
+    Role string `json:"role,omitempty"`
+    stats.TermReport
+}
+
+// handleStatsTerms serves the most frequent meaningful words for a word
+// cloud. ?from= and ?to= (YYYY-MM-DD, both inclusive) bound the messages by
+// date, ?role=user|assistant counts one side only, ?top= caps the list.
+func (s *Server) handleStatsTerms(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    query := r.URL.Query()
+    opts := stats.TermOptions{Top: defaultTermLimit}
+    var invalid []fieldError
+
+    if raw := query.Get("from"); raw != "" {
+        t, err := time.Parse("2006-01-02", raw)
+        if err != nil {
+            invalid = append(invalid, fieldError{Field: "from", Message: "must be a date (YYYY-MM-DD)"})
+        }
+        opts.From = t
+    }
+    if raw := query.Get("to"); raw != "" {
+        t, err := time.Parse("2006-01-02", raw)
+        if err != nil {
+            invalid = append(invalid, fieldError{Field: "to", Message: "must be a date (YYYY-MM-DD)"})
+        }
+        opts.To = t.AddDate(0, 0, 1)
+    }
+    if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+        invalid = append(invalid, fieldError{Field: "to", Message: "must not be before from"})
+    }
+    switch role := query.Get("role"); role {
+    case "", "user", "assistant":
+        opts.Role = role
+    default:
+        invalid = append(invalid, fieldError{Field: "role", Message: "must be user or assistant"})
+    }
+    if raw := query.Get("top"); raw != "" {
+        top, err := strconv.Atoi(raw)
+        if err != nil || top < 1 || top > maxTermLimit {
+            invalid = append(invalid, fieldError{Field: "top", Message: "must be an integer between 1 and " + strconv.Itoa(maxTermLimit)})
+        }
+        opts.Top = top
+    }
+    if len(invalid) > 0 {
+        writeValidationError(w, invalid...)
+        return
+    }
+
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, termsResponse{
+        From:       query.Get("from"),
+        To:         query.Get("to"),
+        Role:       opts.Role,
+        TermReport: stats.Terms(conversations, opts),
+    })
+}
+
+// handleStatsReview serves the year-in-review report. ?year= defaults to the
+// current year; ?format= is json (default), markdown or html.
+func (s *Server) handleStatsReview(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    query := r.URL.Query()
+    var invalid []fieldError
+
+    year := time.Now().UTC().Year()
+    if raw := query.Get("year"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil || parsed < 1970 || parsed > 9999 {
+            invalid = append(invalid, fieldError{Field: "year", Message: "must be a four-digit year"})
+        }
+        year = parsed
+    }
+    format := strings.ToLower(query.Get("format"))
+    switch format {
+    case "":
+        format = "json"
+    case "json", "markdown", "md", "html":
+    default:
+        invalid = append(invalid, fieldError{Field: "format", Message: "must be " + strings.Join(stats.ReviewFormats, ", ")})
+    }
+    if len(invalid) > 0 {
+        writeValidationError(w, invalid...)
+        return
+    }
+
+    conversations, err := s.find(r, nil)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    review := stats.ComputeReview(conversations, year)
+    if format == "json" {
+        writeJSON(w, http.StatusOK, review)
+        return
+    }
+    w.Header().Set("Content-Type", stats.ReviewContentType(format))
+    w.WriteHeader(http.StatusOK)
+    _ = stats.WriteReview(w, format, review)
+}
+
+// handleStatsUsage reports how much of the store is in use and the limits
+// the server was started with.
+func (s *Server) handleStatsUsage(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    writeJSON(w, http.StatusOK, s.store.Usage())
+}
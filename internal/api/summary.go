@@ -0,0 +1,245 @@
+package api
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/summary"
+)
+
+const (
+    // summaryWait is how long a request waits for a fresh summary before
+    // answering 202; it stays under the server's write timeout.
+    summaryWait = 10 * time.Second
+    // summaryTimeout bounds one background summarization.
+    summaryTimeout = 2 * time.Minute
+    summaryRetry   = "5"
+)
+
+// SetSummarizer replaces the heuristic summarizer used by
+// /api/conversations/{id}/summary.
+func (s *Server) SetSummarizer(summarizer summary.Summarizer) {
+    if summarizer != nil {
+        s.summarizer = summarizer
+    }
+}
+
+// summaryJobs runs at most one summarization per conversation and method at
+// a time; concurrent requests wait on the same job.
+type summaryJobs struct {
+    mu      sync.Mutex
+    running map[string]*summaryJob
+}
+
+type summaryJob struct {
+    done   chan struct{}
+    result models.Summary
+    err    error
+}
+
+func newSummaryJobs() *summaryJobs {
+    return &summaryJobs{running: make(map[string]*summaryJob)}
+}
+
+// conversationSummary serves GET /api/conversations/{id}/summary. Summaries
+// are generated on first request and cached until the conversation changes;
+// ?refresh=true forces a new one and ?method= picks another summarizer
+// (see pickSummarizer), such as heuristic to skip a configured LLM.
+// Generation that outlasts summaryWait continues in the background and the
+// request gets 202 with Retry-After.
+func (s *Server) conversationSummary(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    query := r.URL.Query()
+    summarizer, ok := s.pickSummarizer(w, query.Get("method"))
+    if !ok {
+        return
+    }
+    refresh := false
+    if raw := query.Get("refresh"); raw != "" {
+        parsed, err := strconv.ParseBool(raw)
+        if err != nil {
+            writeValidationError(w, fieldError{Field: "refresh", Message: "must be true or false"})
+            return
+        }
+        refresh = parsed
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    if cached, ok := s.store.CachedSummary(id, summarizer.Method()); ok && !refresh && cached.SourceUpdatedAt.Equal(convo.UpdatedAt) {
+        writeJSON(w, http.StatusOK, cached)
+        return
+    }
+
+    job := s.summaries.start(s.store, summarizer, convo)
+    timer := time.NewTimer(summaryWait)
+    defer timer.Stop()
+
+    select {
+    case <-job.done:
+    case <-timer.C:
+        w.Header().Set("Retry-After", summaryRetry)
+        writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending", "conversationId": id})
+        return
+    case <-r.Context().Done():
+        return
+    }
+
+    if job.err != nil {
+        if job.err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, job.err)
+            return
+        }
+        writeErrorBody(w, http.StatusBadGateway, errorBody{Code: CodeSummarizerFailed, Message: job.err.Error()})
+        return
+    }
+    writeJSON(w, http.StatusOK, job.result)
+}
+
+// pickSummarizer resolves the method a request asks for: the server's
+// summarizer when empty or named, otherwise one that runs without a model.
+// An unknown method is answered with a validation error and ok is false.
+func (s *Server) pickSummarizer(w http.ResponseWriter, method string) (summary.Summarizer, bool) {
+    method = strings.TrimSpace(method)
+    if method == "" || method == s.summarizer.Method() {
+        return s.summarizer, true
+    }
+    if local, ok := summary.Local(method); ok {
+        return local, true
+    }
+    allowed := "must be heuristic, first-message, first-sentences[:N] or longest-message"
+    if strings.HasPrefix(s.summarizer.Method(), "llm:") {
+        allowed += ", or " + s.summarizer.Method()
+    }
+    writeValidationError(w, fieldError{Field: "method", Message: allowed})
+    return nil, false
+}
+
+// summarizeRequest is the body of POST /api/conversations/{id}/summarize.
+// Method picks the summarizer: heuristic, first-message, first-sentences
+// (or first-sentences:N), longest-message, or the server's LLM by its
+// method name; left out, the server's summarizer.
+type summarizeRequest struct {
+    Method string `json:"method,omitempty"`
+}
+
+// conversationSummarize serves POST /api/conversations/{id}/summarize: it
+// replaces the conversation's one-line summary, which imports take from
+// the opening message, with a generated one, and answers with the
+// conversation. The generated summary is cached as GET .../summary caches
+// it, and a slow summarizer is waited on the same way, with 202 and
+// Retry-After; posting again then picks up the finished summary.
+func (s *Server) conversationSummarize(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    loc, ok := s.timezone(w, r)
+    if !ok {
+        return
+    }
+    var payload summarizeRequest
+    if err := decodeJSON(r.Body, &payload); err != nil && err != io.EOF {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+    summarizer, ok := s.pickSummarizer(w, payload.Method)
+    if !ok {
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+
+    text := ""
+    if cached, ok := s.store.CachedSummary(id, summarizer.Method()); ok && cached.SourceUpdatedAt.Equal(convo.UpdatedAt) {
+        text = cached.Text
+    } else {
+        job := s.summaries.start(s.store, summarizer, convo)
+        timer := time.NewTimer(summaryWait)
+        defer timer.Stop()
+
+        select {
+        case <-job.done:
+        case <-timer.C:
+            w.Header().Set("Retry-After", summaryRetry)
+            writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending", "conversationId": id})
+            return
+        case <-r.Context().Done():
+            return
+        }
+        if job.err != nil {
+            if job.err == storage.ErrNotFound {
+                writeError(w, http.StatusNotFound, job.err)
+                return
+            }
+            writeErrorBody(w, http.StatusBadGateway, errorBody{Code: CodeSummarizerFailed, Message: job.err.Error()})
+            return
+        }
+        text = job.result.Text
+    }
+
+    convo, err = s.store.UpdateSummary(id, text)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, convo.InZone(loc))
+}
+
+// start returns the running job for the conversation, or launches one. The
+// job outlives the request that started it so a slow summarizer still
+// fills the cache.
+func (j *summaryJobs) start(store *storage.Store, summarizer summary.Summarizer, convo models.Conversation) *summaryJob {
+    key := convo.ID + "\x00" + summarizer.Method()
+
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if job, ok := j.running[key]; ok {
+        return job
+    }
+    job := &summaryJob{done: make(chan struct{})}
+    j.running[key] = job
+
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+        defer cancel()
+
+        text, err := summarizer.Summarize(ctx, convo)
+        if err == nil {
+            job.result = models.Summary{
+                ConversationID:  convo.ID,
+                Text:            text,
+                Method:          summarizer.Method(),
+                SourceUpdatedAt: convo.UpdatedAt,
+                GeneratedAt:     time.Now().UTC(),
+            }
+            err = store.SaveSummary(job.result)
+        }
+        job.err = err
+
+        j.mu.Lock()
+        delete(j.running, key)
+        j.mu.Unlock()
+        close(job.done)
+    }()
+    return job
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+    "net/http"
+
+    "zatGPT/internal/chatsync"
+    "zatGPT/internal/models"
+)
+
+const syncHistoryLimit = 20
+
+// SetSync attaches the background ChatGPT sync so its state is reported by
+// /api/sync/status.
+func (s *Server) SetSync(scheduler *chatsync.Scheduler) {
+    s.sync = scheduler
+}
+
+// handleSyncStatus reports the sync schedule, the latest run and the recent
+// sync history (including runs started from the importer CLI).
+func (s *Server) handleSyncStatus(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    history := []models.ImportRecord{}
+    for _, record := range s.store.Imports() {
+        if chatsync.IsSync(record) {
+            history = append(history, record)
+            if len(history) == syncHistoryLimit {
+                break
+            }
+        }
+    }
+
+    status := s.sync.Status()
+    if status.LastRun == nil && len(history) > 0 {
+        status.LastRun = &history[0]
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "status":  status,
+        "history": history,
+    })
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/query"
+    "zatGPT/internal/storage"
+    "zatGPT/internal/telemetry"
+)
+
+// bulkTagRequest is the body of POST /api/conversations/bulk-tag: the
+// conversations are picked by ids or, without ids, by a search query.
+type bulkTagRequest struct {
+    IDs    []string `json:"ids,omitempty"`
+    Query  string   `json:"query,omitempty"`
+    Add    []string `json:"add,omitempty"`
+    Remove []string `json:"remove,omitempty"`
+}
+
+// tagsRequest is the body of POST and DELETE /api/conversations/{id}/tags.
+type tagsRequest struct {
+    Tags []string `json:"tags"`
+}
+
+func (s *Server) handleBulkTag(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    var payload bulkTagRequest
+
+    if err := decodeJSON(r.Body, &payload); err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    payload.Query = strings.TrimSpace(payload.Query)
+    if len(payload.IDs) == 0 && payload.Query == "" {
+        writeErrorString(w, http.StatusBadRequest, "either ids or query is required")
+        return
+    }
+    if len(payload.Add) == 0 && len(payload.Remove) == 0 {
+        writeErrorString(w, http.StatusBadRequest, "at least one tag to add or remove is required")
+        return
+    }
+
+    var match func(models.Conversation) bool
+    if len(payload.IDs) == 0 {
+        q, err := query.Parse(payload.Query)
+        if err != nil {
+            writeError(w, http.StatusBadRequest, err)
+            return
+        }
+        match = q.Match
+    }
+
+    _, span := telemetry.Start(r.Context(), "store.BulkTag")
+    result, err := s.store.BulkTag(payload.IDs, match, payload.Add, payload.Remove)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, result)
+}
+
+// handleTags serves GET /api/tags: every tag in use with the number of
+// conversations carrying it, most used first.
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"tags": s.store.Tags()})
+}
+
+// conversationTags serves /api/conversations/{id}/tags. GET lists the
+// conversation's tags, POST adds the {"tags": [...]} of the body and DELETE
+// removes them, or the single tag named in /tags/{tag}. Changes answer with
+// the resulting tags.
+func (s *Server) conversationTags(w http.ResponseWriter, r *http.Request, id, tag string) {
+    switch {
+    case tag != "" && r.Method != http.MethodDelete:
+        methodNotAllowed(w, http.MethodDelete)
+        return
+    case r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete:
+        methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+        return
+    }
+
+    var tags []string
+    switch {
+    case r.Method == http.MethodGet:
+    case tag != "":
+        tags = []string{tag}
+    default:
+        var payload tagsRequest
+        if err := decodeJSON(r.Body, &payload); err != nil {
+            writeError(w, http.StatusBadRequest, err)
+            return
+        }
+        tags = storage.NormalizeTags(payload.Tags)
+        if len(tags) == 0 {
+            writeValidationError(w, fieldError{Field: "tags", Message: "must contain at least one tag"})
+            return
+        }
+    }
+
+    if len(tags) > 0 {
+        var add, remove []string
+        if r.Method == http.MethodPost {
+            add = tags
+        } else {
+            remove = tags
+        }
+        _, span := telemetry.Start(r.Context(), "store.BulkTag")
+        result, err := s.store.BulkTag([]string{id}, nil, add, remove)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        if result.Matched == 0 {
+            writeError(w, http.StatusNotFound, storage.ErrNotFound)
+            return
+        }
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    if convo.Tags == nil {
+        convo.Tags = []string{}
+    }
+    writeJSON(w, http.StatusOK, map[string]any{"id": convo.ID, "tags": convo.Tags})
+}
+
+// splitList flattens repeated and comma-separated query values
+// (?tag=a&tag=b or ?tag=a,b), dropping empty ones.
+func splitList(values []string) []string {
+    var out []string
+    for _, value := range values {
+        for _, part := range strings.Split(value, ",") {
+            if part = strings.TrimSpace(part); part != "" {
+                out = append(out, part)
+            }
+        }
+    }
+    return out
+}
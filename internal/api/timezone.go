@@ -0,0 +1,52 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+    "time"
+
+    "zatGPT/internal/models"
+)
+
+// TimezoneHeader names the IANA time zone (such as "Europe/Berlin") a
+// client wants dates shown in, for clients that would rather not add ?tz=
+// to every URL. The web UI sends the browser's.
+const TimezoneHeader = "Time-Zone"
+
+// SetTimezone sets the zone conversation dates and times are shown in when
+// a request asks for none; nil, the default, is UTC.
+func (s *Server) SetTimezone(loc *time.Location) {
+    s.location = loc
+}
+
+// timezone resolves the zone a request wants dates in: ?tz=, then the
+// Time-Zone header, then the server's. An unknown zone is answered with a
+// validation error and ok is false.
+func (s *Server) timezone(w http.ResponseWriter, r *http.Request) (loc *time.Location, ok bool) {
+    name := strings.TrimSpace(r.URL.Query().Get("tz"))
+    if name == "" {
+        name = strings.TrimSpace(r.Header.Get(TimezoneHeader))
+    }
+    if name == "" {
+        if s.location == nil {
+            return time.UTC, true
+        }
+        return s.location, true
+    }
+    // LoadLocation reads "Local" as the server's own zone, which is no
+    // business of the client's.
+    loc, err := time.LoadLocation(name)
+    if err != nil || name == "Local" {
+        writeValidationError(w, fieldError{Field: "tz", Message: "must be an IANA time zone such as Europe/Berlin"})
+        return nil, false
+    }
+    return loc, true
+}
+
+// inZone shows items in loc, in place.
+func inZone(items []models.Conversation, loc *time.Location) []models.Conversation {
+    for i := range items {
+        items[i] = items[i].InZone(loc)
+    }
+    return items
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+    "net/http"
+    "strconv"
+
+    "zatGPT/internal/topics"
+)
+
+// defaultTopicLimit is how many topics GET /api/topics returns without ?top=.
+const defaultTopicLimit = 50
+
+// topicsResponse is the body of GET /api/topics.
+type topicsResponse struct {
+    // Conversations counts the conversations that have topics.
+    Conversations int            `json:"conversations"`
+    Topics        []topics.Topic `json:"topics"`
+}
+
+// handleTopics serves the topics most common across the archive, each with
+// the number of conversations picked for it at import. ?top= caps the list.
+func (s *Server) handleTopics(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    top := defaultTopicLimit
+    if raw := r.URL.Query().Get("top"); raw != "" {
+        n, err := strconv.Atoi(raw)
+        if err != nil || n < 1 || n > maxTermLimit {
+            writeValidationError(w, fieldError{Field: "top", Message: "must be an integer between 1 and " + strconv.Itoa(maxTermLimit)})
+            return
+        }
+        top = n
+    }
+
+    list, conversations := s.store.Topics(top)
+    writeJSON(w, http.StatusOK, topicsResponse{Conversations: conversations, Topics: list})
+}
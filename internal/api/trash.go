@@ -0,0 +1,80 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+    "time"
+
+    "zatGPT/internal/telemetry"
+)
+
+// handleTrash serves deleted conversations: GET /api/trash lists them,
+// POST /api/trash/{id}/restore puts one back in the list, and DELETE on
+// /api/trash/{id} or /api/trash purges one or all of them for good.
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+    rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/trash"), "/")
+    id, sub, _ := strings.Cut(rest, "/")
+
+    switch {
+    case id == "":
+        switch r.Method {
+        case http.MethodGet:
+            loc, ok := s.timezone(w, r)
+            if !ok {
+                return
+            }
+            items := s.store.Trash()
+            writeJSON(w, http.StatusOK, map[string]any{
+                "conversations": inZone(items, loc),
+                "total":         len(items),
+            })
+        case http.MethodDelete:
+            s.purgeTrash(w, r)
+        default:
+            methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+        }
+    case sub == "restore":
+        if r.Method != http.MethodPost {
+            methodNotAllowed(w, http.MethodPost)
+            return
+        }
+        s.restoreTrashed(w, r, id)
+    case sub == "":
+        if r.Method != http.MethodDelete {
+            methodNotAllowed(w, http.MethodDelete)
+            return
+        }
+        _, span := telemetry.Start(r.Context(), "store.Purge")
+        err := s.store.Purge(id)
+        telemetry.End(span, err)
+        if err != nil {
+            writeError(w, statusFor(err), err)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        writeNotFound(w)
+    }
+}
+
+func (s *Server) restoreTrashed(w http.ResponseWriter, r *http.Request, id string) {
+    _, span := telemetry.Start(r.Context(), "store.RestoreTrashed")
+    convo, err := s.store.RestoreTrashed(id)
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, convo)
+}
+
+func (s *Server) purgeTrash(w http.ResponseWriter, r *http.Request) {
+    _, span := telemetry.Start(r.Context(), "store.PurgeTrash")
+    purged, err := s.store.PurgeTrash(time.Time{})
+    telemetry.End(span, err)
+    if err != nil {
+        writeError(w, statusFor(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+}
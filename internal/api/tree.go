@@ -0,0 +1,307 @@
+package api
+
+import (
+    "net/http"
+    "slices"
+    "time"
+
+    "zatGPT/internal/models"
+    "zatGPT/internal/storage"
+)
+
+type treeNode struct {
+    ID          string   `json:"id"`
+    ParentID    string   `json:"parentId,omitempty"`
+    Children    []string `json:"children"`
+    Author      string   `json:"author,omitempty"`
+    Preview     string   `json:"preview,omitempty"`
+    CreatedAt   string   `json:"createdAt,omitempty"`
+    Canonical   bool     `json:"canonical"`
+    BranchIndex int      `json:"branchIndex"`
+}
+
+// conversationTree returns the edit/regeneration graph of a conversation.
+// Linear conversations are reported as a single canonical chain.
+func (s *Server) conversationTree(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    content := make(map[string]string, len(convo.Messages))
+    for _, msg := range convo.Messages {
+        content[msg.ID] = msg.Content
+    }
+
+    source := convo.Tree
+    if len(source) == 0 {
+        source = linearTree(convo.Messages)
+    }
+
+    nodes := make([]treeNode, 0, len(source))
+    branchPoints := 0
+    for _, node := range source {
+        text := node.Content
+        if text == "" {
+            text = content[node.ID]
+        }
+        item := treeNode{
+            ID:          node.ID,
+            ParentID:    node.ParentID,
+            Children:    node.Children,
+            Author:      node.Author,
+            Preview:     preview(text, 160),
+            Canonical:   node.Canonical,
+            BranchIndex: node.BranchIndex,
+        }
+        if item.Children == nil {
+            item.Children = []string{}
+        }
+        if len(node.Children) > 1 {
+            branchPoints++
+        }
+        if !node.CreatedAt.IsZero() {
+            item.CreatedAt = node.CreatedAt.UTC().Format(time.RFC3339)
+        }
+        nodes = append(nodes, item)
+    }
+
+    writeJSON(w, http.StatusOK, map[string]any{
+        "conversationId": convo.ID,
+        "branchPoints":   branchPoints,
+        "nodes":          nodes,
+    })
+}
+
+func linearTree(messages []models.Message) []models.MessageNode {
+    nodes := make([]models.MessageNode, len(messages))
+    for i, msg := range messages {
+        nodes[i] = models.MessageNode{
+            ID:        msg.ID,
+            Author:    msg.Author,
+            CreatedAt: msg.CreatedAt,
+            Canonical: true,
+        }
+        if i > 0 {
+            nodes[i].ParentID = messages[i-1].ID
+        }
+        if i+1 < len(messages) {
+            nodes[i].Children = []string{messages[i+1].ID}
+        }
+    }
+    return nodes
+}
+
+func preview(text string, limit int) string {
+    runes := []rune(text)
+    if len(runes) <= limit {
+        return text
+    }
+    return string(runes[:limit]) + "..."
+}
+
+// branchInfo describes one branch of a conversation: the path from the root
+// to a leaf of the tree. ForkID is the last node the branch shares with the
+// canonical path, empty for the canonical branch itself.
+type branchInfo struct {
+    LeafID       string `json:"leafId"`
+    ForkID       string `json:"forkId,omitempty"`
+    Canonical    bool   `json:"canonical"`
+    MessageCount int    `json:"messageCount"`
+    Preview      string `json:"preview,omitempty"`
+    UpdatedAt    string `json:"updatedAt,omitempty"`
+}
+
+type branchConversation struct {
+    models.Conversation
+    Branch branchInfo `json:"branch"`
+}
+
+// conversationBranches serves /api/conversations/{id}/branches, listing
+// every branch of the conversation, and /branches/{nodeId}, which returns
+// the conversation with the messages of the branch through that node in
+// place of the canonical ones. Below the node the branch follows the
+// canonical child where there is one and otherwise the latest.
+func (s *Server) conversationBranches(w http.ResponseWriter, r *http.Request, id, nodeID string) {
+    if r.Method != http.MethodGet {
+        methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    convo, err := s.store.Get(id)
+    if err != nil {
+        if err == storage.ErrNotFound {
+            writeError(w, http.StatusNotFound, err)
+            return
+        }
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    tree := newMessageTree(convo)
+    if nodeID == "" {
+        branches := make([]branchInfo, 0)
+        for _, node := range tree.nodes {
+            if len(node.Children) == 0 {
+                branches = append(branches, tree.describe(tree.pathTo(node.ID)))
+            }
+        }
+        writeJSON(w, http.StatusOK, map[string]any{
+            "conversationId": convo.ID,
+            "branches":       branches,
+        })
+        return
+    }
+
+    if _, ok := tree.byID[nodeID]; !ok {
+        writeErrorString(w, http.StatusNotFound, "message not found")
+        return
+    }
+    path := tree.extend(tree.pathTo(nodeID))
+    convo.Messages = tree.messages(path)
+    convo.Tree = nil
+    writeJSON(w, http.StatusOK, branchConversation{
+        Conversation: convo,
+        Branch:       tree.describe(path),
+    })
+}
+
+// messageTree indexes a conversation's nodes for walking its branches.
+type messageTree struct {
+    nodes      []models.MessageNode
+    byID       map[string]models.MessageNode
+    transcript map[string]models.Message
+}
+
+func newMessageTree(convo models.Conversation) messageTree {
+    tree := messageTree{
+        nodes:      convo.Tree,
+        byID:       make(map[string]models.MessageNode, len(convo.Tree)),
+        transcript: make(map[string]models.Message, len(convo.Messages)),
+    }
+    if len(tree.nodes) == 0 {
+        tree.nodes = linearTree(convo.Messages)
+    }
+    for _, node := range tree.nodes {
+        tree.byID[node.ID] = node
+    }
+    for _, msg := range convo.Messages {
+        tree.transcript[msg.ID] = msg
+    }
+    return tree
+}
+
+// pathTo returns the nodes from the root down to id.
+func (t messageTree) pathTo(id string) []models.MessageNode {
+    var path []models.MessageNode
+    seen := make(map[string]bool)
+    for node, ok := t.byID[id]; ok && !seen[node.ID]; node, ok = t.byID[node.ParentID] {
+        seen[node.ID] = true
+        path = append(path, node)
+    }
+    slices.Reverse(path)
+    return path
+}
+
+// extend continues path down to a leaf, taking the canonical child at each
+// step, or the last (most recent) one off the canonical path.
+func (t messageTree) extend(path []models.MessageNode) []models.MessageNode {
+    seen := make(map[string]bool, len(path))
+    for _, node := range path {
+        seen[node.ID] = true
+    }
+    for len(path) > 0 {
+        var next models.MessageNode
+        found := false
+        for _, childID := range path[len(path)-1].Children {
+            child, ok := t.byID[childID]
+            if !ok || seen[childID] {
+                continue
+            }
+            next, found = child, true
+            if child.Canonical {
+                break
+            }
+        }
+        if !found {
+            break
+        }
+        seen[next.ID] = true
+        path = append(path, next)
+    }
+    return path
+}
+
+// messages returns the user and assistant messages along path. Canonical
+// nodes take theirs from the transcript; the others carry their own text.
+func (t messageTree) messages(path []models.MessageNode) []models.Message {
+    var out []models.Message
+    for _, node := range path {
+        if msg, ok := t.transcript[node.ID]; ok {
+            out = append(out, msg)
+            continue
+        }
+        if node.Content == "" || (node.Author != "user" && node.Author != "assistant") {
+            continue
+        }
+        out = append(out, models.Message{
+            ID:        node.ID,
+            Author:    node.Author,
+            Content:   node.Content,
+            Model:     node.Model,
+            CreatedAt: node.CreatedAt,
+        })
+    }
+    return out
+}
+
+func (t messageTree) describe(path []models.MessageNode) branchInfo {
+    messages := t.messages(path)
+    info := branchInfo{MessageCount: len(messages), Canonical: true}
+    if len(path) > 0 {
+        info.LeafID = path[len(path)-1].ID
+    }
+    for i, node := range path {
+        if node.Canonical {
+            continue
+        }
+        info.Canonical = false
+        if i > 0 {
+            info.ForkID = path[i-1].ID
+        }
+        for _, rest := range path[i:] {
+            if text := t.text(rest); text != "" {
+                info.Preview = preview(text, 160)
+                break
+            }
+        }
+        break
+    }
+    var latest time.Time
+    for _, msg := range messages {
+        if msg.CreatedAt.After(latest) {
+            latest = msg.CreatedAt
+        }
+    }
+    if !latest.IsZero() {
+        info.UpdatedAt = latest.UTC().Format(time.RFC3339)
+    }
+    return info
+}
+
+func (t messageTree) text(node models.MessageNode) string {
+    if node.Content != "" {
+        return node.Content
+    }
+    return t.transcript[node.ID].Content
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "zatGPT/internal/ids"
+    "zatGPT/internal/importer"
+    "zatGPT/internal/models"
+    "zatGPT/internal/redact"
+    "zatGPT/internal/storage"
+)
+
+// maxImportUpload bounds one POST /api/import request, every file included.
+const maxImportUpload = 2 << 30
+
+// importUploadTimeout replaces the server's read and write timeouts for an
+// upload, which are far too short to receive and import a large export.
+const importUploadTimeout = 30 * time.Minute
+
+// Per-file statuses of an upload, matching the importer's -dir report.
+const (
+    uploadImported = "imported"
+    uploadSkipped  = "skipped"
+    uploadIgnored  = "ignored"
+    uploadFailed   = "failed"
+)
+
+// uploadReport is the response of POST /api/import.
+type uploadReport struct {
+    Files             []uploadResult `json:"files"`
+    Imported          int            `json:"imported"`
+    Skipped           int            `json:"skipped"`
+    Ignored           int            `json:"ignored"`
+    Failed            int            `json:"failed"`
+    Conversations     int            `json:"conversations"`
+    Created           int            `json:"created"`
+    Updated           int            `json:"updated"`
+    Unchanged         int            `json:"unchanged"`
+    AttachmentsCopied int            `json:"attachmentsCopied"`
+}
+
+type uploadResult struct {
+    File              string                 `json:"file"`
+    Format            string                 `json:"format,omitempty"`
+    Source            string                 `json:"source,omitempty"`
+    Hash              string                 `json:"hash,omitempty"`
+    Status            string                 `json:"status"`
+    Error             string                 `json:"error,omitempty"`
+    Conversations     int                    `json:"conversations"`
+    Created           int                    `json:"created"`
+    Updated           int                    `json:"updated"`
+    Unchanged         int                    `json:"unchanged"`
+    AttachmentsCopied int                    `json:"attachmentsCopied"`
+    ImportID          string                 `json:"importId,omitempty"`
+    Assets            *importer.AssetCatalog `json:"assets,omitempty"`
+    Changes           *models.ImportChanges  `json:"changes,omitempty"`
+    Report            *models.ImportReport   `json:"report,omitempty"`
+
+    // err is the failure behind Error, kept to pick the response status.
+    err error
+}
+
+func (r *uploadReport) add(result uploadResult) {
+    r.Files = append(r.Files, result)
+    switch result.Status {
+    case uploadImported:
+        r.Imported++
+    case uploadSkipped:
+        r.Skipped++
+    case uploadIgnored:
+        r.Ignored++
+    case uploadFailed:
+        r.Failed++
+    }
+    r.Conversations += result.Conversations
+    r.Created += result.Created
+    r.Updated += result.Updated
+    r.Unchanged += result.Unchanged
+    r.AttachmentsCopied += result.AttachmentsCopied
+}
+
+// uploadStore adapts *storage.Store to importer.Destination.
+type uploadStore struct {
+    *storage.Store
+}
+
+func (u uploadStore) Merge(items []models.Conversation) (importer.MergeResult, error) {
+    return importer.Merge(u.Store, items)
+}
+
+// handleImportUpload serves POST /api/import: each "file" part of a
+// multipart/form-data body (a conversations.json, chat.html, export ZIP or
+// any other format the importer reads) is imported into the store and
+// recorded in the import history. Files imported before are skipped unless
+// ?force=true; ?allRoles=true keeps system and tool messages, as the
+// importer's -all-roles does, and ?redact= replaces personal data with
+// placeholders, as its -redact does. The response reports every file like
+// the importer's -dir scan; it is an error only when no file was imported
+// or skipped.
+func (s *Server) handleImportUpload(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    force := false
+    if raw := r.URL.Query().Get("force"); raw != "" {
+        parsed, err := strconv.ParseBool(raw)
+        if err != nil {
+            writeValidationError(w, fieldError{Field: "force", Message: "must be true or false"})
+            return
+        }
+        force = parsed
+    }
+    var opts importer.Options
+    if raw := r.URL.Query().Get("allRoles"); raw != "" {
+        parsed, err := strconv.ParseBool(raw)
+        if err != nil {
+            writeValidationError(w, fieldError{Field: "allRoles", Message: "must be true or false"})
+            return
+        }
+        opts.AllRoles = parsed
+    }
+    if raw := r.URL.Query().Get("redact"); raw != "" {
+        redactor, err := redact.New(redact.ParseKinds(raw), nil)
+        if err != nil {
+            writeValidationError(w, fieldError{Field: "redact", Message: "must be all or some of " + strings.Join(redact.Kinds, ", ")})
+            return
+        }
+        opts.Hooks = []importer.Hook{redactor}
+    }
+
+    extendDeadlines(w, importUploadTimeout)
+
+    r.Body = http.MaxBytesReader(w, r.Body, maxImportUpload)
+    parts, err := r.MultipartReader()
+    if err != nil {
+        writeErrorString(w, http.StatusUnsupportedMediaType, "upload the export as multipart/form-data")
+        return
+    }
+
+    dir, err := os.MkdirTemp("", "zatgpt-upload-")
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    defer os.RemoveAll(dir)
+
+    var report uploadReport
+    for n := 1; ; n++ {
+        part, err := parts.NextPart()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            writeUploadError(w, err)
+            return
+        }
+        if part.FormName() != "file" {
+            part.Close()
+            continue
+        }
+        name := uploadName(part.FileName())
+        path, hash, err := saveUploadPart(filepath.Join(dir, strconv.Itoa(n)), name, part)
+        part.Close()
+        if err != nil {
+            writeUploadError(w, err)
+            return
+        }
+        report.add(s.importUpload(r, name, path, hash, opts, force))
+    }
+
+    if len(report.Files) == 0 {
+        writeValidationError(w, fieldError{Field: "file", Message: "is required"})
+        return
+    }
+    if report.Imported+report.Skipped == 0 {
+        first := report.Files[0]
+        status := http.StatusBadRequest
+        if first.Status == uploadFailed {
+            status = statusFor(first.err)
+        }
+        writeErrorBody(w, status, errorBody{Code: codeFor(status, first.err), Message: first.File + ": " + first.Error})
+        return
+    }
+    writeJSON(w, http.StatusOK, report)
+}
+
+// importUpload imports one saved upload, turning errors into a per-file
+// status so one bad file does not fail the others.
+func (s *Server) importUpload(r *http.Request, name, path, hash string, opts importer.Options, force bool) uploadResult {
+    result := uploadResult{File: name, Hash: hash}
+    if prev, ok := s.store.ImportedHash(hash); ok && !force {
+        result.Format = prev.Format
+        result.Status = uploadSkipped
+        result.Error = "already imported " + prev.FinishedAt.Format(time.RFC3339)
+        return result
+    }
+
+    started := time.Now().UTC()
+    exp, err := importer.Open(path, opts)
+    if err != nil {
+        return result.failed(err)
+    }
+    defer exp.Close()
+    result.Format = string(exp.Format)
+    result.Source = exp.Source
+
+    loaded, err := importer.Load(r.Context(), exp, uploadStore{s.store}, importer.LoadOptions{})
+    result.Conversations = loaded.Conversations
+    result.Created = loaded.Created
+    result.Updated = loaded.Updated
+    result.Unchanged = loaded.Unchanged
+    result.AttachmentsCopied = loaded.AttachmentsCopied
+    if err != nil {
+        return result.failed(err)
+    }
+    result.Assets = loaded.Assets
+
+    record := models.ImportRecord{
+        ID:            ids.New(),
+        File:          name,
+        Format:        result.Format,
+        Source:        result.Source,
+        Hash:          hash,
+        Conversations: result.Conversations,
+        Created:       result.Created,
+        Updated:       result.Updated,
+        Unchanged:     result.Unchanged,
+        Attachments:   result.AttachmentsCopied,
+        Status:        "ok",
+        StartedAt:     started,
+        FinishedAt:    time.Now().UTC(),
+        Changes:       loaded.Changes,
+        Report:        loaded.Report,
+    }
+    if err := s.store.RecordImport(record); err != nil {
+        return result.failed(err)
+    }
+    result.Status = uploadImported
+    result.ImportID = record.ID
+    result.Changes = changeCounts(loaded.Changes)
+    result.Report = loaded.Report
+    return result
+}
+
+func (r uploadResult) failed(err error) uploadResult {
+    r.Status = uploadFailed
+    if errors.Is(err, importer.ErrNotExport) {
+        r.Status = uploadIgnored
+    }
+    r.Error = err.Error()
+    r.err = err
+    return r
+}
+
+// uploadName is the base name of an uploaded file, without any directories
+// the client sent along.
+func uploadName(name string) string {
+    name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+    if name == "" || name == "." || name == "/" {
+        return "upload"
+    }
+    return name
+}
+
+// saveUploadPart writes one uploaded file into its own directory under its
+// original name, so the importer recognizes it as it would on disk, and
+// returns its path and SHA-256 for the import history.
+func saveUploadPart(dir, name string, src io.Reader) (path, hash string, err error) {
+    if err := os.Mkdir(dir, 0o700); err != nil {
+        return "", "", err
+    }
+    path = filepath.Join(dir, name)
+    file, err := os.Create(path)
+    if err != nil {
+        return "", "", err
+    }
+    digest := sha256.New()
+    _, err = io.Copy(io.MultiWriter(file, digest), src)
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return "", "", err
+    }
+    return path, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        writeErrorString(w, http.StatusRequestEntityTooLarge, "upload exceeds the import size limit")
+        return
+    }
+    writeError(w, http.StatusBadRequest, err)
+}
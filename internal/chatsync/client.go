@@ -0,0 +1,300 @@
+// Package chatsync pulls conversations straight from ChatGPT's web backend
+// so the archive stays current between official exports. It uses the same
+// private API as the ChatGPT web app, authenticated with a token the user
+// copies from their browser; it may break whenever that API changes.
+package chatsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"zatGPT/internal/importer"
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+// DefaultBaseURL is the ChatGPT web origin.
+const DefaultBaseURL = "https://chatgpt.com"
+
+// ErrUnauthorized is returned when the token is missing, invalid or expired.
+var ErrUnauthorized = errors.New("chatgpt rejected the token; copy a fresh one from the browser")
+
+// Client talks to the ChatGPT backend API. Set AccessToken (the bearer token
+// the web app sends) or SessionToken (the __Secure-next-auth.session-token
+// cookie), which is exchanged for an access token on first use.
+type Client struct {
+	BaseURL      string
+	AccessToken  string
+	SessionToken string
+	HTTP         *http.Client
+	// Delay spaces out conversation fetches to stay clear of rate limits.
+	Delay time.Duration
+}
+
+// NewClient returns a Client for the public ChatGPT origin.
+func NewClient(accessToken, sessionToken string) *Client {
+	return &Client{
+		BaseURL:      DefaultBaseURL,
+		AccessToken:  accessToken,
+		SessionToken: sessionToken,
+		HTTP:         &http.Client{Timeout: 60 * time.Second, Transport: telemetry.Transport(nil)},
+		Delay:        500 * time.Millisecond,
+	}
+}
+
+// Summary is one entry of the conversation listing.
+type Summary struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	UpdateTime Timestamp `json:"update_time"`
+}
+
+// Timestamp accepts both the ISO 8601 strings and the Unix seconds the
+// backend has used for update_time.
+type Timestamp struct {
+	time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if secs, err := strconv.ParseFloat(string(data), 64); err == nil {
+		t.Time = time.Unix(0, int64(secs*float64(time.Second))).UTC()
+		return nil
+	}
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
+
+const pageSize = 28
+
+// List pages through conversations, most recently updated first, until one
+// is not newer than since (zero means no cutoff) or max have been returned.
+func (c *Client) List(ctx context.Context, since time.Time, max int) ([]Summary, error) {
+	var out []Summary
+	for offset := 0; ; offset += pageSize {
+		var page struct {
+			Items []Summary `json:"items"`
+			Total int       `json:"total"`
+		}
+		query := url.Values{
+			"offset": {strconv.Itoa(offset)},
+			"limit":  {strconv.Itoa(pageSize)},
+			"order":  {"updated"},
+		}
+		if err := c.getJSON(ctx, "/backend-api/conversations?"+query.Encode(), &page); err != nil {
+			return out, err
+		}
+		for _, item := range page.Items {
+			if !since.IsZero() && !item.UpdateTime.After(since) {
+				return out, nil
+			}
+			out = append(out, item)
+			if max > 0 && len(out) >= max {
+				return out, nil
+			}
+		}
+		if len(page.Items) < pageSize || offset+len(page.Items) >= page.Total {
+			return out, nil
+		}
+	}
+}
+
+// Conversation fetches one conversation with its full message graph.
+func (c *Client) Conversation(ctx context.Context, id string) (models.Conversation, error) {
+	body, err := c.get(ctx, "/backend-api/conversation/"+url.PathEscape(id))
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	defer body.Close()
+
+	convo, err := importer.DecodeConversation(body)
+	if err != nil {
+		return models.Conversation{}, fmt.Errorf("conversation %s: %w", id, err)
+	}
+	if convo.ID == "" || convo.SourceID == "" {
+		convo.ID, convo.SourceID = id, id
+	}
+	return convo, nil
+}
+
+// Store is the persistence Sync writes to; *storage.Store satisfies it.
+type Store interface {
+	importer.ConversationStore
+}
+
+// Result reports one sync pass.
+type Result struct {
+	Listed    int      `json:"listed"`
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Unchanged int      `json:"unchanged"`
+	Failed    []string `json:"failed,omitempty"`
+
+	Changes models.ImportChanges `json:"changes"`
+}
+
+// syncBatch is how many downloaded conversations Sync saves at a time.
+// Downloads are slow, so a failed pass loses little; saving each one on
+// its own would rewrite a JSON store once per conversation.
+const syncBatch = 50
+
+// Sync upserts every conversation updated after since (at most max). A
+// conversation that fails to download is reported in Result.Failed and
+// does not stop the pass. Conversations already downloaded are saved even
+// when the pass stops early.
+func (c *Client) Sync(ctx context.Context, store Store, since time.Time, max int) (Result, error) {
+	var result Result
+	items, err := c.List(ctx, since, max)
+	result.Listed = len(items)
+	if err != nil {
+		return result, err
+	}
+
+	pending := make([]models.Conversation, 0, min(len(items), syncBatch))
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		merged, err := importer.Merge(store, pending)
+		pending = pending[:0]
+		if err != nil {
+			return err
+		}
+		result.Created += merged.Created
+		result.Updated += merged.Updated
+		result.Unchanged += merged.Unchanged
+		importer.CombineChanges(&result.Changes, merged.Changes)
+		return nil
+	}
+
+	for i, item := range items {
+		if i > 0 && c.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				return result, errors.Join(ctx.Err(), flush())
+			case <-time.After(c.Delay):
+			}
+		}
+
+		convo, err := c.Conversation(ctx, item.ID)
+		if errors.Is(err, ErrUnauthorized) || ctx.Err() != nil {
+			return result, errors.Join(err, flush())
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, item.ID)
+			continue
+		}
+
+		pending = append(pending, convo)
+		if len(pending) == syncBatch {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, flush()
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, dest any) error {
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(dest)
+}
+
+// get issues an authenticated GET, retrying once after a 429.
+func (c *Client) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return resp.Body, nil
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			resp.Body.Close()
+			return nil, ErrUnauthorized
+		case resp.StatusCode == http.StatusTooManyRequests && attempt == 0:
+			resp.Body.Close()
+			wait := 10 * time.Second
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 && secs < 300 {
+				wait = time.Duration(secs) * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("chatgpt %s returned %s", path, resp.Status)
+		}
+	}
+}
+
+// accessToken returns AccessToken, first exchanging SessionToken for one if
+// needed.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	if c.AccessToken != "" {
+		return c.AccessToken, nil
+	}
+	if c.SessionToken == "" {
+		return "", ErrUnauthorized
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/auth/session", nil)
+	if err != nil {
+		return "", err
+	}
+	req.AddCookie(&http.Cookie{Name: "__Secure-next-auth.session-token", Value: c.SessionToken})
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", ErrUnauthorized
+	}
+
+	var session struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil || session.AccessToken == "" {
+		return "", ErrUnauthorized
+	}
+	c.AccessToken = session.AccessToken
+	return c.AccessToken, nil
+}
@@ -0,0 +1,219 @@
+package chatsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"zatGPT/internal/ids"
+	"zatGPT/internal/importer"
+	"zatGPT/internal/models"
+	"zatGPT/internal/webhook"
+)
+
+// FormatAPI marks import history entries written by a sync run.
+const FormatAPI = "api"
+
+// Import history statuses for sync runs.
+const (
+	StatusOK      = "ok"
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+)
+
+// Webhook event types sent by the Scheduler.
+const (
+	EventSyncFailed    = "sync.failed"
+	EventSyncRecovered = "sync.recovered"
+)
+
+// HistoryStore is a Store that also keeps the import history;
+// *storage.Store satisfies it.
+type HistoryStore interface {
+	Store
+	Imports() []models.ImportRecord
+	RecordImport(record models.ImportRecord) error
+}
+
+// IsSync reports whether an import history entry was written by a sync run.
+func IsSync(record models.ImportRecord) bool {
+	return record.Format == FormatAPI && record.Source == importer.SourceChatGPT
+}
+
+// LastCleanSync returns the start of the newest sync run that fetched
+// everything it listed; conversations updated before then are current.
+func LastCleanSync(store HistoryStore) time.Time {
+	for _, record := range store.Imports() {
+		if IsSync(record) && (record.Status == StatusOK || record.Status == "") {
+			return record.StartedAt
+		}
+	}
+	return time.Time{}
+}
+
+// SyncOnce runs one pass from the last clean sync and records the outcome,
+// failures included, in the import history. Only a clean pass moves the
+// cutoff forward, so conversations that failed are retried next time.
+func (c *Client) SyncOnce(ctx context.Context, store HistoryStore, max int) (models.ImportRecord, Result, error) {
+	started := time.Now().UTC()
+	result, err := c.Sync(ctx, store, LastCleanSync(store), max)
+
+	record := models.ImportRecord{
+		ID:            ids.New(),
+		File:          c.BaseURL,
+		Format:        FormatAPI,
+		Source:        importer.SourceChatGPT,
+		Conversations: result.Created + result.Updated + result.Unchanged,
+		Created:       result.Created,
+		Updated:       result.Updated,
+		Unchanged:     result.Unchanged,
+		Status:        StatusOK,
+		StartedAt:     started,
+		FinishedAt:    time.Now().UTC(),
+		Changes:       &result.Changes,
+	}
+	switch {
+	case err != nil:
+		record.Status = StatusFailed
+		record.Error = err.Error()
+	case len(result.Failed) > 0:
+		record.Status = StatusPartial
+		record.Error = fmt.Sprintf("could not download %d conversations: %s", len(result.Failed), strings.Join(result.Failed, ", "))
+	}
+
+	if recordErr := store.RecordImport(record); recordErr != nil && err == nil {
+		err = fmt.Errorf("failed to record sync history: %w", recordErr)
+	}
+	return record, result, err
+}
+
+// Scheduler runs SyncOnce every Interval and posts a webhook when a run
+// fails and again when syncing recovers.
+type Scheduler struct {
+	Client   *Client
+	Store    HistoryStore
+	Interval time.Duration
+	Max      int
+	Notifier *webhook.Notifier
+	Logf     func(format string, args ...any)
+
+	mu       sync.Mutex
+	running  bool
+	nextRun  time.Time
+	lastRun  *models.ImportRecord
+	failures int
+}
+
+// Status is the Scheduler's state as served by /api/sync/status.
+type Status struct {
+	Enabled             bool                 `json:"enabled"`
+	Interval            string               `json:"interval,omitempty"`
+	Running             bool                 `json:"running"`
+	NextRun             *time.Time           `json:"nextRun,omitempty"`
+	LastRun             *models.ImportRecord `json:"lastRun,omitempty"`
+	ConsecutiveFailures int                  `json:"consecutiveFailures"`
+}
+
+// Status reports the schedule and the outcome of the latest run. A nil
+// Scheduler reports sync as disabled.
+func (s *Scheduler) Status() Status {
+	if s == nil {
+		return Status{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{
+		Enabled:             true,
+		Interval:            s.Interval.String(),
+		Running:             s.running,
+		LastRun:             s.lastRun,
+		ConsecutiveFailures: s.failures,
+	}
+	if !s.nextRun.IsZero() {
+		next := s.nextRun
+		status.NextRun = &next
+	}
+	return status
+}
+
+// Run syncs immediately and then every Interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		s.mu.Lock()
+		s.nextRun = time.Now().UTC().Add(s.Interval)
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context) {
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	// A run cut short by shutdown, or one that panics, still ends.
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	record, _, err := s.Client.SyncOnce(ctx, s.Store, s.Max)
+	if ctx.Err() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.lastRun = &record
+	wasFailing := s.failures > 0
+	if record.Status == StatusOK {
+		s.failures = 0
+	} else {
+		s.failures++
+	}
+	failures := s.failures
+	s.mu.Unlock()
+
+	switch {
+	case record.Status != StatusOK:
+		s.logf("chatgpt sync %s: %v", record.Status, firstErr(err, record.Error))
+		s.notify(ctx, EventSyncFailed, map[string]any{"run": record, "consecutiveFailures": failures})
+	case wasFailing:
+		s.logf("chatgpt sync recovered: %d new, %d updated", record.Created, record.Updated)
+		s.notify(ctx, EventSyncRecovered, map[string]any{"run": record})
+	case record.Conversations > 0:
+		s.logf("chatgpt sync: %d new, %d updated", record.Created, record.Updated)
+	}
+}
+
+func (s *Scheduler) notify(ctx context.Context, event string, data any) {
+	if err := s.Notifier.Send(ctx, event, data); err != nil {
+		s.logf("webhook delivery failed: %v", err)
+	}
+}
+
+func (s *Scheduler) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func firstErr(err error, fallback string) any {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+func runBackup(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	filesDir := fs.String("files-dir", "", "where the store's attachments are kept (default: the directory of -data, or data for a postgres store)")
+	outPath := fs.String("out", "", "snapshot file to write (default zatgpt-snapshot-<time>.zip in the current directory)")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	if *outPath == "" {
+		*outPath = "zatgpt-snapshot-" + time.Now().UTC().Format("20060102-150405") + ".zip"
+	}
+
+	store, err := storage.Open(*dataPath, storage.Options{ReadOnly: true, Dir: *filesDir})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+
+	manifest, err := store.SaveSnapshot(*outPath)
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to write snapshot: %w", err))
+	}
+
+	out.Infof("Wrote %s: %d conversations, %d attachments", *outPath, manifest.Conversations, manifest.Attachments)
+	if err := out.Result(backupResult{Out: *outPath, Manifest: manifest}); err != nil {
+		out.Fatal(err)
+	}
+}
+
+// backupResult is the backup command's -json output.
+type backupResult struct {
+	Out      string                   `json:"out"`
+	Manifest storage.SnapshotManifest `json:"manifest"`
+}
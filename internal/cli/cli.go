@@ -0,0 +1,186 @@
+// Package cli implements the zatgpt command and its subcommands. Each
+// subcommand parses its own flags; values missing from the command line
+// are taken from the global flags, the environment and then the config
+// file.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"zatGPT/internal/storage"
+)
+
+// The default store files of each engine.
+const (
+	defaultDataPath   = "data/conversations_store.json"
+	defaultSQLitePath = "data/conversations_store.db"
+	defaultBoltPath   = "data/conversations_store.bolt"
+)
+
+// command is one zatgpt subcommand. run registers its flags on fs and
+// parses args with parseFlags.
+type command struct {
+	name    string
+	summary string
+	run     func(fs *flag.FlagSet, args []string)
+}
+
+// commands lists the subcommands in the order help shows them.
+var commands = []command{
+	{"serve", "serve the web UI and API", runServe},
+	{"import", "import an export (ChatGPT, Claude, Bard) or sync from ChatGPT", runImport},
+	{"export", "export the conversations matching a query", runExport},
+	{"search", "full-text search the store", runSearch},
+	{"stats", "print archive totals", runStats},
+	{"purge", "delete conversations from the trash for good", runPurge},
+	{"compact", "rewrite the store file as small as it goes", runCompact},
+	{"fsck", "check the store for damage and repair it", runFsck},
+	{"report", "compile a year in review", runReport},
+	{"backup", "write a snapshot of the store and its attachments", runBackup},
+	{"restore", "restore or merge a snapshot", runRestore},
+}
+
+// settings are the flag values a subcommand falls back to, highest
+// precedence first: the global flags, then the environment, then its config
+// file section, then the config file's top level.
+var settings []settingsLayer
+
+type settingsLayer struct {
+	source string
+	values map[string]string
+	// strict layers name flags of one subcommand, so a key it lacks is a
+	// mistake rather than a setting meant for another subcommand.
+	strict bool
+}
+
+// Main runs zatgpt with the arguments after the program name:
+//
+//	zatgpt [-config file] [-data path] <command> [flags]
+func Main(args []string) {
+	global := flag.NewFlagSet("zatgpt", flag.ExitOnError)
+	configPath := global.String("config", os.Getenv("ZATGPT_CONFIG"), "read default flag values from this TOML file (default: zatgpt/config.toml under the user config directory, when present)")
+	dataPath := global.String("data", "", "store file for every command (overrides the config file; a command's own -data wins)")
+	global.Usage = func() { usage(global) }
+	_ = global.Parse(args)
+
+	if global.NArg() == 0 || global.Arg(0) == "help" {
+		if name := global.Arg(1); name != "" {
+			run(name, []string{"-h"}, *configPath, nil)
+		}
+		global.Usage()
+		os.Exit(2)
+	}
+
+	overrides := map[string]string{}
+	if *dataPath != "" {
+		overrides["data"] = *dataPath
+	}
+	run(global.Arg(0), global.Args()[1:], *configPath, overrides)
+}
+
+// Run runs one subcommand, as the single-purpose binaries under cmd/ do.
+// The config file is read from ZATGPT_CONFIG or the default location.
+func Run(name string, args []string) {
+	run(name, args, os.Getenv("ZATGPT_CONFIG"), nil)
+}
+
+func run(name string, args []string, configPath string, overrides map[string]string) {
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "zatgpt: unknown command %q; run zatgpt help for the list\n", name)
+		os.Exit(2)
+	}
+
+	config, source, err := loadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "zatgpt: %v\n", err)
+		os.Exit(2)
+	}
+	settings = append([]settingsLayer{{source: "-data", values: overrides}}, envSettings()...)
+	if config != nil {
+		settings = append(settings,
+			settingsLayer{source: fmt.Sprintf("%s [%s]", source, name), values: config.sections[name], strict: true},
+			settingsLayer{source: source, values: config.global})
+	}
+
+	cmd.run(flag.NewFlagSet("zatgpt "+name, flag.ExitOnError), args)
+}
+
+// parseFlags parses a subcommand's arguments, then fills every flag left
+// unset from the settings layers.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	_ = fs.Parse(args)
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, layer := range settings {
+		for key, value := range layer.values {
+			if given[key] {
+				continue
+			}
+			if fs.Lookup(key) == nil {
+				if layer.strict {
+					fmt.Fprintf(os.Stderr, "%s: %s has no flag -%s\n", layer.source, fs.Name(), key)
+					os.Exit(2)
+				}
+				continue
+			}
+			if err := fs.Set(key, value); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: invalid %s: %v\n", layer.source, key, err)
+				os.Exit(2)
+			}
+			given[key] = true
+		}
+	}
+}
+
+func usage(global *flag.FlagSet) {
+	w := global.Output()
+	fmt.Fprintf(w, "usage: zatgpt [flags] <command> [command flags]\n\ncommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\nRun zatgpt help <command> for a command's flags. Any command flag can also be set\nwith ZATGPT_<FLAG> (ZATGPT_ADDR, ZATGPT_MAX_STORE_SIZE) or in the config file;\nthe command line wins, then the environment, then the file.\n\nflags:\n")
+	global.PrintDefaults()
+}
+
+// enginePath moves the default -data path to the default file of engine.
+func enginePath(engine, dataPath string) string {
+	if dataPath != defaultDataPath {
+		return dataPath
+	}
+	switch engine {
+	case storage.EngineSQLite:
+		return defaultSQLitePath
+	case storage.EngineBolt:
+		return defaultBoltPath
+	}
+	return dataPath
+}
+
+// storeLimits builds the store's limits from the -max-* flags.
+func storeLimits(maxConversations int, maxStoreSize, maxAttachmentSize string) (storage.Limits, error) {
+	if maxConversations < 0 {
+		return storage.Limits{}, errors.New("-max-conversations cannot be negative")
+	}
+	storeBytes, err := storage.ParseSize(maxStoreSize)
+	if err != nil {
+		return storage.Limits{}, fmt.Errorf("-max-store-size: %w", err)
+	}
+	attachmentBytes, err := storage.ParseSize(maxAttachmentSize)
+	if err != nil {
+		return storage.Limits{}, fmt.Errorf("-max-attachment-size: %w", err)
+	}
+	return storage.Limits{MaxConversations: maxConversations, MaxStoreBytes: storeBytes, MaxAttachmentBytes: attachmentBytes}, nil
+}
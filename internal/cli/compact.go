@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+// runCompact rewrites the store file: a JSON store without whitespace (or
+// gzip-compressed), a SQLite or bolt store without the free space earlier
+// writes left behind.
+func runCompact(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	encoding := fs.String("encoding", "", "encoding of a json store file from now on: compact, gzip or indented (default compact, or gzip for a file that already is)")
+	lockWait := fs.Duration("lock-wait", 0, "wait up to this long for another process (such as a running server) to release the store; 0 fails at once")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	if !storage.ValidEncoding(*encoding) {
+		out.Fatal(fmt.Errorf("invalid -encoding %q (want compact, gzip or indented)", *encoding))
+	}
+	store, err := storage.Open(*dataPath, storage.Options{LockWait: *lockWait})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+	defer store.Close()
+
+	result, err := store.Compact(*encoding)
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to compact the store: %w", err))
+	}
+
+	format := result.Engine
+	if result.Encoding != "" {
+		format += ", " + result.Encoding
+	}
+	out.Infof("Compacted %s (%s): %s -> %s", storage.DisplayPath(*dataPath), format, storage.FormatSize(result.Before), storage.FormatSize(result.After))
+	if err := out.Result(result); err != nil {
+		out.Fatal(err)
+	}
+}
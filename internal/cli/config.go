@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// config is a parsed config file: flag values by name, at the top level
+// (for every command that has the flag) or in a [command] section. The
+// syntax is the flat subset of TOML: strings, numbers, booleans and
+// one-line arrays, which stand for a comma-separated flag value.
+//
+//	# ~/.config/zatgpt/config.toml
+//	data = "/srv/zatgpt/store.db"
+//
+//	[serve]
+//	addr = ":9000"
+//	trash-days = 90
+//	cors-origins = ["https://app.example", "https://admin.example"]
+type config struct {
+	global   map[string]string
+	sections map[string]map[string]string
+}
+
+// loadConfig reads the config file at path, or at the default location
+// when path is empty. Only an explicitly named file has to exist; without
+// one, loadConfig returns a nil config.
+func loadConfig(path string) (*config, string, error) {
+	explicit := path != ""
+	if !explicit {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, "", nil
+		}
+		path = filepath.Join(dir, "zatgpt", "config.toml")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to read config: %w", err)
+	}
+	defer file.Close()
+
+	cfg := &config{global: map[string]string{}, sections: map[string]map[string]string{}}
+	values := cfg.global
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return nil, "", fmt.Errorf("%s:%d: malformed section header %q", path, n, line)
+			}
+			if cfg.sections[name] == nil {
+				cfg.sections[name] = map[string]string{}
+			}
+			values = cfg.sections[name]
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimPrefix(strings.Trim(strings.TrimSpace(key), `"`), "-")
+		if !ok || key == "" {
+			return nil, "", fmt.Errorf("%s:%d: want key = value, got %q", path, n, line)
+		}
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, "", fmt.Errorf("%s:%d: %s: %w", path, n, key, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	for name := range cfg.sections {
+		if !knownCommand(name) {
+			return nil, "", fmt.Errorf("%s: section [%s] names no command", path, name)
+		}
+	}
+	return cfg, path, nil
+}
+
+// parseValue turns the right-hand side of a config line into a flag value.
+// Arrays become comma-separated lists; a trailing # comment is dropped.
+func parseValue(raw string) (string, error) {
+	if list, ok := strings.CutPrefix(raw, "["); ok {
+		var items []string
+		for {
+			list = strings.TrimSpace(list)
+			if rest, ok := strings.CutPrefix(list, "]"); ok {
+				return strings.Join(items, ","), endOfValue(rest)
+			}
+			item, rest, err := scanValue(list, ",]#")
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+			rest = strings.TrimSpace(rest)
+			if next, ok := strings.CutPrefix(rest, ","); ok {
+				rest = next
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", errors.New("unterminated array (arrays must fit on one line)")
+			}
+			list = rest
+		}
+	}
+	value, rest, err := scanValue(raw, "#")
+	if err != nil {
+		return "", err
+	}
+	return value, endOfValue(rest)
+}
+
+// scanValue reads one basic ("..."), literal ('...') or bare value from the
+// start of s, a bare one running up to the first of the stop characters.
+func scanValue(s, stop string) (value, rest string, err error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("malformed string %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		value, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return "", "", errors.New("unterminated string")
+		}
+		return value, rest, nil
+	}
+	end := strings.IndexAny(s, stop)
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
+
+// endOfValue checks that only a comment follows a value.
+func endOfValue(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after the value", rest)
+	}
+	return nil
+}
+
+// envSettings returns a settings layer per environment variable naming a
+// flag: ZATGPT_ followed by the flag name in upper case with dashes as
+// underscores (ZATGPT_ADDR, ZATGPT_DATA, ZATGPT_MAX_STORE_SIZE), plus the
+// older names in envAliases. Empty variables count as unset.
+func envSettings() []settingsLayer {
+	var layers []settingsLayer
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		key, ok := strings.CutPrefix(name, "ZATGPT_")
+		if !ok || key == "" || value == "" {
+			continue
+		}
+		key = strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		layers = append(layers, settingsLayer{source: name, values: map[string]string{key: value}})
+	}
+	slices.SortFunc(layers, func(a, b settingsLayer) int { return strings.Compare(a.source, b.source) })
+	for _, alias := range envAliases {
+		if value := os.Getenv(alias.env); value != "" {
+			layers = append(layers, settingsLayer{source: alias.env, values: map[string]string{alias.flag: value}})
+		}
+	}
+	return layers
+}
+
+// envAliases are environment variables that set a flag under a name of
+// their own. They rank below ZATGPT_<FLAG>.
+var envAliases = []struct{ env, flag string }{
+	{"ZATGPT_API_TOKEN", "token"},
+	{"ZATGPT_WEBHOOK_URLS", "webhook"},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "otlp-endpoint"},
+}
+
+func knownCommand(name string) bool {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsMethods are the methods the API answers to from another origin.
+var corsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// corsHeaders are the request headers the API reads, allowed in every
+// preflight.
+var corsHeaders = []string{"Authorization", "Content-Type", "Range", "If-Modified-Since", "If-None-Match", "X-Request-ID", "Idempotency-Key", "Time-Zone"}
+
+// corsExposed are the response headers browser scripts may read.
+const corsExposed = "Accept-Ranges, Content-Range, Content-Length, Last-Modified, ETag, X-Request-ID, Idempotent-Replayed"
+
+// corsPolicy says which browser origins may call the API and with what.
+type corsPolicy struct {
+	// Origins are the allowed origins; "*" allows any.
+	Origins []string
+	// Headers are request headers allowed on top of corsHeaders.
+	Headers []string
+	// Credentials lets pages send cookies and client certificates along.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight; 0 leaves it to
+	// them.
+	MaxAge time.Duration
+}
+
+// newCORSPolicy builds the policy of the -cors-* flags. It returns nil for
+// -cors-origins none, which turns CORS off for same-origin deployments.
+// An empty origins list allows any origin, unless the API needs a key.
+func newCORSPolicy(origins, headers string, credentials bool, maxAge time.Duration, apiKey bool) (*corsPolicy, error) {
+	list := splitList(origins)
+	switch {
+	case slices.Contains(list, "none"):
+		if len(list) > 1 {
+			return nil, errors.New("-cors-origins none cannot be combined with other origins")
+		}
+		if headers != "" || credentials || maxAge != 0 {
+			return nil, errors.New("-cors-headers, -cors-credentials and -cors-max-age need CORS enabled")
+		}
+		return nil, nil
+	case len(list) == 0 && !apiKey:
+		list = []string{"*"}
+	}
+	for _, origin := range list {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return nil, errors.New("-cors-origins must list http(s) origins such as https://app.example, * or none; got " + strconv.Quote(origin))
+		}
+	}
+	if credentials && slices.Contains(list, "*") {
+		return nil, errors.New("-cors-credentials needs -cors-origins to list the origins; browsers refuse credentials with *")
+	}
+	if maxAge < 0 {
+		return nil, errors.New("-cors-max-age must not be negative")
+	}
+	return &corsPolicy{Origins: list, Headers: splitList(headers), Credentials: credentials, MaxAge: maxAge}, nil
+}
+
+// withCORS lets browsers on the policy's origins call the API. Other
+// origins get no CORS headers, so browsers keep the responses from the
+// calling page, and their preflights fail. A nil policy serves no CORS
+// headers at all and passes OPTIONS requests on like any other.
+func withCORS(policy *corsPolicy, next http.Handler) http.Handler {
+	if policy == nil {
+		return next
+	}
+	anyOrigin := slices.Contains(policy.Origins, "*")
+	headers := append(slices.Clone(corsHeaders), policy.Headers...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
+
+		allowed := ""
+		if anyOrigin {
+			allowed = "*"
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin != "" && slices.Contains(policy.Origins, origin) {
+				allowed = origin
+			}
+		}
+
+		if !preflight {
+			if allowed != "" {
+				w.Header().Set("Access-Control-Allow-Origin", allowed)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposed)
+				if policy.Credentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Allow", strings.Join(append(slices.Clone(corsMethods), http.MethodOptions), ", "))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A preflight is answered here, never by the API: it carries no
+		// credentials, and the browser only reads its headers.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if allowed == "" || !slices.Contains(corsMethods, r.Header.Get("Access-Control-Request-Method")) || !allowedHeaders(headers, r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		if policy.Credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowedHeaders reports whether every header in the comma-separated
+// requested list is one of allowed; header names ignore case.
+func allowedHeaders(allowed []string, requested string) bool {
+	for _, header := range splitList(requested) {
+		if !slices.ContainsFunc(allowed, func(name string) bool { return strings.EqualFold(name, header) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/export"
+	"zatGPT/internal/models"
+	"zatGPT/internal/query"
+	"zatGPT/internal/storage"
+)
+
+func runExport(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	filter := fs.String("q", "", "search query selecting conversations to export (e.g. \"tag:work after:2024-01-01\")")
+	formatName := fs.String("format", "json", "export format: json, markdown, html or pdf")
+	pdf := fs.Bool("pdf", false, "shorthand for -format pdf")
+	outPath := fs.String("out", "-", "output file, or - for stdout")
+	siteDir := fs.String("site", "", "write a static website (index, conversation and tag pages, client-side search) to this directory instead of one file")
+	siteTitle := fs.String("site-title", "Conversation archive", "with -site, the title of the site's index page")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+	if *pdf {
+		*formatName = string(export.FormatPDF)
+	}
+
+	if *siteDir != "" {
+		conflict := false
+		fs.Visit(func(f *flag.Flag) {
+			conflict = conflict || f.Name == "format" || f.Name == "pdf" || f.Name == "out"
+		})
+		if conflict {
+			out.Fatal(errors.New("-site writes a directory of HTML pages; it cannot be combined with -format, -pdf or -out"))
+		}
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		out.Fatal(err)
+	}
+	if out.JSON && *outPath == "-" && format != export.FormatJSON {
+		out.Fatal(errors.New("-json with -format " + string(format) + " needs -out; stdout carries the JSON result"))
+	}
+
+	q, err := query.Parse(*filter)
+	if err != nil {
+		out.Fatal(fmt.Errorf("invalid query: %w", err))
+	}
+
+	store, err := storage.Open(*dataPath, storage.Options{ReadOnly: true})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+
+	items, err := store.Find(context.Background(), q.Match)
+	if err != nil {
+		out.Fatal(err)
+	}
+
+	if *siteDir != "" {
+		site, err := export.WriteSite(*siteDir, *siteTitle, items)
+		if err != nil {
+			out.Fatal(fmt.Errorf("failed to write site: %w", err))
+		}
+		out.Infof("Wrote %d conversations and %d tag pages to %s", site.Conversations, site.Tags, *siteDir)
+		if err := out.Result(exportResult{Query: *filter, Format: "site", Count: len(items), Out: *siteDir}); err != nil {
+			out.Fatal(err)
+		}
+		return
+	}
+
+	result := exportResult{Query: *filter, Format: string(format), Count: len(items)}
+	if out.JSON && *outPath == "-" {
+		// The conversations themselves are the result.
+		if items == nil {
+			items = []models.Conversation{}
+		}
+		result.Conversations = &items
+	} else {
+		var dst io.Writer = os.Stdout
+		if *outPath != "-" {
+			file, err := os.Create(*outPath)
+			if err != nil {
+				out.Fatal(fmt.Errorf("failed to create output: %w", err))
+			}
+			defer file.Close()
+			dst = file
+			result.Out = *outPath
+		}
+		if err := export.Write(dst, format, items); err != nil {
+			out.Fatal(fmt.Errorf("failed to write export: %w", err))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d conversations\n", len(items))
+	if err := out.Result(result); err != nil {
+		out.Fatal(err)
+	}
+}
+
+// exportResult is the exporter's -json output.
+type exportResult struct {
+	Query         string                 `json:"query"`
+	Format        string                 `json:"format"`
+	Count         int                    `json:"count"`
+	Out           string                 `json:"out,omitempty"`
+	Conversations *[]models.Conversation `json:"conversations,omitempty"`
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+	"zatGPT/internal/telemetry"
+)
+
+// stdinFile is the -file value that reads the export from standard input.
+const stdinFile = "-"
+
+// spooled is an export read from stdin or downloaded into a temporary
+// directory, since importing needs a file it can hash, reopen and, for a
+// ZIP, seek in.
+type spooled struct {
+	// Path is the temporary copy; Name is what the import history records
+	// in its place.
+	Path string
+	Name string
+	dir  string
+}
+
+func (s *spooled) Remove() {
+	os.RemoveAll(s.dir)
+}
+
+// spoolStdin copies standard input to a temporary file.
+func spoolStdin(out *cliout.Output) (*spooled, error) {
+	s, file, err := createSpool("stdin")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(file, os.Stdin)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.Remove()
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	if n == 0 {
+		s.Remove()
+		return nil, fmt.Errorf("stdin is empty")
+	}
+	out.Infof("Read %s from stdin", storage.FormatSize(n))
+	return s, nil
+}
+
+// spoolURL downloads an http(s) URL to a temporary file, showing progress
+// when the server sends the length.
+func spoolURL(ctx context.Context, out *cliout.Output, rawURL string) (*spooled, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid -url %q (want an http or https URL)", rawURL)
+	}
+	// Signed storage URLs carry their secret in the query, so only the
+	// scheme, host and path are shown and recorded.
+	shown := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: telemetry.Transport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", shown, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", shown, resp.Status)
+	}
+
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "download"
+	}
+	s, file, err := createSpool(name)
+	if err != nil {
+		return nil, err
+	}
+	s.Name = shown
+
+	var body io.Reader = resp.Body
+	var progress *cliout.Progress
+	if resp.ContentLength > 0 {
+		progress = out.Progress(name)
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, progress: progress}
+	}
+	n, err := io.Copy(file, body)
+	if progress != nil {
+		progress.Done()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength > 0 && n != resp.ContentLength {
+		err = fmt.Errorf("got %d of %d bytes", n, resp.ContentLength)
+	}
+	if err != nil {
+		s.Remove()
+		return nil, fmt.Errorf("failed to download %s: %w", shown, err)
+	}
+	out.Infof("Downloaded %s from %s", storage.FormatSize(n), u.Host)
+	return s, nil
+}
+
+// createSpool makes a temporary directory holding an empty file called
+// name.
+func createSpool(name string) (*spooled, *os.File, error) {
+	dir, err := os.MkdirTemp("", "zatgpt-import-")
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &spooled{Path: filepath.Join(dir, filepath.Base(strings.ReplaceAll(name, "\\", "/"))), Name: name, dir: dir}
+	file, err := os.Create(s.Path)
+	if err != nil {
+		s.Remove()
+		return nil, nil, err
+	}
+	return s, file, nil
+}
+
+// progressReader reports how much of total has been read.
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	percent  int
+	progress *cliout.Progress
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	// Redraw once per percent, not once per read.
+	if percent := int(p.read * 100 / p.total); percent != p.percent || err != nil {
+		p.percent = percent
+		p.progress.Update(float64(p.read)/float64(p.total), fmt.Sprintf("%s of %s", storage.FormatSize(p.read), storage.FormatSize(p.total)))
+	}
+	return n, err
+}
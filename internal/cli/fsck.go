@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+// runFsck checks the store file and the attachment directory, and with
+// -repair puts right what it found. It exits with status 1 while problems
+// remain, so it can guard a backup script.
+func runFsck(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	repair := fs.Bool("repair", false, "fix what the check finds, moving whatever is taken out of the store to a quarantine directory beside it")
+	lockWait := fs.Duration("lock-wait", 0, "wait up to this long for another process (such as a running server) to release the store; 0 fails at once")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	// Salvage opens a damaged JSON store with what can be read, so the
+	// damage can be reported and repaired instead of stopping the check.
+	store, err := storage.Open(*dataPath, storage.Options{LockWait: *lockWait, ReadOnly: !*repair, Salvage: true})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+	defer store.Close()
+
+	report, err := store.CheckIntegrity(*repair)
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to check the store: %w", err))
+	}
+
+	out.Infof("Checked %s (%s): %d conversations, %d attachments", report.Path, report.Engine, report.Conversations, report.Attachments)
+	if report.FreeBytes > 0 {
+		out.Infof("Free disk space: %s", storage.FormatSize(report.FreeBytes))
+	}
+	for _, issue := range report.Issues {
+		where := strings.Join(strings.Fields(issue.ConversationID+" "+issue.MessageID+" "+issue.Ref), " ")
+		if where != "" {
+			where = " [" + where + "]"
+		}
+		out.Infof("  %s%s: %s", issue.Kind, where, issue.Detail)
+	}
+	switch {
+	case len(report.Issues) == 0:
+		out.Infof("No problems found")
+	case report.Repaired:
+		out.Infof("Repaired %d problems; what was taken out is in %s", len(report.Issues), report.Quarantine)
+	default:
+		out.Infof("%d problems found; run again with -repair to fix them", len(report.Issues))
+	}
+	if err := out.Result(report); err != nil {
+		out.Fatal(err)
+	}
+	if !report.OK() {
+		store.Close()
+		os.Exit(1)
+	}
+}
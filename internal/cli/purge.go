@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+// runPurge deletes trashed conversations for good, as the server's
+// -trash-days does on a schedule.
+func runPurge(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	days := fs.Int("days", 0, "only purge conversations deleted more than this many days ago; 0 empties the whole trash")
+	lockWait := fs.Duration("lock-wait", 0, "wait up to this long for another process (such as a running server) to release the store; 0 fails at once")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	if *days < 0 {
+		out.Fatal(errors.New("-days cannot be negative"))
+	}
+	store, err := storage.Open(*dataPath, storage.Options{LockWait: *lockWait})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+	defer store.Close()
+
+	var cutoff time.Time
+	if *days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -*days)
+	}
+	purged, err := store.PurgeTrash(cutoff)
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to purge the trash: %w", err))
+	}
+
+	out.Infof("Purged %d conversations from the trash", purged)
+	if err := out.Result(purgeResult{Purged: purged, Remaining: len(store.Trash())}); err != nil {
+		out.Fatal(err)
+	}
+}
+
+// purgeResult is the purge command's -json output.
+type purgeResult struct {
+	Purged    int `json:"purged"`
+	Remaining int `json:"remaining"`
+}
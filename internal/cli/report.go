@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/stats"
+	"zatGPT/internal/storage"
+)
+
+func runReport(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	year := fs.Int("year", time.Now().Year(), "calendar year to review")
+	formatName := fs.String("format", "markdown", "report format: "+strings.Join(stats.ReviewFormats, ", "))
+	outPath := fs.String("out", "-", "output file, or - for stdout")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	format := strings.ToLower(*formatName)
+	switch format {
+	case "html", "markdown", "md", "json":
+	default:
+		out.Fatal(fmt.Errorf("unsupported report format %q (want %s)", *formatName, strings.Join(stats.ReviewFormats, ", ")))
+	}
+	if out.JSON && *outPath == "-" && format != "json" {
+		out.Fatal(errors.New("-json with -format " + format + " needs -out; stdout carries the JSON result"))
+	}
+
+	store, err := storage.Open(*dataPath, storage.Options{ReadOnly: true})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+
+	conversations, err := store.Find(context.Background(), nil)
+	if err != nil {
+		out.Fatal(err)
+	}
+	review := stats.ComputeReview(conversations, *year)
+	result := reportResult{Year: *year, Format: format, Conversations: review.Conversations, Messages: review.Messages}
+
+	if out.JSON && *outPath == "-" {
+		// The review itself is the result.
+		result.Review = &review
+	} else {
+		var dst io.Writer = os.Stdout
+		if *outPath != "-" {
+			file, err := os.Create(*outPath)
+			if err != nil {
+				out.Fatal(fmt.Errorf("failed to create output: %w", err))
+			}
+			defer file.Close()
+			dst = file
+			result.Out = *outPath
+		}
+		if err := stats.WriteReview(dst, format, review); err != nil {
+			out.Fatal(fmt.Errorf("failed to write report: %w", err))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Reviewed %d conversations from %d\n", review.Conversations, *year)
+	if err := out.Result(result); err != nil {
+		out.Fatal(err)
+	}
+}
+
+// reportResult is the report command's -json output.
+type reportResult struct {
+	Year          int           `json:"year"`
+	Format        string        `json:"format"`
+	Conversations int           `json:"conversations"`
+	Messages      int           `json:"messages"`
+	Out           string        `json:"out,omitempty"`
+	Review        *stats.Review `json:"review,omitempty"`
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+// listLimit caps how many titles each section of the plan prints.
+const listLimit = 20
+
+func runRestore(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to the persistence file to restore into")
+	filesDir := fs.String("files-dir", "", "where the store's attachments are kept (default: the directory of -data, or data for a postgres store)")
+	merge := fs.Bool("merge", false, "merge the snapshot into the store instead of replacing it: nothing is removed, and conversations newer in the store are kept")
+	dryRun := fs.Bool("dry-run", false, "verify the snapshot and show what would change, without restoring")
+	yes := fs.Bool("yes", false, "restore without asking for confirmation")
+	lockWait := fs.Duration("lock-wait", 0, "wait up to this long for another process (such as a running server) to release the store; 0 fails at once")
+	out := cliout.FlagSet(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags] path/to/snapshot.zip\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	// Accept flags after the snapshot path too (restore snap.zip -merge).
+	path := fs.Arg(0)
+	if fs.NArg() > 1 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			os.Exit(2)
+		}
+		if fs.NArg() > 0 {
+			out.Fatal(fmt.Errorf("unexpected argument %q", fs.Arg(0)))
+		}
+	}
+	if path == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if out.JSON && !*yes && !*dryRun {
+		out.Fatal(errors.New("-json needs -yes or -dry-run; there is no one to confirm the restore"))
+	}
+
+	snap, err := storage.OpenSnapshot(path)
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to verify %s: %w", path, err))
+	}
+	defer snap.Close()
+	if snap.Checksummed {
+		out.Infof("Verified %s: schema v%d, created %s, %d conversations, %d attachments, %d checksums OK",
+			path, snap.Manifest.SchemaVersion, snap.Manifest.CreatedAt.Format("2006-01-02 15:04 MST"),
+			snap.Manifest.Conversations, snap.Manifest.Attachments, len(snap.Manifest.Files))
+	} else {
+		out.Warnf("%s is a plain store file, not a snapshot archive: it has no checksums to verify and no attachments", path)
+		out.Infof("Read %s: %d conversations", path, snap.Manifest.Conversations)
+	}
+
+	store, err := storage.Open(*dataPath, storage.Options{LockWait: *lockWait, Dir: *filesDir})
+	if errors.Is(err, storage.ErrLocked) {
+		out.Fatal(fmt.Errorf("%w; stop the server first or pass -lock-wait", err))
+	}
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+	defer store.Close()
+
+	plan := store.PlanRestore(snap, *merge)
+	printPlan(out, storage.DisplayPath(*dataPath), plan)
+	result := restoreResult{Snapshot: path, Manifest: snap.Manifest, Checksummed: snap.Checksummed, Plan: plan}
+
+	switch {
+	case *dryRun:
+	case len(plan.Added)+len(plan.Changed)+len(plan.Removed)+plan.Attachments == 0:
+		out.Infof("Nothing to restore; the store already matches the snapshot")
+	case !*yes && !confirm("Apply these changes?"):
+		out.Infof("Restore cancelled")
+	default:
+		if _, err := store.Restore(snap, *merge); err != nil {
+			out.Fatal(fmt.Errorf("restore failed, store file left unchanged: %w", err))
+		}
+		result.Applied = true
+		out.Infof("Restored %s into %s", path, storage.DisplayPath(*dataPath))
+	}
+
+	if err := out.Result(result); err != nil {
+		out.Fatal(err)
+	}
+}
+
+func printPlan(out *cliout.Output, dataPath string, plan storage.RestorePlan) {
+	mode := "replace"
+	if plan.Merge {
+		mode = "merge"
+	}
+	out.Infof("Restore plan for %s (%s):", dataPath, mode)
+	printChanges(out, "+", "added", plan.Added)
+	printChanges(out, "~", "replaced by the snapshot's copy", plan.Changed)
+	if plan.Merge {
+		out.Infof("  %d kept (only in the store, or newer there)", plan.Kept)
+	} else {
+		printChanges(out, "-", "removed", plan.Removed)
+	}
+	out.Infof("  %d unchanged", plan.Unchanged)
+	out.Infof("  %d attachments to copy", plan.Attachments)
+}
+
+func printChanges(out *cliout.Output, marker, label string, changes []storage.RestoreChange) {
+	out.Infof("  %s %d %s", marker, len(changes), label)
+	for i, change := range changes {
+		if i == listLimit {
+			out.Infof("      ... and %d more", len(changes)-listLimit)
+			break
+		}
+		out.Infof("      %s (%s)", change.Title, change.ID)
+	}
+}
+
+// confirm asks a yes/no question on stderr, defaulting to no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// restoreResult is the restore command's -json output.
+type restoreResult struct {
+	Snapshot    string                   `json:"snapshot"`
+	Manifest    storage.SnapshotManifest `json:"manifest"`
+	Checksummed bool                     `json:"checksummed"`
+	Plan        storage.RestorePlan      `json:"plan"`
+	Applied     bool                     `json:"applied"`
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"time"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/models"
+	"zatGPT/internal/query"
+	"zatGPT/internal/storage"
+)
+
+func runSearch(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	since := fs.String("since", "", "only conversations started on or after this month or day (YYYY-MM or YYYY-MM-DD)")
+	until := fs.String("until", "", "only conversations started on or before this month or day (YYYY-MM or YYYY-MM-DD)")
+	limit := fs.Int("limit", 20, "show at most this many conversations")
+	snippetCount := fs.Int("snippets", 2, "context snippets to print per conversation")
+	fuzzy := fs.Bool("fuzzy", false, "match the words in titles and summaries only, allowing a typo or two per word")
+	out := cliout.FlagSet(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] <query>\n\nThe query takes the same syntax as the web search: words, \"quoted phrases\", tag:, lang:, after: and before:.\n\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	raw := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if raw == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	q, err := query.Parse(raw)
+	if err != nil {
+		out.Fatal(fmt.Errorf("invalid query: %w", err))
+	}
+	if len(q.Terms) == 0 {
+		out.Fatal(errors.New("the query must contain at least one word to search for"))
+	}
+	if *since != "" {
+		if q.After, _, err = parseMonthOrDay("-since", *since); err != nil {
+			out.Fatal(err)
+		}
+	}
+	if *until != "" {
+		_, end, err := parseMonthOrDay("-until", *until)
+		if err != nil {
+			out.Fatal(err)
+		}
+		q.Before = end
+	}
+	if *limit < 1 {
+		out.Fatal(errors.New("-limit must be at least 1"))
+	}
+
+	store, err := storage.Open(*dataPath, storage.Options{ReadOnly: true})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+
+	var match func(models.Conversation) bool
+	filters := q
+	filters.Terms = nil
+	if !filters.IsEmpty() {
+		match = filters.Match
+	}
+	search := store.Search
+	if *fuzzy {
+		search = store.FuzzySearch
+	}
+	hits, total, err := search(context.Background(), q.Terms, match, 0, *limit)
+	if err != nil {
+		out.Fatal(err)
+	}
+
+	if out.JSON {
+		if hits == nil {
+			hits = []storage.SearchHit{}
+		}
+		if err := out.Result(searchResult{Query: raw, Total: total, Results: hits}); err != nil {
+			out.Fatal(err)
+		}
+		return
+	}
+
+	highlight := highlighter(isTerminal(os.Stdout))
+	for i, hit := range hits {
+		if i > 0 {
+			fmt.Println()
+		}
+		convo := hit.Conversation
+		fmt.Printf("%s\n  %s · %s\n", convo.Title, conversationDate(convo), convo.ID)
+		for j, snippet := range hit.Snippets {
+			if j == *snippetCount {
+				break
+			}
+			fmt.Printf("  %s: %s\n", snippet.Field, highlight(snippet.Text))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d matching conversations\n", len(hits), total)
+}
+
+// searchResult is the search command's -json output.
+type searchResult struct {
+	Query   string              `json:"query"`
+	Total   int                 `json:"total"`
+	Results []storage.SearchHit `json:"results"`
+}
+
+// parseMonthOrDay reads YYYY-MM or YYYY-MM-DD, returning the start of that
+// month or day and the start of the one after it.
+func parseMonthOrDay(name, value string) (start, end time.Time, err error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse("2006-01", value); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%s: expected YYYY-MM or YYYY-MM-DD, got %q", name, value)
+}
+
+// conversationDate is the day a conversation started, as shown in the list.
+func conversationDate(convo models.Conversation) string {
+	switch {
+	case convo.DateStarted != "":
+		return convo.DateStarted
+	case !convo.CreatedAt.IsZero():
+		return convo.CreatedAt.UTC().Format("2006-01-02")
+	}
+	return "undated"
+}
+
+// highlighter turns a search snippet, HTML-escaped with matches in <mark>,
+// into terminal text: matches in bold on a terminal, plain otherwise.
+func highlighter(bold bool) func(string) string {
+	on, off := "", ""
+	if bold {
+		on, off = "\033[1m", "\033[0m"
+	}
+	marks := strings.NewReplacer("<mark>", on, "</mark>", off)
+	return func(text string) string {
+		return html.UnescapeString(marks.Replace(text))
+	}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
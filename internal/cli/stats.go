@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+
+	"zatGPT/internal/cliout"
+	"zatGPT/internal/storage"
+)
+
+// runStats prints the totals GET /api/stats serves.
+func runStats(fs *flag.FlagSet, args []string) {
+	dataPath := fs.String("data", defaultDataPath, "path to persistence file")
+	out := cliout.FlagSet(fs)
+	parseFlags(fs, args)
+
+	store, err := storage.Open(*dataPath, storage.Options{ReadOnly: true})
+	if err != nil {
+		out.Fatal(fmt.Errorf("failed to open store: %w", err))
+	}
+	overview, err := store.Stats(context.Background())
+	if err != nil {
+		out.Fatal(err)
+	}
+
+	if out.JSON {
+		if err := out.Result(overview); err != nil {
+			out.Fatal(err)
+		}
+		return
+	}
+	fmt.Printf("%d conversations, %d messages (%.1f per conversation)\n", overview.Conversations, overview.Messages, overview.AverageMessages)
+	for _, role := range slices.Sorted(maps.Keys(overview.MessagesByRole)) {
+		fmt.Printf("  %-10s %d\n", role, overview.MessagesByRole[role])
+	}
+	if len(overview.Models) > 0 {
+		fmt.Println("\nModels:")
+		for _, model := range overview.Models {
+			fmt.Printf("  %-20s %d messages\n", model.Key, model.Messages)
+		}
+	}
+	if len(overview.Months) > 0 {
+		fmt.Println("\nMonths:")
+		for _, month := range overview.Months {
+			fmt.Printf("  %s  %4d conversations  %6d messages\n", month.Key, month.Conversations, month.Messages)
+		}
+	}
+	if len(overview.BusiestDays) > 0 {
+		fmt.Println("\nBusiest days:")
+		for _, day := range overview.BusiestDays {
+			fmt.Printf("  %s  %d messages\n", day.Key, day.Messages)
+		}
+	}
+}
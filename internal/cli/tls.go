@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeManager gets and renews certificates for domains from the ACME
+// directory (Let's Encrypt when empty), keeping them in cacheDir. Without
+// a cacheDir the caller sets Cache before the manager is used.
+func acmeManager(domains []string, email, cacheDir, directory string) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Email:      email,
+	}
+	if cacheDir != "" {
+		manager.Cache = autocert.DirCache(filepath.Clean(cacheDir))
+	}
+	if directory != "" {
+		manager.Client = &acme.Client{DirectoryURL: directory}
+	}
+	return manager
+}
+
+// redirectToHTTPS answers plain HTTP requests with a permanent redirect to
+// the same URL over HTTPS on the port of httpsAddr. The redirect keeps the
+// method, so a form posted to the http:// URL is posted again.
+func redirectToHTTPS(httpsAddr string) (http.Handler, error) {
+	_, port, err := net.SplitHostPort(httpsAddr)
+	if err != nil {
+		return nil, err
+	}
+	if port == "443" || port == "https" {
+		port = ""
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if host == "" {
+			http.Error(w, "a Host header is required", http.StatusBadRequest)
+			return
+		}
+		if port != "" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	}), nil
+}
+
+// redirectAddr resolves -http-addr: where plain HTTP is redirected from,
+// or "" for nowhere. It defaults to :80 with -acme-domain, whose HTTP
+// challenges are answered there, and "none" turns it off.
+func redirectAddr(addr string, https, acme bool) (string, error) {
+	switch {
+	case addr == "none":
+		return "", nil
+	case addr == "" && acme:
+		return ":80", nil
+	case addr != "" && !https:
+		return "", errors.New("-http-addr redirects to HTTPS and needs -tls-cert or -acme-domain")
+	}
+	return addr, nil
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"zatGPT/internal/importer"
+)
+
+// defaultSettle is how long a file must go unchanged before -watch imports
+// it, so a download still being written is not read half-way.
+const defaultSettle = 3 * time.Second
+
+// watch imports every export under imp.watchDir, then keeps watching the
+// directory tree and imports each export that appears or changes once it
+// has settled, until the process is interrupted. Files already in the
+// import history are skipped as with -dir, so an export rewritten with the
+// same content is not imported twice.
+func (imp *importRun) watch(ctx context.Context, dataPath string, force bool, report *importReport) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", imp.watchDir, err)
+	}
+	defer watcher.Close()
+
+	// pending holds the files waiting to settle, by the time of their last
+	// change.
+	pending := map[string]time.Time{}
+	// addTree watches root and its subdirectories and queues the exports
+	// found in them as last changed at changed.
+	addTree := func(root string, changed time.Time) error {
+		return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				if importer.IsCandidate(p) && !imp.ownFile(p, dataPath) {
+					pending[p] = changed
+				}
+				return nil
+			}
+			if p != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return watcher.Add(p)
+		})
+	}
+	if err := addTree(imp.watchDir, time.Time{}); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", imp.watchDir, err)
+	}
+	imp.importSettled(ctx, pending, dataPath, force, report)
+	imp.out.Infof("Watching %s for new exports (Ctrl-C to stop)", imp.watchDir)
+
+	ticker := time.NewTicker(imp.settle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+			case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+				info, err := os.Stat(event.Name)
+				if err != nil {
+					continue
+				}
+				if info.IsDir() {
+					if strings.HasPrefix(info.Name(), ".") {
+						continue
+					}
+					if err := addTree(event.Name, time.Now()); err != nil {
+						imp.out.Warnf("failed to watch %s: %v", event.Name, err)
+					}
+				} else if importer.IsCandidate(event.Name) && !imp.ownFile(event.Name, dataPath) {
+					pending[event.Name] = time.Now()
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				imp.out.Warnf("missed some changes under %s; rescanning it", imp.watchDir)
+				if err := addTree(imp.watchDir, time.Now()); err != nil {
+					imp.out.Warnf("failed to rescan %s: %v", imp.watchDir, err)
+				}
+				continue
+			}
+			imp.out.Warnf("watching %s: %v", imp.watchDir, err)
+		case <-ticker.C:
+			imp.importSettled(ctx, pending, dataPath, force, report)
+		}
+	}
+}
+
+// importSettled imports the pending files that have not changed for
+// imp.settle, in path order, and removes them from pending. Empty files are
+// left waiting, since some browsers create the file before writing the
+// download into it. A local store is opened for the imports and closed
+// again after them, so it is not held locked while nothing is imported.
+func (imp *importRun) importSettled(ctx context.Context, pending map[string]time.Time, dataPath string, force bool, report *importReport) {
+	var settled []string
+	for _, path := range slices.Sorted(maps.Keys(pending)) {
+		if time.Since(pending[path]) < imp.settle {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(pending, path)
+			continue
+		}
+		if info.Size() > 0 {
+			settled = append(settled, path)
+		}
+	}
+	if len(settled) == 0 {
+		return
+	}
+	if imp.server == nil {
+		if err := imp.openStore(dataPath); err != nil {
+			// Try again once the files have waited another settle period.
+			imp.out.Warnf("%v", err)
+			for _, path := range settled {
+				pending[path] = time.Now()
+			}
+			return
+		}
+		defer imp.closeStore()
+	}
+
+	for _, path := range settled {
+		if ctx.Err() != nil {
+			return
+		}
+		delete(pending, path)
+
+		result := imp.importDirEntry(ctx, path, force)
+		report.add(result)
+		if !imp.out.JSON {
+			for _, record := range imp.records {
+				fmt.Println()
+				_ = importer.WriteReport(os.Stdout, record)
+				fmt.Println()
+			}
+			imp.records = imp.records[:0]
+		}
+	}
+	if report.AttachmentsCopied > 0 && imp.store != nil {
+		report.AttachmentDir = imp.store.AttachmentDir()
+	}
+}
@@ -0,0 +1,62 @@
+// Package cliout gives every command the same two output modes: human text
+// by default, or a single JSON document on stdout (with progress on stderr)
+// when -json is set, so the tools compose with jq and scripts.
+package cliout
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Output writes a command's results in the selected mode.
+type Output struct {
+	JSON   bool
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// FlagSet registers -json on a command's flag set and returns an Output
+// bound to it; read its fields only after the flags are parsed.
+func FlagSet(fs *flag.FlagSet) *Output {
+	out := &Output{Stdout: os.Stdout, Stderr: os.Stderr}
+	fs.BoolVar(&out.JSON, "json", false, "emit a JSON result on stdout; human-readable messages go to stderr")
+	return out
+}
+
+// Infof prints a human-readable line. In JSON mode it goes to stderr so
+// stdout stays machine-readable.
+func (o *Output) Infof(format string, args ...any) {
+	w := o.Stdout
+	if o.JSON {
+		w = o.Stderr
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+}
+
+// Warnf prints a warning to stderr in both modes.
+func (o *Output) Warnf(format string, args ...any) {
+	fmt.Fprintf(o.Stderr, format+"\n", args...)
+}
+
+// Result writes v as the command's JSON result. It is a no-op in text mode.
+func (o *Output) Result(v any) error {
+	if !o.JSON {
+		return nil
+	}
+	enc := json.NewEncoder(o.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Fatal reports err and exits with status 1. In JSON mode the error is
+// written to stdout as {"error": "..."} so callers always get a document.
+func (o *Output) Fatal(err error) {
+	if o.JSON {
+		_ = json.NewEncoder(o.Stdout).Encode(map[string]string{"error": err.Error()})
+	}
+	fmt.Fprintln(o.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,82 @@
+package cliout
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Progress draws a progress bar with an ETA on stderr. On a terminal the
+// bar is redrawn in place; otherwise, as in a log file, a line is written
+// every tenth of the way.
+type Progress struct {
+	out     *Output
+	label   string
+	started time.Time
+	tty     bool
+	// step is the last tenth reported when not on a terminal.
+	step  int
+	drawn bool
+}
+
+const progressWidth = 30
+
+// Progress starts a progress bar for label.
+func (o *Output) Progress(label string) *Progress {
+	p := &Progress{out: o, label: label, started: time.Now()}
+	if file, ok := o.Stderr.(*os.File); ok {
+		if info, err := file.Stat(); err == nil {
+			p.tty = info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return p
+}
+
+// Update reports that fraction (0 to 1) of the work is done; detail is
+// shown after the bar, e.g. a count of items.
+func (p *Progress) Update(fraction float64, detail string) {
+	fraction = min(max(fraction, 0), 1)
+	if !p.tty {
+		step := int(fraction * 10)
+		if step <= p.step {
+			return
+		}
+		p.step = step
+	}
+
+	line := fmt.Sprintf("%s [%s] %3.0f%% %s", p.label, bar(fraction), fraction*100, detail)
+	if eta, ok := p.eta(fraction); ok {
+		line += ", ETA " + eta.String()
+	}
+	if p.tty {
+		fmt.Fprintf(p.out.Stderr, "\r\033[K%s", line)
+		p.drawn = true
+	} else {
+		fmt.Fprintln(p.out.Stderr, line)
+	}
+}
+
+// Done ends the bar, leaving the last line drawn in place.
+func (p *Progress) Done() {
+	if p.drawn {
+		fmt.Fprintln(p.out.Stderr)
+		p.drawn = false
+	}
+}
+
+// eta extrapolates the time left from the rate so far, once there is
+// enough of it to go on.
+func (p *Progress) eta(fraction float64) (time.Duration, bool) {
+	elapsed := time.Since(p.started)
+	if fraction <= 0 || fraction >= 1 || elapsed < time.Second {
+		return 0, false
+	}
+	left := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+	return left.Round(time.Second), true
+}
+
+func bar(fraction float64) string {
+	filled := int(fraction * progressWidth)
+	return strings.Repeat("#", filled) + strings.Repeat("-", progressWidth-filled)
+}
@@ -0,0 +1,140 @@
+package compare
+
+import (
+	"strings"
+	"unicode"
+
+	"zatGPT/internal/models"
+)
+
+// Threshold is the minimum similarity for two messages to be aligned.
+const Threshold = 0.3
+
+// MaxMessages bounds the alignment table; longer conversations are compared
+// on their first MaxMessages messages.
+const MaxMessages = 2000
+
+// Row is one line of the aligned view. Kind is "match" when both sides hold
+// similar messages, or "onlyA"/"onlyB" for segments unique to one side.
+type Row struct {
+	Kind       string          `json:"kind"`
+	A          *models.Message `json:"a,omitempty"`
+	B          *models.Message `json:"b,omitempty"`
+	Similarity float64         `json:"similarity,omitempty"`
+}
+
+// Result is the aligned comparison of two conversations.
+type Result struct {
+	Rows       []Row   `json:"rows"`
+	Matched    int     `json:"matched"`
+	OnlyA      int     `json:"onlyA"`
+	OnlyB      int     `json:"onlyB"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Align pairs up similar messages of a and b in order, maximising total
+// similarity (a weighted longest-common-subsequence). Messages are only
+// paired with messages of the same author.
+func Align(a, b []models.Message) Result {
+	if len(a) > MaxMessages {
+		a = a[:MaxMessages]
+	}
+	if len(b) > MaxMessages {
+		b = b[:MaxMessages]
+	}
+
+	wordsA := make([]map[string]bool, len(a))
+	for i, msg := range a {
+		wordsA[i] = words(msg.Content)
+	}
+	wordsB := make([]map[string]bool, len(b))
+	for j, msg := range b {
+		wordsB[j] = words(msg.Content)
+	}
+
+	sim := func(i, j int) float64 {
+		if a[i].Author != b[j].Author {
+			return 0
+		}
+		s := jaccard(wordsA[i], wordsB[j])
+		if s < Threshold {
+			return 0
+		}
+		return s
+	}
+
+	// score[i][j] is the best total similarity aligning a[i:] with b[j:].
+	score := make([][]float64, len(a)+1)
+	for i := range score {
+		score[i] = make([]float64, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			best := score[i+1][j]
+			if score[i][j+1] > best {
+				best = score[i][j+1]
+			}
+			if s := sim(i, j); s > 0 && score[i+1][j+1]+s > best {
+				best = score[i+1][j+1] + s
+			}
+			score[i][j] = best
+		}
+	}
+
+	var result Result
+	total := 0.0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && sim(i, j) > 0 && score[i][j] == score[i+1][j+1]+sim(i, j):
+			s := sim(i, j)
+			result.Rows = append(result.Rows, Row{Kind: "match", A: &a[i], B: &b[j], Similarity: round(s)})
+			result.Matched++
+			total += s
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && score[i][j] == score[i+1][j]):
+			result.Rows = append(result.Rows, Row{Kind: "onlyA", A: &a[i]})
+			result.OnlyA++
+			i++
+		default:
+			result.Rows = append(result.Rows, Row{Kind: "onlyB", B: &b[j]})
+			result.OnlyB++
+			j++
+		}
+	}
+
+	if n := len(a) + len(b); n > 0 {
+		result.Similarity = round(2 * total / float64(n))
+	}
+	return result
+}
+
+func words(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 2 {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func round(v float64) float64 {
+	return float64(int(v*1000+0.5)) / 1000
+}
@@ -0,0 +1,255 @@
+// Package duplicates finds conversations that hold the same messages under
+// different IDs, as repeated imports of overlapping export snapshots leave
+// behind, and merges them into one.
+package duplicates
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/redact"
+	"zatGPT/internal/tokenizer"
+)
+
+// DefaultMinOverlap is the share of the smaller conversation's messages that
+// must also appear in the other one for the two to count as duplicates.
+const DefaultMinOverlap = 0.5
+
+// minChars is the shortest message counted, so "thanks" and "continue" do
+// not tie unrelated conversations together.
+const minChars = 20
+
+// maxPostings skips messages found in more conversations than this, such as
+// a prompt template pasted into every chat.
+const maxPostings = 50
+
+// Member is one conversation of a Group. Shared counts its messages that
+// also appear in another member.
+type Member struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Messages  int       `json:"messages"`
+	Shared    int       `json:"sharedMessages"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Group is a set of probable duplicates, largest conversation first, which
+// is the one Merge keeps when the IDs are passed in this order. Similarity
+// is the overlap of its most similar pair.
+type Group struct {
+	Conversations []Member `json:"conversations"`
+	Shared        int      `json:"sharedMessages"`
+	Similarity    float64  `json:"similarity"`
+	SameTitle     bool     `json:"sameTitle"`
+}
+
+// Detect groups conversations whose messages overlap by at least minOverlap
+// (of the smaller one's countable messages), or that share a title and at
+// least one message. Messages are compared by author and content, with
+// whitespace collapsed. Groups are returned most shared messages first.
+func Detect(conversations []models.Conversation, minOverlap float64) []Group {
+	if minOverlap <= 0 {
+		minOverlap = DefaultMinOverlap
+	}
+
+	hashes := make([]map[uint64]bool, len(conversations))
+	postings := make(map[uint64][]int)
+	for i, conversation := range conversations {
+		hashes[i] = make(map[uint64]bool)
+		for _, message := range conversation.Messages {
+			key, ok := messageKey(message)
+			if !ok || hashes[i][key] {
+				continue
+			}
+			hashes[i][key] = true
+			postings[key] = append(postings[key], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+	overlaps := make(map[pair]int)
+	for _, members := range postings {
+		if len(members) < 2 || len(members) > maxPostings {
+			continue
+		}
+		for x := range members {
+			for y := x + 1; y < len(members); y++ {
+				overlaps[pair{members[x], members[y]}]++
+			}
+		}
+	}
+
+	parent := make([]int, len(conversations))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	similarity := make(map[int]float64)
+	for p, shared := range overlaps {
+		score := float64(shared) / float64(min(len(hashes[p.a]), len(hashes[p.b])))
+		if score < minOverlap && !sameTitle(conversations[p.a], conversations[p.b]) {
+			continue
+		}
+		ra, rb := root(p.a), root(p.b)
+		parent[ra] = rb
+		similarity[rb] = max(similarity[rb], similarity[ra], score)
+	}
+
+	byRoot := make(map[int][]int)
+	for i := range conversations {
+		byRoot[root(i)] = append(byRoot[root(i)], i)
+	}
+
+	groups := []Group{}
+	for r, members := range byRoot {
+		if len(members) < 2 {
+			continue
+		}
+		counts := make(map[uint64]int)
+		for _, i := range members {
+			for key := range hashes[i] {
+				counts[key]++
+			}
+		}
+		group := Group{Similarity: math.Round(similarity[r]*100) / 100, SameTitle: true}
+		for _, n := range counts {
+			if n > 1 {
+				group.Shared++
+			}
+		}
+		for _, i := range members {
+			conversation := conversations[i]
+			member := Member{ID: conversation.ID, Title: conversation.Title, Messages: len(conversation.Messages), UpdatedAt: conversation.UpdatedAt}
+			for key := range hashes[i] {
+				if counts[key] > 1 {
+					member.Shared++
+				}
+			}
+			group.SameTitle = group.SameTitle && sameTitle(conversation, conversations[members[0]])
+			group.Conversations = append(group.Conversations, member)
+		}
+		slices.SortFunc(group.Conversations, func(a, b Member) int {
+			return cmp.Or(cmp.Compare(b.Messages, a.Messages), b.UpdatedAt.Compare(a.UpdatedAt), cmp.Compare(a.ID, b.ID))
+		})
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b Group) int {
+		return cmp.Or(cmp.Compare(b.Shared, a.Shared), cmp.Compare(a.Conversations[0].ID, b.Conversations[0].ID))
+	})
+	return groups
+}
+
+// Merge folds others into target: the result keeps target's ID, title and
+// settings and holds the union of the messages, tags, attachments and
+// redaction records. A message already in the result (same author and
+// content, or same ID) is not added again. Messages are put in time order
+// when every one has a timestamp; otherwise the added ones follow
+// target's. The message tree is dropped once messages are added, since it
+// describes target's alone, and the tokens are counted again.
+// Merge reports how many messages it added.
+func Merge(target models.Conversation, others ...models.Conversation) (models.Conversation, int) {
+	merged := target
+	merged.Messages = slices.Clone(target.Messages)
+	merged.Tags = slices.Clone(target.Tags)
+	merged.Attachments = slices.Clone(target.Attachments)
+	merged.Redactions = slices.Clone(target.Redactions)
+	merged.StartedAt, merged.EndedAt = target.Span()
+
+	keys := make(map[uint64]bool)
+	messageIDs := make(map[string]bool)
+	for _, message := range merged.Messages {
+		keys[contentKey(message)] = true
+		if message.ID != "" {
+			messageIDs[message.ID] = true
+		}
+	}
+	refs := make(map[string]bool)
+	for _, attachment := range merged.Attachments {
+		refs[attachment.Ref] = true
+	}
+
+	added := 0
+	for _, other := range others {
+		for _, message := range other.Messages {
+			key := contentKey(message)
+			if keys[key] || (message.ID != "" && messageIDs[message.ID]) {
+				continue
+			}
+			keys[key] = true
+			if message.ID != "" {
+				messageIDs[message.ID] = true
+			}
+			merged.Messages = append(merged.Messages, message)
+			added++
+		}
+		for _, tag := range other.Tags {
+			if !slices.Contains(merged.Tags, tag) {
+				merged.Tags = append(merged.Tags, tag)
+			}
+		}
+		for _, attachment := range other.Attachments {
+			if !refs[attachment.Ref] {
+				refs[attachment.Ref] = true
+				merged.Attachments = append(merged.Attachments, attachment)
+			}
+		}
+		if merged.Summary == "" {
+			merged.Summary = other.Summary
+		}
+		started, ended := other.Span()
+		if other.DateStarted != "" && (merged.DateStarted == "" || other.DateStarted < merged.DateStarted) {
+			merged.DateStarted, merged.StartedAt = other.DateStarted, started
+		}
+		if other.DateEnded > merged.DateEnded {
+			merged.DateEnded, merged.EndedAt = other.DateEnded, ended
+		}
+		if other.CreatedAt.Before(merged.CreatedAt) && !other.CreatedAt.IsZero() {
+			merged.CreatedAt = other.CreatedAt
+		}
+		merged.Starred = merged.Starred || other.Starred
+		merged.Redactions = redact.Merge(merged.Redactions, other.Redactions)
+	}
+
+	if added > 0 {
+		merged.Tree = nil
+		timed := !slices.ContainsFunc(merged.Messages, func(m models.Message) bool { return m.CreatedAt.IsZero() })
+		if timed {
+			slices.SortStableFunc(merged.Messages, func(a, b models.Message) int { return a.CreatedAt.Compare(b.CreatedAt) })
+		}
+		tokenizer.CountConversation(&merged)
+	}
+	return merged, added
+}
+
+// messageKey hashes a message for Detect; ok is false for messages too short
+// to tell conversations apart.
+func messageKey(message models.Message) (uint64, bool) {
+	if utf8.RuneCountInString(strings.TrimSpace(message.Content)) < minChars {
+		return 0, false
+	}
+	return contentKey(message), true
+}
+
+func contentKey(message models.Message) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(message.Author))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(message.Content), " ")))
+	return h.Sum64()
+}
+
+func sameTitle(a, b models.Conversation) bool {
+	return strings.EqualFold(strings.TrimSpace(a.Title), strings.TrimSpace(b.Title))
+}
@@ -0,0 +1,153 @@
+// Package embeddings computes vector embeddings of conversations and their
+// messages with an OpenAI-compatible embeddings endpoint, and ranks
+// conversations by cosine similarity to a query, which finds paraphrased
+// topics keyword search misses.
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+// OpenAIURL is the endpoint the "openai" provider spec stands for.
+const OpenAIURL = "https://api.openai.com/v1/embeddings"
+
+// Provider turns texts into vectors.
+type Provider interface {
+	Embed(ctx context.Context, texts []string) ([]models.Vector, error)
+	// Model names the model in stored embeddings, so vectors from
+	// different models are never compared.
+	Model() string
+}
+
+// New resolves a provider spec: "openai" for OpenAI's API, or the http(s)
+// URL of an OpenAI-compatible /v1/embeddings endpoint, such as a local
+// Ollama, LM Studio or llama.cpp server. An empty spec disables semantic
+// search and returns a nil Provider.
+func New(spec, model, apiKey string) (Provider, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == "openai":
+		if model == "" {
+			model = "text-embedding-3-small"
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("the openai provider needs an API key")
+		}
+		spec = OpenAIURL
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if model == "" {
+			return nil, fmt.Errorf("an embeddings endpoint needs a model name")
+		}
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q (want openai or an http(s) URL)", spec)
+	}
+	return &HTTP{
+		URL:    spec,
+		Name:   model,
+		APIKey: apiKey,
+		Client: &http.Client{Timeout: 2 * time.Minute, Transport: telemetry.Transport(nil)},
+	}, nil
+}
+
+// HTTP calls an OpenAI-compatible /v1/embeddings endpoint.
+type HTTP struct {
+	URL    string
+	Name   string
+	APIKey string
+	Client *http.Client
+}
+
+func (h *HTTP) Model() string { return h.Name }
+
+func (h *HTTP) Embed(ctx context.Context, texts []string) ([]models.Vector, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": h.Name,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embeddings endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+
+	var payload struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings endpoint returned %d vectors for %d texts", len(payload.Data), len(texts))
+	}
+	out := make([]models.Vector, len(texts))
+	for _, item := range payload.Data {
+		if item.Index < 0 || item.Index >= len(out) || len(item.Embedding) == 0 {
+			return nil, fmt.Errorf("embeddings endpoint returned a malformed vector")
+		}
+		out[item.Index] = normalize(item.Embedding)
+	}
+	return out, nil
+}
+
+// normalize scales v to unit length, so cosine similarity is a dot
+// product.
+func normalize(v []float32) models.Vector {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// dot is the cosine similarity of two unit vectors; vectors of different
+// lengths, from another model, score 0.
+func dot(a, b models.Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
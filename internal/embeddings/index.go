@@ -0,0 +1,265 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+const (
+	// maxTextRunes trims long messages to what small embedding models
+	// accept; the opening of a message says most about its topic.
+	maxTextRunes = 6000
+	// requestTexts is how many texts go into one embeddings request.
+	requestTexts = 64
+	// saveEvery is how many conversations are embedded between saves.
+	saveEvery = 20
+	// topMessages is how many of a conversation's best messages a Hit
+	// lists.
+	topMessages = 3
+)
+
+// Store is the persistence the indexer needs; *storage.Store satisfies it.
+type Store interface {
+	Get(id string) (models.Conversation, error)
+	StaleEmbeddings(model string) []string
+	Embeddings(model string) []models.Embedding
+	SaveEmbeddings(embeddings []models.Embedding) error
+}
+
+// Indexer keeps the store's embeddings up to date and searches them.
+type Indexer struct {
+	Provider Provider
+	Store    Store
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Status reports the indexer's progress.
+type Status struct {
+	Model     string    `json:"model"`
+	Indexed   int       `json:"indexed"`
+	Pending   int       `json:"pending"`
+	LastRun   time.Time `json:"lastRun,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// Hit is a conversation that matched a semantic search. Score is the best
+// cosine similarity of its title and summary or any of its messages.
+type Hit struct {
+	ConversationID string         `json:"conversationId"`
+	Score          float64        `json:"score"`
+	Messages       []MessageScore `json:"messages,omitempty"`
+}
+
+// MessageScore is one message's similarity to the query.
+type MessageScore struct {
+	MessageID string  `json:"messageId"`
+	Score     float64 `json:"score"`
+}
+
+// NewIndexer returns an Indexer embedding store's conversations with
+// provider.
+func NewIndexer(provider Provider, store Store) *Indexer {
+	return &Indexer{Provider: provider, Store: store}
+}
+
+// Run embeds stale conversations immediately and then every interval until
+// ctx is cancelled, reporting each pass through logf.
+func (ix *Indexer) Run(ctx context.Context, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := ix.IndexStale(ctx)
+		if err != nil && ctx.Err() == nil {
+			logf("embedding failed: %v", err)
+		} else if n > 0 {
+			logf("embedded %d conversations", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IndexStale embeds every conversation that has no embedding from the
+// provider's model or changed since it got one, saving as it goes so an
+// interrupted pass keeps its work. It returns how many it embedded.
+func (ix *Indexer) IndexStale(ctx context.Context) (int, error) {
+	ctx, span := telemetry.Start(ctx, "embeddings.IndexStale")
+	n, err := ix.indexStale(ctx)
+	telemetry.End(span, err)
+
+	ix.mu.Lock()
+	ix.status.LastRun = time.Now().UTC()
+	ix.status.LastError = ""
+	if err != nil {
+		ix.status.LastError = err.Error()
+	}
+	ix.mu.Unlock()
+	return n, err
+}
+
+func (ix *Indexer) indexStale(ctx context.Context) (int, error) {
+	model := ix.Provider.Model()
+	done := 0
+	var batch []models.Embedding
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := ix.Store.SaveEmbeddings(batch)
+		if err == nil {
+			done += len(batch)
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	for _, id := range ix.Store.StaleEmbeddings(model) {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		convo, err := ix.Store.Get(id)
+		if err != nil {
+			continue
+		}
+		embedding, err := ix.embedConversation(ctx, convo)
+		if err != nil {
+			if flushErr := flush(); flushErr != nil {
+				return done, flushErr
+			}
+			return done, err
+		}
+		batch = append(batch, embedding)
+		if len(batch) >= saveEvery {
+			if err := flush(); err != nil {
+				return done, err
+			}
+		}
+	}
+	return done, flush()
+}
+
+// embedConversation embeds convo's title and summary and each message with
+// text.
+func (ix *Indexer) embedConversation(ctx context.Context, convo models.Conversation) (models.Embedding, error) {
+	texts := []string{trimText(strings.TrimSpace(convo.Title + "\n\n" + convo.Summary))}
+	var messageIDs []string
+	for _, msg := range convo.Messages {
+		text := strings.TrimSpace(msg.Content)
+		if text == "" {
+			continue
+		}
+		texts = append(texts, trimText(text))
+		messageIDs = append(messageIDs, msg.ID)
+	}
+	if texts[0] == "" {
+		texts[0] = convo.ID
+	}
+
+	vectors := make([]models.Vector, 0, len(texts))
+	for start := 0; start < len(texts); start += requestTexts {
+		end := min(start+requestTexts, len(texts))
+		out, err := ix.Provider.Embed(ctx, texts[start:end])
+		if err != nil {
+			return models.Embedding{}, err
+		}
+		vectors = append(vectors, out...)
+	}
+
+	embedding := models.Embedding{
+		ConversationID:  convo.ID,
+		Model:           ix.Provider.Model(),
+		Vector:          vectors[0],
+		SourceUpdatedAt: convo.UpdatedAt,
+		GeneratedAt:     time.Now().UTC(),
+	}
+	for i, id := range messageIDs {
+		embedding.Messages = append(embedding.Messages, models.MessageEmbedding{MessageID: id, Vector: vectors[i+1]})
+	}
+	return embedding, nil
+}
+
+func trimText(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxTextRunes {
+		return text
+	}
+	return string(runes[:maxTextRunes])
+}
+
+// ErrEmptyQuery is returned by Search for a blank query.
+var ErrEmptyQuery = errors.New("query is empty")
+
+// Search embeds query and ranks every embedded conversation by similarity
+// to it, best first, returning the window from offset and the total.
+// Conversations not embedded yet are left out.
+func (ix *Indexer) Search(ctx context.Context, query string, offset, limit int) ([]Hit, int, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, 0, ErrEmptyQuery
+	}
+	vectors, err := ix.Provider.Embed(ctx, []string{trimText(query)})
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := Rank(vectors[0], ix.Store.Embeddings(ix.Provider.Model()))
+	total := len(hits)
+	start := min(max(offset, 0), total)
+	end := total
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return hits[start:end], total, nil
+}
+
+// Rank scores every embedding against the unit vector query, best first.
+func Rank(query models.Vector, embeddings []models.Embedding) []Hit {
+	hits := make([]Hit, 0, len(embeddings))
+	for _, embedding := range embeddings {
+		hit := Hit{ConversationID: embedding.ConversationID, Score: dot(query, embedding.Vector)}
+		scores := make([]MessageScore, 0, len(embedding.Messages))
+		for _, msg := range embedding.Messages {
+			score := dot(query, msg.Vector)
+			scores = append(scores, MessageScore{MessageID: msg.MessageID, Score: score})
+			hit.Score = max(hit.Score, score)
+		}
+		sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+		if len(scores) > topMessages {
+			scores = scores[:topMessages]
+		}
+		hit.Messages = scores
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score == hits[j].Score {
+			return hits[i].ConversationID < hits[j].ConversationID
+		}
+		return hits[i].Score > hits[j].Score
+	})
+	return hits
+}
+
+// Status reports the provider's model, how many conversations are
+// embedded and how many wait for the next pass.
+func (ix *Indexer) Status() Status {
+	ix.mu.Lock()
+	status := ix.status
+	ix.mu.Unlock()
+	status.Model = ix.Provider.Model()
+	status.Indexed = len(ix.Store.Embeddings(status.Model))
+	status.Pending = len(ix.Store.StaleEmbeddings(status.Model))
+	return status
+}
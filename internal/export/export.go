@@ -0,0 +1,192 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Format identifies an export encoding.
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatPDF      Format = "pdf"
+)
+
+// ParseFormat resolves a user-supplied format name, defaulting to JSON.
+func ParseFormat(raw string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	case "pdf":
+		return FormatPDF, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", raw)
+	}
+}
+
+// ContentType returns the MIME type for the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the conventional file extension, including the dot.
+func (f Format) Extension() string {
+	switch f {
+	case FormatMarkdown:
+		return ".md"
+	case FormatHTML:
+		return ".html"
+	case FormatPDF:
+		return ".pdf"
+	default:
+		return ".json"
+	}
+}
+
+// Write encodes conversations in the given format.
+func Write(w io.Writer, format Format, conversations []models.Conversation) error {
+	switch format {
+	case FormatMarkdown:
+		for i, convo := range conversations {
+			if i > 0 {
+				if _, err := io.WriteString(w, "\n---\n\n"); err != nil {
+					return err
+				}
+			}
+			if err := writeMarkdown(w, convo); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatHTML:
+		return writeHTML(w, conversations)
+	case FormatPDF:
+		return writePDF(w, conversations)
+	default:
+		payload := struct {
+			ExportedAt    time.Time             `json:"exportedAt"`
+			Count         int                   `json:"count"`
+			Conversations []models.Conversation `json:"conversations"`
+		}{
+			ExportedAt:    time.Now().UTC(),
+			Count:         len(conversations),
+			Conversations: conversations,
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(&payload)
+	}
+}
+
+// WriteConversation encodes a single conversation in the given format.
+func WriteConversation(w io.Writer, format Format, convo models.Conversation) error {
+	switch format {
+	case FormatMarkdown:
+		return writeMarkdown(w, convo)
+	case FormatHTML:
+		return writeHTML(w, []models.Conversation{convo})
+	case FormatPDF:
+		return writePDF(w, []models.Conversation{convo})
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(&convo)
+	}
+}
+
+func writeMarkdown(w io.Writer, convo models.Conversation) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", convo.Title)
+	if convo.DateStarted != "" || convo.DateEnded != "" {
+		fmt.Fprintf(&b, "- Started: %s\n- Ended: %s\n", orDash(convo.DateStarted), orDash(convo.DateEnded))
+	}
+	if len(convo.Tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(convo.Tags, ", "))
+	}
+	if convo.Summary != "" {
+		fmt.Fprintf(&b, "\n> %s\n", strings.ReplaceAll(convo.Summary, "\n", "\n> "))
+	}
+
+	for _, msg := range convo.Messages {
+		fmt.Fprintf(&b, "\n## %s", roleLabel(msg.Author))
+		if !msg.CreatedAt.IsZero() {
+			fmt.Fprintf(&b, " (%s)", msg.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintf(&b, "\n\n%s\n", msg.Content)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "You"
+	case "assistant":
+		return "ChatGPT"
+	default:
+		if role == "" {
+			return "Unknown"
+		}
+		return strings.ToUpper(role[:1]) + role[1:]
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Filename builds a filesystem-friendly name for a conversation export.
+func Filename(convo models.Conversation, format Format) string {
+	return Slug(convo.Title, convo.ID) + format.Extension()
+}
+
+// Slug lowercases title into a dash-separated name, falling back to fallback
+// when nothing usable remains.
+func Slug(title, fallback string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = fallback
+	}
+	return slug
+}
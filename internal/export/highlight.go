@@ -0,0 +1,166 @@
+package export
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// syntax describes just enough of a language to colour its keywords,
+// strings, comments and numbers.
+type syntax struct {
+	keywords     map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+func words(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	cStyle = syntax{lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`}
+	hashes = syntax{lineComments: []string{"#"}, quotes: `"'`}
+)
+
+// syntaxes is keyed by snippets.NormalizeLanguage names.
+var syntaxes = map[string]syntax{
+	"go": {keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: "\"'`"},
+	"javascript": {keywords: words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new null of return super switch this throw true false try typeof undefined var void while yield"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: "\"'`"},
+	"typescript": {keywords: words("abstract any as async await boolean break case catch class const continue declare default delete do else enum export extends false finally for from function if implements import in instanceof interface let namespace never new null number of private protected public readonly return string super switch this throw true try type typeof undefined unknown var void while yield"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: "\"'`"},
+	"python": {keywords: words("and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield self"),
+		lineComments: hashes.lineComments, quotes: hashes.quotes},
+	"bash": {keywords: words("case do done elif else esac export fi for function if in local return then until while echo"),
+		lineComments: hashes.lineComments, quotes: hashes.quotes},
+	"ruby": {keywords: words("begin class def do else elsif end ensure false if module next nil require rescue return self then true unless until when while yield"),
+		lineComments: hashes.lineComments, quotes: hashes.quotes},
+	"rust": {keywords: words("as async await break const continue crate else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: `"`},
+	"java": {keywords: words("abstract boolean break case catch char class continue default do double else enum extends false final finally float for if implements import instanceof int interface long new null package private protected public return static super switch this throw throws true try void while"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: cStyle.quotes},
+	"c": {keywords: words("break case char const continue default do double else enum extern float for goto if int long return short signed sizeof static struct switch typedef union unsigned void volatile while NULL"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: cStyle.quotes},
+	"cpp": {keywords: words("auto bool break case catch char class const constexpr continue default delete do double else enum false float for if include int long namespace new nullptr private protected public return static struct switch template this throw true try typename using virtual void while"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: cStyle.quotes},
+	"csharp": {keywords: words("async await bool break case catch class const continue default do else enum false finally for foreach if in int interface namespace new null private protected public return static string switch this throw true try using var void while"),
+		lineComments: cStyle.lineComments, blockComment: cStyle.blockComment, quotes: cStyle.quotes},
+	"sql": {keywords: words("select from where and or not insert into values update set delete create table index primary key foreign references join left right inner outer on group by order having limit as distinct null is in exists union all case when then else end"),
+		lineComments: []string{"--"}, blockComment: cStyle.blockComment, quotes: `'"`},
+	"yaml":      {keywords: words("true false null yes no"), lineComments: hashes.lineComments, quotes: hashes.quotes},
+	"terraform": {keywords: words("resource data variable output module provider locals terraform true false null for in if"), lineComments: []string{"#", "//"}, blockComment: cStyle.blockComment, quotes: `"`},
+	"json":      {keywords: words("true false null"), quotes: `"`},
+}
+
+// highlight HTML-escapes code and wraps its tokens in <span class="tok-*">
+// (kw, str, com, num). Languages it does not know are only escaped.
+func highlight(code, lang string) string {
+	syn, ok := syntaxes[lang]
+	if !ok {
+		return html.EscapeString(code)
+	}
+	// SQL keywords are case-insensitive.
+	fold := lang == "sql"
+
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="tok-` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString(`</span>`)
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if end := commentEnd(rest, syn); end > 0 {
+			span("com", rest[:end])
+			i += end
+			continue
+		}
+		if strings.ContainsRune(syn.quotes, rune(rest[0])) {
+			end := stringEnd(rest)
+			span("str", rest[:end])
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case unicode.IsDigit(r):
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !unicode.IsDigit(r) && !unicode.IsLetter(r) && r != '.' && r != '_'
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			span("num", rest[:end])
+			i += end
+		case unicode.IsLetter(r) || r == '_':
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			word := rest[:end]
+			if syn.keywords[word] || fold && syn.keywords[strings.ToLower(word)] {
+				span("kw", word)
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i += end
+		default:
+			b.WriteString(html.EscapeString(rest[:size]))
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// commentEnd returns the length of the comment code starts with, or 0.
+func commentEnd(code string, syn syntax) int {
+	for _, marker := range syn.lineComments {
+		if strings.HasPrefix(code, marker) {
+			if end := strings.IndexByte(code, '\n'); end >= 0 {
+				return end
+			}
+			return len(code)
+		}
+	}
+	if open, close := syn.blockComment[0], syn.blockComment[1]; open != "" && strings.HasPrefix(code, open) {
+		if end := strings.Index(code[len(open):], close); end >= 0 {
+			return len(open) + end + len(close)
+		}
+		return len(code)
+	}
+	return 0
+}
+
+// stringEnd returns the length of the string literal code starts with,
+// honouring backslash escapes. Strings other than backquoted ones end at
+// the line, so an unbalanced quote does not swallow the rest of the block.
+func stringEnd(code string) int {
+	quote := code[0]
+	for i := 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
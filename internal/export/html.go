@@ -0,0 +1,153 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/snippets"
+)
+
+// writeHTML renders conversations as one standalone page: the styles are
+// inline and nothing is fetched, so the file can be mailed or opened
+// offline.
+func writeHTML(w io.Writer, conversations []models.Conversation) error {
+	title := fmt.Sprintf("%d conversations", len(conversations))
+	if len(conversations) == 1 {
+		title = conversations[0].Title
+	}
+	return htmlTemplate.Execute(w, struct {
+		Title         string
+		Conversations []models.Conversation
+		ExportedAt    time.Time
+	}{title, conversations, time.Now().UTC()})
+}
+
+// renderMessage turns a message's Markdown-ish content into HTML: fenced
+// blocks become highlighted <pre> blocks, blank lines separate paragraphs
+// and `inline code` is kept as such. Everything else is escaped as text.
+func renderMessage(content string) template.HTML {
+	var b strings.Builder
+	for _, part := range snippets.Parts(content) {
+		if part.Fenced {
+			b.WriteString(`<figure class="code">`)
+			if part.Language != "" {
+				fmt.Fprintf(&b, `<figcaption>%s</figcaption>`, html.EscapeString(part.Language))
+			}
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre></figure>\n", highlight(part.Text, part.Language))
+			continue
+		}
+		for _, paragraph := range strings.Split(part.Text, "\n\n") {
+			if paragraph = strings.Trim(paragraph, "\n"); strings.TrimSpace(paragraph) == "" {
+				continue
+			}
+			b.WriteString("<p>")
+			pieces := strings.Split(paragraph, "`")
+			for i, piece := range pieces {
+				// Odd pieces sit between backticks, except after an
+				// unmatched last one, which is kept as text.
+				switch {
+				case i%2 == 0:
+					b.WriteString(lineBreaks(piece))
+				case i < len(pieces)-1:
+					b.WriteString("<code>" + html.EscapeString(piece) + "</code>")
+				default:
+					b.WriteString("`" + lineBreaks(piece))
+				}
+			}
+			b.WriteString("</p>\n")
+		}
+	}
+	return template.HTML(b.String())
+}
+
+func lineBreaks(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n")
+}
+
+// avatarClass picks the avatar colour of a role.
+func avatarClass(role string) string {
+	switch role {
+	case "user", "assistant", "system", "tool":
+		return role
+	default:
+		return "other"
+	}
+}
+
+func messageTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// pageStyle styles the HTML export and every page of a static site.
+const pageStyle = `body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #1f2933; line-height: 1.55; }
+header.conversation { border-bottom: 1px solid #e5e7eb; margin: 2.5rem 0 1.5rem; padding-bottom: 1rem; }
+header.conversation h1 { margin: 0 0 0.5rem; font-size: 1.75rem; }
+.meta { color: #6b7280; font-size: 0.9rem; margin: 0; }
+.tags span { display: inline-block; margin-right: 0.35rem; padding: 0.1rem 0.55rem; background: #eef2ff; border-radius: 1rem; font-size: 0.85rem; }
+blockquote { margin: 1rem 0 0; padding: 0.5rem 1rem; background: #f9fafb; border-left: 3px solid #c7d2fe; }
+.message { display: flex; gap: 0.85rem; margin: 1.25rem 0; }
+.avatar { flex: none; width: 2.25rem; height: 2.25rem; border-radius: 50%; color: #fff; font-weight: 600; display: flex; align-items: center; justify-content: center; }
+.avatar.user { background: #6366f1; }
+.avatar.assistant { background: #10a37f; }
+.avatar.system, .avatar.tool, .avatar.other { background: #9ca3af; }
+.bubble { flex: 1; min-width: 0; }
+.bubble .who { font-weight: 600; }
+.bubble .who time, .bubble .who .model { color: #6b7280; font-weight: normal; font-size: 0.85rem; margin-left: 0.5rem; }
+.bubble p { margin: 0.4rem 0; overflow-wrap: anywhere; }
+.bubble p code { background: #f3f4f6; border-radius: 0.25rem; padding: 0.05rem 0.3rem; font-size: 0.9em; }
+figure.code { margin: 0.6rem 0; background: #0f172a; border-radius: 0.5rem; overflow: hidden; }
+figure.code figcaption { color: #94a3b8; font-size: 0.75rem; padding: 0.35rem 0.85rem 0; }
+figure.code pre { margin: 0; padding: 0.6rem 0.85rem 0.8rem; overflow-x: auto; color: #e2e8f0; font-size: 0.85rem; line-height: 1.45; }
+.tok-kw { color: #c792ea; }
+.tok-str { color: #c3e88d; }
+.tok-com { color: #7f8ba0; font-style: italic; }
+.tok-num { color: #f78c6c; }
+footer { color: #9ca3af; font-size: 0.8rem; margin: 3rem 0 1rem; text-align: center; }
+`
+
+var htmlTemplate = template.Must(template.New("conversations").Funcs(template.FuncMap{
+	"role":    roleLabel,
+	"initial": func(role string) string { return roleLabel(role)[:1] },
+	"avatar":  avatarClass,
+	"render":  renderMessage,
+	"time":    messageTime,
+	"date":    func(t time.Time) string { return t.Format("2006-01-02 15:04 UTC") },
+	"style":   func() template.CSS { return template.CSS(pageStyle) },
+	// tagHref links a tag to its page; exports have none.
+	"tagHref": func(string) string { return "" },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+{{style}}</style>
+</head>
+<body>
+{{range .Conversations}}{{template "article" .}}{{end}}<footer>Exported {{date .ExportedAt}}</footer>
+</body>
+</html>
+{{define "article"}}<article>
+<header class="conversation">
+<h1>{{.Title}}</h1>
+{{if or .DateStarted .DateEnded}}<p class="meta">{{with .DateStarted}}Started {{.}}{{end}}{{if and .DateStarted .DateEnded}} · {{end}}{{with .DateEnded}}Ended {{.}}{{end}}</p>
+{{end}}{{if .Tags}}<p class="meta tags">{{range .Tags}}{{$href := tagHref .}}{{if $href}}<a href="{{$href}}"><span>{{.}}</span></a>{{else}}<span>{{.}}</span>{{end}}{{end}}</p>
+{{end}}{{with .Summary}}<blockquote>{{.}}</blockquote>
+{{end}}</header>
+{{range .Messages}}<div class="message">
+<div class="avatar {{avatar .Author}}" aria-hidden="true">{{initial .Author}}</div>
+<div class="bubble">
+<div class="who">{{role .Author}}{{with .Model}}<span class="model">{{.}}</span>{{end}}{{with time .CreatedAt}}<time>{{.}}</time>{{end}}</div>
+{{render .Content}}</div>
+</div>
+{{end}}</article>
+{{end}}`))
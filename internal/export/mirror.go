@@ -0,0 +1,313 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// DefaultLayout files each conversation by the month it started.
+const DefaultLayout = "{year}/{month}/{slug}"
+
+// mirrorManifest is the file in a mirror directory recording what Sync
+// wrote, so later runs only rewrite what changed and only delete their own
+// files.
+const mirrorManifest = ".zatgpt-mirror.json"
+
+// layoutFields are the placeholders a Mirror layout may use.
+var layoutFields = []string{"{year}", "{month}", "{day}", "{slug}", "{id}"}
+
+// MirrorStore is what a Mirror reads; *storage.Store satisfies it.
+type MirrorStore interface {
+	List() []models.Conversation
+	Get(id string) (models.Conversation, error)
+}
+
+// Mirror keeps a directory of one file per conversation and format in step
+// with a store, such as a notes vault that should hold the archive as
+// Markdown.
+type Mirror struct {
+	Dir     string
+	Formats []Format
+	// Layout is the path of a conversation's files below Dir, without the
+	// extension. {year}, {month} and {day} are the day it was created
+	// (UTC), {slug} its title as Slug makes it and {id} its ID. Empty uses
+	// DefaultLayout.
+	Layout string
+}
+
+// MirrorResult counts what one Sync did.
+type MirrorResult struct {
+	Written   int
+	Unchanged int
+	Removed   int
+}
+
+// mirrorEntry is a conversation's record in the manifest.
+type mirrorEntry struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+	Files     []string  `json:"files"`
+}
+
+// NewMirror checks formats and layout and returns a Mirror writing to dir.
+// PDF is refused: a mirror is meant to be read and diffed as text.
+func NewMirror(dir string, formats []Format, layout string) (*Mirror, error) {
+	if dir == "" {
+		return nil, errors.New("a directory is required")
+	}
+	if len(formats) == 0 {
+		formats = []Format{FormatMarkdown}
+	}
+	for _, format := range formats {
+		if format == FormatPDF {
+			return nil, fmt.Errorf("the %s format cannot be mirrored", format)
+		}
+	}
+	if layout == "" {
+		layout = DefaultLayout
+	}
+	if !strings.Contains(layout, "{slug}") && !strings.Contains(layout, "{id}") {
+		return nil, fmt.Errorf("layout %q must contain {slug} or {id}", layout)
+	}
+	rest := layout
+	for _, field := range layoutFields {
+		rest = strings.ReplaceAll(rest, field, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return nil, fmt.Errorf("layout %q has an unknown placeholder (want %s)", layout, strings.Join(layoutFields, ", "))
+	}
+	if path.IsAbs(layout) || slices.Contains(strings.Split(layout, "/"), "..") {
+		return nil, fmt.Errorf("layout %q must stay inside the directory", layout)
+	}
+	return &Mirror{Dir: dir, Formats: formats, Layout: layout}, nil
+}
+
+// Sync writes the files of every conversation that changed since the last
+// Sync, moves those whose title or date gave them a new path, and deletes
+// the files of conversations no longer in the store. Files Sync did not
+// write are never touched. Two conversations that would share a path are
+// told apart by a short ID suffix.
+func (m *Mirror) Sync(ctx context.Context, store MirrorStore) (MirrorResult, error) {
+	var result MirrorResult
+	previous, err := m.readManifest()
+	if err != nil {
+		return result, err
+	}
+
+	items := store.List()
+	// Oldest first, so a conversation keeps its path when a newer one
+	// with the same title arrives.
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	next := make(map[string]mirrorEntry, len(items))
+	taken := make(map[string]bool, len(items))
+	files := make(map[string]bool, len(items)*len(m.Formats))
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		base := m.path(item, "")
+		if taken[base] {
+			base = m.path(item, "-"+shortID(item.ID))
+		}
+		taken[base] = true
+
+		entry := mirrorEntry{UpdatedAt: item.UpdatedAt}
+		for _, format := range m.Formats {
+			entry.Files = append(entry.Files, base+format.Extension())
+			files[base+format.Extension()] = true
+		}
+		next[item.ID] = entry
+
+		if old, ok := previous[item.ID]; ok && old.UpdatedAt.Equal(item.UpdatedAt) && slices.Equal(old.Files, entry.Files) && m.exists(entry.Files) {
+			result.Unchanged++
+			continue
+		}
+		convo, err := store.Get(item.ID)
+		if err != nil {
+			// Deleted since List: keep its old files for the next Sync to
+			// remove.
+			if old, ok := previous[item.ID]; ok {
+				next[item.ID] = old
+			} else {
+				delete(next, item.ID)
+			}
+			continue
+		}
+		for i, format := range m.Formats {
+			if err := m.writeFile(entry.Files[i], format, convo); err != nil {
+				return result, err
+			}
+		}
+		result.Written++
+	}
+
+	// A file another conversation has moved into stays.
+	for id, old := range previous {
+		current := next[id].Files
+		removed := false
+		for _, file := range old.Files {
+			if files[file] {
+				continue
+			}
+			if err := m.remove(file); err != nil {
+				return result, err
+			}
+			removed = true
+		}
+		if removed && len(current) == 0 {
+			result.Removed++
+		}
+	}
+
+	return result, m.writeManifest(next)
+}
+
+// Run syncs the mirror immediately and then every interval until ctx is
+// cancelled, reporting each pass through logf.
+func (m *Mirror) Run(ctx context.Context, store MirrorStore, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := m.Sync(ctx, store)
+		if err != nil && ctx.Err() == nil {
+			logf("export to %s failed: %v", m.Dir, err)
+		} else if result.Written > 0 || result.Removed > 0 {
+			logf("exported %d conversations to %s, removed %d", result.Written, m.Dir, result.Removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// path fills in the layout for convo, with suffix after the slug (or the
+// ID, for a layout without one).
+func (m *Mirror) path(convo models.Conversation, suffix string) string {
+	day := convo.CreatedAt
+	if day.IsZero() {
+		day = convo.UpdatedAt
+	}
+	day = day.UTC()
+	slug, id := Slug(convo.Title, convo.ID), convo.ID
+	if strings.Contains(m.Layout, "{slug}") {
+		slug += suffix
+	} else {
+		id += suffix
+	}
+	return strings.NewReplacer(
+		"{year}", day.Format("2006"),
+		"{month}", day.Format("01"),
+		"{day}", day.Format("02"),
+		"{slug}", slug,
+		"{id}", id,
+	).Replace(m.Layout)
+}
+
+func shortID(id string) string {
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) > 8 {
+		return id[len(id)-8:]
+	}
+	return id
+}
+
+// writeFile writes convo to the relative path name through a temporary
+// file, so a reader never sees half a file.
+func (m *Mirror) writeFile(name string, format Format, convo models.Conversation) error {
+	target := filepath.Join(m.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = WriteConversation(file, format, convo)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// remove deletes the relative path name and then any directories it
+// leaves empty, up to Dir.
+func (m *Mirror) remove(name string) error {
+	target := filepath.Join(m.Dir, filepath.FromSlash(name))
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	root := filepath.Clean(m.Dir)
+	for dir := filepath.Dir(target); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Mirror) exists(files []string) bool {
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(m.Dir, filepath.FromSlash(name))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Mirror) readManifest() (map[string]mirrorEntry, error) {
+	data, err := os.ReadFile(filepath.Join(m.Dir, mirrorManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]mirrorEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]mirrorEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", mirrorManifest, err)
+	}
+	return entries, nil
+}
+
+func (m *Mirror) writeManifest(entries map[string]mirrorEntry) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(m.Dir, mirrorManifest)
+	if err := os.WriteFile(target+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(target+".tmp", target)
+}
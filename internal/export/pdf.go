@@ -0,0 +1,411 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/snippets"
+)
+
+// The PDF export lays conversations out on A4 pages in the Go fonts, each
+// embedded as a subset of the glyphs the document uses. Text is written as
+// glyph indices (Identity-H) with a ToUnicode map, so it can be searched
+// and copied; characters the fonts have no glyph for print as "?".
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 56.0
+	pdfBottom     = 64.0
+)
+
+// pdfFont is one of the embedded fonts, named /F1.. in every page.
+type pdfFont struct {
+	resource string
+	base     string
+	flags    int
+	ttf      *trueType
+}
+
+var (
+	pdfRegular = &pdfFont{"F1", "Go-Regular", 32, parseTrueType(goregular.TTF)}
+	pdfBold    = &pdfFont{"F2", "Go-Bold", 32, parseTrueType(gobold.TTF)}
+	pdfItalic  = &pdfFont{"F3", "Go-Italic", 32 | 64, parseTrueType(goitalic.TTF)}
+	pdfMono    = &pdfFont{"F4", "Go-Mono", 32 | 1, parseTrueType(gomono.TTF)}
+	pdfFonts   = []*pdfFont{pdfRegular, pdfBold, pdfItalic, pdfMono}
+)
+
+// pdfText drops the control characters text may hold, expanding tabs to
+// four spaces.
+func pdfText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 && r != '\t' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(text, "\t", "    "))
+}
+
+func (f *pdfFont) width(text string, size float64) float64 {
+	units := 0
+	for _, r := range text {
+		units += f.ttf.glyph(r).width
+	}
+	return float64(units) * size / 1000
+}
+
+// wrap breaks text into lines no wider than width, splitting words that
+// are wider on their own.
+func (f *pdfFont) wrap(text string, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if f.width(candidate, size) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		for f.width(word, size) > width {
+			runes := []rune(word)
+			n := 1
+			for n < len(runes) && f.width(string(runes[:n+1]), size) <= width {
+				n++
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfWriter accumulates the content stream of each page, noting the glyphs
+// each font draws so only those are embedded.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+	used  map[*pdfFont]map[uint16]rune
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// need starts a new page unless height fits above the bottom margin.
+func (p *pdfWriter) need(height float64) {
+	if p.page == nil || p.y-height < pdfBottom {
+		p.newPage()
+	}
+}
+
+// text writes text as a hex string of two-byte glyph indices.
+func (p *pdfWriter) text(font *pdfFont, size, x, y float64, gray float64, text string) {
+	if p.used == nil {
+		p.used = map[*pdfFont]map[uint16]rune{}
+	}
+	used := p.used[font]
+	if used == nil {
+		used = map[uint16]rune{}
+		p.used[font] = used
+	}
+	var glyphs strings.Builder
+	for _, r := range text {
+		g := font.ttf.glyph(r)
+		used[g.index] = g.char
+		fmt.Fprintf(&glyphs, "%04X", g.index)
+	}
+	fmt.Fprintf(p.page, "%.3f g BT /%s %.1f Tf %.2f %.2f Td <%s> Tj ET\n", gray, font.resource, size, x, y, glyphs.String())
+}
+
+// paragraph writes text wrapped to the page at indent, moving down a line
+// at a time and across pages as needed.
+func (p *pdfWriter) paragraph(font *pdfFont, size, indent, gray float64, text string) {
+	leading := size * 1.4
+	for _, raw := range strings.Split(text, "\n") {
+		for _, line := range font.wrap(pdfText(raw), size, pdfPageWidth-pdfMargin-indent) {
+			p.need(leading)
+			p.y -= leading
+			p.text(font, size, indent, p.y, gray, line)
+		}
+	}
+}
+
+// code writes a fenced block in Go Mono on a shaded band, one rectangle per
+// line so a block can span pages.
+func (p *pdfWriter) code(text string, indent float64) {
+	const size = 8.5
+	leading := size * 1.35
+	width := pdfPageWidth - pdfMargin - indent
+	maxChars := int((width - 8) / (size * 0.6))
+	for _, raw := range strings.Split(text, "\n") {
+		encoded := []rune(pdfText(raw))
+		for first := true; first || len(encoded) > 0; first = false {
+			n := min(len(encoded), maxChars)
+			p.need(leading)
+			fmt.Fprintf(p.page, "0.95 g %.2f %.2f %.2f %.2f re f\n", indent, p.y-leading, width, leading)
+			p.y -= leading
+			p.text(pdfMono, size, indent+4, p.y+2.5, 0.1, string(encoded[:n]))
+			encoded = encoded[n:]
+		}
+	}
+	p.y -= 4
+}
+
+func (p *pdfWriter) titlePage(convo models.Conversation, exported time.Time) {
+	p.newPage()
+	p.y = pdfPageHeight - 220
+	p.paragraph(pdfBold, 24, pdfMargin, 0, orDash(convo.Title))
+	p.y -= 16
+
+	var meta []string
+	if convo.DateStarted != "" || convo.DateEnded != "" {
+		meta = append(meta, "Started "+orDash(convo.DateStarted), "Ended "+orDash(convo.DateEnded))
+	}
+	meta = append(meta, fmt.Sprintf("%d messages", len(convo.Messages)))
+	if len(convo.Tags) > 0 {
+		meta = append(meta, "Tags: "+strings.Join(convo.Tags, ", "))
+	}
+	meta = append(meta, "Conversation ID: "+convo.ID)
+	for _, line := range meta {
+		p.paragraph(pdfRegular, 11, pdfMargin, 0.35, line)
+	}
+	if convo.Summary != "" {
+		p.y -= 16
+		p.paragraph(pdfItalic, 11, pdfMargin, 0.2, convo.Summary)
+	}
+	p.y -= 24
+	p.paragraph(pdfRegular, 9, pdfMargin, 0.5, "Exported "+exported.Format("2006-01-02 15:04 UTC"))
+}
+
+func (p *pdfWriter) timeline(convo models.Conversation) {
+	p.newPage()
+	for _, msg := range convo.Messages {
+		p.need(40)
+		p.y -= 14
+		label := pdfText(roleLabel(msg.Author))
+		p.text(pdfBold, 11, pdfMargin, p.y, 0, label)
+
+		var details []string
+		if !msg.CreatedAt.IsZero() {
+			details = append(details, msg.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		if msg.Model != "" {
+			details = append(details, msg.Model)
+		}
+		if len(details) > 0 {
+			x := pdfMargin + pdfBold.width(label, 11) + 8
+			p.text(pdfRegular, 9, x, p.y, 0.45, pdfText(strings.Join(details, " · ")))
+		}
+		p.y -= 2
+
+		for _, part := range snippets.Parts(msg.Content) {
+			if part.Fenced {
+				p.y -= 4
+				p.code(part.Text, pdfMargin+12)
+				continue
+			}
+			for _, paragraph := range strings.Split(part.Text, "\n\n") {
+				if strings.TrimSpace(paragraph) == "" {
+					continue
+				}
+				p.paragraph(pdfRegular, 10.5, pdfMargin+12, 0.1, strings.Trim(paragraph, "\n"))
+				p.y -= 4
+			}
+		}
+		p.y -= 10
+	}
+}
+
+// writePDF renders every conversation as a title page followed by its
+// message timeline, numbering the pages "n of total".
+func writePDF(w io.Writer, conversations []models.Conversation) error {
+	exported := time.Now().UTC()
+	var p pdfWriter
+	for _, convo := range conversations {
+		p.titlePage(convo, exported)
+		p.timeline(convo)
+	}
+	if len(p.pages) == 0 {
+		p.newPage()
+		p.paragraph(pdfRegular, 11, pdfMargin, 0.35, "No conversations.")
+	}
+	for i, page := range p.pages {
+		p.page = page
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(p.pages))
+		p.text(pdfRegular, 8, (pdfPageWidth-pdfRegular.width(footer, 8))/2, 32, 0.5, footer)
+	}
+
+	title := fmt.Sprintf("%d conversations", len(conversations))
+	if len(conversations) == 1 {
+		title = conversations[0].Title
+	}
+	return writePDFObjects(w, p.pages, p.used, title, exported)
+}
+
+// writePDFObjects serialises the document: catalog, page tree, fonts, then
+// a page and a compressed content stream per page, and the xref table.
+func writePDFObjects(w io.Writer, pages []*bytes.Buffer, used map[*pdfFont]map[uint16]rune, title string, created time.Time) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) error {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		object(fmt.Sprintf("<< %s /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", dict, compressed.Len(), compressed.Bytes()))
+		return nil
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-2 are the catalog and page tree, 3 the info dictionary and
+	// the fonts follow, five objects each; pages start after them, two
+	// objects each.
+	firstPage := 4 + pdfFontObjects*len(pdfFonts)
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object(fmt.Sprintf("<< /Title %s /Producer (zatGPT) /CreationDate (D:%s) >>", pdfTextString(title), created.Format("20060102150405Z")))
+
+	var fonts []string
+	for _, font := range pdfFonts {
+		first := len(offsets) + 1
+		fonts = append(fonts, fmt.Sprintf("/%s %d 0 R", font.resource, first))
+		if err := writePDFFont(object, stream, first, font, used[font]); err != nil {
+			return err
+		}
+	}
+
+	for i, page := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, strings.Join(fonts, " "), firstPage+2*i+1))
+		if err := stream("", page.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfFontObjects is how many objects writePDFFont adds.
+const pdfFontObjects = 5
+
+// writePDFFont adds a font as objects first on: a Type 0 font over a
+// TrueType CID font, its descriptor, the subset of its glyphs the document
+// draws and the map from those glyphs back to text.
+func writePDFFont(object func(string), stream func(string, []byte) error, first int, font *pdfFont, used map[uint16]rune) error {
+	glyphs := make([]uint16, 0, len(used))
+	keep := make(map[uint16]bool, len(used))
+	for index := range used {
+		glyphs = append(glyphs, index)
+		keep[index] = true
+	}
+	slices.Sort(glyphs)
+	subset, err := subsetTrueType(font.ttf.data, keep)
+	if err != nil {
+		return fmt.Errorf("embed %s: %w", font.base, err)
+	}
+
+	// A subset's name starts with a tag of six capitals that differs
+	// between subsets of the same font.
+	sum := crc32.ChecksumIEEE(subset)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	name := string(tag) + "+" + font.base
+
+	var widths strings.Builder
+	for _, index := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", index, font.ttf.glyph(used[index]).width)
+	}
+	var toUnicode strings.Builder
+	toUnicode.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		chunk := glyphs[start:min(start+100, len(glyphs))]
+		fmt.Fprintf(&toUnicode, "%d beginbfchar\n", len(chunk))
+		for _, index := range chunk {
+			fmt.Fprintf(&toUnicode, "<%04X> <%s>\n", index, utf16Hex(string(used[index])))
+		}
+		toUnicode.WriteString("endbfchar\n")
+	}
+	toUnicode.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, first+1, first+4))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		name, first+2, widths.String()))
+	bbox, ascent, descent, capHeight := font.ttf.metrics()
+	italicAngle := 0
+	if font.flags&64 != 0 {
+		italicAngle = -12
+	}
+	object(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d] /ItalicAngle %d /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, font.flags, bbox[0], bbox[1], bbox[2], bbox[3], italicAngle, ascent, descent, capHeight, first+3))
+	if err := stream(fmt.Sprintf("/Length1 %d", len(subset)), subset); err != nil {
+		return err
+	}
+	return stream("", []byte(toUnicode.String()))
+}
+
+// pdfTextString quotes text for the document information dictionary, as
+// UTF-16 with a byte order mark.
+func pdfTextString(text string) string {
+	return "<FEFF" + utf16Hex(text) + ">"
+}
+
+// utf16Hex spells text as big-endian UTF-16 in hex.
+func utf16Hex(text string) string {
+	var b strings.Builder
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	return b.String()
+}
@@ -0,0 +1,406 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// siteMarker marks a directory WriteSite wrote, so a later run may replace
+// it; any other non-empty directory is refused.
+const siteMarker = ".zatgpt-site"
+
+// The parts of a static site below its directory.
+const (
+	siteConversations = "conversations"
+	siteTags          = "tags"
+	siteIndex         = "search-index.json"
+)
+
+// SiteResult counts what WriteSite wrote.
+type SiteResult struct {
+	Conversations int
+	Tags          int
+}
+
+// siteEntry is a conversation's line in the index and tag pages, and its
+// entry in the search index. Href is relative to the site's root.
+type siteEntry struct {
+	Title   string   `json:"title"`
+	Href    string   `json:"href"`
+	Date    string   `json:"date,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Topics  []string `json:"topics,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+}
+
+// siteTag is a tag with the page listing its conversations.
+type siteTag struct {
+	Name    string
+	Href    string
+	Entries []siteEntry
+}
+
+// WriteSite renders conversations as a static website in dir, to be served
+// as plain files (GitHub Pages, any web server) with no zatGPT server
+// behind it: an index page listing every conversation, newest first, one
+// page per conversation as its HTML export renders it, one page per tag,
+// and a search box that filters titles, summaries, tags and topics against
+// a JSON index in the browser. dir must be empty, missing, or a site
+// WriteSite wrote before, which is replaced.
+func WriteSite(dir, title string, conversations []models.Conversation) (SiteResult, error) {
+	if err := prepareSiteDir(dir); err != nil {
+		return SiteResult{}, err
+	}
+
+	// Oldest first, so a conversation keeps its page name when a newer one
+	// with the same title arrives.
+	items := append([]models.Conversation(nil), conversations...)
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	tagPages := siteTagNames(items)
+	tagHref := func(tag string) string {
+		return "../" + siteTags + "/" + tagPages[tag] + ".html"
+	}
+	pages := template.Must(siteTemplate.Clone()).Funcs(template.FuncMap{"tagHref": tagHref})
+
+	taken := make(map[string]bool, len(items))
+	entries := make([]siteEntry, 0, len(items))
+	tags := make(map[string]*siteTag)
+	for _, item := range items {
+		name := Slug(item.Title, item.ID)
+		if taken[name] {
+			name += "-" + shortID(item.ID)
+		}
+		taken[name] = true
+		href := siteConversations + "/" + name + ".html"
+
+		err := writeSiteFile(dir, href, func(w io.Writer) error {
+			return pages.ExecuteTemplate(w, "site-conversation", struct {
+				SiteTitle    string
+				Conversation models.Conversation
+			}{title, item})
+		})
+		if err != nil {
+			return SiteResult{}, err
+		}
+
+		entry := siteEntry{Title: item.Title, Href: href, Date: item.DateStarted, Tags: item.Tags, Topics: item.Topics, Summary: item.Summary}
+		entries = append(entries, entry)
+		for _, tag := range item.Tags {
+			if tags[tag] == nil {
+				tags[tag] = &siteTag{Name: tag, Href: siteTags + "/" + tagPages[tag] + ".html"}
+			}
+			tags[tag].Entries = append(tags[tag].Entries, entry)
+		}
+	}
+	// Newest first from here on.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	tagList := make([]*siteTag, 0, len(tags))
+	for _, tag := range tags {
+		for i, j := 0, len(tag.Entries)-1; i < j; i, j = i+1, j-1 {
+			tag.Entries[i], tag.Entries[j] = tag.Entries[j], tag.Entries[i]
+		}
+		tagList = append(tagList, tag)
+		err := writeSiteFile(dir, tag.Href, func(w io.Writer) error {
+			return pages.ExecuteTemplate(w, "site-tag", struct {
+				SiteTitle string
+				Tag       *siteTag
+			}{title, tag})
+		})
+		if err != nil {
+			return SiteResult{}, err
+		}
+	}
+	sort.Slice(tagList, func(i, j int) bool {
+		if len(tagList[i].Entries) != len(tagList[j].Entries) {
+			return len(tagList[i].Entries) > len(tagList[j].Entries)
+		}
+		return tagList[i].Name < tagList[j].Name
+	})
+
+	files := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"index.html", func(w io.Writer) error {
+			return pages.ExecuteTemplate(w, "site-index", struct {
+				SiteTitle   string
+				Entries     []siteEntry
+				Tags        []*siteTag
+				GeneratedAt time.Time
+			}{title, entries, tagList, time.Now().UTC()})
+		}},
+		{"style.css", func(w io.Writer) error {
+			_, err := io.WriteString(w, pageStyle+siteStyle)
+			return err
+		}},
+		{"search.js", func(w io.Writer) error {
+			_, err := io.WriteString(w, siteScript)
+			return err
+		}},
+		{siteIndex, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(entries)
+		}},
+		{siteMarker, func(w io.Writer) error {
+			_, err := io.WriteString(w, "Written by zatgpt export -site; the next run replaces this directory.\n")
+			return err
+		}},
+	}
+	for _, file := range files {
+		if err := writeSiteFile(dir, file.name, file.write); err != nil {
+			return SiteResult{}, err
+		}
+	}
+	return SiteResult{Conversations: len(entries), Tags: len(tagList)}, nil
+}
+
+// prepareSiteDir creates dir, or empties it when an earlier WriteSite
+// wrote it.
+func prepareSiteDir(dir string) error {
+	if dir == "" {
+		return errors.New("a directory is required")
+	}
+	existing, err := os.ReadDir(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return os.MkdirAll(dir, 0o755)
+	case err != nil:
+		return err
+	case len(existing) == 0:
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, siteMarker)); err != nil {
+		return fmt.Errorf("%s is not empty and was not written by a site export; pick an empty directory", dir)
+	}
+	for _, entry := range existing {
+		// Keep what the user added next to the site, such as a CNAME file
+		// or .git.
+		switch entry.Name() {
+		case siteConversations, siteTags, "index.html", "style.css", "search.js", siteIndex:
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// siteTagNames gives each tag a page name, telling apart tags that slug
+// the same by a number.
+func siteTagNames(items []models.Conversation) map[string]string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+
+	names := make(map[string]string, len(tags))
+	taken := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		base := Slug(tag, "tag")
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = base + "-" + strconv.Itoa(n)
+		}
+		taken[name] = true
+		names[tag] = name
+	}
+	return names
+}
+
+// writeSiteFile writes the file at the slash-separated path name below dir.
+func writeSiteFile(dir, name string, write func(w io.Writer) error) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(file)
+	err = write(buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// siteStyle adds the index, tag and search styles to pageStyle.
+const siteStyle = `
+nav.site { margin: 1.5rem 0 0; font-size: 0.9rem; }
+nav.site a, .entries a, .tags a { color: #4f46e5; text-decoration: none; }
+nav.site a:hover, .entries a:hover { text-decoration: underline; }
+.site-header h1 { margin: 2rem 0 0.25rem; }
+.search { width: 100%; box-sizing: border-box; margin: 1rem 0; padding: 0.6rem 0.8rem; font-size: 1rem; border: 1px solid #d1d5db; border-radius: 0.5rem; }
+.entries { list-style: none; padding: 0; margin: 0; }
+.entries li { padding: 0.75rem 0; border-bottom: 1px solid #f3f4f6; }
+.entries .title { font-weight: 600; }
+.entries .summary { color: #4b5563; margin: 0.25rem 0 0; font-size: 0.92rem; }
+.tags a span { cursor: pointer; }
+.tags .count { color: #6b7280; font-size: 0.8rem; margin-left: 0.2rem; }
+[hidden] { display: none !important; }
+`
+
+// siteScript filters the index page: every word typed must appear in a
+// conversation's title, summary, tags or topics.
+const siteScript = `(function () {
+  "use strict";
+  var input = document.getElementById("search");
+  var list = document.getElementById("entries");
+  var results = document.getElementById("results");
+  var status = document.getElementById("search-status");
+  if (!input) return;
+  var index = null;
+  var loading = null;
+
+  function load() {
+    if (!loading) {
+      loading = fetch("search-index.json")
+        .then(function (res) { return res.json(); })
+        .then(function (entries) {
+          index = entries.map(function (e) {
+            var text = [e.title, e.summary || ""].concat(e.tags || [], e.topics || []).join(" ");
+            return { entry: e, text: text.toLowerCase() };
+          });
+        });
+    }
+    return loading;
+  }
+
+  function item(e) {
+    var li = document.createElement("li");
+    var a = document.createElement("a");
+    a.className = "title";
+    a.href = e.href;
+    a.textContent = e.title;
+    li.appendChild(a);
+    if (e.date || (e.tags && e.tags.length)) {
+      var meta = document.createElement("p");
+      meta.className = "meta";
+      meta.textContent = [e.date || ""].concat(e.tags || []).filter(Boolean).join(" · ");
+      li.appendChild(meta);
+    }
+    if (e.summary) {
+      var p = document.createElement("p");
+      p.className = "summary";
+      p.textContent = e.summary;
+      li.appendChild(p);
+    }
+    return li;
+  }
+
+  function search() {
+    var words = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (!words.length) {
+      results.hidden = true;
+      list.hidden = false;
+      status.textContent = "";
+      return;
+    }
+    load().then(function () {
+      var hits = index.filter(function (doc) {
+        return words.every(function (w) { return doc.text.indexOf(w) >= 0; });
+      });
+      results.textContent = "";
+      hits.forEach(function (doc) { results.appendChild(item(doc.entry)); });
+      results.hidden = false;
+      list.hidden = true;
+      status.textContent = hits.length + (hits.length === 1 ? " conversation" : " conversations");
+    }, function () {
+      status.textContent = "Search needs the site to be served over HTTP.";
+    });
+  }
+
+  input.addEventListener("input", search);
+  input.addEventListener("focus", load);
+  if (input.value) search();
+})();
+`
+
+var siteTemplate = template.Must(template.Must(htmlTemplate.Clone()).Parse(`
+{{define "site-head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+{{end}}
+{{define "site-entries"}}{{range .}}{{$date := .Date}}<li><a class="title" href="{{.Href}}">{{.Title}}</a>
+{{if or .Date .Tags}}<p class="meta">{{.Date}}{{range $i, $tag := .Tags}}{{if or $i $date}} · {{end}}{{$tag}}{{end}}</p>
+{{end}}{{with .Summary}}<p class="summary">{{.}}</p>
+{{end}}</li>
+{{end}}{{end}}
+{{define "site-index"}}{{template "site-head" .SiteTitle}}<link rel="stylesheet" href="style.css">
+<script src="search.js" defer></script>
+</head>
+<body>
+<header class="site-header">
+<h1>{{.SiteTitle}}</h1>
+<p class="meta">{{len .Entries}} conversations · generated {{date .GeneratedAt}}</p>
+</header>
+{{if .Tags}}<p class="meta tags">{{range .Tags}}<a href="{{.Href}}"><span>{{.Name}}<small class="count">{{len .Entries}}</small></span></a>{{end}}</p>
+{{end}}<input id="search" class="search" type="search" placeholder="Search titles, summaries, tags and topics" autocomplete="off">
+<p id="search-status" class="meta"></p>
+<ul id="results" class="entries" hidden></ul>
+<ul id="entries" class="entries">
+{{template "site-entries" .Entries}}</ul>
+</body>
+</html>
+{{end}}
+{{define "site-conversation"}}{{template "site-head" .Conversation.Title}}<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<nav class="site"><a href="../index.html">← {{.SiteTitle}}</a></nav>
+{{template "article" .Conversation}}</body>
+</html>
+{{end}}
+{{define "site-tag"}}{{template "site-head" .Tag.Name}}<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<nav class="site"><a href="../index.html">← {{.SiteTitle}}</a></nav>
+<header class="site-header">
+<h1>{{.Tag.Name}}</h1>
+<p class="meta">{{len .Tag.Entries}} conversations</p>
+</header>
+<ul class="entries">
+{{range .Tag.Entries}}<li><a class="title" href="../{{.Href}}">{{.Title}}</a>
+{{with .Date}}<p class="meta">{{.}}</p>
+{{end}}{{with .Summary}}<p class="summary">{{.}}</p>
+{{end}}</li>
+{{end}}</ul>
+</body>
+</html>
+{{end}}
+`))
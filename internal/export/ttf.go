@@ -0,0 +1,279 @@
+package export
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// pdfGlyph is how a font draws one character: its glyph index, advance
+// width in thousandths of the font size, and the character the glyph
+// stands for, which is "?" when the font has none for it.
+type pdfGlyph struct {
+	index uint16
+	width int
+	char  rune
+}
+
+// trueType is a parsed TrueType font and the glyphs looked up in it so far.
+type trueType struct {
+	data []byte
+	font *sfnt.Font
+
+	mu     sync.Mutex
+	glyphs map[rune]pdfGlyph
+}
+
+// pdfUnits asks sfnt for metrics in thousandths of the font size, the
+// units PDF glyph space uses.
+var pdfUnits = fixed.I(1000)
+
+func parseTrueType(data []byte) *trueType {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("export: parse embedded font: %v", err))
+	}
+	return &trueType{data: data, font: f, glyphs: map[rune]pdfGlyph{}}
+}
+
+// glyph returns how the font draws r, falling back to "?" and then to the
+// missing-glyph box for characters it has no glyph for.
+func (t *trueType) glyph(r rune) pdfGlyph {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if g, ok := t.glyphs[r]; ok {
+		return g
+	}
+	var buf sfnt.Buffer
+	char := r
+	index, err := t.font.GlyphIndex(&buf, r)
+	if err != nil || index == 0 {
+		char = '?'
+		index, _ = t.font.GlyphIndex(&buf, char)
+	}
+	g := pdfGlyph{index: uint16(index), char: char}
+	if advance, err := t.font.GlyphAdvance(&buf, index, pdfUnits, font.HintingNone); err == nil {
+		g.width = advance.Round()
+	}
+	t.glyphs[r] = g
+	return g
+}
+
+// metrics returns the font-wide numbers a PDF font descriptor carries, in
+// thousandths of the font size.
+func (t *trueType) metrics() (bbox [4]int, ascent, descent, capHeight int) {
+	var buf sfnt.Buffer
+	if bounds, err := t.font.Bounds(&buf, pdfUnits, font.HintingNone); err == nil {
+		// sfnt's Y axis points down; PDF's points up.
+		bbox = [4]int{bounds.Min.X.Floor(), -bounds.Max.Y.Ceil(), bounds.Max.X.Ceil(), -bounds.Min.Y.Floor()}
+	}
+	if m, err := t.font.Metrics(&buf, pdfUnits, font.HintingNone); err == nil {
+		ascent, descent, capHeight = m.Ascent.Round(), -m.Descent.Round(), m.CapHeight.Round()
+	}
+	return bbox, ascent, descent, capHeight
+}
+
+// The tables a TrueType font embedded in a PDF needs, with its character
+// map for readers that insist on one. Names and layout tables are left out;
+// the PDF addresses glyphs by index and carries its own ToUnicode map.
+var subsetTables = map[string]bool{
+	"cmap": true, "cvt ": true, "fpgm": true, "glyf": true, "head": true,
+	"hhea": true, "hmtx": true, "loca": true, "maxp": true, "prep": true,
+}
+
+var errBadTrueType = errors.New("malformed TrueType font")
+
+// subsetTrueType returns a copy of the font data in which only the glyphs
+// in keep, the missing-glyph box and the components they are built from
+// keep their outlines. Glyph indices are unchanged, so a PDF can keep
+// mapping character codes to glyphs one to one.
+func subsetTrueType(data []byte, keep map[uint16]bool) ([]byte, error) {
+	tables, err := trueTypeTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 || glyf == nil {
+		return nil, errBadTrueType
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longOffsets := binary.BigEndian.Uint16(head[50:]) != 0
+	offsets := make([]uint32, numGlyphs+1)
+	for i := range offsets {
+		if longOffsets {
+			if len(loca) < 4*(i+1) {
+				return nil, errBadTrueType
+			}
+			offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		} else {
+			if len(loca) < 2*(i+1) {
+				return nil, errBadTrueType
+			}
+			offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+	}
+	outline := func(index int) []byte {
+		if index >= numGlyphs || offsets[index] > offsets[index+1] || int(offsets[index+1]) > len(glyf) {
+			return nil
+		}
+		return glyf[offsets[index]:offsets[index+1]]
+	}
+
+	kept := map[int]bool{0: true}
+	pending := []int{0}
+	for index := range keep {
+		pending = append(pending, int(index))
+	}
+	for len(pending) > 0 {
+		index := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		kept[index] = true
+		for _, component := range glyphComponents(outline(index)) {
+			if !kept[component] {
+				pending = append(pending, component)
+			}
+		}
+	}
+
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for index := range numGlyphs {
+		binary.BigEndian.PutUint32(newLoca[4*index:], uint32(len(newGlyf)))
+		if kept[index] {
+			newGlyf = append(newGlyf, outline(index)...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	tables["head"], tables["loca"], tables["glyf"] = newHead, newLoca, newGlyf
+	return writeTrueType(tables)
+}
+
+// trueTypeTables returns the subset tables of a TrueType font by tag.
+func trueTypeTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errBadTrueType
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errBadTrueType
+	}
+	tables := map[string][]byte{}
+	for i := range numTables {
+		record := data[12+16*i:]
+		tag := string(record[:4])
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, errBadTrueType
+		}
+		if subsetTables[tag] {
+			tables[tag] = data[offset : offset+length]
+		}
+	}
+	return tables, nil
+}
+
+// glyphComponents returns the glyphs a composite glyph is built from, or
+// nothing for a simple one.
+func glyphComponents(outline []byte) []int {
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) >= 0 {
+		return nil
+	}
+	var components []int
+	for pos := 10; pos+4 <= len(outline); {
+		flags := binary.BigEndian.Uint16(outline[pos:])
+		components = append(components, int(binary.BigEndian.Uint16(outline[pos+2:])))
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// writeTrueType serialises tables as a TrueType font, filling in the
+// checksums the format requires.
+func writeTrueType(tables map[string][]byte) ([]byte, error) {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	out := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*len(tags)-searchRange))
+
+	headAt := -1
+	for i, tag := range tags {
+		table := tables[tag]
+		if tag == "head" {
+			headAt = len(out)
+		}
+		record := out[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], trueTypeChecksum(table))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table)))
+		out = append(out, table...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if headAt < 0 {
+		return nil, errBadTrueType
+	}
+	binary.BigEndian.PutUint32(out[headAt+8:], 0xB1B0AFBA-trueTypeChecksum(out))
+	return out, nil
+}
+
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
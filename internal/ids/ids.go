@@ -0,0 +1,151 @@
+// Package ids generates identifiers for records created by the application:
+// conversations added through the API and import history entries. The
+// scheme is chosen once at startup with Use; UUIDv7 is the default because
+// its IDs sort by creation time.
+//
+// Conversations the importer reads keep the IDs of their export. Those
+// without one keep the title-and-time IDs the importer has always given
+// them, and those without a time either get a content-derived FromContent
+// ID, so re-importing the same file maps onto the same records.
+package ids
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Generator produces new unique IDs.
+type Generator interface {
+	NewID() string
+}
+
+// Schemes lists the names accepted by Use.
+var Schemes = []string{"uuidv7", "random"}
+
+var (
+	mu      sync.RWMutex
+	current Generator = &UUIDv7{}
+)
+
+// Use selects the generator behind New: "uuidv7" (the default) or "random"
+// (32 hex characters, the format of IDs issued before UUIDs).
+func Use(scheme string) error {
+	var g Generator
+	switch strings.ToLower(strings.TrimSpace(scheme)) {
+	case "", "uuidv7", "uuid":
+		g = &UUIDv7{}
+	case "random", "hex":
+		g = Random{}
+	default:
+		return fmt.Errorf("unknown ID scheme %q (want %s)", scheme, strings.Join(Schemes, " or "))
+	}
+	Set(g)
+	return nil
+}
+
+// Set installs a custom generator.
+func Set(g Generator) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = g
+}
+
+// New returns a fresh ID from the selected generator.
+func New() string {
+	mu.RLock()
+	g := current
+	mu.RUnlock()
+	return g.NewID()
+}
+
+// UUIDv7 issues RFC 9562 version 7 UUIDs: a millisecond Unix timestamp
+// followed by random bits. IDs from one generator are strictly increasing,
+// even within the same millisecond.
+type UUIDv7 struct {
+	mu     sync.Mutex
+	lastMS int64
+	seq    uint16
+}
+
+func (g *UUIDv7) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("ids: reading random bytes: %v", err))
+	}
+
+	g.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= g.lastMS {
+		// Same (or an earlier, after a clock step) millisecond: count up in
+		// the 12-bit rand_a field, borrowing the next millisecond on overflow.
+		ms = g.lastMS
+		g.seq++
+		if g.seq > 0x0fff {
+			ms++
+			g.seq = 0
+		}
+	} else {
+		g.seq = binary.BigEndian.Uint16(b[6:8]) & 0x07ff
+	}
+	g.lastMS = ms
+	seq := g.seq
+	g.mu.Unlock()
+
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	b[8] = 0x80 | b[8]&0x3f
+	return format(b)
+}
+
+// Random issues 128 random bits as 32 hex characters.
+type Random struct{}
+
+func (Random) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("ids: reading random bytes: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// namespace is the UUIDv5 namespace for content-derived IDs.
+var namespace = [16]byte{0x6b, 0x9e, 0x3c, 0x52, 0x1d, 0x4a, 0x4f, 0x0b, 0x9a, 0x61, 0x2e, 0x7d, 0x58, 0xc3, 0x0f, 0x94}
+
+// FromContent derives a stable RFC 9562 version 5 UUID from parts, so the
+// same content always maps to the same ID.
+func FromContent(parts ...string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(strings.Join(parts, "\x00")))
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	b[6] = 0x50 | b[6]&0x0f
+	b[8] = 0x80 | b[8]&0x3f
+	return format(b)
+}
+
+func format(b [16]byte) string {
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
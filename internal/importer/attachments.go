@@ -0,0 +1,334 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+type exportAttachment struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	MimeType    string `json:"mimeType"`
+	MimeTypeAlt string `json:"mime_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+type exportAssetPointer struct {
+	ContentType  string `json:"content_type"`
+	AssetPointer string `json:"asset_pointer"`
+	SizeBytes    int64  `json:"size_bytes"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Format       string `json:"format"`
+	Metadata     struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	} `json:"metadata"`
+	// Voice-mode turns wrap the audio pointer inside a combined
+	// audio/video pointer.
+	AudioAssetPointer *exportAssetPointer `json:"audio_asset_pointer"`
+}
+
+// AssetSource opens attachment blobs referenced by an export. Open also
+// returns the blob's file name inside the export.
+type AssetSource interface {
+	Open(ref string) (io.ReadCloser, string, error)
+}
+
+// AttachmentSink persists attachment blobs; *storage.Store satisfies it.
+type AttachmentSink interface {
+	SaveAttachment(ref string, src io.Reader) (int64, error)
+	HasAttachment(ref string) bool
+}
+
+// ErrAssetNotFound is returned by an AssetSource that does not hold a ref.
+var ErrAssetNotFound = errors.New("asset not found in export")
+
+// DirAssets returns an AssetSource for an unpacked export directory. Export
+// files are named after their ref (e.g. `file-abc123-photo.png`), possibly
+// inside sub-folders such as `dalle-generations/`.
+func DirAssets(dir string) AssetSource {
+	return &dirAssets{root: dir}
+}
+
+type dirAssets struct {
+	root  string
+	once  sync.Once
+	files []string
+}
+
+func (d *dirAssets) Open(ref string) (io.ReadCloser, string, error) {
+	d.once.Do(d.index)
+	for _, path := range d.files {
+		if namedAfter(filepath.Base(path), ref) {
+			file, err := os.Open(path)
+			return file, filepath.Base(path), err
+		}
+	}
+	return nil, "", ErrAssetNotFound
+}
+
+func (d *dirAssets) index() {
+	_ = filepath.WalkDir(d.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() || strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".html") {
+			return nil
+		}
+		d.files = append(d.files, path)
+		return nil
+	})
+}
+
+// CopyAttachments copies every blob referenced by conversations from src into
+// dst, updating each attachment's Stored flag (and any missing name, size, or
+// MIME type) in place. A missing or unknown MIME type is taken from the
+// file's extension, or else sniffed from its first bytes. Blobs already held
+// by dst from an earlier import stay marked as stored even if this export no
+// longer ships them.
+func CopyAttachments(conversations []models.Conversation, src AssetSource, dst AttachmentSink) (int, error) {
+	copied := 0
+	for i := range conversations {
+		for j := range conversations[i].Attachments {
+			att := &conversations[i].Attachments[j]
+
+			rc, name, err := src.Open(att.Ref)
+			if errors.Is(err, ErrAssetNotFound) {
+				att.Stored = dst.HasAttachment(att.Ref)
+				continue
+			}
+			if err != nil {
+				return copied, err
+			}
+
+			blob := bufio.NewReader(rc)
+			head, _ := blob.Peek(512)
+			sniffed := http.DetectContentType(head)
+			n, err := dst.SaveAttachment(att.Ref, blob)
+			rc.Close()
+			if err != nil {
+				return copied, err
+			}
+
+			att.Stored = true
+			if att.Size == 0 {
+				att.Size = n
+			}
+			if att.Name == "" {
+				att.Name = strings.TrimLeft(strings.TrimPrefix(name, att.Ref), "-_")
+			}
+			if att.MimeType == "" || att.MimeType == unknownMimeType {
+				att.MimeType = firstNonEmpty(mime.TypeByExtension(filepath.Ext(name)), sniffed)
+			}
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// appendAttachments records every file referenced by msg, whether declared in
+// its metadata or embedded as an asset pointer in the content parts.
+func appendAttachments(dst []models.Attachment, messageID string, msg *exportMessage) []models.Attachment {
+	seen := make(map[string]int)
+	for i, item := range dst {
+		seen[item.Ref] = i
+	}
+
+	add := func(att models.Attachment) {
+		if !safeRef(att.Ref) {
+			return
+		}
+		if i, ok := seen[att.Ref]; ok {
+			dst[i] = mergeAttachment(dst[i], att)
+			return
+		}
+		seen[att.Ref] = len(dst)
+		dst = append(dst, att)
+	}
+
+	for _, meta := range msg.Metadata.Attachments {
+		add(models.Attachment{
+			Ref:       strings.TrimSpace(meta.ID),
+			MessageID: messageID,
+			Name:      strings.TrimSpace(meta.Name),
+			MimeType:  firstNonEmpty(meta.MimeType, meta.MimeTypeAlt),
+			Size:      meta.Size,
+			Width:     meta.Width,
+			Height:    meta.Height,
+		})
+	}
+
+	for _, part := range msg.Content.Parts {
+		if len(part) == 0 || part[0] != '{' {
+			continue
+		}
+		var pointer exportAssetPointer
+		if err := json.Unmarshal(part, &pointer); err != nil {
+			continue
+		}
+		if pointer.AudioAssetPointer != nil {
+			pointer = *pointer.AudioAssetPointer
+		}
+		if pointer.AssetPointer == "" {
+			continue
+		}
+		att := models.Attachment{
+			Ref:       assetRef(pointer.AssetPointer),
+			MessageID: messageID,
+			Size:      pointer.SizeBytes,
+			Width:     pointer.Width,
+			Height:    pointer.Height,
+		}
+		if pointer.ContentType == "audio_asset_pointer" {
+			att.MimeType = audioMimeType(pointer.Format)
+			if d := pointer.Metadata.End - pointer.Metadata.Start; d > 0 {
+				att.Duration = d
+			}
+		}
+		add(att)
+	}
+
+	return dst
+}
+
+// unknownMimeType is the type of a file whose type is not known.
+const unknownMimeType = "application/octet-stream"
+
+// audioMimeType is the MIME type of a voice clip in format, or
+// unknownMimeType for a format that is missing or not one ChatGPT uses,
+// which CopyAttachments then works out from the file.
+func audioMimeType(format string) string {
+	switch strings.ToLower(format) {
+	case "wav":
+		return "audio/wav"
+	case "mp3":
+		return "audio/mpeg"
+	case "ogg", "opus":
+		return "audio/ogg"
+	case "webm":
+		return "audio/webm"
+	case "m4a", "aac", "mp4":
+		return "audio/mp4"
+	default:
+		return unknownMimeType
+	}
+}
+
+// namedAfter reports whether an export file called base holds the blob
+// ref: it is named ref, or ref followed by "-", "_" or "." and more, as in
+// file-abc123-photo.png. A bare prefix is not enough, or file-abc1 would
+// open file-abc123's blob.
+func namedAfter(base, ref string) bool {
+	rest, ok := strings.CutPrefix(base, ref)
+	return ok && (rest == "" || strings.ContainsRune("-_.", rune(rest[0])))
+}
+
+// safeRef rejects refs that could not double as a plain file name.
+func safeRef(ref string) bool {
+	if ref == "" || strings.Trim(ref, ".") == "" {
+		return false
+	}
+	return strings.IndexFunc(ref, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) < 0
+}
+
+// assetRef strips the scheme from pointers such as `file-service://file-abc`.
+func assetRef(pointer string) string {
+	if _, ref, ok := strings.Cut(pointer, "://"); ok {
+		return strings.TrimSpace(ref)
+	}
+	return strings.TrimSpace(pointer)
+}
+
+func mergeAttachment(a, b models.Attachment) models.Attachment {
+	if a.Name == "" {
+		a.Name = b.Name
+	}
+	if a.MimeType == "" || a.MimeType == unknownMimeType && b.MimeType != "" {
+		a.MimeType = b.MimeType
+	}
+	if a.Size == 0 {
+		a.Size = b.Size
+	}
+	if a.Width == 0 {
+		a.Width = b.Width
+	}
+	if a.Height == 0 {
+		a.Height = b.Height
+	}
+	if a.Duration == 0 {
+		a.Duration = b.Duration
+	}
+	return a
+}
+
+// TextRecognizer extracts text from an image file; ocr.Engine satisfies it.
+type TextRecognizer interface {
+	Recognize(ctx context.Context, path string) (string, error)
+}
+
+// AttachmentLocator resolves stored blobs to file paths; *storage.Store
+// satisfies it.
+type AttachmentLocator interface {
+	LocateAttachment(ref string) (string, bool)
+}
+
+// RecognizeAttachments runs OCR over stored image attachments that have no
+// text yet. skip, when non-nil, lets callers bypass refs recognised by an
+// earlier import. Failures on individual images are collected and returned
+// together without stopping the run.
+func RecognizeAttachments(ctx context.Context, conversations []models.Conversation, engine TextRecognizer, blobs AttachmentLocator, skip func(ref string) bool) (int, error) {
+	var (
+		recognized int
+		failures   []error
+	)
+	for i := range conversations {
+		for j := range conversations[i].Attachments {
+			att := &conversations[i].Attachments[j]
+			if !att.Stored || att.Text != "" || !strings.HasPrefix(att.MimeType, "image/") {
+				continue
+			}
+			if skip != nil && skip(att.Ref) {
+				continue
+			}
+			path, ok := blobs.LocateAttachment(att.Ref)
+			if !ok {
+				continue
+			}
+
+			spanCtx, span := telemetry.Start(ctx, "ocr.Recognize", attribute.String("attachment.ref", att.Ref))
+			text, err := engine.Recognize(spanCtx, path)
+			telemetry.End(span, err)
+			if err != nil {
+				if ctx.Err() != nil {
+					return recognized, ctx.Err()
+				}
+				failures = append(failures, fmt.Errorf("%s: %w", att.Ref, err))
+				continue
+			}
+			att.Text = text
+			recognized++
+		}
+	}
+	return recognized, errors.Join(failures...)
+}
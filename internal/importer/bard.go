@@ -0,0 +1,467 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"zatGPT/internal/models"
+)
+
+// Sources an Export can come from.
+const (
+	SourceChatGPT = "chatgpt"
+	SourceBard    = "bard"
+)
+
+// SourceGemini names the product Bard became. Its Takeout activity log is
+// read as SourceBard; ParseSource accepts either name.
+const SourceGemini = "gemini"
+
+// ParseSource resolves a product name given for Options.Source: one of
+// Sources, or SourceGemini.
+func ParseSource(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == SourceGemini {
+		return SourceBard, nil
+	}
+	if !slices.Contains(Sources, name) {
+		return "", fmt.Errorf("unknown source %q (want one of %s or %s)", name, strings.Join(Sources, ", "), SourceGemini)
+	}
+	return name, nil
+}
+
+// Ways to split a Bard activity log into conversations. The log has no
+// conversation boundaries, only one entry per prompt.
+const (
+	GroupByDay     = "day"
+	GroupBySession = "session"
+)
+
+// bardSessionGap separates sessions when grouping by session.
+const bardSessionGap = 30 * time.Minute
+
+// Options tunes how exports are converted.
+type Options struct {
+	// BardGrouping is GroupByDay (the default) or GroupBySession.
+	BardGrouping string
+	// Source, when set, only accepts exports from that product (one of
+	// Sources) instead of detecting it; files from any other are
+	// ErrNotExport.
+	Source string
+	// AllRoles keeps the system and tool messages of ChatGPT exports
+	// (Custom Instructions, the assistant's tool calls and their results,
+	// such as browsing and code output), which are dropped by default.
+	AllRoles bool
+	// Workers is how many goroutines convert conversations; zero uses
+	// every core.
+	Workers int
+	// Hooks see each converted conversation in turn and may change or
+	// skip it (see Hook).
+	Hooks []Hook
+}
+
+// bardActivity is one prompt/response entry of a Google Takeout
+// "My Activity" log for Bard or Gemini Apps.
+type bardActivity struct {
+	Header       string `json:"header"`
+	Title        string `json:"title"`
+	Time         string `json:"time"`
+	SafeHTMLItem []struct {
+		HTML string `json:"html"`
+	} `json:"safeHtmlItem"`
+	// AttachedFiles names the files uploaded with a Gemini prompt, which
+	// Takeout puts next to MyActivity.json.
+	AttachedFiles []string `json:"attachedFiles"`
+
+	prompt   string
+	response string
+	at       time.Time
+}
+
+func isBardHeader(header string) bool {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return header == "bard" || header == "gemini apps" || header == "gemini"
+}
+
+// decodeBardJSON reads MyActivity.json. Entries other than prompts (feedback,
+// settings changes) are dropped.
+func decodeBardJSON(r io.Reader) ([]bardActivity, error) {
+	var payload []bardActivity
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, ErrNotExport
+	}
+
+	out := payload[:0]
+	recognized := false
+	for _, item := range payload {
+		if !isBardHeader(item.Header) {
+			continue
+		}
+		recognized = true
+		prompt, ok := bardPrompt(item.Title)
+		if !ok {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339Nano, item.Time)
+		if err != nil {
+			continue
+		}
+		item.prompt = prompt
+		item.at = at.UTC()
+		var parts []string
+		for _, frag := range item.SafeHTMLItem {
+			if text := htmlText(frag.HTML); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		item.response = strings.Join(parts, "\n\n")
+		out = append(out, item)
+	}
+	if !recognized {
+		return nil, ErrNotExport
+	}
+	return out, nil
+}
+
+// decodeBardHTML reads MyActivity.html, where each entry is an "outer-cell"
+// whose content cell holds the prompt line, a timestamp line and the
+// response markup, separated by <br>.
+func decodeBardHTML(data []byte) ([]bardActivity, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotExport
+	}
+
+	var out []bardActivity
+	recognized := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && hasClass(n, "outer-cell") {
+			header := findByClass(n, "header-cell")
+			content := findByClass(n, "content-cell")
+			if header != nil && content != nil && isBardHeader(nodeText(header)) {
+				recognized = true
+				if item, ok := bardHTMLEntry(content); ok {
+					item.Header = strings.TrimSpace(nodeText(header))
+					out = append(out, item)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	if !recognized {
+		return nil, ErrNotExport
+	}
+	return out, nil
+}
+
+func bardHTMLEntry(content *html.Node) (bardActivity, bool) {
+	var lines [2]strings.Builder
+	line := 0
+	var rest bytes.Buffer
+	for c := content.FirstChild; c != nil; c = c.NextSibling {
+		if line < 2 {
+			if c.Type == html.ElementNode && c.Data == "br" {
+				line++
+				continue
+			}
+			if c.Type == html.TextNode || (c.Type == html.ElementNode && c.Data == "a") {
+				lines[line].WriteString(nodeText(c))
+				continue
+			}
+			// Response markup may start right after the timestamp without
+			// a trailing <br>.
+			if line == 0 {
+				continue
+			}
+			line = 2
+		}
+		_ = html.Render(&rest, c)
+	}
+
+	prompt, ok := bardPrompt(lines[0].String())
+	if !ok {
+		return bardActivity{}, false
+	}
+	at, ok := parseTakeoutTime(lines[1].String())
+	if !ok {
+		return bardActivity{}, false
+	}
+	return bardActivity{prompt: prompt, at: at, response: htmlText(rest.String())}, true
+}
+
+// bardPrompt extracts the prompt from a "Prompted <text>" activity title.
+func bardPrompt(title string) (string, bool) {
+	title = strings.TrimSpace(strings.ReplaceAll(title, " ", " "))
+	prompt, ok := strings.CutPrefix(title, "Prompted ")
+	if !ok {
+		return "", false
+	}
+	prompt = strings.TrimSpace(prompt)
+	return prompt, prompt != ""
+}
+
+var takeoutTimeLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM MST",
+	"2 Jan 2006, 15:04:05 MST",
+	"Jan 2, 2006, 15:04:05 MST",
+}
+
+func parseTakeoutTime(raw string) (time.Time, bool) {
+	raw = strings.Join(strings.Fields(strings.NewReplacer(" ", " ", " ", " ").Replace(raw)), " ")
+	for _, layout := range takeoutTimeLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// convertBard groups activities into conversations, oldest first.
+func convertBard(items []bardActivity, grouping string) []models.Conversation {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].at.Before(items[j].at) })
+
+	var groups [][]bardActivity
+	for i, item := range items {
+		if i > 0 && !sameBardGroup(items[i-1], item, grouping) {
+			groups = append(groups, nil)
+		}
+		if len(groups) == 0 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], item)
+	}
+
+	conversations := make([]models.Conversation, 0, len(groups))
+	for _, group := range groups {
+		conversations = append(conversations, bardConversation(group, grouping))
+	}
+	return conversations
+}
+
+func sameBardGroup(prev, next bardActivity, grouping string) bool {
+	if grouping == GroupBySession {
+		return next.at.Sub(prev.at) <= bardSessionGap
+	}
+	return prev.at.Format("2006-01-02") == next.at.Format("2006-01-02")
+}
+
+func bardConversation(group []bardActivity, grouping string) models.Conversation {
+	first, last := group[0], group[len(group)-1]
+
+	id := "bard-" + first.at.Format("20060102")
+	if grouping == GroupBySession {
+		id = "bard-" + first.at.Format("20060102T150405")
+	}
+
+	messages := make([]models.Message, 0, len(group)*2)
+	var attachments []models.Attachment
+	for i, item := range group {
+		model := SourceBard
+		if strings.Contains(strings.ToLower(item.Header), "gemini") {
+			model = "gemini"
+		}
+		promptID := fmt.Sprintf("%s-%d-prompt", id, i)
+		messages = append(messages, models.Message{
+			ID:        promptID,
+			Author:    "user",
+			Content:   item.prompt,
+			CreatedAt: item.at,
+		})
+		for _, name := range item.AttachedFiles {
+			name = path.Base(strings.TrimSpace(strings.ReplaceAll(name, "\\", "/")))
+			ref := takeoutRef(promptID, name)
+			if !safeRef(ref) {
+				continue
+			}
+			attachments = append(attachments, models.Attachment{
+				Ref:       ref,
+				MessageID: promptID,
+				Name:      name,
+				MimeType:  mime.TypeByExtension(path.Ext(name)),
+			})
+		}
+		if item.response != "" {
+			messages = append(messages, models.Message{
+				ID:        fmt.Sprintf("%s-%d-response", id, i),
+				Author:    "assistant",
+				Content:   item.response,
+				Model:     model,
+				CreatedAt: item.at,
+			})
+		}
+	}
+
+	return models.Conversation{
+		ID:          id,
+		Title:       truncate(first.prompt, 80),
+		Summary:     truncate(first.prompt, 240),
+		DateStarted: first.at.Format("2006-01-02"),
+		DateEnded:   last.at.Format("2006-01-02"),
+		StartedAt:   first.at,
+		EndedAt:     last.at,
+		SourceID:    id,
+		Source:      SourceBard,
+		Messages:    messages,
+		Attachments: attachments,
+		CreatedAt:   first.at,
+		UpdatedAt:   last.at,
+	}
+}
+
+// takeoutRef is the ref of a file Takeout shipped under name for the
+// prompt promptID: the two joined, with the characters a ref cannot hold
+// replaced. Takeout names files as they were uploaded, so two prompts may
+// attach different files called image.png; the prompt keeps their blobs
+// apart. takeoutAssets finds the file by its name.
+func takeoutRef(promptID, name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, promptID+"-"+name)
+}
+
+// takeoutAssets serves the files of a Takeout export, which are named as
+// they were attached rather than after their ref, by way of the file name
+// each ref's attachment records.
+type takeoutAssets struct {
+	AssetSource
+	names map[string]string
+}
+
+// withTakeoutNames wraps src for the attachments of conversations
+// converted from a Takeout export.
+func withTakeoutNames(src AssetSource, conversations []models.Conversation) takeoutAssets {
+	names := make(map[string]string)
+	for _, convo := range conversations {
+		for _, att := range convo.Attachments {
+			names[att.Ref] = att.Name
+		}
+	}
+	return takeoutAssets{AssetSource: src, names: names}
+}
+
+func (t takeoutAssets) Open(ref string) (io.ReadCloser, string, error) {
+	name, ok := t.names[ref]
+	if !ok {
+		return nil, "", ErrAssetNotFound
+	}
+	return t.AssetSource.Open(name)
+}
+
+// htmlText flattens response markup to plain text, keeping paragraph breaks,
+// list bullets and code blocks.
+func htmlText(markup string) string {
+	if strings.TrimSpace(markup) == "" {
+		return ""
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(markup), &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil {
+		return strings.TrimSpace(markup)
+	}
+
+	var b strings.Builder
+	var render func(n *html.Node)
+	render = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		switch n.Data {
+		case "br":
+			b.WriteString("\n")
+			return
+		case "pre":
+			b.WriteString("\n```\n")
+			b.WriteString(strings.Trim(nodeText(n), "\n"))
+			b.WriteString("\n```\n")
+			return
+		case "li":
+			b.WriteString("\n- ")
+		case "p", "div", "ul", "ol", "table", "tr", "h1", "h2", "h3", "h4", "h5", "h6":
+			b.WriteString("\n")
+			defer b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(c)
+		}
+	}
+	for _, n := range nodes {
+		render(n)
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == "class" {
+			for _, c := range strings.Fields(attr.Val) {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func findByClass(n *html.Node, class string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && hasClass(c, class) {
+			return c
+		}
+		if found := findByClass(c, class); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
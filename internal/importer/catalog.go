@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"mime"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AssetFile is one file shipped inside an export archive.
+type AssetFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// AssetCatalog summarises the asset files of an export archive against the
+// attachments its conversations reference. Unreferenced files (such as
+// DALL·E images from deleted chats) are not imported; Missing lists refs
+// that messages point at but the archive does not contain.
+type AssetCatalog struct {
+	Files        int         `json:"files"`
+	Bytes        int64       `json:"bytes"`
+	Referenced   int         `json:"referenced"`
+	Unreferenced []AssetFile `json:"unreferenced,omitempty"`
+	Missing      []string    `json:"missing,omitempty"`
+}
+
+// assetLister is implemented by asset sources that can enumerate their
+// files. Only archives do: the directory around a loose conversations.json
+// may hold anything.
+type assetLister interface {
+	list() []AssetFile
+}
+
+// CatalogAssets catalogs the files of src against the attachment refs an
+// export's conversations hold. It returns nil when src cannot list its
+// files.
+func CatalogAssets(attachmentRefs []string, src AssetSource) *AssetCatalog {
+	// Blobs are named after their ref, e.g. file-abc123-photo.png, except
+	// in Takeout exports.
+	named := namedAfter
+	if takeout, ok := src.(takeoutAssets); ok {
+		src = takeout.AssetSource
+		named = func(base, ref string) bool { return base == takeout.names[ref] }
+	}
+	lister, ok := src.(assetLister)
+	if !ok {
+		return nil
+	}
+
+	refs := make(map[string]bool, len(attachmentRefs))
+	for _, ref := range attachmentRefs {
+		refs[ref] = false
+	}
+
+	catalog := &AssetCatalog{}
+	for _, file := range lister.list() {
+		catalog.Files++
+		catalog.Bytes += file.Size
+		base := path.Base(file.Path)
+		referenced := false
+		for ref := range refs {
+			if named(base, ref) {
+				refs[ref] = true
+				referenced = true
+			}
+		}
+		if referenced {
+			catalog.Referenced++
+		} else {
+			catalog.Unreferenced = append(catalog.Unreferenced, file)
+		}
+	}
+	for ref, found := range refs {
+		if !found {
+			catalog.Missing = append(catalog.Missing, ref)
+		}
+	}
+	sort.Strings(catalog.Missing)
+	return catalog
+}
+
+func (z zipAssets) list() []AssetFile {
+	var files []AssetFile
+	for _, file := range z.archive.File {
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasSuffix(base, ".json") || strings.HasSuffix(base, ".html") {
+			continue
+		}
+		files = append(files, AssetFile{
+			Path:     file.Name,
+			Size:     int64(file.UncompressedSize64),
+			MimeType: mime.TypeByExtension(path.Ext(base)),
+		})
+	}
+	return files
+}
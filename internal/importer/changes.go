@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// maxChangeEntries caps each list in an ImportChanges so a first import of
+// thousands of conversations does not bloat the history.
+const maxChangeEntries = 200
+
+// RecordChange adds incoming to changes by comparing it with the stored
+// version (nil when the conversation is new).
+func RecordChange(changes *models.ImportChanges, existing *models.Conversation, incoming models.Conversation) {
+	entry := models.ConversationChange{
+		ID:       incoming.ID,
+		Title:    incoming.Title,
+		Messages: len(incoming.Messages),
+	}
+
+	if existing == nil {
+		changes.NewCount++
+		if len(changes.New) < maxChangeEntries {
+			changes.New = append(changes.New, entry)
+		}
+		return
+	}
+
+	if len(incoming.Messages) > len(existing.Messages) {
+		changes.GrownCount++
+		if len(changes.Grown) < maxChangeEntries {
+			e := entry
+			e.PreviousMessages = len(existing.Messages)
+			changes.Grown = append(changes.Grown, e)
+		}
+	}
+	previous := strings.TrimSpace(existing.Title)
+	if previous != "" && previous != strings.TrimSpace(incoming.Title) {
+		changes.RenamedCount++
+		if len(changes.Renamed) < maxChangeEntries {
+			e := entry
+			e.PreviousTitle = previous
+			changes.Renamed = append(changes.Renamed, e)
+		}
+	}
+}
+
+// CombineChanges folds src into dst, keeping dst's lists within the same cap.
+func CombineChanges(dst, src *models.ImportChanges) {
+	if src == nil {
+		return
+	}
+	dst.NewCount += src.NewCount
+	dst.GrownCount += src.GrownCount
+	dst.RenamedCount += src.RenamedCount
+	dst.New = appendCapped(dst.New, src.New)
+	dst.Grown = appendCapped(dst.Grown, src.Grown)
+	dst.Renamed = appendCapped(dst.Renamed, src.Renamed)
+}
+
+func appendCapped(dst, src []models.ConversationChange) []models.ConversationChange {
+	if room := maxChangeEntries - len(dst); room < len(src) {
+		src = src[:max(room, 0)]
+	}
+	return append(dst, src...)
+}
+
+// ConversationStore is the part of *storage.Store that Merge writes to.
+type ConversationStore interface {
+	UpsertChanged(conversations []models.Conversation, seen func(previous *models.Conversation, incoming models.Conversation, changed bool)) error
+}
+
+// MergeResult counts what Merge did to the store.
+type MergeResult struct {
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Changes   *models.ImportChanges `json:"changes"`
+}
+
+// Merge upserts converted conversations into store in one save, skipping
+// those whose ContentHash shows they have not changed since they were last
+// imported, and records which were new, grew or were renamed.
+func Merge(store ConversationStore, items []models.Conversation) (MergeResult, error) {
+	result := MergeResult{Changes: &models.ImportChanges{}}
+	err := store.UpsertChanged(items, func(previous *models.Conversation, incoming models.Conversation, changed bool) {
+		switch {
+		case !changed:
+			result.Unchanged++
+			return
+		case previous == nil:
+			result.Created++
+		default:
+			result.Updated++
+		}
+		RecordChange(result.Changes, previous, incoming)
+	})
+	if err != nil {
+		return MergeResult{}, err
+	}
+	return result, nil
+}
+
+// ContentHash fingerprints a converted conversation: the SHA-256 of its
+// JSON encoding, without the hash itself. StartedAt and EndedAt are left
+// out too; they repeat CreatedAt and UpdatedAt, and hashing them would
+// make every conversation look changed to the first import that set them.
+// So is Stats, which the store works out from the messages.
+func ContentHash(conversation models.Conversation) string {
+	conversation.ContentHash = ""
+	conversation.StartedAt, conversation.EndedAt = time.Time{}, time.Time{}
+	conversation.Stats = nil
+	h := sha256.New()
+	json.NewEncoder(h).Encode(conversation)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IsEmpty reports whether an import changed nothing.
+func IsEmpty(changes *models.ImportChanges) bool {
+	return changes == nil || changes.NewCount+changes.GrownCount+changes.RenamedCount == 0
+}
+
+// WriteReport renders an import history entry and its changes as plain text.
+func WriteReport(w io.Writer, record models.ImportRecord) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Import %s — %s (%s", record.ID, record.File, record.Format)
+	if record.Source != "" {
+		fmt.Fprintf(&b, ", %s", record.Source)
+	}
+	fmt.Fprintf(&b, ") at %s\n", record.StartedAt.Local().Format(time.DateTime))
+	if record.Status != "" && record.Status != "ok" {
+		fmt.Fprintf(&b, "Status: %s", record.Status)
+		if record.Error != "" {
+			fmt.Fprintf(&b, " — %s", record.Error)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d conversations (%d new, %d updated, %d unchanged)\n", record.Conversations, record.Created, record.Updated, record.Unchanged)
+
+	changes := record.Changes
+	if IsEmpty(changes) {
+		b.WriteString("\nNothing new since the previous import.\n")
+		writeIssues(&b, record.Report)
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	section := func(heading string, count int, items []models.ConversationChange, line func(models.ConversationChange) string) {
+		if count == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", heading, count)
+		for _, item := range items {
+			fmt.Fprintf(&b, "  + %s\n", line(item))
+		}
+		if more := count - len(items); more > 0 {
+			fmt.Fprintf(&b, "  … and %d more\n", more)
+		}
+	}
+	section("New conversations", changes.NewCount, changes.New, func(c models.ConversationChange) string {
+		return fmt.Sprintf("%s (%d messages)", c.Title, c.Messages)
+	})
+	section("Conversations with new messages", changes.GrownCount, changes.Grown, func(c models.ConversationChange) string {
+		return fmt.Sprintf("%s: %d → %d messages (+%d)", c.Title, c.PreviousMessages, c.Messages, c.Messages-c.PreviousMessages)
+	})
+	section("Renamed", changes.RenamedCount, changes.Renamed, func(c models.ConversationChange) string {
+		return fmt.Sprintf("%q → %q", c.PreviousTitle, c.Title)
+	})
+	writeIssues(&b, record.Report)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeIssues adds the conversion report's skipped conversations, warnings
+// and unknown content types to a printed import report.
+func writeIssues(b *strings.Builder, report *models.ImportReport) {
+	if !HasIssues(report) {
+		return
+	}
+	for _, heading := range []struct {
+		skipped bool
+		title   string
+		count   int
+	}{{true, "Skipped", report.Skipped}, {false, "Imported with warnings", report.Warnings}} {
+		if heading.count == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n%s (%d):\n", heading.title, heading.count)
+		shown := 0
+		for _, issue := range report.Issues {
+			if issue.Skipped != heading.skipped {
+				continue
+			}
+			name := issue.Title
+			if name == "" {
+				name = issue.ID
+			}
+			if name == "" {
+				name = "(no id)"
+			}
+			fmt.Fprintf(b, "  ! %s: %s\n", name, issue.Reason)
+			shown++
+		}
+		if more := heading.count - shown; more > 0 {
+			fmt.Fprintf(b, "  … and %d more\n", more)
+		}
+	}
+	if len(report.UnknownContentTypes) > 0 {
+		b.WriteString("\nUnsupported content types (messages left out):\n")
+		for _, contentType := range slices.Sorted(maps.Keys(report.UnknownContentTypes)) {
+			fmt.Fprintf(b, "  %s: %d\n", contentType, report.UnknownContentTypes[contentType])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrCheckpointMismatch is returned when resuming from a checkpoint that
+// does not describe the export being loaded.
+var ErrCheckpointMismatch = errors.New("the export does not match the checkpoint")
+
+// Checkpoint records how far a Load of one export file got, so an
+// interrupted import can resume instead of starting over. Done counts the
+// conversations saved so far, in file order, and LastID names the last of
+// them; the totals cover those conversations.
+type Checkpoint struct {
+	File              string    `json:"file"`
+	Hash              string    `json:"hash"`
+	Done              int       `json:"done"`
+	LastID            string    `json:"lastId,omitempty"`
+	Created           int       `json:"created"`
+	Updated           int       `json:"updated"`
+	Unchanged         int       `json:"unchanged"`
+	AttachmentsCopied int       `json:"attachmentsCopied"`
+	StartedAt         time.Time `json:"startedAt"`
+	SavedAt           time.Time `json:"savedAt"`
+}
+
+// ReadCheckpoint loads the checkpoint at path; ok is false when there is
+// none.
+func ReadCheckpoint(path string) (cp Checkpoint, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, false, err
+	}
+	return cp, true, nil
+}
+
+// WriteCheckpoint saves cp to path through a temporary file, so a crash
+// mid-write leaves the previous checkpoint intact.
+func WriteCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// RemoveCheckpoint deletes the checkpoint at path, if any.
+func RemoveCheckpoint(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// SourceClaude marks conversations from an Anthropic (claude.ai) data
+// export.
+const SourceClaude = "claude"
+
+// Claude data export conversations.json: an array of conversations, each
+// with its chat_messages in order. Newer exports link messages through
+// parent_message_uuid and name the current leaf, so edited and retried
+// messages keep their branches.
+type claudeConversation struct {
+	UUID         string           `json:"uuid"`
+	Name         string           `json:"name"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	CurrentLeaf  string           `json:"current_leaf_message_uuid"`
+	ChatMessages *[]claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	UUID        string             `json:"uuid"`
+	Parent      string             `json:"parent_message_uuid"`
+	Sender      string             `json:"sender"`
+	Text        string             `json:"text"`
+	Content     flexText           `json:"content"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Attachments []claudeAttachment `json:"attachments"`
+	Files       []claudeAttachment `json:"files"`
+}
+
+// claudeAttachment is a file added to a message. The export ships only the
+// text claude.ai extracted from documents, never the files themselves.
+type claudeAttachment struct {
+	FileName         string `json:"file_name"`
+	FileSize         int64  `json:"file_size"`
+	FileType         string `json:"file_type"`
+	ExtractedContent string `json:"extracted_content"`
+}
+
+func decodeClaude(_ string, data []byte) ([]models.Conversation, error) {
+	var chats []claudeConversation
+	if err := json.Unmarshal(data, &chats); err != nil {
+		return nil, ErrNotExport
+	}
+
+	var out []models.Conversation
+	for _, chat := range chats {
+		if chat.UUID == "" || chat.ChatMessages == nil {
+			return nil, ErrNotExport
+		}
+		id := "claude-" + chat.UUID
+		g := newGraph(id, chat.Name, unixSeconds(chat.CreatedAt), unixSeconds(chat.UpdatedAt))
+
+		var attachments []models.Attachment
+		previous := ""
+		for _, msg := range *chat.ChatMessages {
+			if msg.UUID == "" {
+				continue
+			}
+			role := "user"
+			if msg.Sender == "assistant" {
+				role = "assistant"
+			}
+			text := strings.TrimSpace(string(msg.Content))
+			if text == "" {
+				text = msg.Text
+			}
+			// Messages hang off the previous one unless the export says
+			// otherwise; the root UUID it uses is not itself a message.
+			parent := previous
+			if _, ok := g.raw.Mapping[msg.Parent]; ok {
+				parent = msg.Parent
+			}
+			g.add(msg.UUID, parent, role, text, "", unixSeconds(msg.CreatedAt))
+			previous = msg.UUID
+
+			for i, file := range append(msg.Attachments, msg.Files...) {
+				attachments = append(attachments, models.Attachment{
+					Ref:       fmt.Sprintf("%s-%s-%d", id, msg.UUID, i),
+					MessageID: msg.UUID,
+					Name:      file.FileName,
+					Size:      file.FileSize,
+					Text:      file.ExtractedContent,
+				})
+			}
+		}
+		if _, ok := g.raw.Mapping[chat.CurrentLeaf]; ok {
+			g.raw.CurrentNode = chat.CurrentLeaf
+		}
+
+		convo, ok := g.convert(SourceClaude)
+		if !ok {
+			continue
+		}
+		convo.Attachments = append(convo.Attachments, attachments...)
+		out = append(out, convo)
+	}
+	return out, nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
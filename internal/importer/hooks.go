@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"zatGPT/internal/models"
+)
+
+// Hook lets code built on the importer enrich or filter conversations
+// without changing the parsers: set in Options.Hooks, it sees every
+// conversation an import converts, before its tokens are counted and its
+// ContentHash set, and may change it in place (to tag it, or redact it).
+// Returning ErrSkip leaves the conversation out of the import, noted in
+// the report; any other error fails the import. Streamed exports are
+// converted on several goroutines, so OnConversation must be safe for
+// concurrent use.
+type Hook interface {
+	OnConversation(conv *models.Conversation) error
+}
+
+// HookFunc adapts an ordinary function to a Hook.
+type HookFunc func(conv *models.Conversation) error
+
+// OnConversation calls f(conv).
+func (f HookFunc) OnConversation(conv *models.Conversation) error {
+	return f(conv)
+}
+
+// ErrSkip is returned by a Hook to leave a conversation out of an import.
+var ErrSkip = errors.New("skip conversation")
+
+// runHooks passes convo through hooks in order, stopping at the first that
+// skips it (reported as skipped) or fails.
+func runHooks(hooks []Hook, convo *models.Conversation) (skipped bool, err error) {
+	for _, hook := range hooks {
+		err := hook.OnConversation(convo)
+		if errors.Is(err, ErrSkip) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("import hook failed on conversation %s: %w", convo.ID, err)
+		}
+	}
+	return false, nil
+}
+
+// hookBatch runs the hooks over copies of a batch of a loaded export,
+// which Batches may hand out again, and returns those they keep, noting
+// the others in report as skipped. The copies have slices of their own, so
+// a hook editing messages, parts, tags, attachments or the tree in place
+// leaves the loaded export as it was.
+func hookBatch(hooks []Hook, batch []models.Conversation, report *models.ImportReport) ([]models.Conversation, error) {
+	kept := make([]models.Conversation, 0, len(batch))
+	for _, convo := range batch {
+		convo = cloneForHooks(convo)
+		skipped, err := runHooks(hooks, &convo)
+		if err != nil {
+			return nil, err
+		}
+		if skipped {
+			noteHookSkipped(report, &convo)
+			continue
+		}
+		kept = append(kept, convo)
+	}
+	return kept, nil
+}
+
+// cloneForHooks copies the slices of convo that a hook may change in place.
+func cloneForHooks(convo models.Conversation) models.Conversation {
+	convo.Messages = slices.Clone(convo.Messages)
+	for i := range convo.Messages {
+		convo.Messages[i].Parts = slices.Clone(convo.Messages[i].Parts)
+	}
+	convo.Tags = slices.Clone(convo.Tags)
+	convo.Topics = slices.Clone(convo.Topics)
+	convo.Attachments = slices.Clone(convo.Attachments)
+	convo.Tree = slices.Clone(convo.Tree)
+	convo.Redactions = slices.Clone(convo.Redactions)
+	return convo
+}
+
+// noteHookSkipped records that a hook left convo out of the import.
+func noteHookSkipped(report *models.ImportReport, convo *models.Conversation) {
+	report.Skipped++
+	addIssue(report, models.ImportIssue{ID: convo.ID, Title: convo.Title, Skipped: true, Reason: reasonHook})
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+// BatchSize is how many conversations Load converts and saves at a time, so
+// a multi-gigabyte export is never held in memory whole.
+const BatchSize = 500
+
+// Destination is where Load writes: the local store, or a server reached
+// through its API.
+type Destination interface {
+	AttachmentSink
+	Merge(items []models.Conversation) (MergeResult, error)
+}
+
+// wholeSaver is implemented by destinations that can tell whether every
+// save rewrites all they hold, as a JSON store does (*storage.Store).
+type wholeSaver interface {
+	SavesWhole() bool
+}
+
+// LoadResult totals what Load did with one export.
+type LoadResult struct {
+	Conversations     int
+	Created           int
+	Updated           int
+	Unchanged         int
+	AttachmentsCopied int
+	Changes           *models.ImportChanges
+	// Report is the export's conversion report, nil when everything in it
+	// was converted.
+	Report *models.ImportReport
+	// Assets catalogs the files of a ZIP export; nil for other formats.
+	Assets *AssetCatalog
+	// Resumed counts the conversations skipped because a checkpoint had
+	// them saved already. They are included in the totals above, but not
+	// in Changes.
+	Resumed int
+}
+
+// LoadOptions tunes Load.
+type LoadOptions struct {
+	// Prepare, when non-nil, runs on each batch after its attachments are
+	// copied and before it is saved (the importer's OCR pass).
+	Prepare func(context.Context, []models.Conversation)
+	// Resume, when set, is a checkpoint of an earlier, interrupted load of
+	// the same export: the conversations it has as done are skipped.
+	Resume *Checkpoint
+	// Progress, when non-nil, is called after every saved batch with a
+	// checkpoint of the load so far and the fraction of the export read.
+	// Its File, Hash and StartedAt come from Resume. An error stops the
+	// load.
+	Progress func(cp Checkpoint, fraction float64) error
+}
+
+// Load copies the attachments of exp and merges its conversations into dst,
+// BatchSize at a time.
+//
+// When a batch fails or ctx is cancelled, the batches before it stay saved and the returned
+// result counts them; loading the same export again is safe, and resuming
+// from the last checkpoint skips them. A destination that rewrites all it
+// holds on every save (see wholeSaver) is merged into once, at the end, so
+// a large export does not rewrite it once per batch; nothing is saved to it
+// when the load stops early, and Progress is called only then.
+func Load(ctx context.Context, exp *Export, dst Destination, opts LoadOptions) (LoadResult, error) {
+	result := LoadResult{Changes: &models.ImportChanges{}}
+	var cp Checkpoint
+	if opts.Resume != nil {
+		cp = *opts.Resume
+		result.Resumed = cp.Done
+		result.Conversations = cp.Done
+		result.Created = cp.Created
+		result.Updated = cp.Updated
+		result.Unchanged = cp.Unchanged
+		result.AttachmentsCopied = cp.AttachmentsCopied
+	}
+
+	whole, ok := dst.(wholeSaver)
+	saveOnce := ok && whole.SavesWhole()
+	var pending []models.Conversation
+
+	var refs []string
+	var batchErr error
+	seen := 0
+	err := exp.Batches(BatchSize, func(items []models.Conversation) error {
+		for _, item := range items {
+			for _, att := range item.Attachments {
+				refs = append(refs, att.Ref)
+			}
+		}
+		seen += len(items)
+		if skip := result.Resumed - (seen - len(items)); skip > 0 {
+			if skip > len(items) {
+				return nil
+			}
+			if items[skip-1].ID != cp.LastID {
+				batchErr = fmt.Errorf("%w: conversation %d is %s, not %s", ErrCheckpointMismatch, result.Resumed, items[skip-1].ID, cp.LastID)
+				return batchErr
+			}
+			if items = items[skip:]; len(items) == 0 {
+				return nil
+			}
+		}
+
+		// A cancelled load stops between batches, never inside a save.
+		if batchErr = ctx.Err(); batchErr != nil {
+			return batchErr
+		}
+		if batchErr = prepareBatch(ctx, exp.Assets, dst, items, opts.Prepare, &result); batchErr != nil {
+			return batchErr
+		}
+		if saveOnce {
+			pending = append(pending, items...)
+			return nil
+		}
+		batchErr = saveBatch(ctx, dst, items, &result)
+		if batchErr != nil || opts.Progress == nil {
+			return batchErr
+		}
+		batchErr = opts.Progress(loadCheckpoint(cp, items, result), exp.Progress())
+		return batchErr
+	})
+	if err == nil && len(pending) > 0 {
+		batchErr = saveBatch(ctx, dst, pending, &result)
+		if batchErr == nil && opts.Progress != nil {
+			batchErr = opts.Progress(loadCheckpoint(cp, pending, result), exp.Progress())
+		}
+		err = batchErr
+	}
+	if err == nil && seen < result.Resumed {
+		err = fmt.Errorf("%w: the export has %d conversations, the checkpoint %d", ErrCheckpointMismatch, seen, result.Resumed)
+		batchErr = err
+	}
+	if err != nil {
+		if err != batchErr {
+			err = fmt.Errorf("failed to parse export: %w", err)
+		}
+		if result.Conversations > result.Resumed {
+			err = fmt.Errorf("%w (%d conversations were saved before the failure)", err, result.Conversations)
+		}
+		return result, err
+	}
+	result.Assets = CatalogAssets(refs, exp.Assets)
+	if HasIssues(&exp.Report) {
+		report := exp.Report
+		result.Report = &report
+	}
+	return result, nil
+}
+
+// loadCheckpoint brings cp up to date with result, items being the batch
+// just saved.
+func loadCheckpoint(cp Checkpoint, items []models.Conversation, result LoadResult) Checkpoint {
+	cp.Done = result.Conversations
+	cp.LastID = items[len(items)-1].ID
+	cp.Created = result.Created
+	cp.Updated = result.Updated
+	cp.Unchanged = result.Unchanged
+	cp.AttachmentsCopied = result.AttachmentsCopied
+	return cp
+}
+
+// prepareBatch copies the attachments of a batch and runs prepare on it.
+func prepareBatch(ctx context.Context, assets AssetSource, dst AttachmentSink, items []models.Conversation, prepare func(context.Context, []models.Conversation), result *LoadResult) error {
+	_, copySpan := telemetry.Start(ctx, "importer.CopyAttachments")
+	copied, err := CopyAttachments(items, assets, dst)
+	telemetry.End(copySpan, err)
+	result.AttachmentsCopied += copied
+	if err != nil {
+		return fmt.Errorf("failed to copy attachments: %w", err)
+	}
+
+	if prepare != nil {
+		prepare(ctx, items)
+	}
+	return nil
+}
+
+// saveBatch merges a prepared batch into dst.
+func saveBatch(ctx context.Context, dst Destination, items []models.Conversation, result *LoadResult) error {
+	_, persistSpan := telemetry.Start(ctx, "store.Upsert")
+	merged, err := dst.Merge(items)
+	telemetry.End(persistSpan, err)
+	if err != nil {
+		return fmt.Errorf("failed to persist conversations: %w", err)
+	}
+	CombineChanges(result.Changes, merged.Changes)
+	result.Created += merged.Created
+	result.Updated += merged.Updated
+	result.Unchanged += merged.Unchanged
+	result.Conversations += len(items)
+	return nil
+}
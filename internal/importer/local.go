@@ -0,0 +1,389 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// Sources for self-hosted chat front-ends.
+const (
+	SourceOpenWebUI = "openwebui"
+	SourceLMStudio  = "lmstudio"
+	SourceOllama    = "ollama"
+)
+
+// graph assembles a ChatGPT-shaped message mapping so the other chat formats
+// reuse convertConversation's timeline, summary and branch handling.
+type graph struct {
+	raw exportConversation
+}
+
+func newGraph(id, title string, created, updated float64) *graph {
+	g := &graph{raw: exportConversation{
+		ConversationID: id,
+		Title:          title,
+		Mapping:        make(map[string]exportNode),
+	}}
+	if created > 0 {
+		g.raw.CreateTime = &created
+	}
+	if updated > 0 {
+		g.raw.UpdateTime = &updated
+	}
+	return g
+}
+
+// add appends a message under parent (empty for a root) and makes it the
+// current node.
+func (g *graph) add(id, parent, role, text, model string, at float64) {
+	part, _ := json.Marshal(text)
+	msg := &exportMessage{
+		ID:       id,
+		Author:   exportAuthor{Role: role},
+		Content:  exportContent{ContentType: "text", Parts: []json.RawMessage{part}},
+		Metadata: exportMetadata{ModelSlug: model},
+	}
+	if at > 0 {
+		msg.CreateTime = &at
+	}
+	g.raw.Mapping[id] = exportNode{ID: id, Parent: parent, Message: msg}
+	if p, ok := g.raw.Mapping[parent]; ok {
+		p.Children = append(p.Children, id)
+		g.raw.Mapping[parent] = p
+	}
+	g.raw.CurrentNode = id
+}
+
+func (g *graph) convert(source string) (models.Conversation, bool) {
+	convo := convertConversation(g.raw, false, nil)
+	if convo == nil || len(convo.Messages) == 0 {
+		return models.Conversation{}, false
+	}
+	convo.Source = source
+	return *convo, true
+}
+
+// flexText decodes message content that is either a plain string or a list
+// of typed parts ([{"type": "text", "text": "..."}]).
+type flexText string
+
+func (t *flexText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = flexText(s)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err == nil {
+		var texts []string
+		for _, p := range parts {
+			if (p.Type == "" || p.Type == "text") && strings.TrimSpace(p.Text) != "" {
+				texts = append(texts, p.Text)
+			}
+		}
+		*t = flexText(strings.Join(texts, "\n\n"))
+		return nil
+	}
+	var object struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &object); err == nil {
+		*t = flexText(object.Text)
+	}
+	return nil
+}
+
+// seconds normalises Unix timestamps that may be in seconds or milliseconds.
+func seconds(ts float64) float64 {
+	if ts > 1e12 {
+		return ts / 1000
+	}
+	return ts
+}
+
+// Open WebUI "Export chats" JSON: an array of chats (or a single chat), each
+// holding the full message tree under chat.history.
+type openWebUIChat struct {
+	ID        string  `json:"id"`
+	Title     string  `json:"title"`
+	CreatedAt float64 `json:"created_at"`
+	UpdatedAt float64 `json:"updated_at"`
+	Chat      *struct {
+		Title   string   `json:"title"`
+		Models  []string `json:"models"`
+		History struct {
+			CurrentID string                      `json:"currentId"`
+			Messages  map[string]openWebUIMessage `json:"messages"`
+		} `json:"history"`
+		Messages []openWebUIMessage `json:"messages"`
+	} `json:"chat"`
+}
+
+type openWebUIMessage struct {
+	ID        string   `json:"id"`
+	ParentID  string   `json:"parentId"`
+	Role      string   `json:"role"`
+	Content   flexText `json:"content"`
+	Timestamp float64  `json:"timestamp"`
+	Model     string   `json:"model"`
+}
+
+func decodeOpenWebUI(_ string, data []byte) ([]models.Conversation, error) {
+	var chats []openWebUIChat
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var single openWebUIChat
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, ErrNotExport
+		}
+		chats = []openWebUIChat{single}
+	} else if err := json.Unmarshal(data, &chats); err != nil {
+		return nil, ErrNotExport
+	}
+
+	var out []models.Conversation
+	for _, chat := range chats {
+		if chat.Chat == nil || chat.ID == "" {
+			return nil, ErrNotExport
+		}
+		title := firstNonEmpty(chat.Title, chat.Chat.Title)
+		g := newGraph("openwebui-"+chat.ID, title, seconds(chat.CreatedAt), seconds(chat.UpdatedAt))
+
+		messages := chat.Chat.History.Messages
+		if len(messages) == 0 {
+			// Older exports only carry the linear message list.
+			messages = make(map[string]openWebUIMessage, len(chat.Chat.Messages))
+			for _, msg := range chat.Chat.Messages {
+				messages[msg.ID] = msg
+			}
+		}
+		for _, msg := range sortedOpenWebUI(messages) {
+			model := msg.Model
+			if model == "" && msg.Role == "assistant" && len(chat.Chat.Models) > 0 {
+				model = chat.Chat.Models[0]
+			}
+			g.add(msg.ID, msg.ParentID, msg.Role, string(msg.Content), model, seconds(msg.Timestamp))
+		}
+		if current := chat.Chat.History.CurrentID; current != "" {
+			if _, ok := g.raw.Mapping[current]; ok {
+				g.raw.CurrentNode = current
+			}
+		}
+		if convo, ok := g.convert(SourceOpenWebUI); ok {
+			out = append(out, convo)
+		}
+	}
+	if len(out) == 0 && len(chats) > 0 {
+		return nil, ErrNotExport
+	}
+	return out, nil
+}
+
+// sortedOpenWebUI orders messages so parents come before their children.
+func sortedOpenWebUI(messages map[string]openWebUIMessage) []openWebUIMessage {
+	out := make([]openWebUIMessage, 0, len(messages))
+	placed := make(map[string]bool, len(messages))
+	var place func(msg openWebUIMessage, depth int)
+	place = func(msg openWebUIMessage, depth int) {
+		if placed[msg.ID] || depth > len(messages) {
+			return
+		}
+		if parent, ok := messages[msg.ParentID]; ok {
+			place(parent, depth+1)
+		}
+		placed[msg.ID] = true
+		out = append(out, msg)
+	}
+	ids := make([]string, 0, len(messages))
+	for id := range messages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := messages[ids[i]], messages[ids[j]]
+		if a.Timestamp == b.Timestamp {
+			return a.ID < b.ID
+		}
+		return a.Timestamp < b.Timestamp
+	})
+	for _, id := range ids {
+		place(messages[id], 0)
+	}
+	return out
+}
+
+// LM Studio stores one conversation per `*.conversation.json`. Each message
+// lists its regenerated versions and which one is selected; older builds
+// write plain role/content messages.
+type lmStudioChat struct {
+	Name          string            `json:"name"`
+	CreatedAt     float64           `json:"createdAt"`
+	Messages      []lmStudioMessage `json:"messages"`
+	LastUsedModel *struct {
+		Identifier string `json:"identifier"`
+	} `json:"lastUsedModel"`
+}
+
+type lmStudioMessage struct {
+	Versions          []lmStudioVersion `json:"versions"`
+	CurrentlySelected int               `json:"currentlySelected"`
+
+	Role    string   `json:"role"`
+	Content flexText `json:"content"`
+}
+
+type lmStudioVersion struct {
+	Role    string   `json:"role"`
+	Content flexText `json:"content"`
+	Steps   []struct {
+		Type    string   `json:"type"`
+		Content flexText `json:"content"`
+	} `json:"steps"`
+	SenderInfo struct {
+		SenderName string `json:"senderName"`
+	} `json:"senderInfo"`
+}
+
+func (v lmStudioVersion) text() string {
+	if len(v.Steps) == 0 {
+		return string(v.Content)
+	}
+	var parts []string
+	for _, step := range v.Steps {
+		if step.Type == "contentBlock" && strings.TrimSpace(string(step.Content)) != "" {
+			parts = append(parts, string(step.Content))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func decodeLMStudio(path string, data []byte) ([]models.Conversation, error) {
+	var chat lmStudioChat
+	if err := json.Unmarshal(data, &chat); err != nil || len(chat.Messages) == 0 || (chat.Name == "" && chat.CreatedAt == 0) {
+		return nil, ErrNotExport
+	}
+
+	stem := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".json"), ".conversation")
+	created := seconds(chat.CreatedAt)
+	g := newGraph("lmstudio-"+stem, chat.Name, created, 0)
+
+	defaultModel := ""
+	if chat.LastUsedModel != nil {
+		defaultModel = chat.LastUsedModel.Identifier
+	}
+
+	parent := ""
+	for i, msg := range chat.Messages {
+		versions := msg.Versions
+		if len(versions) == 0 {
+			versions = []lmStudioVersion{{Role: msg.Role, Content: msg.Content}}
+		}
+		selected := ""
+		for j, version := range versions {
+			id := strconv.Itoa(i)
+			if len(versions) > 1 {
+				id += "." + strconv.Itoa(j)
+			}
+			model := ""
+			if version.Role == "assistant" {
+				model = firstNonEmpty(version.SenderInfo.SenderName, defaultModel)
+			}
+			g.add(g.raw.ConversationID+"-"+id, parent, version.Role, version.text(), model, 0)
+			if j == msg.CurrentlySelected || selected == "" {
+				selected = g.raw.ConversationID + "-" + id
+			}
+		}
+		parent = selected
+	}
+	g.raw.CurrentNode = parent
+
+	convo, ok := g.convert(SourceLMStudio)
+	if !ok {
+		return nil, ErrNotExport
+	}
+	return []models.Conversation{convo}, nil
+}
+
+var ollamaRun = regexp.MustCompile(`ollama\s+run\s+(\S+)`)
+
+// decodeOllama parses a saved `ollama run` terminal session: prompts follow
+// ">>> " (continued with "... "), everything else is the model's reply.
+func decodeOllama(path string, data []byte) ([]models.Conversation, error) {
+	type turn struct {
+		role string
+		text []string
+	}
+	var (
+		model string
+		turns []turn
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, ">>> "):
+			prompt := strings.TrimPrefix(line, ">>> ")
+			if strings.HasPrefix(prompt, "/") || strings.HasPrefix(prompt, "Send a message") {
+				turns = append(turns, turn{role: "command"})
+				continue
+			}
+			turns = append(turns, turn{role: "user", text: []string{strings.Trim(prompt, `"`)}})
+		case strings.HasPrefix(line, "... ") && len(turns) > 0 && turns[len(turns)-1].role == "user":
+			t := &turns[len(turns)-1]
+			t.text = append(t.text, strings.Trim(strings.TrimPrefix(line, "... "), `"`))
+		case len(turns) == 0:
+			if m := ollamaRun.FindStringSubmatch(line); m != nil {
+				model = m[1]
+			}
+		default:
+			last := turns[len(turns)-1]
+			if last.role == "user" {
+				turns = append(turns, turn{role: "assistant"})
+			}
+			if last.role != "command" {
+				t := &turns[len(turns)-1]
+				t.text = append(t.text, line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var created float64
+	if info, err := os.Stat(path); err == nil {
+		created = float64(info.ModTime().Unix())
+	}
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	g := newGraph("ollama-"+stem, "", created, created)
+	parent := ""
+	for i, t := range turns {
+		if t.role == "command" {
+			continue
+		}
+		id := g.raw.ConversationID + "-" + strconv.Itoa(i)
+		m := ""
+		if t.role == "assistant" {
+			m = model
+		}
+		g.add(id, parent, t.role, strings.TrimSpace(strings.Join(t.text, "\n")), m, created)
+		parent = id
+	}
+
+	convo, ok := g.convert(SourceOllama)
+	if !ok {
+		return nil, ErrNotExport
+	}
+	return []models.Conversation{convo}, nil
+}
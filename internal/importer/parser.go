@@ -2,30 +2,62 @@ package importer
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"math"
-	"os"
 	"sort"
 	"strings"
 	"time"
 
+	"zatGPT/internal/ids"
 	"zatGPT/internal/models"
+	"zatGPT/internal/tokenizer"
 )
 
-// LoadAndConvert reads an export file and returns Conversation models ready for persistence.
-func LoadAndConvert(path string) ([]models.Conversation, error) {
-	payload, err := readExport(path)
+// LoadAndConvert reads an export file and returns Conversation models ready
+// for persistence, with a report of what could not be converted. It accepts
+// everything Open does, including the export ZIP as downloaded, with the
+// same opts, whose Hooks can enrich or filter what it returns; use Open
+// directly to reach the archive's attachments.
+func LoadAndConvert(path string, opts Options) ([]models.Conversation, models.ImportReport, error) {
+	exp, err := Open(path, opts)
 	if err != nil {
-		return nil, err
+		return nil, models.ImportReport{}, err
 	}
+	defer exp.Close()
 
+	var conversations []models.Conversation
+	err = exp.Batches(500, func(batch []models.Conversation) error {
+		conversations = append(conversations, batch...)
+		return nil
+	})
+	return conversations, exp.Report, err
+}
+
+// DecodeConversation converts a single conversation object in the export
+// shape, as served by ChatGPT's backend API for one conversation.
+func DecodeConversation(r io.Reader) (models.Conversation, error) {
+	var raw exportConversation
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return models.Conversation{}, err
+	}
+	convo := convertConversation(raw, false, nil)
+	if convo == nil {
+		return models.Conversation{}, ErrNotExport
+	}
+	tokenizer.CountConversation(convo)
+	convo.ContentHash = ContentHash(*convo)
+	return *convo, nil
+}
+
+func convertAll(payload []exportConversation, allRoles bool, report *models.ImportReport) []models.Conversation {
 	conversations := make([]models.Conversation, 0, len(payload))
 	for _, raw := range payload {
-		if item := convertConversation(raw); item != nil {
+		if item := convertConversation(raw, allRoles, report); item != nil {
 			conversations = append(conversations, *item)
 		}
 	}
-
-	return conversations, nil
+	return conversations
 }
 
 type exportConversation struct {
@@ -46,44 +78,117 @@ type exportNode struct {
 }
 
 type exportMessage struct {
-	ID         string        `json:"id"`
-	Author     exportAuthor  `json:"author"`
-	CreateTime *float64      `json:"create_time"`
-	UpdateTime *float64      `json:"update_time"`
-	Content    exportContent `json:"content"`
+	ID         string         `json:"id"`
+	Author     exportAuthor   `json:"author"`
+	CreateTime *float64       `json:"create_time"`
+	UpdateTime *float64       `json:"update_time"`
+	Content    exportContent  `json:"content"`
+	Status     string         `json:"status"`
+	Weight     *float64       `json:"weight"`
+	Recipient  string         `json:"recipient"`
+	Metadata   exportMetadata `json:"metadata"`
+}
+
+type exportMetadata struct {
+	Attachments   []exportAttachment `json:"attachments"`
+	ModelSlug     string             `json:"model_slug"`
+	FinishDetails *struct {
+		Type string `json:"type"`
+	} `json:"finish_details"`
 }
 
 type exportAuthor struct {
 	Role string `json:"role"`
+	Name string `json:"name"`
 }
 
 type exportContent struct {
 	ContentType string            `json:"content_type"`
 	Parts       []json.RawMessage `json:"parts"`
+
+	// Fields of the content types of system and tool messages.
+	Text             string `json:"text"`
+	Language         string `json:"language"`
+	Result           string `json:"result"`
+	Summary          string `json:"summary"`
+	Title            string `json:"title"`
+	URL              string `json:"url"`
+	UserProfile      string `json:"user_profile"`
+	UserInstructions string `json:"user_instructions"`
+}
+
+// decodeExport reads an in-memory export array, as embedded in chat.html.
+// Entries without a message mapping are kept, for convertAll to report.
+func decodeExport(r io.Reader) ([]exportConversation, error) {
+	var payload []exportConversation
+	keep := func(raw exportConversation) error {
+		payload = append(payload, raw)
+		return nil
+	}
+	err := streamExport(r, keep, func(raw exportConversation) { keep(raw) })
+	return payload, err
 }
 
-func readExport(path string) ([]exportConversation, error) {
-	file, err := os.Open(path)
+// streamExport decodes the conversations of an export array one at a time,
+// so a multi-gigabyte conversations.json is never held in memory at once.
+// Only entries with a message mapping reach fn; the others go to skip, when
+// it is non-nil. Other files in an export (message_feedback.json,
+// shared_conversations.json) are arrays too, and are reported as
+// ErrNotExport when none of their entries has a mapping.
+func streamExport(r io.Reader, fn func(exportConversation) error, skip func(exportConversation)) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return ErrNotExport
 	}
-	defer file.Close()
 
-	var payload []exportConversation
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&payload); err != nil {
-		return nil, err
+	entries, exports := 0, 0
+	for decoder.More() {
+		var raw exportConversation
+		if err := decoder.Decode(&raw); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field == "" {
+				return ErrNotExport
+			}
+			return err
+		}
+		entries++
+		if len(raw.Mapping) == 0 {
+			if skip != nil {
+				skip(raw)
+			}
+			continue
+		}
+		exports++
+		if err := fn(raw); err != nil {
+			return err
+		}
 	}
-	return payload, nil
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if entries > 0 && exports == 0 {
+		return ErrNotExport
+	}
+	return nil
 }
 
-func convertConversation(raw exportConversation) *models.Conversation {
+// convertConversation converts the current branch of raw. Only user and
+// assistant messages, and Code Interpreter runs, are kept unless allRoles is
+// set, which keeps every system and tool message. A conversation it skips, or whose messages it cannot
+// all read, is noted in report, which may be nil.
+func convertConversation(raw exportConversation, allRoles bool, report *models.ImportReport) *models.Conversation {
 	if len(raw.Mapping) == 0 {
+		noteSkipped(report, raw, reasonNoMapping)
 		return nil
 	}
 
 	timeline := traversalPath(raw)
 	if len(timeline) == 0 {
+		noteSkipped(report, raw, reasonEmptyBranch)
 		return nil
 	}
 
@@ -95,6 +200,9 @@ func convertConversation(raw exportConversation) *models.Conversation {
 		firstUser      string
 		firstAssistant string
 		messages       []models.Message
+		attachments    []models.Attachment
+		// dropped counts the messages left out for their content type.
+		dropped map[string]int
 	)
 
 	for _, node := range timeline {
@@ -102,6 +210,8 @@ func convertConversation(raw exportConversation) *models.Conversation {
 			continue
 		}
 
+		attachments = appendAttachments(attachments, node.ID, node.Message)
+
 		if ts, ok := toTime(node.Message.CreateTime); ok {
 			if !hasEarliest || ts.Before(earliest) {
 				earliest = ts
@@ -113,33 +223,45 @@ func convertConversation(raw exportConversation) *models.Conversation {
 			}
 		}
 
+		role := messageRole(node.Message)
+		tools := allRoles || codeRun(node.Message)
+		kept := role == models.AuthorUser || role == models.AuthorAssistant ||
+			(tools && (role == models.AuthorSystem || role == models.AuthorTool))
+		contentType := node.Message.Content.ContentType
+		if kept && contentType != "" && !knownContentTypes[contentType] {
+			if dropped == nil {
+				dropped = make(map[string]int)
+			}
+			dropped[contentType]++
+			continue
+		}
 		text := extractText(node.Message.Content)
+		if text == "" && tools && (role == models.AuthorSystem || role == models.AuthorTool) {
+			text = toolText(node.Message.Content)
+		}
 		if text == "" {
 			continue
 		}
 
-		role := strings.ToLower(node.Message.Author.Role)
 		switch role {
-		case "user":
+		case models.AuthorUser:
 			if firstUser == "" {
 				firstUser = text
 			}
-			messages = append(messages, models.Message{
-				ID:        node.ID,
-				Author:    role,
-				Content:   text,
-				CreatedAt: timestampOrZero(node.Message.CreateTime),
-			})
-		case "assistant":
+			messages = append(messages, newMessage(node, role, text))
+		case models.AuthorAssistant:
 			if firstAssistant == "" {
 				firstAssistant = text
 			}
-			messages = append(messages, models.Message{
-				ID:        node.ID,
-				Author:    role,
-				Content:   text,
-				CreatedAt: timestampOrZero(node.Message.CreateTime),
-			})
+			msg := newMessage(node, role, text)
+			msg.Model = node.Message.Metadata.ModelSlug
+			messages = append(messages, msg)
+		case models.AuthorSystem, models.AuthorTool:
+			if tools {
+				msg := newMessage(node, role, text)
+				msg.Model = node.Message.Metadata.ModelSlug
+				messages = append(messages, msg)
+			}
 		}
 	}
 
@@ -167,23 +289,25 @@ func convertConversation(raw exportConversation) *models.Conversation {
 	if title == "" {
 		title = truncate(summary, 80)
 		if title == "" {
-			title = "Untitled conversation"
+			title = models.UntitledTitle
 		}
 	}
 
 	var dateStarted, dateEnded string
-	var createdAt, updatedAt time.Time
+	var startedAt, endedAt, createdAt, updatedAt time.Time
 
 	if hasEarliest {
 		dateStarted = earliest.UTC().Format("2006-01-02")
-		createdAt = earliest.UTC()
+		startedAt = earliest.UTC()
+		createdAt = startedAt
 	} else {
 		createdAt = time.Now().UTC()
 	}
 
 	if hasLatest {
 		dateEnded = latest.UTC().Format("2006-01-02")
-		updatedAt = latest.UTC()
+		endedAt = latest.UTC()
+		updatedAt = endedAt
 	} else {
 		updatedAt = createdAt
 	}
@@ -192,21 +316,52 @@ func convertConversation(raw exportConversation) *models.Conversation {
 	if id == "" {
 		id = strings.TrimSpace(raw.ID)
 	}
-	if id == "" {
+	// Conversations with no ID of their own keep the title-and-time IDs
+	// earlier imports gave them, so re-importing the export updates them
+	// rather than adding copies. Without a time that ID was never stable,
+	// so those get a content-derived one.
+	if id == "" && hasEarliest {
 		id = newDeterministicID(title, createdAt)
 	}
+	if id == "" {
+		id = ids.FromContent(SourceChatGPT, title, summary)
+	}
 
-	return &models.Conversation{
+	convo := &models.Conversation{
 		ID:          id,
 		Title:       title,
 		Summary:     summary,
 		DateStarted: dateStarted,
 		DateEnded:   dateEnded,
+		StartedAt:   startedAt,
+		EndedAt:     endedAt,
 		SourceID:    id,
+		Source:      SourceChatGPT,
 		Messages:    messages,
+		Attachments: attachments,
+		Tree:        buildTree(raw, timeline),
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
 	}
+	noteDropped(report, convo, dropped)
+	return convo
+}
+
+// newMessage converts the message of node, whose text is already extracted.
+func newMessage(node exportNode, role, text string) models.Message {
+	msg := models.Message{
+		ID:        node.ID,
+		Author:    role,
+		Content:   text,
+		Parts:     messageParts(node.Message),
+		Status:    node.Message.Status,
+		Weight:    node.Message.Weight,
+		CreatedAt: timestampOrZero(node.Message.CreateTime),
+	}
+	if details := node.Message.Metadata.FinishDetails; details != nil {
+		msg.FinishReason = details.Type
+	}
+	return msg
 }
 
 func traversalPath(raw exportConversation) []exportNode {
@@ -270,6 +425,64 @@ func timelineByTimestamps(raw exportConversation) []exportNode {
 	return nodes
 }
 
+// messageRole returns the Author a message is stored under: its author's
+// role, except that Custom Instructions are "system" whoever the export
+// says wrote them (older exports say the user), and that an assistant
+// message addressed to a tool (the browser, python, dalle) is a "tool"
+// call.
+func messageRole(msg *exportMessage) string {
+	role := strings.ToLower(msg.Author.Role)
+	switch {
+	case msg.Content.ContentType == "user_editable_context":
+		return models.AuthorSystem
+	case role == models.AuthorAssistant && msg.Recipient != "" && msg.Recipient != "all":
+		return models.AuthorTool
+	}
+	return role
+}
+
+// codeRun reports whether msg is code the assistant ran in Code
+// Interpreter, or what running it returned. Unlike other tool messages,
+// these are kept without allRoles: they are often the point of the
+// conversation.
+func codeRun(msg *exportMessage) bool {
+	switch msg.Content.ContentType {
+	case "code":
+		return msg.Recipient == "python"
+	case "execution_output", "system_error":
+		return msg.Author.Name == "python"
+	}
+	return false
+}
+
+// messageParts returns the code or output a tool message carries as
+// structured parts, or nil for content that is only text. Exports mostly
+// name the language of code "unknown"; code sent to Code Interpreter is
+// Python.
+func messageParts(msg *exportMessage) []models.MessagePart {
+	content := msg.Content
+	text := strings.TrimSpace(content.Text)
+	if text == "" {
+		return nil
+	}
+	switch content.ContentType {
+	case "code":
+		language := content.Language
+		if language == "unknown" {
+			language = ""
+		}
+		if language == "" && msg.Recipient == "python" {
+			language = "python"
+		}
+		return []models.MessagePart{{Type: models.PartCode, Language: language, Source: text}}
+	case "execution_output":
+		return []models.MessagePart{{Type: models.PartOutput, Output: text}}
+	case "system_error":
+		return []models.MessagePart{{Type: models.PartError, Output: text}}
+	}
+	return nil
+}
+
 func extractText(content exportContent) string {
 	switch content.ContentType {
 	case "text":
@@ -281,24 +494,81 @@ func extractText(content exportContent) string {
 	}
 }
 
+// toolText extracts the text of the content types only system and tool
+// messages have: Custom Instructions, code sent to a tool and what the tool
+// sent back.
+func toolText(content exportContent) string {
+	switch content.ContentType {
+	case "user_editable_context":
+		return joinNonEmpty(content.UserProfile, content.UserInstructions)
+	case "code":
+		code := strings.TrimSpace(content.Text)
+		if code == "" {
+			return ""
+		}
+		language := content.Language
+		if language == "unknown" {
+			language = ""
+		}
+		return "```" + language + "\n" + code + "\n```"
+	case "execution_output", "system_error":
+		return strings.TrimSpace(content.Text)
+	case "tether_browsing_display":
+		return firstNonEmpty(content.Result, content.Summary)
+	case "tether_quote":
+		return joinNonEmpty(content.Title, content.URL, content.Text)
+	default:
+		return ""
+	}
+}
+
+func joinNonEmpty(values ...string) string {
+	var kept []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return strings.Join(kept, "\n\n")
+}
+
 func collectStringParts(parts []json.RawMessage) string {
 	var builder strings.Builder
 	for _, part := range parts {
-		var text string
-		if err := json.Unmarshal(part, &text); err == nil {
-			cleaned := strings.TrimSpace(text)
-			if cleaned == "" {
-				continue
-			}
-			if builder.Len() > 0 {
-				builder.WriteString("\n\n")
-			}
-			builder.WriteString(cleaned)
+		text, ok := partText(part)
+		if !ok {
+			continue
+		}
+		cleaned := strings.TrimSpace(text)
+		if cleaned == "" {
+			continue
 		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n\n")
+		}
+		builder.WriteString(cleaned)
 	}
 	return strings.TrimSpace(builder.String())
 }
 
+// partText returns the text of a plain string part or of a voice-mode
+// audio_transcription object.
+func partText(part json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(part, &text); err == nil {
+		return text, true
+	}
+
+	var object struct {
+		ContentType string `json:"content_type"`
+		Text        string `json:"text"`
+	}
+	if err := json.Unmarshal(part, &object); err == nil && object.ContentType == "audio_transcription" {
+		return object.Text, true
+	}
+	return "", false
+}
+
 func truncate(text string, limit int) string {
 	if len(text) <= limit {
 		return text
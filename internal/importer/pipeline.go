@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/tokenizer"
+)
+
+// errStopped ends the decoder once the consumer has given up.
+var errStopped = errors.New("conversion stopped")
+
+// workerCount resolves Options.Workers: zero or less uses every core.
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// convertJob is one entry of a streamed export on its way from the
+// decoder, through a worker, back to Batches in file order.
+type convertJob struct {
+	seq     int
+	raw     exportConversation
+	skipped bool
+
+	item   *models.Conversation
+	report models.ImportReport
+	// err is a Hook's failure, which ends the conversion.
+	err error
+}
+
+// convertStream decodes the export array in r on one goroutine, converts
+// its conversations (hooks run, tokens counted and ContentHash set) on
+// e.workers goroutines, and hands them to fn in file order, size at a time,
+// so checkpoints and resuming see the same order as a sequential run. Up
+// to size conversations beyond the batch fn is working on are converted
+// meanwhile. The last, partial batch is only handed out when the whole
+// stream decoded. A failing Hook ends the conversion with its error.
+func (e *Export) convertStream(r io.Reader, size int, fn func([]models.Conversation) error) error {
+	workers := workerCount(e.workers)
+	// window bounds the entries decoded but not yet handed out, so a slow
+	// conversation cannot make the others pile up in memory.
+	window := make(chan struct{}, size+workers)
+	jobs := make(chan *convertJob, workers)
+	results := make(chan *convertJob, workers)
+	stop := make(chan struct{})
+
+	var decodeErr error
+	decoded := make(chan struct{})
+	go func() {
+		defer close(decoded)
+		defer close(jobs)
+		seq := 0
+		send := func(job *convertJob) error {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return errStopped
+			}
+			job.seq = seq
+			seq++
+			select {
+			case jobs <- job:
+				return nil
+			case <-stop:
+				return errStopped
+			}
+		}
+		decodeErr = streamExport(r, func(raw exportConversation) error {
+			return send(&convertJob{raw: raw})
+		}, func(raw exportConversation) {
+			send(&convertJob{raw: raw, skipped: true})
+		})
+	}()
+
+	var workersDone sync.WaitGroup
+	for range workers {
+		workersDone.Go(func() {
+			for job := range jobs {
+				if job.skipped {
+					noteSkipped(&job.report, job.raw, reasonNoMapping)
+				} else if item := convertConversation(job.raw, e.allRoles, &job.report); item != nil {
+					skipped, err := runHooks(e.hooks, item)
+					switch {
+					case err != nil:
+						job.err = err
+					case skipped:
+						noteHookSkipped(&job.report, item)
+					default:
+						tokenizer.CountConversation(item)
+						item.ContentHash = ContentHash(*item)
+						job.item = item
+					}
+				}
+				job.raw = exportConversation{}
+				select {
+				case results <- job:
+				case <-stop:
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		workersDone.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]*convertJob)
+	next := 0
+	batch := make([]models.Conversation, 0, size)
+	var fnErr error
+	for job := range results {
+		if fnErr != nil {
+			continue
+		}
+		pending[job.seq] = job
+		for ready, ok := pending[next]; ok; ready, ok = pending[next] {
+			delete(pending, next)
+			next++
+			<-window
+			if ready.err != nil {
+				fnErr = ready.err
+				close(stop)
+				break
+			}
+			mergeReport(&e.Report, ready.report)
+			if ready.item == nil {
+				continue
+			}
+			e.Report.Converted++
+			batch = append(batch, *ready.item)
+			if len(batch) < size {
+				continue
+			}
+			full := batch
+			batch = make([]models.Conversation, 0, size)
+			if fnErr = fn(full); fnErr != nil {
+				close(stop)
+				break
+			}
+		}
+	}
+	<-decoded
+
+	switch {
+	case fnErr != nil:
+		return fnErr
+	case decodeErr != nil:
+		return decodeErr
+	case len(batch) > 0:
+		return fn(batch)
+	}
+	return nil
+}
+
+// mergeReport adds what one conversation's conversion noted to report.
+func mergeReport(report *models.ImportReport, one models.ImportReport) {
+	report.Skipped += one.Skipped
+	report.Warnings += one.Warnings
+	for _, issue := range one.Issues {
+		addIssue(report, issue)
+	}
+	if len(one.UnknownContentTypes) > 0 && report.UnknownContentTypes == nil {
+		report.UnknownContentTypes = make(map[string]int)
+	}
+	for contentType, n := range one.UnknownContentTypes {
+		report.UnknownContentTypes[contentType] += n
+	}
+}
+
+// stampHashes counts the tokens of each conversation and sets its
+// ContentHash, spreading items over workers goroutines.
+func stampHashes(items []models.Conversation, workers int) {
+	workers = min(workerCount(workers), len(items))
+	if workers <= 1 {
+		for i := range items {
+			tokenizer.CountConversation(&items[i])
+			items[i].ContentHash = ContentHash(items[i])
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for range workers {
+		wg.Go(func() {
+			for i := range next {
+				tokenizer.CountConversation(&items[i])
+				items[i].ContentHash = ContentHash(items[i])
+			}
+		})
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// maxReportIssues caps ImportReport.Issues, as maxChangeEntries caps the
+// change lists.
+const maxReportIssues = 200
+
+// Why convertConversation, or a Hook, skips a conversation.
+const (
+	reasonNoMapping   = "no message mapping"
+	reasonEmptyBranch = "the current branch has no messages"
+	reasonHook        = "left out by an import hook"
+)
+
+// knownContentTypes are the message content types extractText or toolText
+// read. Messages of any other type are counted in
+// ImportReport.UnknownContentTypes.
+var knownContentTypes = map[string]bool{
+	"text":                    true,
+	"multimodal_text":         true,
+	"user_editable_context":   true,
+	"code":                    true,
+	"execution_output":        true,
+	"system_error":            true,
+	"tether_browsing_display": true,
+	"tether_quote":            true,
+}
+
+// noteSkipped records that raw was left out of the import. report may be
+// nil, for conversions nobody reports on.
+func noteSkipped(report *models.ImportReport, raw exportConversation, reason string) {
+	if report == nil {
+		return
+	}
+	report.Skipped++
+	addIssue(report, models.ImportIssue{ID: rawID(raw), Title: strings.TrimSpace(raw.Title), Skipped: true, Reason: reason})
+}
+
+// noteDropped records that convo was imported without some of its
+// messages, whose content types (with how many of each) are in dropped.
+func noteDropped(report *models.ImportReport, convo *models.Conversation, dropped map[string]int) {
+	if report == nil || len(dropped) == 0 {
+		return
+	}
+	if report.UnknownContentTypes == nil {
+		report.UnknownContentTypes = make(map[string]int)
+	}
+	total := 0
+	types := make([]string, 0, len(dropped))
+	for _, contentType := range slices.Sorted(maps.Keys(dropped)) {
+		report.UnknownContentTypes[contentType] += dropped[contentType]
+		total += dropped[contentType]
+		types = append(types, contentType)
+	}
+	report.Warnings++
+	addIssue(report, models.ImportIssue{
+		ID:     convo.ID,
+		Title:  convo.Title,
+		Reason: fmt.Sprintf("%d messages of unsupported content types left out (%s)", total, strings.Join(types, ", ")),
+	})
+}
+
+func addIssue(report *models.ImportReport, issue models.ImportIssue) {
+	if len(report.Issues) < maxReportIssues {
+		report.Issues = append(report.Issues, issue)
+	}
+}
+
+// rawID is the ID an export gives a conversation, if any.
+func rawID(raw exportConversation) string {
+	if id := strings.TrimSpace(raw.ConversationID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(raw.ID)
+}
+
+// HasIssues reports whether report lists anything an import left out.
+func HasIssues(report *models.ImportReport) bool {
+	return report != nil && (report.Skipped+report.Warnings > 0 || len(report.UnknownContentTypes) > 0)
+}
@@ -0,0 +1,535 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"zatGPT/internal/models"
+)
+
+// Format identifies how an export file is packaged.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatZIP  Format = "zip"
+	FormatHTML Format = "html"
+	FormatText Format = "text"
+)
+
+// ErrNotExport is returned for files that are not a conversation export
+// Open reads, such as the user.json or message_feedback.json shipped
+// alongside one.
+var ErrNotExport = errors.New("not a supported conversation export")
+
+// Export is an opened export file: its conversations plus the source of the
+// attachment blobs it references. Source names the product that produced it. Close releases the underlying archive.
+//
+// ChatGPT's conversations.json (loose or inside the ZIP) is streamed: Open
+// only checks that it is an export, and Batches decodes it as it goes, so
+// Conversations is nil. Other formats are small enough to load whole into
+// Conversations; Batches serves those too.
+type Export struct {
+	Path          string
+	Format        Format
+	Source        string
+	Conversations []models.Conversation
+	Assets        AssetSource
+	// Report lists the conversations and messages that could not be
+	// converted. It is complete once Batches has handed out every
+	// conversation.
+	Report models.ImportReport
+	closer io.Closer
+
+	// stream opens the conversations.json of a streamed export, which is
+	// streamSize bytes long.
+	stream     func() (io.ReadCloser, error)
+	streamSize int64
+	// read counts the bytes of the stream, or the conversations, that
+	// Batches has handed out, for Progress. The decoder goroutine of a
+	// streamed export updates it while Progress reads it.
+	read atomic.Int64
+	// allRoles, workers and hooks are Options.AllRoles, Options.Workers
+	// and Options.Hooks, for conversations converted by Batches.
+	allRoles bool
+	workers  int
+	hooks    []Hook
+}
+
+// Progress reports how much of the export Batches has handed out, from 0
+// to 1: by bytes read for a streamed export, by conversations otherwise.
+func (e *Export) Progress() float64 {
+	total := int64(len(e.Conversations))
+	if e.stream != nil {
+		total = e.streamSize
+	}
+	if total <= 0 {
+		return 0
+	}
+	return min(float64(e.read.Load())/float64(total), 1)
+}
+
+// countingReader counts the bytes read through it into n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// errStopStream ends a stream early once Open has seen enough.
+var errStopStream = errors.New("stop")
+
+// Batches hands the export's conversations to fn in file order, at most
+// size at a time, passed through the hooks and with their tokens counted
+// and ContentHash set. A batch a hook skipped conversations of is smaller,
+// and one it skipped all of is not handed out. fn may keep the slice it is
+// given. A streamed export is decoded, converted and
+// handed out concurrently (see convertStream).
+func (e *Export) Batches(size int, fn func([]models.Conversation) error) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid batch size %d", size)
+	}
+	if e.stream == nil {
+		e.Report.Converted = len(e.Conversations)
+		for start := 0; start < len(e.Conversations); start += size {
+			batch := e.Conversations[start:min(start+size, len(e.Conversations))]
+			e.read.Store(int64(start + len(batch)))
+			if len(e.hooks) > 0 {
+				kept, err := hookBatch(e.hooks, batch, &e.Report)
+				if err != nil {
+					return err
+				}
+				e.Report.Converted -= len(batch) - len(kept)
+				if batch = kept; len(batch) == 0 {
+					continue
+				}
+			}
+			stampHashes(batch, e.workers)
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rc, err := e.stream()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	e.read.Store(0)
+	e.Report = models.ImportReport{}
+	return e.convertStream(bufio.NewReader(countingReader{rc, &e.read}), size, fn)
+}
+
+// probeStream checks that the stream holds a ChatGPT export, reading only
+// up to its first conversation.
+func (e *Export) probeStream() error {
+	rc, err := e.stream()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	err = streamExport(bufio.NewReader(rc), func(exportConversation) error {
+		return errStopStream
+	}, nil)
+	if errors.Is(err, errStopStream) {
+		return nil
+	}
+	return err
+}
+
+// Close releases any archive held open for Assets.
+func (e *Export) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// DetectFormat sniffs a file's leading bytes: a ZIP signature, JSON, HTML
+// markup (the chat.html page of an export), or a saved `ollama run` session.
+func DetectFormat(path string) (Format, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = bytes.TrimLeft(bytes.TrimPrefix(head[:n], []byte("\xef\xbb\xbf")), " \t\r\n")
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return FormatZIP, nil
+	case bytes.HasPrefix(head, []byte("[")), bytes.HasPrefix(head, []byte("{")):
+		return FormatJSON, nil
+	case bytes.HasPrefix(head, []byte("<")):
+		return FormatHTML, nil
+	case bytes.HasPrefix(head, []byte(">>> ")), ollamaRun.Match(head):
+		return FormatText, nil
+	default:
+		return "", ErrNotExport
+	}
+}
+
+// fallbackDecoder converts a non-ChatGPT export held in memory.
+type fallbackDecoder struct {
+	source string
+	decode func(path string, data []byte, opts Options) ([]models.Conversation, error)
+}
+
+// Decoders tried, in order, when a file is not a ChatGPT export.
+var (
+	jsonDecoders = []fallbackDecoder{
+		{SourceBard, func(_ string, data []byte, opts Options) ([]models.Conversation, error) {
+			items, err := decodeBardJSON(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return convertBard(items, opts.BardGrouping), nil
+		}},
+		{SourceClaude, withoutOptions(decodeClaude)},
+		{SourceOpenWebUI, withoutOptions(decodeOpenWebUI)},
+		{SourceLMStudio, withoutOptions(decodeLMStudio)},
+	}
+	htmlDecoders = []fallbackDecoder{
+		{SourceBard, func(_ string, data []byte, opts Options) ([]models.Conversation, error) {
+			items, err := decodeBardHTML(data)
+			if err != nil {
+				return nil, err
+			}
+			return convertBard(items, opts.BardGrouping), nil
+		}},
+	}
+	textDecoders = []fallbackDecoder{
+		{SourceOllama, withoutOptions(decodeOllama)},
+	}
+)
+
+func withoutOptions(fn func(path string, data []byte) ([]models.Conversation, error)) func(string, []byte, Options) ([]models.Conversation, error) {
+	return func(path string, data []byte, _ Options) ([]models.Conversation, error) {
+		return fn(path, data)
+	}
+}
+
+// Sources lists every product Open reads exports from, for Options.Source.
+var Sources = []string{SourceChatGPT, SourceClaude, SourceBard, SourceOpenWebUI, SourceLMStudio, SourceOllama}
+
+// accepts reports whether opts allow an export from source.
+func (opts Options) accepts(source string) bool {
+	return opts.Source == "" || opts.Source == source
+}
+
+// decodeFallback runs decoders until one recognises data.
+func decodeFallback(path string, data []byte, decoders []fallbackDecoder, opts Options) (string, []models.Conversation, error) {
+	for _, d := range decoders {
+		if !opts.accepts(d.source) {
+			continue
+		}
+		convos, err := d.decode(path, data, opts)
+		if errors.Is(err, ErrNotExport) {
+			continue
+		}
+		return d.source, convos, err
+	}
+	return "", nil, ErrNotExport
+}
+
+// Open detects the format of path and loads its conversations: a ChatGPT
+// or Claude export, a Google Takeout Bard/Gemini activity log, or a chat
+// saved by a local front-end (Open WebUI, LM Studio, ollama). Attachments are read from
+// the archive for ZIP exports and from the surrounding directory otherwise.
+func Open(path string, opts Options) (*Export, error) {
+	format, err := DetectFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := &Export{Path: path, Format: format, Source: SourceChatGPT, allRoles: opts.AllRoles, workers: opts.Workers, hooks: opts.Hooks}
+	var payload []exportConversation
+	switch format {
+	case FormatJSON, FormatHTML:
+		exp.Assets = DirAssets(filepath.Dir(path))
+		switch {
+		case !opts.accepts(SourceChatGPT):
+			err = ErrNotExport
+		case format == FormatJSON:
+			exp.stream = func() (io.ReadCloser, error) { return os.Open(path) }
+			if info, statErr := os.Stat(path); statErr == nil {
+				exp.streamSize = info.Size()
+			}
+			err = exp.probeStream()
+		default:
+			payload, err = readHTMLExport(path)
+		}
+		if !errors.Is(err, ErrNotExport) {
+			break
+		}
+		exp.stream = nil
+		decoders := jsonDecoders
+		if format == FormatHTML {
+			decoders = htmlDecoders
+		}
+		if err = exp.decodeFile(decoders, opts); err != nil {
+			return nil, err
+		}
+		if exp.Source == SourceBard {
+			exp.Assets = withTakeoutNames(exp.Assets, exp.Conversations)
+		}
+		return exp, nil
+	case FormatText:
+		if err := exp.decodeFile(textDecoders, opts); err != nil {
+			return nil, err
+		}
+		return exp, nil
+	case FormatZIP:
+		var archive *zip.ReadCloser
+		archive, err = zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		exp.Assets = zipAssets{&archive.Reader}
+		exp.closer = archive
+		file := findZIPExport(&archive.Reader)
+		switch {
+		case file == nil:
+			err = fmt.Errorf("%w: archive has no conversations.json", ErrNotExport)
+		case !opts.accepts(SourceChatGPT):
+			err = ErrNotExport
+		case strings.HasSuffix(file.Name, ".html"):
+			payload, err = readZIPFile(file, decodeHTMLExport)
+		default:
+			exp.stream = file.Open
+			exp.streamSize = int64(file.UncompressedSize64)
+			err = exp.probeStream()
+		}
+		if errors.Is(err, ErrNotExport) && file != nil && strings.HasSuffix(file.Name, ".json") {
+			// Claude's export ZIP has a conversations.json of its own.
+			exp.stream = nil
+			var data []byte
+			if data, err = readZIPData(file); err == nil {
+				exp.Source, exp.Conversations, err = decodeFallback(file.Name, data, jsonDecoders, opts)
+			}
+			if err == nil {
+				return exp, nil
+			}
+		}
+		if errors.Is(err, ErrNotExport) && opts.accepts(SourceBard) {
+			exp.stream = nil
+			var bard []bardActivity
+			if bard, err = readZIPBard(&archive.Reader); err == nil {
+				exp.Source = SourceBard
+				exp.Conversations = convertBard(bard, opts.BardGrouping)
+				exp.Assets = withTakeoutNames(exp.Assets, exp.Conversations)
+				return exp, nil
+			}
+		}
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if exp.stream == nil {
+		exp.Conversations = convertAll(payload, opts.AllRoles, &exp.Report)
+	}
+	return exp, nil
+}
+
+func (e *Export) decodeFile(decoders []fallbackDecoder, opts Options) error {
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return err
+	}
+	e.Source, e.Conversations, err = decodeFallback(e.Path, data, decoders, opts)
+	return err
+}
+
+// readZIPBard reads a Takeout archive's "My Activity/<Bard|Gemini Apps>/
+// MyActivity.json", falling back to the HTML rendering.
+func readZIPBard(archive *zip.Reader) ([]bardActivity, error) {
+	for _, name := range []string{"MyActivity.json", "MyActivity.html"} {
+		for _, file := range archive.File {
+			if path.Base(file.Name) != name || !isBardHeader(path.Base(path.Dir(file.Name))) {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			if name == "MyActivity.html" {
+				return decodeBardHTML(data)
+			}
+			return decodeBardJSON(bytes.NewReader(data))
+		}
+	}
+	return nil, fmt.Errorf("%w: archive has no conversations.json or Bard activity", ErrNotExport)
+}
+
+// readHTMLExport pulls the `var jsonData = [...]` array that chat.html
+// embeds.
+func readHTMLExport(path string) ([]exportConversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHTMLExport(data)
+}
+
+func decodeHTMLExport(data []byte) ([]exportConversation, error) {
+	idx := bytes.Index(data, []byte("jsonData"))
+	if idx < 0 {
+		return nil, ErrNotExport
+	}
+	start := bytes.IndexByte(data[idx:], '[')
+	if start < 0 {
+		return nil, ErrNotExport
+	}
+	return decodeExport(bytes.NewReader(data[idx+start:]))
+}
+
+// findZIPExport locates the archive's conversations.json, falling back to
+// chat.html. The shallowest match wins when exports are nested.
+func findZIPExport(archive *zip.Reader) *zip.File {
+	for _, name := range []string{"conversations.json", "chat.html"} {
+		var match *zip.File
+		for _, file := range archive.File {
+			if path.Base(file.Name) != name {
+				continue
+			}
+			if match == nil || strings.Count(file.Name, "/") < strings.Count(match.Name, "/") {
+				match = file
+			}
+		}
+		if match != nil {
+			return match
+		}
+	}
+	return nil
+}
+
+func readZIPFile(file *zip.File, decode func([]byte) ([]exportConversation, error)) ([]exportConversation, error) {
+	data, err := readZIPData(file)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+func readZIPData(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// zipAssets serves attachment blobs straight from an export archive.
+type zipAssets struct {
+	archive *zip.Reader
+}
+
+func (z zipAssets) Open(ref string) (io.ReadCloser, string, error) {
+	for _, file := range z.archive.File {
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasSuffix(base, ".json") || strings.HasSuffix(base, ".html") {
+			continue
+		}
+		if namedAfter(base, ref) {
+			rc, err := file.Open()
+			return rc, base, err
+		}
+	}
+	return nil, "", ErrAssetNotFound
+}
+
+// HashFile returns the hex SHA-256 of a file, used to recognise exports
+// that were already imported.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// FindExports walks root and returns every candidate export file (see
+// IsCandidate), sorted by path. Hidden directories are skipped.
+func FindExports(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if p != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsCandidate(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// IsCandidate reports whether the file at path may be an export, judging by
+// its name: ZIP, JSON, HTML and text transcripts. A chat.html next to a
+// conversations.json is left out since it carries the same data.
+func IsCandidate(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip", ".json", ".html", ".htm", ".txt":
+	default:
+		return false
+	}
+	if strings.EqualFold(filepath.Base(path), "chat.html") {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), "conversations.json")); err == nil {
+			return false
+		}
+	}
+	return true
+}
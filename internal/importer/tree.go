@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"sort"
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// buildTree converts the export's mapping into MessageNodes, marking the
+// nodes on timeline as canonical. It returns nil when no node has more than
+// one child, since a linear conversation is fully described by its messages.
+func buildTree(raw exportConversation, timeline []exportNode) []models.MessageNode {
+	branched := false
+	for _, node := range raw.Mapping {
+		if len(node.Children) > 1 {
+			branched = true
+			break
+		}
+	}
+	if !branched {
+		return nil
+	}
+
+	canonical := make(map[string]bool, len(timeline))
+	for _, node := range timeline {
+		canonical[node.ID] = true
+	}
+
+	nodes := make([]models.MessageNode, 0, len(raw.Mapping))
+	for id, node := range raw.Mapping {
+		if node.ID == "" {
+			node.ID = id
+		}
+
+		item := models.MessageNode{
+			ID:        node.ID,
+			ParentID:  node.Parent,
+			Children:  append([]string(nil), node.Children...),
+			Canonical: canonical[node.ID],
+		}
+		if parent, ok := raw.Mapping[node.Parent]; ok {
+			for i, child := range parent.Children {
+				if child == node.ID {
+					item.BranchIndex = i
+					break
+				}
+			}
+		}
+		if node.Message != nil {
+			item.Author = strings.ToLower(node.Message.Author.Role)
+			item.CreatedAt = timestampOrZero(node.Message.CreateTime)
+			if !item.Canonical {
+				item.Content = extractText(node.Message.Content)
+				if item.Author == "assistant" {
+					item.Model = node.Message.Metadata.ModelSlug
+				}
+			}
+		}
+		nodes = append(nodes, item)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].ID < nodes[j].ID
+		}
+		return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+	})
+	return nodes
+}
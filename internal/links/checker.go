@@ -0,0 +1,265 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+const (
+	StatusOK    = "ok"
+	StatusDead  = "dead"
+	StatusError = "error"
+)
+
+// CheckStore is the persistence the checker needs; *storage.Store
+// satisfies it.
+type CheckStore interface {
+	Find(ctx context.Context, match func(models.Conversation) bool) ([]models.Conversation, error)
+	LinkChecks() map[string]models.LinkCheck
+	SaveLinkChecks(checks []models.LinkCheck) error
+}
+
+// ErrOffline is returned by CheckStale when no probed server responded.
+var ErrOffline = errors.New("no link responded; network appears to be unavailable, results discarded")
+
+// ErrPrivateAddress is returned for a link, or a redirect, to a host that
+// resolves to a loopback, private, link-local, unspecified or multicast
+// address: links come from imported conversations, and probing them must
+// not reach the machine the server runs on or its network.
+var ErrPrivateAddress = errors.New("refusing to probe a non-public address")
+
+// ErrUnsupportedScheme is returned for a link, or a redirect, that is not
+// http or https.
+var ErrUnsupportedScheme = errors.New("only http and https links are probed")
+
+// Checker probes URLs with HEAD requests, falling back to a ranged GET for
+// servers that reject HEAD.
+type Checker struct {
+	Client      *http.Client
+	Concurrency int
+	UserAgent   string
+}
+
+// NewChecker returns a Checker with conservative defaults. Its client only
+// connects to public addresses, checked after DNS resolution so a name
+// cannot smuggle in a private one, and only follows http and https
+// redirects. It ignores proxy settings, which would connect to the proxy
+// instead.
+func NewChecker() *Checker {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Checker{
+		Client: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: telemetry.Transport(transport),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if !probedScheme(req.URL.Scheme) {
+					return ErrUnsupportedScheme
+				}
+				if len(via) >= 5 {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+		Concurrency: 8,
+		UserAgent:   "zatGPT-link-checker/1.0",
+	}
+}
+
+// publicOnly is a net.Dialer Control that refuses every address other
+// than a public unicast one.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("%s: %w", ip, ErrPrivateAddress)
+	}
+	return nil
+}
+
+func probedScheme(scheme string) bool {
+	return strings.EqualFold(scheme, "http") || strings.EqualFold(scheme, "https")
+}
+
+// Check probes a single URL.
+func (c *Checker) Check(ctx context.Context, link string) models.LinkCheck {
+	result := models.LinkCheck{URL: link, CheckedAt: time.Now().UTC()}
+
+	var code int
+	var err error
+	if u, parseErr := url.Parse(link); parseErr != nil {
+		err = parseErr
+	} else if !probedScheme(u.Scheme) {
+		err = ErrUnsupportedScheme
+	} else {
+		code, err = c.probe(ctx, http.MethodHead, link)
+	}
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented || code == http.StatusForbidden) {
+		code, err = c.probe(ctx, http.MethodGet, link)
+	}
+
+	result.StatusCode = code
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			result.Status = StatusDead
+		} else {
+			result.Status = StatusError
+		}
+	case code == http.StatusNotFound || code == http.StatusGone || code == http.StatusUnavailableForLegalReasons:
+		result.Status = StatusDead
+	case code >= 500 || code == http.StatusTooManyRequests || code == http.StatusForbidden || code == http.StatusUnauthorized:
+		result.Status = StatusError
+	default:
+		result.Status = StatusOK
+	}
+	return result
+}
+
+func (c *Checker) probe(ctx context.Context, method, link string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// CheckStale probes every archived URL whose last check is older than
+// maxAge (or missing) and records the results. It returns how many URLs were
+// checked.
+func (c *Checker) CheckStale(ctx context.Context, store CheckStore, maxAge time.Duration) (int, error) {
+	previous := store.LinkChecks()
+	cutoff := time.Now().Add(-maxAge)
+
+	conversations, err := store.Find(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	var pending []string
+	for _, link := range Collect(conversations) {
+		if check, ok := previous[link.URL]; ok && check.CheckedAt.After(cutoff) {
+			continue
+		}
+		pending = append(pending, link.URL)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	workers := c.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make([]models.LinkCheck, 0, len(pending))
+		queue   = make(chan string)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				check := c.Check(ctx, link)
+				if ctx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				results = append(results, check)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, link := range pending {
+		select {
+		case queue <- link:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	// A pass where no server answered at all usually means this machine is
+	// offline; recording it would flag the whole archive as dead.
+	if len(results) >= 3 && !anyAnswered(results) {
+		return 0, ErrOffline
+	}
+
+	// Keep whatever finished before a cancellation.
+	if err := store.SaveLinkChecks(results); err != nil {
+		return 0, err
+	}
+	return len(results), ctx.Err()
+}
+
+// anyAnswered reports whether a server answered, or the checker itself
+// refused a link, which says nothing about the network.
+func anyAnswered(results []models.LinkCheck) bool {
+	for _, check := range results {
+		if check.StatusCode != 0 || strings.HasSuffix(check.Error, ErrPrivateAddress.Error()) || strings.HasSuffix(check.Error, ErrUnsupportedScheme.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run checks stale links immediately and then every interval until ctx is
+// cancelled, reporting each pass through logf.
+func (c *Checker) Run(ctx context.Context, store CheckStore, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := c.CheckStale(ctx, store, interval)
+		if err != nil && ctx.Err() == nil {
+			logf("link check failed: %v", err)
+		} else if n > 0 {
+			logf("checked %d links", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package links
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Link is a URL mentioned in the archive, attributed to the message where it
+// first appeared.
+type Link struct {
+	URL               string    `json:"url"`
+	Domain            string    `json:"domain"`
+	ConversationID    string    `json:"conversationId"`
+	ConversationTitle string    `json:"conversationTitle"`
+	MessageID         string    `json:"messageId"`
+	Author            string    `json:"author"`
+	FirstSeen         time.Time `json:"firstSeen"`
+	Occurrences       int       `json:"occurrences"`
+	// Check is the latest reachability result, when link checking is on.
+	Check *models.LinkCheck `json:"check,omitempty"`
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// Extract returns the distinct http(s) URLs in text, in order of appearance,
+// with trailing punctuation and unbalanced closing brackets removed.
+func Extract(text string) []string {
+	var (
+		out  []string
+		seen = make(map[string]bool)
+	)
+	for _, raw := range urlPattern.FindAllString(text, -1) {
+		link := trimURL(raw)
+		parsed, err := url.Parse(link)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		if !seen[link] {
+			seen[link] = true
+			out = append(out, link)
+		}
+	}
+	return out
+}
+
+// Collect walks every message of conversations and returns one Link per
+// distinct URL, newest first-sighting first.
+func Collect(conversations []models.Conversation) []Link {
+	byURL := make(map[string]*Link)
+	for _, convo := range conversations {
+		for _, msg := range convo.Messages {
+			seenAt := msg.CreatedAt
+			if seenAt.IsZero() {
+				seenAt = convo.CreatedAt
+			}
+			for _, link := range Extract(msg.Content) {
+				entry, ok := byURL[link]
+				if !ok {
+					entry = &Link{URL: link, Domain: domain(link)}
+					byURL[link] = entry
+				}
+				entry.Occurrences++
+				if entry.ConversationID == "" || seenAt.Before(entry.FirstSeen) {
+					entry.ConversationID = convo.ID
+					entry.ConversationTitle = convo.Title
+					entry.MessageID = msg.ID
+					entry.Author = msg.Author
+					entry.FirstSeen = seenAt
+				}
+			}
+		}
+	}
+
+	out := make([]Link, 0, len(byURL))
+	for _, entry := range byURL {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].FirstSeen.Equal(out[j].FirstSeen) {
+			return out[i].URL < out[j].URL
+		}
+		return out[i].FirstSeen.After(out[j].FirstSeen)
+	})
+	return out
+}
+
+func trimURL(raw string) string {
+	for {
+		trimmed := strings.TrimRight(raw, ".,;:!?*_")
+		for _, pair := range [][2]string{{"(", ")"}, {"[", "]"}, {"{", "}"}} {
+			if strings.HasSuffix(trimmed, pair[1]) && strings.Count(trimmed, pair[0]) < strings.Count(trimmed, pair[1]) {
+				trimmed = strings.TrimSuffix(trimmed, pair[1])
+			}
+		}
+		if trimmed == raw {
+			return raw
+		}
+		raw = trimmed
+	}
+}
+
+func domain(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -1,23 +1,418 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Conversation holds the metadata we surface in the UI and expose via the API.
 type Conversation struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Summary     string    `json:"summary"`
-	DateStarted string    `json:"dateStarted"`
-	DateEnded   string    `json:"dateEnded"`
-	SourceID    string    `json:"sourceId,omitempty"`
-	Messages    []Message `json:"messages,omitempty"`
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Summary     string `json:"summary"`
+	DateStarted string `json:"dateStarted"`
+	DateEnded   string `json:"dateEnded"`
+	// StartedAt and EndedAt are when the first and last messages were
+	// written; DateStarted and DateEnded are their dates in UTC. Both are
+	// zero for conversations created through the API and for those
+	// imported before they were kept (see Span).
+	StartedAt time.Time `json:"startedAt,omitzero"`
+	EndedAt   time.Time `json:"endedAt,omitzero"`
+	SourceID  string    `json:"sourceId,omitempty"`
+	// Source names the product the conversation was imported from
+	// ("chatgpt", "bard").
+	Source string   `json:"source,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Topics are the words that set the conversation apart from the rest
+	// of the archive, best first, picked when it is imported (see package
+	// topics).
+	Topics []string `json:"topics,omitempty"`
+	// Archived hides a conversation from the default list without deleting
+	// it; Starred pins it. Both are set by the user, never by an import.
+	Archived    bool         `json:"archived,omitempty"`
+	Starred     bool         `json:"starred,omitempty"`
+	Messages    []Message    `json:"messages,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Tree holds the full message graph when the export contains edits or
+	// regenerations; it is omitted for strictly linear conversations.
+	Tree []MessageNode `json:"tree,omitempty"`
+	// Redactions records what was replaced with placeholders, by import or
+	// later (see package redact), without the text that was.
+	Redactions []Redaction `json:"redactions,omitempty"`
+	// Tokens is the sum of the messages' Tokens.
+	Tokens int `json:"tokens,omitempty"`
+	// Stats describes the messages; the store works it out whenever it
+	// takes a conversation in, and list and search results leave it out.
+	Stats *ConversationStats `json:"stats,omitempty"`
+	// ContentHash fingerprints the conversation as the importer converted
+	// it, so re-importing an unchanged export can leave it alone.
+	ContentHash string    `json:"contentHash,omitempty"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+	// DeletedAt is when the conversation was moved to the trash; it is
+	// zero for every conversation outside it.
+	DeletedAt time.Time `json:"deletedAt,omitzero"`
+	// MergedInto is the conversation a merge folded this one into, set
+	// while it sits in the trash so re-imports leave it there.
+	MergedInto string `json:"mergedInto,omitempty"`
+}
+
+// Span returns when the conversation started and ended. Conversations
+// imported before StartedAt and EndedAt were kept fall back to CreatedAt
+// and UpdatedAt, which the importers set to the same times, as long as
+// they still fall on DateStarted and DateEnded. A zero time means it is
+// not known.
+func (c Conversation) Span() (started, ended time.Time) {
+	started, ended = c.StartedAt, c.EndedAt
+	if started.IsZero() && c.DateStarted != "" && c.CreatedAt.UTC().Format(time.DateOnly) == c.DateStarted {
+		started = c.CreatedAt
+	}
+	if ended.IsZero() && c.DateEnded != "" && c.UpdatedAt.UTC().Format(time.DateOnly) == c.DateEnded {
+		ended = c.UpdatedAt
+	}
+	return started, ended
+}
+
+// InZone returns the conversation with its times in loc, and DateStarted
+// and DateEnded the days it started and ended there. A date whose time is
+// not known is left as it is. Messages and Tree are copied, not changed
+// in place.
+func (c Conversation) InZone(loc *time.Location) Conversation {
+	started, ended := c.Span()
+	if !started.IsZero() {
+		c.StartedAt = started.In(loc)
+		c.DateStarted = c.StartedAt.Format(time.DateOnly)
+	}
+	if !ended.IsZero() {
+		c.EndedAt = ended.In(loc)
+		c.DateEnded = c.EndedAt.Format(time.DateOnly)
+	}
+	c.CreatedAt = inZone(c.CreatedAt, loc)
+	c.UpdatedAt = inZone(c.UpdatedAt, loc)
+	c.DeletedAt = inZone(c.DeletedAt, loc)
+	if c.Messages != nil {
+		messages := make([]Message, len(c.Messages))
+		for i, message := range c.Messages {
+			message.CreatedAt = inZone(message.CreatedAt, loc)
+			messages[i] = message
+		}
+		c.Messages = messages
+	}
+	if c.Tree != nil {
+		tree := make([]MessageNode, len(c.Tree))
+		for i, node := range c.Tree {
+			node.CreatedAt = inZone(node.CreatedAt, loc)
+			tree[i] = node
+		}
+		c.Tree = tree
+	}
+	return c
+}
+
+// ConversationStats describes a conversation's messages. MessagesByRole
+// and TokensByRole count them by Author. DurationSeconds runs from the
+// first timestamped message to the last, and LongestGapSeconds is the
+// longest wait between one timestamped message and the next; both are 0
+// with fewer than two timestamps. AverageLength is the mean length of the
+// messages in characters. Models lists the models that wrote replies, in
+// the order they first did.
+type ConversationStats struct {
+	MessagesByRole    map[string]int `json:"messagesByRole"`
+	TokensByRole      map[string]int `json:"tokensByRole,omitempty"`
+	Tokens            int            `json:"tokens"`
+	AverageLength     int            `json:"averageLength"`
+	DurationSeconds   int64          `json:"durationSeconds"`
+	LongestGapSeconds int64          `json:"longestGapSeconds"`
+	Models            []string       `json:"models,omitempty"`
 }
 
+// UntitledTitle is the title an import gives a conversation it finds
+// nothing to name after.
+const UntitledTitle = "Untitled conversation"
+
+// placeholderTitles are the titles that name no conversation in
+// particular: the importer's, and those ChatGPT and other chat apps give a
+// chat they never got to name.
+var placeholderTitles = []string{UntitledTitle, "untitled", "new chat", "new conversation"}
+
+// Untitled reports whether the conversation has no title of its own, only
+// an empty or placeholder one.
+func (c Conversation) Untitled() bool {
+	title := strings.TrimSpace(c.Title)
+	if title == "" {
+		return true
+	}
+	for _, placeholder := range placeholderTitles {
+		if strings.EqualFold(title, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// inZone is t in loc, leaving the zero time zero.
+func inZone(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(loc)
+}
+
+// Message is one turn of a conversation, by Author: AuthorUser or
+// AuthorAssistant, or, in conversations imported with every role kept,
+// AuthorSystem (Custom Instructions) or AuthorTool (a call the assistant
+// made to a tool, or what the tool returned). Code the assistant ran in
+// Code Interpreter and its output are kept as AuthorTool messages either
+// way, with the code or output also in Parts; Content holds the same as
+// Markdown. Model names the model that wrote an assistant reply
+// (model_slug in ChatGPT exports, e.g. "gpt-4o", "o1").
+// Status, FinishReason ("stop", "max_tokens", "interrupted") and Weight (1
+// for messages ChatGPT displays, 0 for hidden ones; nil when the export does
+// not say) are kept as the export reports them, when it does. Tokens is the length of the content in tokens of Model's
+// tokenizer, counted at import (see package tokenizer).
 type Message struct {
-	ID        string    `json:"id"`
-	Author    string    `json:"author"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID           string        `json:"id"`
+	Author       string        `json:"author"`
+	Content      string        `json:"content"`
+	Parts        []MessagePart `json:"parts,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	Status       string        `json:"status,omitempty"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Weight       *float64      `json:"weight,omitempty"`
+	Tokens       int           `json:"tokens,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// MessagePart is a piece of a message the UI renders on its own rather
+// than as text: code the assistant ran (PartCode, with its Language when
+// the export names it) in Source, or what running it printed (PartOutput)
+// or the error it failed with (PartError) in Output.
+type MessagePart struct {
+	Type     string `json:"type"`
+	Language string `json:"language,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// The Types of message parts.
+const (
+	PartCode   = "code"
+	PartOutput = "output"
+	PartError  = "error"
+)
+
+// The Authors of messages.
+const (
+	AuthorUser      = "user"
+	AuthorAssistant = "assistant"
+	AuthorSystem    = "system"
+	AuthorTool      = "tool"
+)
+
+// MessageNode is one node of a conversation's edit/regeneration tree.
+// Canonical nodes lie on the path the export marked as current and carry no
+// Content or Model because the same message is already in
+// Conversation.Messages. BranchIndex is the node's position among its
+// parent's children.
+type MessageNode struct {
+	ID          string    `json:"id"`
+	ParentID    string    `json:"parentId,omitempty"`
+	Children    []string  `json:"children,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Content     string    `json:"content,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Canonical   bool      `json:"canonical"`
+	BranchIndex int       `json:"branchIndex"`
+}
+
+// Attachment describes an uploaded or generated file referenced by a message.
+// Ref is the export's file identifier and doubles as the blob key in storage;
+// Stored is false when the export referenced the file but did not ship it.
+// Text holds OCR output for images, when OCR was enabled during import.
+// Voice-mode audio carries its Duration in seconds; MessageID points at the
+// transcript message holding the spoken text.
+type Attachment struct {
+	Ref       string  `json:"ref"`
+	MessageID string  `json:"messageId,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	MimeType  string  `json:"mimeType,omitempty"`
+	Size      int64   `json:"size,omitempty"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	Duration  float64 `json:"duration,omitempty"`
+	Stored    bool    `json:"stored"`
+	Text      string  `json:"text,omitempty"`
+}
+
+// Redaction counts the matches of one kind (KindEmail and the others of
+// package redact, or a custom pattern's name) replaced in a conversation,
+// and lists the messages they were in; matches in the title, summary or
+// attachments' text count without adding a message.
+type Redaction struct {
+	Kind       string   `json:"kind"`
+	Count      int      `json:"count"`
+	MessageIDs []string `json:"messageIds,omitempty"`
+}
+
+// LinkCheck records the outcome of the most recent reachability probe of a
+// URL mentioned in the archive. Status is "ok", "dead" (gone for good: 404,
+// 410, unknown host) or "error" (possibly transient: timeouts, 5xx, 429).
+type LinkCheck struct {
+	URL        string    `json:"url"`
+	Status     string    `json:"status"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// ImportRecord is one entry in the import history: a single export file
+// (or sync run) loaded into the store. Status is "ok", "partial" (some
+// conversations failed) or "failed"; records from before statuses were
+// tracked leave it empty.
+type ImportRecord struct {
+	ID            string    `json:"id"`
+	File          string    `json:"file"`
+	Format        string    `json:"format"`
+	Source        string    `json:"source,omitempty"`
+	Hash          string    `json:"hash,omitempty"`
+	Conversations int       `json:"conversations"`
+	Created       int       `json:"created"`
+	Updated       int       `json:"updated"`
+	Unchanged     int       `json:"unchanged,omitempty"`
+	Attachments   int       `json:"attachments,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	// Changes summarises what the import added to the archive.
+	Changes *ImportChanges `json:"changes,omitempty"`
+	// Report lists what the import could not convert; nil when it
+	// converted everything.
+	Report *ImportReport `json:"report,omitempty"`
+}
+
+// ImportReport lists what an import left out of the archive: conversations
+// it skipped and why, conversations it imported with messages missing, and
+// the message content types it cannot read. Issues is capped; the counts
+// are not.
+type ImportReport struct {
+	Converted           int            `json:"converted"`
+	Skipped             int            `json:"skipped"`
+	Warnings            int            `json:"warnings"`
+	Issues              []ImportIssue  `json:"issues,omitempty"`
+	UnknownContentTypes map[string]int `json:"unknownContentTypes,omitempty"`
+}
+
+// ImportIssue is one conversation in an ImportReport: skipped, or imported
+// with Reason as a warning.
+type ImportIssue struct {
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// ImportChanges lists the conversations an import created, extended with
+// new messages, or renamed. The lists are capped; the counts are not.
+type ImportChanges struct {
+	NewCount     int                  `json:"newCount"`
+	GrownCount   int                  `json:"grownCount"`
+	RenamedCount int                  `json:"renamedCount"`
+	New          []ConversationChange `json:"new,omitempty"`
+	Grown        []ConversationChange `json:"grown,omitempty"`
+	Renamed      []ConversationChange `json:"renamed,omitempty"`
+}
+
+// ConversationChange describes one conversation in an ImportChanges list.
+type ConversationChange struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	PreviousTitle    string `json:"previousTitle,omitempty"`
+	Messages         int    `json:"messages"`
+	PreviousMessages int    `json:"previousMessages,omitempty"`
+}
+
+// Summary is a cached, on-demand summary of a conversation. It is separate
+// from Conversation.Summary (which comes from the import) and is regenerated
+// once the conversation's UpdatedAt moves past SourceUpdatedAt. Method is
+// "heuristic" or "llm:<model>".
+type Summary struct {
+	ConversationID  string    `json:"conversationId"`
+	Text            string    `json:"text"`
+	Method          string    `json:"method"`
+	SourceUpdatedAt time.Time `json:"sourceUpdatedAt"`
+	GeneratedAt     time.Time `json:"generatedAt"`
+}
+
+// Embedding holds the vectors one embedding model computed for a
+// conversation: Vector for its title and summary, and one per message with
+// text. Like a Summary it is recomputed once the conversation's UpdatedAt
+// moves past SourceUpdatedAt.
+type Embedding struct {
+	ConversationID  string             `json:"conversationId"`
+	Model           string             `json:"model"`
+	Vector          Vector             `json:"vector,omitempty"`
+	Messages        []MessageEmbedding `json:"messages,omitempty"`
+	SourceUpdatedAt time.Time          `json:"sourceUpdatedAt"`
+	GeneratedAt     time.Time          `json:"generatedAt"`
+}
+
+// MessageEmbedding is the vector of one message.
+type MessageEmbedding struct {
+	MessageID string `json:"messageId"`
+	Vector    Vector `json:"vector"`
+}
+
+// Revision is an earlier version of a conversation, kept when a newer
+// export replaced its title or messages. Number counts a conversation's
+// versions from 1; UpdatedAt is the version's own and ReplacedAt when the
+// import superseded it.
+type Revision struct {
+	ConversationID string    `json:"conversationId"`
+	Number         int       `json:"number"`
+	Title          string    `json:"title"`
+	Messages       []Message `json:"messages"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ReplacedAt     time.Time `json:"replacedAt,omitzero"`
+}
+
+// Share is a public link to one conversation: anyone holding Token can read
+// the conversation, without the API key, until ExpiresAt (never when zero)
+// or until the share is revoked.
+type Share struct {
+	Token          string    `json:"token"`
+	ConversationID string    `json:"conversationId"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt,omitzero"`
+}
+
+// Expired reports whether the share has expired at now.
+func (s Share) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// SavedSearch is a search kept under a name, so a view such as "Go
+// questions from 2024 tagged work" is one click away. Query takes the
+// search syntax; Filters narrow its results as the list's filters do.
+type SavedSearch struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Query     string        `json:"query,omitempty"`
+	Filters   SearchFilters `json:"filters,omitzero"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// SearchFilters are the filters of GET /api/conversations a saved search
+// applies. From and To are dates (YYYY-MM-DD) bounding the day a
+// conversation started, both inclusive.
+type SearchFilters struct {
+	Tags        []string `json:"tags,omitempty"`
+	Archived    *bool    `json:"archived,omitempty"`
+	Starred     *bool    `json:"starred,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          string   `json:"to,omitempty"`
+	HasRole     string   `json:"hasRole,omitempty"`
+	MinMessages int      `json:"minMessages,omitempty"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Vector is an embedding. It is encoded in JSON as the base64 of its
+// little-endian float32s, which takes a third of the space of a number
+// array.
+type Vector []float32
+
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 4*len(v))
+	for _, f := range v {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(f))
+	}
+	return json.Marshal(buf)
+}
+
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var buf []byte
+	if err := json.Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	if len(buf)%4 != 0 {
+		return fmt.Errorf("vector of %d bytes is not a whole number of float32s", len(buf))
+	}
+	out := make(Vector, len(buf)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	*v = out
+	return nil
+}
@@ -0,0 +1,123 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"zatGPT/internal/telemetry"
+)
+
+// Engine extracts text from an image file.
+type Engine interface {
+	Recognize(ctx context.Context, path string) (string, error)
+}
+
+// New resolves an engine spec: "tesseract" (optionally "tesseract:<lang>")
+// runs the local tesseract binary, and an http(s) URL posts image bytes to an
+// external OCR service.
+func New(spec string) (Engine, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("empty OCR engine spec")
+	case spec == "tesseract" || strings.HasPrefix(spec, "tesseract:"):
+		_, lang, _ := strings.Cut(spec, ":")
+		return &Tesseract{Binary: "tesseract", Lang: lang}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return &Service{URL: spec, Client: &http.Client{Timeout: 60 * time.Second, Transport: telemetry.Transport(nil)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown OCR engine %q (want tesseract or an http(s) URL)", spec)
+	}
+}
+
+// Tesseract shells out to the tesseract CLI.
+type Tesseract struct {
+	Binary string
+	Lang   string
+}
+
+// Recognize runs `tesseract <path> stdout [-l lang]`.
+func (t *Tesseract) Recognize(ctx context.Context, path string) (string, error) {
+	args := []string{path, "stdout"}
+	if t.Lang != "" {
+		args = append(args, "-l", t.Lang)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return normalize(stdout.String()), nil
+}
+
+// Service posts the raw image to URL and expects either a plain-text body or
+// a JSON object with a "text" field.
+type Service struct {
+	URL    string
+	Client *http.Client
+}
+
+// Recognize uploads the image at path and returns the recognised text.
+func (s *Service) Recognize(ctx context.Context, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, file)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ocr service returned %s", resp.Status)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", err
+		}
+		return normalize(payload.Text), nil
+	}
+	return normalize(string(body)), nil
+}
+
+// normalize collapses the blank lines and trailing spaces OCR output tends
+// to contain.
+func normalize(text string) string {
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
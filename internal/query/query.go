@@ -0,0 +1,212 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/snippets"
+)
+
+const dateLayout = "2006-01-02"
+
+// Query is a parsed filter expression such as `terraform tag:work after:2024-01-01`.
+// Free-text terms must all appear (case-insensitively) in the title,
+// summary, message content, or OCR text of attachments; every tag must be present on the conversation.
+// After and Before bound the conversation's start date (After inclusive,
+// Before exclusive). Langs switches to code search: the conversation must
+// contain a fenced block in one of the languages, and terms are matched as
+// whole identifiers inside those blocks, so `lang:go ListenAndServe` finds
+// `http.ListenAndServe(...)` but not `ListenAndServeTLS`.
+type Query struct {
+	Terms  []string
+	Tags   []string
+	Langs  []string
+	After  time.Time
+	Before time.Time
+}
+
+// Parse splits raw into free-text terms and `key:value` filters.
+// Double-quoted phrases are kept together as a single term.
+func Parse(raw string) (Query, error) {
+	var q Query
+	for _, token := range tokenize(raw) {
+		key, value, ok := strings.Cut(token, ":")
+		if ok && value != "" {
+			switch strings.ToLower(key) {
+			case "tag":
+				q.Tags = append(q.Tags, strings.ToLower(value))
+				continue
+			case "lang":
+				q.Langs = append(q.Langs, snippets.NormalizeLanguage(value))
+				continue
+			case "after":
+				t, err := parseDate(key, value)
+				if err != nil {
+					return Query{}, err
+				}
+				q.After = t
+				continue
+			case "before":
+				t, err := parseDate(key, value)
+				if err != nil {
+					return Query{}, err
+				}
+				q.Before = t
+				continue
+			}
+		}
+		q.Terms = append(q.Terms, strings.ToLower(token))
+	}
+	return q, nil
+}
+
+// IsEmpty reports whether the query has no terms or filters and therefore
+// matches everything.
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Tags) == 0 && len(q.Langs) == 0 && q.After.IsZero() && q.Before.IsZero()
+}
+
+// Match reports whether the conversation satisfies every part of the query.
+func (q Query) Match(convo models.Conversation) bool {
+	for _, tag := range q.Tags {
+		if !hasTag(convo.Tags, tag) {
+			return false
+		}
+	}
+
+	if !q.After.IsZero() || !q.Before.IsZero() {
+		started := startedAt(convo)
+		if !q.After.IsZero() && started.Before(q.After) {
+			return false
+		}
+		if !q.Before.IsZero() && !started.Before(q.Before) {
+			return false
+		}
+	}
+
+	if len(q.Langs) > 0 {
+		return q.matchCode(convo)
+	}
+
+	for _, term := range q.Terms {
+		if !containsTerm(convo, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchCode requires at least one block in a requested language and every
+// term to appear as an identifier in one of those blocks.
+func (q Query) matchCode(convo models.Conversation) bool {
+	identifiers := make(map[string]bool)
+	found := false
+	for _, msg := range convo.Messages {
+		for _, block := range snippets.Blocks(msg.Content) {
+			if !containsString(q.Langs, block.Language) {
+				continue
+			}
+			found = true
+			for _, ident := range snippets.Identifiers(block.Code) {
+				identifiers[strings.ToLower(ident)] = true
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+
+	for _, term := range q.Terms {
+		if !identifiers[strings.TrimSuffix(term, "()")] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// startedAt prefers the displayed start date, falling back to CreatedAt for
+// conversations created without one.
+func startedAt(convo models.Conversation) time.Time {
+	if t, err := time.Parse(dateLayout, convo.DateStarted); err == nil {
+		return t
+	}
+	return convo.CreatedAt
+}
+
+func parseDate(key, value string) (time.Time, error) {
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: expected YYYY-MM-DD, got %q", strings.ToLower(key), value)
+	}
+	return t, nil
+}
+
+func hasTag(tags []string, want string) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(tag, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTerm(convo models.Conversation, term string) bool {
+	if strings.Contains(strings.ToLower(convo.Title), term) {
+		return true
+	}
+	if strings.Contains(strings.ToLower(convo.Summary), term) {
+		return true
+	}
+	for _, msg := range convo.Messages {
+		if strings.Contains(strings.ToLower(msg.Content), term) {
+			return true
+		}
+	}
+	for _, att := range convo.Attachments {
+		if att.Text != "" && strings.Contains(strings.ToLower(att.Text), term) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenize(raw string) []string {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
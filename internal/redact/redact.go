@@ -0,0 +1,428 @@
+// Package redact replaces personal data and secrets in conversations with
+// placeholders, so transcripts can be shared: email addresses, phone
+// numbers, API keys and credit card numbers, found by regular expressions,
+// plus any patterns the user adds. What it replaced is recorded on the
+// conversation as models.Redaction, never the text itself.
+package redact
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+)
+
+// The kinds of data found by the built-in rules.
+const (
+	KindEmail      = "email"
+	KindPhone      = "phone"
+	KindAPIKey     = "api-key"
+	KindCreditCard = "credit-card"
+)
+
+// Kinds lists the built-in kinds in the order they are redacted: keys and
+// card numbers before phone numbers, which would otherwise take part of
+// them.
+var Kinds = []string{KindAPIKey, KindEmail, KindCreditCard, KindPhone}
+
+// KindCustom names the matches of a custom pattern given without a name.
+const KindCustom = "custom"
+
+// rule finds one kind of data.
+type rule struct {
+	kind    string
+	pattern *regexp.Regexp
+	// bounded rules only match text that stands alone (see standsAlone),
+	// so "sk-..." inside a longer token, or a phone number that is part of
+	// an IP address or a longer number, is left alone.
+	bounded bool
+	// valid, when set, checks a match further.
+	valid func(match string) bool
+}
+
+var builtin = map[string]rule{
+	KindEmail: {
+		kind:    KindEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		bounded: true,
+	},
+	KindAPIKey: {
+		kind: KindAPIKey,
+		// OpenAI and Anthropic, AWS access key IDs, GitHub, GitLab, Slack,
+		// Google and Stripe.
+		pattern: regexp.MustCompile(`(?:sk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35}|[rs]k_(?:live|test)_[0-9A-Za-z]{16,})`),
+		bounded: true,
+	},
+	KindCreditCard: {
+		kind:    KindCreditCard,
+		pattern: regexp.MustCompile(`\d(?:[ -]?\d){12,18}`),
+		bounded: true,
+		valid:   validCard,
+	},
+	KindPhone: {
+		kind:    KindPhone,
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?|\d{2,4}[ .-])\d{3,4}[ .-]?\d{3,4}`),
+		bounded: true,
+		valid:   validPhone,
+	},
+}
+
+// validCard reports whether match has the length and Luhn checksum of a
+// card number.
+func validCard(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validPhone reports whether match has the digits of a phone number.
+func validPhone(match string) bool {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 9 && digits <= 15
+}
+
+// Redactor replaces what its rules find. It is safe for concurrent use,
+// and is an importer Hook through OnConversation.
+type Redactor struct {
+	rules []rule
+}
+
+// New returns a Redactor for the built-in kinds (nil for every one of
+// Kinds) and the custom patterns, each "name=regexp" or a bare regular
+// expression, whose matches are counted as KindCustom.
+func New(kinds []string, patterns []string) (*Redactor, error) {
+	if kinds == nil {
+		kinds = Kinds
+	}
+	for _, kind := range kinds {
+		if !ValidKind(kind) {
+			return nil, fmt.Errorf("unknown redaction kind %q (want %s)", kind, strings.Join(Kinds, ", "))
+		}
+	}
+	r := &Redactor{}
+	for _, kind := range Kinds {
+		if slices.Contains(kinds, kind) {
+			r.rules = append(r.rules, builtin[kind])
+		}
+	}
+	for _, spec := range patterns {
+		custom, err := parsePattern(spec)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, custom)
+	}
+	if len(r.rules) == 0 {
+		return nil, errors.New("nothing to redact: name a kind or a pattern")
+	}
+	return r, nil
+}
+
+// ValidKind reports whether kind is one of Kinds.
+func ValidKind(kind string) bool {
+	_, ok := builtin[kind]
+	return ok
+}
+
+// patternName matches the name before the = of a named pattern.
+var patternName = regexp.MustCompile(`^([a-z][a-z0-9-]*)=`)
+
+// parsePattern compiles a custom pattern, "name=regexp" or a bare regular
+// expression.
+func parsePattern(spec string) (rule, error) {
+	kind := KindCustom
+	if m := patternName.FindStringSubmatch(spec); m != nil {
+		kind, spec = m[1], spec[len(m[0]):]
+	}
+	if spec == "" {
+		return rule{}, fmt.Errorf("redaction pattern %q is empty", kind)
+	}
+	pattern, err := regexp.Compile(spec)
+	if err != nil {
+		return rule{}, fmt.Errorf("invalid redaction pattern %q: %w", spec, err)
+	}
+	return rule{kind: kind, pattern: pattern}, nil
+}
+
+// ParseKinds reads a comma-separated list of built-in kinds, where "all"
+// (or an empty list) is every one.
+func ParseKinds(list string) []string {
+	var kinds []string
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "all" {
+			return nil
+		}
+		if kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// ReadPatterns reads custom patterns from a file, one per line; blank lines
+// and lines starting with # are skipped.
+func ReadPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// Placeholder is the text a match of kind is replaced with.
+func Placeholder(kind string) string {
+	return "[redacted " + kind + "]"
+}
+
+// Text redacts s, adding what it replaced to found by kind.
+func (r *Redactor) Text(s string, found map[string]int) string {
+	for _, rule := range r.rules {
+		var n int
+		s, n = rule.replace(s)
+		if n > 0 && found != nil {
+			found[rule.kind] += n
+		}
+	}
+	return s
+}
+
+// replace replaces the matches of the rule in s, returning how many.
+func (rule rule) replace(s string) (string, int) {
+	matches := rule.pattern.FindAllStringIndex(s, -1)
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if start == end || rule.bounded && !standsAlone(s, start, end) {
+			continue
+		}
+		if rule.valid != nil && !rule.valid(s[start:end]) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Placeholder(rule.kind))
+		last = end
+		n++
+	}
+	if n == 0 {
+		return s, 0
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// standsAlone reports whether s[start:end] has no letter or digit right
+// before or after it, nor a number joined to it by a dot or a dash.
+func standsAlone(s string, start, end int) bool {
+	before, size := utf8.DecodeLastRuneInString(s[:start])
+	if isWordRune(before) {
+		return false
+	}
+	if before == '.' || before == '-' {
+		if digit, _ := utf8.DecodeLastRuneInString(s[:start-size]); unicode.IsDigit(digit) {
+			return false
+		}
+	}
+	after, size := utf8.DecodeRuneInString(s[end:])
+	if isWordRune(after) {
+		return false
+	}
+	if after == '.' || after == '-' {
+		if digit, _ := utf8.DecodeRuneInString(s[end+size:]); unicode.IsDigit(digit) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Conversation redacts the title, summary, messages (with their code and
+// output), tree and attachments' text of conv, adds what it replaced to
+// conv.Redactions and returns it, nil when nothing was. Slices are copied
+// before they are changed, never written through. Tokens are not counted
+// again.
+func (r *Redactor) Conversation(conv *models.Conversation) []models.Redaction {
+	var records []models.Redaction
+	note := func(found map[string]int, messageID string) {
+		records = Merge(records, fromCounts(found, messageID))
+		clear(found)
+	}
+
+	found := make(map[string]int)
+	conv.Title = r.Text(conv.Title, found)
+	conv.Summary = r.Text(conv.Summary, found)
+	note(found, "")
+
+	conv.Messages = r.messages(conv.Messages, note)
+
+	var tree []models.MessageNode
+	for i, node := range conv.Tree {
+		content := r.Text(node.Content, found)
+		if len(found) == 0 {
+			continue
+		}
+		if tree == nil {
+			tree = slices.Clone(conv.Tree)
+		}
+		tree[i].Content = content
+		note(found, node.ID)
+	}
+	if tree != nil {
+		conv.Tree = tree
+	}
+
+	var attachments []models.Attachment
+	for i, attachment := range conv.Attachments {
+		text := r.Text(attachment.Text, found)
+		if len(found) == 0 {
+			continue
+		}
+		if attachments == nil {
+			attachments = slices.Clone(conv.Attachments)
+		}
+		attachments[i].Text = text
+		note(found, "")
+	}
+	if attachments != nil {
+		conv.Attachments = attachments
+	}
+
+	if len(records) > 0 {
+		conv.Redactions = Merge(slices.Clone(conv.Redactions), records)
+	}
+	return records
+}
+
+// Revision redacts the title and messages of an earlier version of a
+// conversation, reporting whether anything was replaced.
+func (r *Redactor) Revision(revision *models.Revision) bool {
+	found := make(map[string]int)
+	revision.Title = r.Text(revision.Title, found)
+	changed := len(found) > 0
+	revision.Messages = r.messages(revision.Messages, func(map[string]int, string) {
+		changed = true
+	})
+	return changed
+}
+
+// messages redacts the content and parts of messages, returning a copy
+// when any changed, and calls note with what was found in each message
+// that did.
+func (r *Redactor) messages(messages []models.Message, note func(found map[string]int, messageID string)) []models.Message {
+	var out []models.Message
+	found := make(map[string]int)
+	for i, message := range messages {
+		content := r.Text(message.Content, found)
+		var parts []models.MessagePart
+		for j, part := range message.Parts {
+			source, output := r.Text(part.Source, found), r.Text(part.Output, found)
+			if source == part.Source && output == part.Output {
+				continue
+			}
+			if parts == nil {
+				parts = slices.Clone(message.Parts)
+			}
+			parts[j].Source, parts[j].Output = source, output
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(messages)
+		}
+		out[i].Content = content
+		if parts != nil {
+			out[i].Parts = parts
+		}
+		note(found, message.ID)
+		clear(found)
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+// OnConversation redacts conv as an importer Hook.
+func (r *Redactor) OnConversation(conv *models.Conversation) error {
+	r.Conversation(conv)
+	return nil
+}
+
+// fromCounts turns what Text found in one place into records.
+func fromCounts(found map[string]int, messageID string) []models.Redaction {
+	records := make([]models.Redaction, 0, len(found))
+	for kind, n := range found {
+		record := models.Redaction{Kind: kind, Count: n}
+		if messageID != "" {
+			record.MessageIDs = []string{messageID}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// Merge adds more to records, by kind: counts add up and message IDs are
+// listed once. Records are kept sorted by kind.
+func Merge(records, more []models.Redaction) []models.Redaction {
+	for _, record := range more {
+		i, ok := slices.BinarySearchFunc(records, record.Kind, func(r models.Redaction, kind string) int {
+			return strings.Compare(r.Kind, kind)
+		})
+		if !ok {
+			records = slices.Insert(records, i, models.Redaction{Kind: record.Kind})
+		}
+		records[i].Count += record.Count
+		for _, id := range record.MessageIDs {
+			if !slices.Contains(records[i].MessageIDs, id) {
+				// Clipped, so records copied from a stored conversation
+				// never append into its IDs.
+				records[i].MessageIDs = append(slices.Clip(records[i].MessageIDs), id)
+			}
+		}
+	}
+	return records
+}
@@ -0,0 +1,224 @@
+// Package remote talks to a running zatGPT server's API so the importer can
+// push converted exports over HTTP instead of writing the store file.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"zatGPT/internal/importer"
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+// BatchSize is how many conversations go into one batch request.
+const BatchSize = 200
+
+// Client is an API client for one server. Token, when set, is sent as a
+// bearer token (the server's -token).
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the server at baseURL.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 5 * time.Minute, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// UseTLS configures client certificates for servers started with
+// -client-ca. caFile, when set, replaces the system roots for verifying the
+// server's certificate (for private CAs).
+func (c *Client) UseTLS(certFile, keyFile, caFile string) error {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s holds no PEM certificates", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = config
+	c.HTTP.Transport = telemetry.Transport(base)
+	return nil
+}
+
+// Error is a non-2xx API response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("server returned %d %s: %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+}
+
+// Merge upserts conversations on the server in batches of BatchSize and
+// combines the per-batch results.
+func (c *Client) Merge(items []models.Conversation) (importer.MergeResult, error) {
+	total := importer.MergeResult{Changes: &models.ImportChanges{}}
+	for start := 0; start < len(items); start += BatchSize {
+		end := min(start+BatchSize, len(items))
+		var result importer.MergeResult
+		body := map[string]any{"conversations": items[start:end]}
+		if err := c.do(http.MethodPost, "/api/conversations/batch", body, &result); err != nil {
+			return total, fmt.Errorf("batch %d-%d: %w", start+1, end, err)
+		}
+		total.Created += result.Created
+		total.Updated += result.Updated
+		total.Unchanged += result.Unchanged
+		importer.CombineChanges(total.Changes, result.Changes)
+	}
+	return total, nil
+}
+
+// SaveAttachment uploads the blob for ref.
+func (c *Client) SaveAttachment(ref string, src io.Reader) (int64, error) {
+	var result struct {
+		Size int64 `json:"size"`
+	}
+	req, err := c.request(http.MethodPut, "/api/attachments/"+url.PathEscape(ref), src)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if err := c.send(req, &result); err != nil {
+		return 0, err
+	}
+	return result.Size, nil
+}
+
+// HasAttachment reports whether the server already serves a blob for ref.
+func (c *Client) HasAttachment(ref string) bool {
+	req, err := c.request(http.MethodHead, "/api/attachments/"+url.PathEscape(ref), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// ImportedHash reports the most recent import of a file with the given
+// content hash in the server's history.
+func (c *Client) ImportedHash(hash string) (models.ImportRecord, bool, error) {
+	var result struct {
+		Imports []models.ImportRecord `json:"imports"`
+	}
+	if err := c.do(http.MethodGet, "/api/imports?hash="+url.QueryEscape(hash), nil, &result); err != nil {
+		return models.ImportRecord{}, false, err
+	}
+	if len(result.Imports) == 0 {
+		return models.ImportRecord{}, false, nil
+	}
+	return result.Imports[0], true, nil
+}
+
+// RecordImport appends an entry to the server's import history.
+func (c *Client) RecordImport(record models.ImportRecord) error {
+	return c.do(http.MethodPost, "/api/imports", record, nil)
+}
+
+// Import fetches one import history entry ("latest" for the newest).
+func (c *Client) Import(id string) (models.ImportRecord, error) {
+	var record models.ImportRecord
+	err := c.do(http.MethodGet, "/api/imports/"+url.PathEscape(id), nil, &record)
+	return record, err
+}
+
+func (c *Client) do(method, path string, payload, dest any) error {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := c.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req, dest)
+}
+
+func (c *Client) request(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+func (c *Client) send(req *http.Request, dest any) error {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		var envelope struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &envelope) == nil && envelope.Error.Message != "" {
+			apiErr.Code = envelope.Error.Code
+			apiErr.Message = envelope.Error.Message
+		}
+		return apiErr
+	}
+	if dest == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("failed to decode server response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// writeMethods are the calls that change data, which the -token guards.
+var writeMethods = map[string]bool{
+	"/zatgpt.v1.Conversations/Upsert": true,
+	"/zatgpt.v1.Conversations/Delete": true,
+	"/zatgpt.v1.Conversations/Import": true,
+}
+
+// Auth returns interceptors that check an "authorization: Bearer ..."
+// metadata entry as the HTTP API checks the header: with apiKey set every
+// call needs apiKey or token, and with token set every call that changes
+// data needs token. Empty values disable their check.
+func Auth(apiKey, token string) []grpc.ServerOption {
+	if apiKey == "" && token == "" {
+		return nil
+	}
+	check := func(ctx context.Context, method string) error {
+		got := bearer(ctx)
+		if apiKey != "" && !matches(got, apiKey) && !matches(got, token) {
+			return status.Error(codes.Unauthenticated, "a valid API key is required")
+		}
+		if token != "" && writeMethods[method] && !matches(got, token) {
+			return status.Error(codes.Unauthenticated, "a valid bearer token is required to modify the archive")
+		}
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := check(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
+
+// bearer returns the credential of a call's authorization metadata.
+func bearer(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if got, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return got
+		}
+	}
+	return ""
+}
+
+func matches(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"cmp"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/rpc/zatgptpb"
+	"zatGPT/internal/tokenizer"
+)
+
+// toProto converts a stored conversation for a response. Attachments, the
+// branch tree and token counts stay behind; the HTTP API serves those.
+func toProto(convo models.Conversation) *zatgptpb.Conversation {
+	out := &zatgptpb.Conversation{
+		Id:           convo.ID,
+		Title:        convo.Title,
+		Summary:      convo.Summary,
+		DateStarted:  convo.DateStarted,
+		DateEnded:    convo.DateEnded,
+		Source:       convo.Source,
+		SourceId:     convo.SourceID,
+		Tags:         convo.Tags,
+		Archived:     convo.Archived,
+		Starred:      convo.Starred,
+		CreatedAt:    timestamp(convo.CreatedAt),
+		UpdatedAt:    timestamp(convo.UpdatedAt),
+		MessageCount: int32(len(convo.Messages)),
+	}
+	for _, msg := range convo.Messages {
+		out.Messages = append(out.Messages, &zatgptpb.Message{
+			Id:        msg.ID,
+			Author:    msg.Author,
+			Content:   msg.Content,
+			Model:     msg.Model,
+			CreatedAt: timestamp(msg.CreatedAt),
+		})
+	}
+	return out
+}
+
+// fromProto converts a conversation sent to Upsert, laid over stored, the
+// version it replaces (zero for a new conversation). What the message has
+// no room for is kept from stored: the topics, content hash and
+// attachments of the conversation, and the parts, status, finish reason
+// and weight of each message it matches by ID. A message whose content
+// changed drops its parts, which described the old content, and every
+// message's tokens are counted again.
+func fromProto(convo *zatgptpb.Conversation, stored models.Conversation) models.Conversation {
+	out := stored
+	out.ID = convo.GetId()
+	out.Title = convo.GetTitle()
+	out.Summary = convo.GetSummary()
+	out.DateStarted = convo.GetDateStarted()
+	out.DateEnded = convo.GetDateEnded()
+	out.Source = cmp.Or(convo.GetSource(), stored.Source)
+	out.SourceID = convo.GetSourceId()
+	out.Tags = convo.GetTags()
+	out.Archived = convo.GetArchived()
+	out.Starred = convo.GetStarred()
+	if created := fromTimestamp(convo.GetCreatedAt()); !created.IsZero() {
+		out.CreatedAt = created
+	}
+	out.UpdatedAt = fromTimestamp(convo.GetUpdatedAt())
+
+	previous := make(map[string]models.Message, len(stored.Messages))
+	for _, msg := range stored.Messages {
+		if _, seen := previous[msg.ID]; msg.ID != "" && !seen {
+			previous[msg.ID] = msg
+		}
+	}
+	out.Messages = make([]models.Message, 0, len(convo.GetMessages()))
+	for _, msg := range convo.GetMessages() {
+		message := previous[msg.GetId()]
+		if message.Content != msg.GetContent() {
+			message.Parts = nil
+		}
+		message.ID = msg.GetId()
+		message.Author = msg.GetAuthor()
+		message.Content = msg.GetContent()
+		message.Model = msg.GetModel()
+		message.CreatedAt = fromTimestamp(msg.GetCreatedAt())
+		out.Messages = append(out.Messages, message)
+	}
+	if len(out.Messages) == 0 {
+		out.Messages = nil
+	}
+	tokenizer.CountConversation(&out)
+	return out
+}
+
+// timestamp leaves a zero time unset rather than sending year 1.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
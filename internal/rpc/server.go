@@ -0,0 +1,360 @@
+// Package rpc serves the gRPC API described by
+// proto/zatgpt/v1/conversations.proto from the same store as the HTTP API,
+// for programs that would rather use a generated client than hand-rolled
+// HTTP calls.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"zatGPT/internal/ids"
+	"zatGPT/internal/importer"
+	"zatGPT/internal/models"
+	"zatGPT/internal/query"
+	"zatGPT/internal/rpc/zatgptpb"
+	"zatGPT/internal/storage"
+	"zatGPT/internal/telemetry"
+)
+
+// defaultLimit is the page size of List and Search when the request sets
+// none, as in the HTTP API.
+const defaultLimit = 50
+
+// maxLimit caps the page size of List and Search.
+const maxLimit = 1000
+
+// maxImportSize bounds the export file one Import call may send, as
+// POST /api/import does.
+const maxImportSize = 2 << 30
+
+// maxMessageSize raises gRPC's 4MB default, so Get and Upsert handle long
+// conversations.
+const maxMessageSize = 64 << 20
+
+// Server implements the Conversations service over a store.
+type Server struct {
+	zatgptpb.UnimplementedConversationsServer
+	store *storage.Store
+}
+
+// New returns a Server for store.
+func New(store *storage.Store) *Server {
+	return &Server{store: store}
+}
+
+// NewGRPCServer returns a gRPC server with the Conversations service
+// registered and opts (credentials, interceptors) applied.
+func NewGRPCServer(store *storage.Store, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.MaxRecvMsgSize(maxMessageSize), grpc.MaxSendMsgSize(maxMessageSize)}, opts...)
+	server := grpc.NewServer(opts...)
+	zatgptpb.RegisterConversationsServer(server, New(store))
+	return server
+}
+
+// List pages through the conversation list with Store.ListPage.
+func (s *Server) List(ctx context.Context, req *zatgptpb.ListRequest) (*zatgptpb.ListResponse, error) {
+	if !storage.ValidSort(req.GetSort()) {
+		return nil, status.Errorf(codes.InvalidArgument, "sort must be one of %s", strings.Join(storage.SortKeys, ", "))
+	}
+	switch req.GetOrder() {
+	case "", storage.OrderAsc, storage.OrderDesc:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "order must be asc or desc")
+	}
+	limit, err := pageSize(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.store.ListPage(storage.ListOptions{
+		Sort:   req.GetSort(),
+		Order:  req.GetOrder(),
+		Cursor: req.GetCursor(),
+		Limit:  limit,
+		Tags:   req.GetTags(),
+	})
+	if err != nil {
+		return nil, statusFor(err)
+	}
+	resp := &zatgptpb.ListResponse{NextCursor: page.NextCursor, Total: int32(page.Total)}
+	for _, item := range page.Conversations {
+		convo := toProto(item)
+		convo.MessageCount = int32(page.MessageCounts[item.ID])
+		resp.Conversations = append(resp.Conversations, convo)
+	}
+	return resp, nil
+}
+
+// Get returns a conversation with its messages, but not its branch tree.
+func (s *Server) Get(ctx context.Context, req *zatgptpb.GetRequest) (*zatgptpb.Conversation, error) {
+	convo, err := s.store.Get(req.GetId())
+	if err != nil {
+		return nil, statusFor(err)
+	}
+	return toProto(convo), nil
+}
+
+// Upsert stores a conversation. Fields the message has no room for, such as
+// attachments, the branch tree and topics, are kept from the stored version
+// (see fromProto).
+func (s *Server) Upsert(ctx context.Context, req *zatgptpb.UpsertRequest) (*zatgptpb.Conversation, error) {
+	in := req.GetConversation()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "conversation is required")
+	}
+	var stored models.Conversation
+	if id := in.GetId(); id != "" {
+		if existing, err := s.store.Get(id); err == nil {
+			stored = existing
+		}
+	}
+	convo := fromProto(in, stored)
+	if convo.Title == "" {
+		return nil, status.Error(codes.InvalidArgument, "conversation.title is required")
+	}
+	if convo.ID == "" {
+		convo.ID = ids.New()
+	}
+	if convo.UpdatedAt.IsZero() {
+		convo.UpdatedAt = time.Now().UTC()
+	}
+
+	_, span := telemetry.Start(ctx, "store.Upsert")
+	err := s.store.Upsert(convo)
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+	saved, err := s.store.Get(convo.ID)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+	return toProto(saved), nil
+}
+
+// Delete moves a conversation to the trash.
+func (s *Server) Delete(ctx context.Context, req *zatgptpb.DeleteRequest) (*zatgptpb.DeleteResponse, error) {
+	if err := s.store.Delete(req.GetId()); err != nil {
+		return nil, statusFor(err)
+	}
+	return &zatgptpb.DeleteResponse{}, nil
+}
+
+// Search runs a query with the web search's syntax.
+func (s *Server) Search(ctx context.Context, req *zatgptpb.SearchRequest) (*zatgptpb.SearchResponse, error) {
+	raw := strings.TrimSpace(req.GetQuery())
+	if raw == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	q, err := query.Parse(raw)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "query: %v", err)
+	}
+	if len(q.Terms) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query must contain at least one word to search for")
+	}
+	if req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must not be negative")
+	}
+	limit, err := pageSize(req.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	var match func(models.Conversation) bool
+	filters := q
+	filters.Terms = nil
+	if !filters.IsEmpty() {
+		match = filters.Match
+	}
+	ctx, span := telemetry.Start(ctx, "store.Search")
+	hits, total, err := s.store.Search(ctx, q.Terms, match, int(req.GetOffset()), limit)
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	resp := &zatgptpb.SearchResponse{Total: int32(total)}
+	for _, hit := range hits {
+		convo := toProto(hit.Conversation)
+		convo.Messages = nil
+		out := &zatgptpb.SearchHit{Conversation: convo, Score: int32(hit.Score), MessageIds: hit.MessageIDs}
+		for _, snippet := range hit.Snippets {
+			out.Snippets = append(out.Snippets, snippet.Text)
+		}
+		resp.Hits = append(resp.Hits, out)
+	}
+	return resp, nil
+}
+
+// Import saves the streamed file to a temporary directory and imports it
+// as POST /api/import does, recording it in the import history.
+func (s *Server) Import(stream grpc.ClientStreamingServer[zatgptpb.ImportChunk, zatgptpb.ImportResult]) error {
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "no file was sent")
+	}
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(strings.ReplaceAll(first.GetFileName(), "\\", "/"))
+	if name == "" || name == "." || name == "/" {
+		return status.Error(codes.InvalidArgument, "file_name is required in the first chunk")
+	}
+
+	dir, err := os.MkdirTemp("", "zatgpt-grpc-import-")
+	if err != nil {
+		return statusFor(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, name)
+	if err := receiveFile(stream, first, path); err != nil {
+		return err
+	}
+	hash, err := importer.HashFile(path)
+	if err != nil {
+		return statusFor(err)
+	}
+
+	result := &zatgptpb.ImportResult{}
+	if prev, ok := s.store.ImportedHash(hash); ok && !first.GetForce() {
+		result.Status = "skipped"
+		result.ImportId = prev.ID
+		result.Format = prev.Format
+		result.Source = prev.Source
+		return stream.SendAndClose(result)
+	}
+
+	ctx := stream.Context()
+	started := time.Now().UTC()
+	exp, err := importer.Open(path, importer.Options{AllRoles: first.GetAllRoles()})
+	if errors.Is(err, importer.ErrNotExport) {
+		return status.Errorf(codes.InvalidArgument, "%s: %v", name, err)
+	}
+	if err != nil {
+		return statusFor(err)
+	}
+	defer exp.Close()
+
+	loaded, err := importer.Load(ctx, exp, destination{s.store}, importer.LoadOptions{})
+	if err != nil {
+		return statusFor(err)
+	}
+	record := models.ImportRecord{
+		ID:            ids.New(),
+		File:          name,
+		Format:        string(exp.Format),
+		Source:        exp.Source,
+		Hash:          hash,
+		Conversations: loaded.Conversations,
+		Created:       loaded.Created,
+		Updated:       loaded.Updated,
+		Unchanged:     loaded.Unchanged,
+		Attachments:   loaded.AttachmentsCopied,
+		Status:        "ok",
+		StartedAt:     started,
+		FinishedAt:    time.Now().UTC(),
+		Changes:       loaded.Changes,
+		Report:        loaded.Report,
+	}
+	if err := s.store.RecordImport(record); err != nil {
+		return statusFor(err)
+	}
+
+	result.Status = "imported"
+	result.ImportId = record.ID
+	result.Format = record.Format
+	result.Source = record.Source
+	result.Conversations = int32(loaded.Conversations)
+	result.Created = int32(loaded.Created)
+	result.Updated = int32(loaded.Updated)
+	result.Unchanged = int32(loaded.Unchanged)
+	result.AttachmentsCopied = int32(loaded.AttachmentsCopied)
+	if loaded.Report != nil {
+		result.Skipped = int32(loaded.Report.Skipped)
+	}
+	return stream.SendAndClose(result)
+}
+
+// receiveFile writes first and the rest of the stream's chunks to path.
+func receiveFile(stream grpc.ClientStreamingServer[zatgptpb.ImportChunk, zatgptpb.ImportResult], first *zatgptpb.ImportChunk, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return statusFor(err)
+	}
+	defer file.Close()
+
+	var size int64
+	for chunk := first; ; {
+		size += int64(len(chunk.GetData()))
+		if size > maxImportSize {
+			return status.Errorf(codes.ResourceExhausted, "the file is larger than %s", storage.FormatSize(maxImportSize))
+		}
+		if _, err := file.Write(chunk.GetData()); err != nil {
+			return statusFor(err)
+		}
+		chunk, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return statusFor(err)
+	}
+	return nil
+}
+
+// destination adapts *storage.Store to importer.Destination.
+type destination struct {
+	*storage.Store
+}
+
+func (d destination) Merge(items []models.Conversation) (importer.MergeResult, error) {
+	return importer.Merge(d.Store, items)
+}
+
+// pageSize checks a request's limit, defaulting an unset one.
+func pageSize(limit int32) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultLimit, nil
+	case limit < 0 || limit > maxLimit:
+		return 0, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxLimit)
+	}
+	return int(limit), nil
+}
+
+// statusFor maps a store or importer error to a gRPC status, as the HTTP
+// API's statusFor maps it to an HTTP status.
+func statusFor(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrImportNotFound):
+		code = codes.NotFound
+	case errors.Is(err, storage.ErrInvalidCursor):
+		code = codes.InvalidArgument
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, storage.ErrReadOnly):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, fmt.Sprint(err))
+}
@@ -0,0 +1,334 @@
+// The gRPC API of zatgpt serve -grpc-addr. It serves the same store as the
+// HTTP API; generate a client for your language from this file.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: zatgpt/v1/conversations.proto
+
+package zatgptpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Conversations_List_FullMethodName   = "/zatgpt.v1.Conversations/List"
+	Conversations_Get_FullMethodName    = "/zatgpt.v1.Conversations/Get"
+	Conversations_Upsert_FullMethodName = "/zatgpt.v1.Conversations/Upsert"
+	Conversations_Delete_FullMethodName = "/zatgpt.v1.Conversations/Delete"
+	Conversations_Search_FullMethodName = "/zatgpt.v1.Conversations/Search"
+	Conversations_Import_FullMethodName = "/zatgpt.v1.Conversations/Import"
+)
+
+// ConversationsClient is the client API for Conversations service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Conversations reads and writes the archived conversations.
+type ConversationsClient interface {
+	// List pages through the conversations, without their messages, newest
+	// first unless sort says otherwise.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Get returns one conversation with its messages.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Conversation, error)
+	// Upsert stores a conversation, replacing the one with the same id. An
+	// empty id creates a new conversation.
+	Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*Conversation, error)
+	// Delete moves a conversation to the trash.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Search finds conversations with the web search's query syntax: words,
+	// "quoted phrases", tag:, lang:, after: and before:.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Import receives an export file (conversations.json, chat.html, an
+	// export ZIP or any other format the importer reads) in chunks and
+	// imports it once the stream is closed.
+	Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportChunk, ImportResult], error)
+}
+
+type conversationsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConversationsClient(cc grpc.ClientConnInterface) ConversationsClient {
+	return &conversationsClient{cc}
+}
+
+func (c *conversationsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, Conversations_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversationsClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Conversation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Conversation)
+	err := c.cc.Invoke(ctx, Conversations_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversationsClient) Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*Conversation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Conversation)
+	err := c.cc.Invoke(ctx, Conversations_Upsert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversationsClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Conversations_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversationsClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Conversations_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversationsClient) Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportChunk, ImportResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Conversations_ServiceDesc.Streams[0], Conversations_Import_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportChunk, ImportResult]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Conversations_ImportClient = grpc.ClientStreamingClient[ImportChunk, ImportResult]
+
+// ConversationsServer is the server API for Conversations service.
+// All implementations must embed UnimplementedConversationsServer
+// for forward compatibility.
+//
+// Conversations reads and writes the archived conversations.
+type ConversationsServer interface {
+	// List pages through the conversations, without their messages, newest
+	// first unless sort says otherwise.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Get returns one conversation with its messages.
+	Get(context.Context, *GetRequest) (*Conversation, error)
+	// Upsert stores a conversation, replacing the one with the same id. An
+	// empty id creates a new conversation.
+	Upsert(context.Context, *UpsertRequest) (*Conversation, error)
+	// Delete moves a conversation to the trash.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Search finds conversations with the web search's query syntax: words,
+	// "quoted phrases", tag:, lang:, after: and before:.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Import receives an export file (conversations.json, chat.html, an
+	// export ZIP or any other format the importer reads) in chunks and
+	// imports it once the stream is closed.
+	Import(grpc.ClientStreamingServer[ImportChunk, ImportResult]) error
+	mustEmbedUnimplementedConversationsServer()
+}
+
+// UnimplementedConversationsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedConversationsServer struct{}
+
+func (UnimplementedConversationsServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedConversationsServer) Get(context.Context, *GetRequest) (*Conversation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedConversationsServer) Upsert(context.Context, *UpsertRequest) (*Conversation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Upsert not implemented")
+}
+func (UnimplementedConversationsServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedConversationsServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedConversationsServer) Import(grpc.ClientStreamingServer[ImportChunk, ImportResult]) error {
+	return status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedConversationsServer) mustEmbedUnimplementedConversationsServer() {}
+func (UnimplementedConversationsServer) testEmbeddedByValue()                       {}
+
+// UnsafeConversationsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConversationsServer will
+// result in compilation errors.
+type UnsafeConversationsServer interface {
+	mustEmbedUnimplementedConversationsServer()
+}
+
+func RegisterConversationsServer(s grpc.ServiceRegistrar, srv ConversationsServer) {
+	// If the following call pancis, it indicates UnimplementedConversationsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Conversations_ServiceDesc, srv)
+}
+
+func _Conversations_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversationsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Conversations_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversationsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Conversations_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversationsServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Conversations_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversationsServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Conversations_Upsert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversationsServer).Upsert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Conversations_Upsert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversationsServer).Upsert(ctx, req.(*UpsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Conversations_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversationsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Conversations_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversationsServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Conversations_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversationsServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Conversations_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversationsServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Conversations_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ConversationsServer).Import(&grpc.GenericServerStream[ImportChunk, ImportResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Conversations_ImportServer = grpc.ClientStreamingServer[ImportChunk, ImportResult]
+
+// Conversations_ServiceDesc is the grpc.ServiceDesc for Conversations service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Conversations_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zatgpt.v1.Conversations",
+	HandlerType: (*ConversationsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Conversations_List_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Conversations_Get_Handler,
+		},
+		{
+			MethodName: "Upsert",
+			Handler:    _Conversations_Upsert_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Conversations_Delete_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Conversations_Search_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Import",
+			Handler:       _Conversations_Import_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "zatgpt/v1/conversations.proto",
+}
@@ -0,0 +1,211 @@
+package snippets
+
+import (
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// Snippet is a fenced code block found in a message.
+type Snippet struct {
+	ConversationID    string `json:"conversationId"`
+	ConversationTitle string `json:"conversationTitle"`
+	MessageID         string `json:"messageId"`
+	Author            string `json:"author"`
+	Index             int    `json:"index"`
+	Language          string `json:"language,omitempty"`
+	Code              string `json:"code"`
+	Prompt            string `json:"prompt,omitempty"`
+}
+
+// Block is a fenced code block within a single piece of text.
+type Block struct {
+	Language string
+	Code     string
+}
+
+var aliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"ts":         "typescript",
+	"py":         "python",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"yml":        "yaml",
+	"rb":         "ruby",
+	"rs":         "rust",
+	"c++":        "cpp",
+	"cs":         "csharp",
+	"dockerfile": "docker",
+	"tf":         "terraform",
+	"hcl":        "terraform",
+}
+
+// NormalizeLanguage lowercases a fence info string's language and folds
+// common aliases (`golang` → `go`, `js` → `javascript`).
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if alias, ok := aliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+// Part is a stretch of a message: prose, or a fenced code block when
+// Fenced is set.
+type Part struct {
+	Fenced   bool
+	Language string
+	Text     string
+}
+
+// Blocks returns every ``` or ~~~ fenced block in text. An unterminated
+// fence runs to the end of the text, matching how Markdown renders it.
+func Blocks(text string) []Block {
+	var blocks []Block
+	for _, part := range Parts(text) {
+		if part.Fenced {
+			blocks = append(blocks, Block{Language: part.Language, Code: part.Text})
+		}
+	}
+	return blocks
+}
+
+// Parts splits text into prose and fenced code blocks, in order. The fence
+// lines themselves are dropped; a block keeps its interior verbatim.
+func Parts(text string) []Part {
+	var (
+		parts  []Part
+		fence  string
+		lang   string
+		body   []string
+		inside bool
+	)
+	flushProse := func() {
+		if prose := strings.Trim(strings.Join(body, "\n"), "\n"); strings.TrimSpace(prose) != "" {
+			parts = append(parts, Part{Text: prose})
+		}
+		body = body[:0]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !inside {
+			if marker := fenceMarker(trimmed); marker != "" {
+				flushProse()
+				inside = true
+				fence = marker
+				info := strings.TrimSpace(strings.TrimLeft(trimmed, marker[:1]))
+				lang, _, _ = strings.Cut(info, " ")
+				continue
+			}
+			body = append(body, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			parts = append(parts, Part{Fenced: true, Language: NormalizeLanguage(lang), Text: strings.Join(body, "\n")})
+			body = body[:0]
+			inside = false
+			continue
+		}
+		body = append(body, line)
+	}
+
+	switch {
+	case !inside:
+		flushProse()
+	case len(body) > 0:
+		parts = append(parts, Part{Fenced: true, Language: NormalizeLanguage(lang), Text: strings.Join(body, "\n")})
+	}
+	return parts
+}
+
+func fenceMarker(line string) string {
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, marker) {
+			n := len(line) - len(strings.TrimLeft(line, marker[:1]))
+			return strings.Repeat(marker[:1], n)
+		}
+	}
+	return ""
+}
+
+// Extract returns the code blocks of a conversation, each paired with the
+// user prompt that preceded it. An empty lang matches every language.
+func Extract(convo models.Conversation, lang string) []Snippet {
+	lang = NormalizeLanguage(lang)
+
+	var (
+		out    []Snippet
+		prompt string
+	)
+	for _, msg := range convo.Messages {
+		if msg.Author == "user" {
+			prompt = msg.Content
+		}
+		for i, block := range Blocks(msg.Content) {
+			if lang != "" && block.Language != lang {
+				continue
+			}
+			snippet := Snippet{
+				ConversationID:    convo.ID,
+				ConversationTitle: convo.Title,
+				MessageID:         msg.ID,
+				Author:            msg.Author,
+				Index:             i,
+				Language:          block.Language,
+				Code:              block.Code,
+			}
+			if msg.Author != "user" {
+				snippet.Prompt = truncate(prompt, 280)
+			}
+			out = append(out, snippet)
+		}
+	}
+	return out
+}
+
+func truncate(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:limit])) + "..."
+}
+
+// Identifiers splits code into identifier tokens, keeping dotted paths
+// intact: `http.ListenAndServe(":8080")` yields `http.ListenAndServe`,
+// `http`, `ListenAndServe` and `8080`. Scope separators such as `::` and
+// `->` are treated like dots.
+func Identifiers(code string) []string {
+	var (
+		tokens []string
+		seen   = make(map[string]bool)
+	)
+	emit := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	code = strings.NewReplacer("::", ".", "->", ".").Replace(code)
+	for _, path := range strings.FieldsFunc(code, func(r rune) bool {
+		return !isIdentRune(r) && r != '.'
+	}) {
+		path = strings.Trim(path, ".")
+		emit(path)
+		if strings.Contains(path, ".") {
+			for _, part := range strings.Split(path, ".") {
+				emit(part)
+			}
+		}
+	}
+	return tokens
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127
+}
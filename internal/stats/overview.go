@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"maps"
+
+	"zatGPT/internal/models"
+)
+
+// Overview holds the archive-wide totals behind a dashboard. Months are
+// keyed by the month a conversation started; BusiestDays by the day its
+// messages were sent, most messages first.
+type Overview struct {
+	Conversations   int            `json:"conversations"`
+	Messages        int            `json:"messages"`
+	MessagesByRole  map[string]int `json:"messagesByRole"`
+	AverageMessages float64        `json:"averageMessages"`
+	Months          []Bucket       `json:"months"`
+	BusiestDays     []Bucket       `json:"busiestDays"`
+	Models          []Bucket       `json:"models"`
+}
+
+const overviewBusiestDays = 10
+
+// OverviewCounter builds an Overview one conversation at a time, so the
+// store can feed it without copying the archive.
+type OverviewCounter struct {
+	overview Overview
+	months   counter
+	days     counter
+	modelUse counter
+}
+
+// NewOverviewCounter returns an empty OverviewCounter.
+func NewOverviewCounter() *OverviewCounter {
+	return &OverviewCounter{
+		overview: Overview{MessagesByRole: map[string]int{}},
+		months:   newCounter(),
+		days:     newCounter(),
+		modelUse: newCounter(),
+	}
+}
+
+// Add counts one conversation.
+func (c *OverviewCounter) Add(convo models.Conversation) {
+	c.overview.Conversations++
+	c.overview.Messages += len(convo.Messages)
+	c.months.add(monthOf(convo), 1, len(convo.Messages))
+
+	started := conversationStart(convo)
+	seenDays := make(map[string]bool)
+	seenModels := make(map[string]bool)
+	for _, msg := range convo.Messages {
+		c.overview.MessagesByRole[msg.Author]++
+
+		if at := msg.CreatedAt; !at.IsZero() || !started.IsZero() {
+			if at.IsZero() {
+				at = started
+			}
+			day := at.UTC().Format("2006-01-02")
+			c.days.add(day, boolCount(!seenDays[day]), 1)
+			seenDays[day] = true
+		}
+
+		if msg.Author != "assistant" {
+			continue
+		}
+		model := msg.Model
+		if model == "" {
+			model = "unknown"
+		}
+		c.modelUse.add(model, boolCount(!seenModels[model]), 1)
+		seenModels[model] = true
+	}
+}
+
+// Overview returns the totals counted so far.
+func (c *OverviewCounter) Overview() Overview {
+	out := c.overview
+	out.MessagesByRole = maps.Clone(c.overview.MessagesByRole)
+	if out.Conversations > 0 {
+		out.AverageMessages = float64(out.Messages) / float64(out.Conversations)
+	}
+	out.Months = c.months.byKey()
+	out.BusiestDays = c.days.byMessages()
+	if len(out.BusiestDays) > overviewBusiestDays {
+		out.BusiestDays = out.BusiestDays[:overviewBusiestDays]
+	}
+	out.Models = c.modelUse.byMessages()
+	return out
+}
@@ -0,0 +1,355 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Review is a "year in review" summary of one calendar year of the archive.
+// A conversation belongs to the year when at least one of its messages
+// does; message counts only include messages from that year.
+type Review struct {
+	Year              int                 `json:"year"`
+	Conversations     int                 `json:"conversations"`
+	Messages          int                 `json:"messages"`
+	UserMessages      int                 `json:"userMessages"`
+	AssistantMessages int                 `json:"assistantMessages"`
+	Words             int                 `json:"words"`
+	ActiveDays        int                 `json:"activeDays"`
+	LongestStreak     int                 `json:"longestStreak"`
+	BusiestDay        *Bucket             `json:"busiestDay,omitempty"`
+	BusiestMonth      *Bucket             `json:"busiestMonth,omitempty"`
+	Longest           *ReviewConversation `json:"longestConversation,omitempty"`
+	Topics            []Term              `json:"topics"`
+	Tags              []Bucket            `json:"tags"`
+	Models            []Bucket            `json:"models"`
+	Months            []Bucket            `json:"months"`
+}
+
+// ReviewConversation identifies a conversation highlighted in a Review.
+type ReviewConversation struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Messages int    `json:"messages"`
+}
+
+const (
+	reviewTopics = 15
+	reviewTags   = 10
+)
+
+// ComputeReview builds the Review for year. Topics are the most frequent
+// terms of the user's own messages, which describe what was asked about
+// better than the assistant's longer replies.
+func ComputeReview(conversations []models.Conversation, year int) Review {
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	review := Review{Year: year, Topics: []Term{}, Tags: []Bucket{}, Models: []Bucket{}}
+	days := newCounter()
+	months := newCounter()
+	modelUse := newCounter()
+	tags := newCounter()
+	var inYear []models.Conversation
+
+	for _, convo := range conversations {
+		started := conversationStart(convo)
+		count := 0
+		seenDays := make(map[string]bool)
+		seenMonths := make(map[string]bool)
+		seenModels := make(map[string]bool)
+
+		for _, msg := range convo.Messages {
+			at := msg.CreatedAt
+			if at.IsZero() {
+				at = started
+			}
+			if at.Before(from) || !at.Before(to) {
+				continue
+			}
+			count++
+			review.Words += len(strings.Fields(msg.Content))
+
+			day := at.UTC().Format("2006-01-02")
+			days.add(day, boolCount(!seenDays[day]), 1)
+			seenDays[day] = true
+			month := day[:7]
+			months.add(month, boolCount(!seenMonths[month]), 1)
+			seenMonths[month] = true
+
+			switch msg.Author {
+			case "user":
+				review.UserMessages++
+			case "assistant":
+				review.AssistantMessages++
+				model := msg.Model
+				if model == "" {
+					model = "unknown"
+				}
+				modelUse.add(model, boolCount(!seenModels[model]), 1)
+				seenModels[model] = true
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		review.Conversations++
+		review.Messages += count
+		inYear = append(inYear, convo)
+		for _, tag := range convo.Tags {
+			tags.add(tag, 1, count)
+		}
+		if review.Longest == nil || count > review.Longest.Messages {
+			review.Longest = &ReviewConversation{ID: convo.ID, Title: convo.Title, Messages: count}
+		}
+	}
+
+	review.ActiveDays = len(days)
+	review.LongestStreak = longestStreak(days)
+	review.BusiestDay = busiest(days)
+	review.BusiestMonth = busiest(months)
+	review.Months = months.byKey()
+	review.Models = modelUse.byMessages()
+	review.Tags = tags.byCount()
+	if len(review.Tags) > reviewTags {
+		review.Tags = review.Tags[:reviewTags]
+	}
+	review.Topics = Terms(inYear, TermOptions{From: from, To: to, Role: "user", Top: reviewTopics}).Terms
+	return review
+}
+
+func boolCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// busiest returns the bucket with the most messages, earliest key first on
+// ties.
+func busiest(c counter) *Bucket {
+	var best *Bucket
+	for _, b := range c.byKey() {
+		if best == nil || b.Messages > best.Messages {
+			b := b
+			best = &b
+		}
+	}
+	return best
+}
+
+func (c counter) byMessages() []Bucket {
+	out := c.list()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages == out[j].Messages {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Messages > out[j].Messages
+	})
+	return out
+}
+
+// longestStreak counts the most consecutive active days in a counter keyed
+// by YYYY-MM-DD.
+func longestStreak(days counter) int {
+	longest, current := 0, 0
+	var prev time.Time
+	for _, b := range days.byKey() {
+		day, err := time.Parse("2006-01-02", b.Key)
+		if err != nil {
+			continue
+		}
+		if !prev.IsZero() && day.Sub(prev) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		prev = day
+		if current > longest {
+			longest = current
+		}
+	}
+	return longest
+}
+
+// ReviewFormats lists the encodings accepted by WriteReview.
+var ReviewFormats = []string{"html", "markdown", "json"}
+
+// WriteReview renders the review as "html", "markdown" (or "md") or "json".
+func WriteReview(w io.Writer, format string, review Review) error {
+	switch format {
+	case "html":
+		return WriteReviewHTML(w, review)
+	case "markdown", "md":
+		return WriteReviewMarkdown(w, review)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(review)
+	default:
+		return fmt.Errorf("unsupported report format %q (want %s)", format, strings.Join(ReviewFormats, ", "))
+	}
+}
+
+// ReviewContentType returns the MIME type for a WriteReview format.
+func ReviewContentType(format string) string {
+	switch format {
+	case "html":
+		return "text/html; charset=utf-8"
+	case "markdown", "md":
+		return "text/markdown; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// WriteReviewMarkdown renders the review as a Markdown document.
+func WriteReviewMarkdown(w io.Writer, review Review) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %d in review\n\n", review.Year)
+	if review.Conversations == 0 {
+		fmt.Fprintf(&b, "No conversations in %d.\n", review.Year)
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	fmt.Fprintf(&b, "- **%d** conversations, **%d** messages (%d from you, %d replies), about **%d** words\n",
+		review.Conversations, review.Messages, review.UserMessages, review.AssistantMessages, review.Words)
+	fmt.Fprintf(&b, "- Active on **%d** days, longest streak **%d** days\n", review.ActiveDays, review.LongestStreak)
+	if review.BusiestDay != nil {
+		fmt.Fprintf(&b, "- Busiest day: **%s** (%d messages in %d conversations)\n", review.BusiestDay.Key, review.BusiestDay.Messages, review.BusiestDay.Conversations)
+	}
+	if review.BusiestMonth != nil {
+		fmt.Fprintf(&b, "- Busiest month: **%s** (%d messages)\n", monthName(review.BusiestMonth.Key), review.BusiestMonth.Messages)
+	}
+	if review.Longest != nil {
+		fmt.Fprintf(&b, "- Longest conversation: **%s** (%d messages)\n", review.Longest.Title, review.Longest.Messages)
+	}
+
+	if len(review.Topics) > 0 {
+		b.WriteString("\n## Top topics\n\n")
+		for i, t := range review.Topics {
+			fmt.Fprintf(&b, "%d. %s (%d mentions in %d conversations)\n", i+1, t.Term, t.Count, t.Conversations)
+		}
+	}
+	if len(review.Tags) > 0 {
+		b.WriteString("\n## Tags\n\n| Tag | Conversations |\n| --- | --- |\n")
+		for _, t := range review.Tags {
+			fmt.Fprintf(&b, "| %s | %d |\n", t.Key, t.Conversations)
+		}
+	}
+	if len(review.Models) > 0 {
+		b.WriteString("\n## Model mix\n\n| Model | Replies | Share |\n| --- | --- | --- |\n")
+		for _, m := range review.Models {
+			fmt.Fprintf(&b, "| %s | %d | %s |\n", m.Key, m.Messages, share(m.Messages, review.AssistantMessages))
+		}
+	}
+	if len(review.Months) > 0 {
+		b.WriteString("\n## By month\n\n| Month | Conversations | Messages |\n| --- | --- | --- |\n")
+		for _, m := range review.Months {
+			fmt.Fprintf(&b, "| %s | %d | %d |\n", monthName(m.Key), m.Conversations, m.Messages)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteReviewHTML renders the review as a standalone HTML page.
+func WriteReviewHTML(w io.Writer, review Review) error {
+	peak := 0
+	for _, m := range review.Months {
+		if m.Messages > peak {
+			peak = m.Messages
+		}
+	}
+	return reviewTemplate.Execute(w, struct {
+		Review
+		Peak int
+	}{review, peak})
+}
+
+func monthName(key string) string {
+	t, err := time.Parse("2006-01", key)
+	if err != nil {
+		return key
+	}
+	return t.Format("January 2006")
+}
+
+func share(part, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(part)*100/float64(total))
+}
+
+var reviewTemplate = template.Must(template.New("review").Funcs(template.FuncMap{
+	"month": monthName,
+	"share": share,
+	"width": func(part, total int) int {
+		if total == 0 {
+			return 0
+		}
+		return part * 100 / total
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Year}} in review</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #1f2933; }
+h1 { font-size: 2.5rem; margin-bottom: 0.5rem; }
+.cards { display: grid; grid-template-columns: repeat(auto-fit, minmax(10rem, 1fr)); gap: 1rem; margin: 1.5rem 0; }
+.card { background: #f3f4f6; border-radius: 0.75rem; padding: 1rem; }
+.card strong { display: block; font-size: 1.75rem; }
+.bar { background: #6366f1; height: 0.75rem; border-radius: 0.375rem; }
+table { width: 100%; border-collapse: collapse; }
+td, th { text-align: left; padding: 0.25rem 0.5rem; border-bottom: 1px solid #e5e7eb; }
+.topics span { display: inline-block; margin: 0.25rem; padding: 0.25rem 0.6rem; background: #eef2ff; border-radius: 1rem; }
+</style>
+</head>
+<body>
+<h1>{{.Year}} in review</h1>
+{{if eq .Conversations 0}}<p>No conversations in {{.Year}}.</p>{{else}}
+<div class="cards">
+  <div class="card"><strong>{{.Conversations}}</strong>conversations</div>
+  <div class="card"><strong>{{.Messages}}</strong>messages</div>
+  <div class="card"><strong>{{.Words}}</strong>words</div>
+  <div class="card"><strong>{{.ActiveDays}}</strong>active days</div>
+  <div class="card"><strong>{{.LongestStreak}}</strong>day streak</div>
+</div>
+<ul>
+{{with .BusiestDay}}  <li>Busiest day: <b>{{.Key}}</b> ({{.Messages}} messages in {{.Conversations}} conversations)</li>
+{{end}}{{with .BusiestMonth}}  <li>Busiest month: <b>{{month .Key}}</b> ({{.Messages}} messages)</li>
+{{end}}{{with .Longest}}  <li>Longest conversation: <a href="/conversation.html?id={{.ID}}">{{.Title}}</a> ({{.Messages}} messages)</li>
+{{end}}</ul>
+{{if .Topics}}<h2>Top topics</h2>
+<p class="topics">{{range .Topics}}<span title="{{.Count}} mentions">{{.Term}}</span>{{end}}</p>
+{{end}}{{if .Models}}<h2>Model mix</h2>
+<table>
+<tr><th>Model</th><th>Replies</th><th>Share</th></tr>
+{{range .Models}}<tr><td>{{.Key}}</td><td>{{.Messages}}</td><td>{{share .Messages $.AssistantMessages}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Tags}}<h2>Tags</h2>
+<table>
+<tr><th>Tag</th><th>Conversations</th></tr>
+{{range .Tags}}<tr><td>{{.Key}}</td><td>{{.Conversations}}</td></tr>
+{{end}}</table>
+{{end}}<h2>By month</h2>
+<table>
+{{range .Months}}<tr><td>{{month .Key}}</td><td style="width:60%"><div class="bar" style="width: {{width .Messages $.Peak}}%"></div></td><td>{{.Messages}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"zatGPT/internal/models"
+)
+
+// Bucket counts conversations and messages under a single key (a month,
+// model, or tag).
+type Bucket struct {
+	Key           string `json:"key"`
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+}
+
+// Report holds the archive-wide distributions.
+type Report struct {
+	Months []Bucket `json:"months"`
+	Models []Bucket `json:"models"`
+	Tags   []Bucket `json:"tags"`
+}
+
+// Compute aggregates conversations into per-month activity (keyed by the
+// month a conversation started), assistant model usage, and tag counts.
+func Compute(conversations []models.Conversation) Report {
+	months := newCounter()
+	modelUse := newCounter()
+	tags := newCounter()
+
+	for _, convo := range conversations {
+		month := monthOf(convo)
+		months.add(month, 1, len(convo.Messages))
+
+		seenModels := make(map[string]bool)
+		for _, msg := range convo.Messages {
+			if msg.Author != "assistant" {
+				continue
+			}
+			model := msg.Model
+			if model == "" {
+				model = "unknown"
+			}
+			conversations := 0
+			if !seenModels[model] {
+				seenModels[model] = true
+				conversations = 1
+			}
+			modelUse.add(model, conversations, 1)
+		}
+
+		for _, tag := range convo.Tags {
+			tags.add(tag, 1, len(convo.Messages))
+		}
+	}
+
+	return Report{
+		Months: months.byKey(),
+		Models: modelUse.byCount(),
+		Tags:   tags.byCount(),
+	}
+}
+
+// WriteCSV emits the report in long format (`report,key,conversations,messages`)
+// so every section can be loaded into a single dataframe.
+func WriteCSV(w io.Writer, report Report, sections ...string) error {
+	if len(sections) == 0 {
+		sections = []string{"months", "models", "tags"}
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"report", "key", "conversations", "messages"}); err != nil {
+		return err
+	}
+	for _, section := range sections {
+		var buckets []Bucket
+		switch section {
+		case "months":
+			buckets = report.Months
+		case "models":
+			buckets = report.Models
+		case "tags":
+			buckets = report.Tags
+		}
+		for _, b := range buckets {
+			if err := out.Write([]string{section, b.Key, strconv.Itoa(b.Conversations), strconv.Itoa(b.Messages)}); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func monthOf(convo models.Conversation) string {
+	if len(convo.DateStarted) >= 7 {
+		return convo.DateStarted[:7]
+	}
+	if !convo.CreatedAt.IsZero() {
+		return convo.CreatedAt.UTC().Format("2006-01")
+	}
+	return "unknown"
+}
+
+type counter map[string]*Bucket
+
+func newCounter() counter {
+	return make(counter)
+}
+
+func (c counter) add(key string, conversations, messages int) {
+	b, ok := c[key]
+	if !ok {
+		b = &Bucket{Key: key}
+		c[key] = b
+	}
+	b.Conversations += conversations
+	b.Messages += messages
+}
+
+func (c counter) byKey() []Bucket {
+	out := c.list()
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func (c counter) byCount() []Bucket {
+	out := c.list()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Conversations == out[j].Conversations {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Conversations > out[j].Conversations
+	})
+	return out
+}
+
+func (c counter) list() []Bucket {
+	out := make([]Bucket, 0, len(c))
+	for _, b := range c {
+		out = append(out, *b)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/terms"
+)
+
+// Term is one entry of a term-frequency report.
+type Term struct {
+	Term          string `json:"term"`
+	Count         int    `json:"count"`
+	Conversations int    `json:"conversations"`
+}
+
+// TermOptions selects the messages counted by Terms. From is inclusive and
+// To exclusive; zero values leave that side open. Role limits counting to
+// "user" or "assistant" messages; empty counts both.
+type TermOptions struct {
+	From time.Time
+	To   time.Time
+	Role string
+	Top  int
+}
+
+// TermReport is the result of Terms.
+type TermReport struct {
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+	Terms         []Term `json:"terms"`
+}
+
+// Terms counts stopword-filtered words across the messages selected by
+// opts and returns the Top most frequent. Messages without a timestamp are
+// dated by their conversation's start.
+func Terms(conversations []models.Conversation, opts TermOptions) TermReport {
+	counts := make(map[string]*Term)
+	report := TermReport{}
+
+	for _, convo := range conversations {
+		started := conversationStart(convo)
+		seen := make(map[string]bool)
+		matched := false
+
+		for _, msg := range convo.Messages {
+			if opts.Role != "" && msg.Author != opts.Role {
+				continue
+			}
+			at := msg.CreatedAt
+			if at.IsZero() {
+				at = started
+			}
+			if !opts.From.IsZero() && (at.IsZero() || at.Before(opts.From)) {
+				continue
+			}
+			if !opts.To.IsZero() && (at.IsZero() || !at.Before(opts.To)) {
+				continue
+			}
+
+			matched = true
+			report.Messages++
+			for _, word := range terms.Tokenize(msg.Content) {
+				t, ok := counts[word]
+				if !ok {
+					t = &Term{Term: word}
+					counts[word] = t
+				}
+				t.Count++
+				if !seen[word] {
+					seen[word] = true
+					t.Conversations++
+				}
+			}
+		}
+		if matched {
+			report.Conversations++
+		}
+	}
+
+	report.Terms = make([]Term, 0, len(counts))
+	for _, t := range counts {
+		report.Terms = append(report.Terms, *t)
+	}
+	sort.Slice(report.Terms, func(i, j int) bool {
+		if report.Terms[i].Count == report.Terms[j].Count {
+			return report.Terms[i].Term < report.Terms[j].Term
+		}
+		return report.Terms[i].Count > report.Terms[j].Count
+	})
+	if opts.Top > 0 && len(report.Terms) > opts.Top {
+		report.Terms = report.Terms[:opts.Top]
+	}
+	return report
+}
+
+func conversationStart(convo models.Conversation) time.Time {
+	if t, err := time.Parse("2006-01-02", convo.DateStarted); err == nil {
+		return t
+	}
+	return convo.CreatedAt
+}
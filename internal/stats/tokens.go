@@ -0,0 +1,267 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/tokenizer"
+)
+
+// Price is what an API charges per million tokens, in US dollars.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PricesAsOf dates DefaultPrices.
+const PricesAsOf = "2025-08"
+
+// DefaultPrices are the API list prices of OpenAI and Anthropic models, by
+// model name prefix; the longest prefix of a model's name applies. ChatGPT
+// exports name GPT-3.5 "text-davinci-002-render-sha" and GPT-4.5 "gpt-4-5".
+var DefaultPrices = map[string]Price{
+	"gpt-5":                   {1.25, 10},
+	"gpt-5-mini":              {0.25, 2},
+	"gpt-5-nano":              {0.05, 0.40},
+	"gpt-4.1":                 {2, 8},
+	"gpt-4.1-mini":            {0.40, 1.60},
+	"gpt-4.1-nano":            {0.10, 0.40},
+	"gpt-4.5":                 {75, 150},
+	"gpt-4-5":                 {75, 150},
+	"gpt-4o":                  {2.50, 10},
+	"gpt-4o-mini":             {0.15, 0.60},
+	"chatgpt-4o":              {5, 15},
+	"gpt-4-turbo":             {10, 30},
+	"gpt-4":                   {30, 60},
+	"gpt-4-32k":               {60, 120},
+	"gpt-3.5-turbo":           {0.50, 1.50},
+	"text-davinci-002-render": {0.50, 1.50},
+	"o1":                      {15, 60},
+	"o1-mini":                 {1.10, 4.40},
+	"o1-pro":                  {150, 600},
+	"o3":                      {2, 8},
+	"o3-mini":                 {1.10, 4.40},
+	"o3-pro":                  {20, 80},
+	"o4-mini":                 {1.10, 4.40},
+	"claude-opus-4":           {15, 75},
+	"claude-sonnet-4":         {3, 15},
+	"claude-3-7-sonnet":       {3, 15},
+	"claude-3-5-sonnet":       {3, 15},
+	"claude-3-5-haiku":        {0.80, 4},
+	"claude-3-opus":           {15, 75},
+	"claude-3-haiku":          {0.25, 1.25},
+}
+
+// LoadPrices reads a JSON object of model name prefixes to prices, such as
+// {"gpt-4o": {"input": 2.5, "output": 10}}, and returns DefaultPrices with
+// those entries added or replaced.
+func LoadPrices(path string) (map[string]Price, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]Price
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	prices := maps.Clone(DefaultPrices)
+	for model, price := range custom {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("%s: negative price for %s", path, model)
+		}
+		prices[strings.ToLower(model)] = price
+	}
+	return prices, nil
+}
+
+// priceOf returns the price of the longest prefix of model in prices.
+func priceOf(prices map[string]Price, model string) (Price, bool) {
+	model = strings.ToLower(model)
+	best, found := "", false
+	for prefix := range prices {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(best)) {
+			best, found = prefix, true
+		}
+	}
+	return prices[best], found
+}
+
+// TokenModel is the API-equivalent usage of one model. Replies counts its
+// messages; OutputTokens is their length and InputTokens the length of
+// everything before each of them in its conversation, which the API would
+// have been sent as context. Cost is zero and Priced false for models
+// without a price.
+type TokenModel struct {
+	Model         string  `json:"model"`
+	Encoding      string  `json:"encoding"`
+	Conversations int     `json:"conversations"`
+	Replies       int     `json:"replies"`
+	InputTokens   int     `json:"inputTokens"`
+	OutputTokens  int     `json:"outputTokens"`
+	Priced        bool    `json:"priced"`
+	Price         Price   `json:"price"`
+	Cost          float64 `json:"cost"`
+}
+
+// TokenMonth totals the tokens and cost of the conversations started in a
+// month.
+type TokenMonth struct {
+	Month         string  `json:"month"`
+	Conversations int     `json:"conversations"`
+	Tokens        int     `json:"tokens"`
+	Cost          float64 `json:"cost"`
+}
+
+// TokenReport holds the token totals of the archive and what the same
+// usage would have cost through the API. TokensByRole splits Tokens by
+// message author; Recounted is how many conversations had no stored counts
+// (they were imported before tokens were counted) and were counted for the
+// report.
+type TokenReport struct {
+	Conversations int            `json:"conversations"`
+	Messages      int            `json:"messages"`
+	Tokens        int            `json:"tokens"`
+	TokensByRole  map[string]int `json:"tokensByRole"`
+	InputTokens   int            `json:"inputTokens"`
+	OutputTokens  int            `json:"outputTokens"`
+	Cost          float64        `json:"cost"`
+	Currency      string         `json:"currency"`
+	PricesAsOf    string         `json:"pricesAsOf"`
+	Recounted     int            `json:"recounted"`
+	Models        []TokenModel   `json:"models"`
+	Months        []TokenMonth   `json:"months"`
+}
+
+// TokenCounter builds a TokenReport one conversation at a time.
+type TokenCounter struct {
+	report TokenReport
+	prices map[string]Price
+	models map[string]*TokenModel
+	months map[string]*TokenMonth
+}
+
+// NewTokenCounter returns an empty TokenCounter pricing models with prices
+// (DefaultPrices when nil).
+func NewTokenCounter(prices map[string]Price) *TokenCounter {
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	return &TokenCounter{
+		report: TokenReport{TokensByRole: map[string]int{}, Currency: "USD", PricesAsOf: PricesAsOf},
+		prices: prices,
+		models: make(map[string]*TokenModel),
+		months: make(map[string]*TokenMonth),
+	}
+}
+
+// Add counts one conversation. One without stored token counts is counted
+// on a copy, leaving convo as it is.
+func (c *TokenCounter) Add(convo models.Conversation) {
+	if convo.Tokens == 0 && len(convo.Messages) > 0 {
+		convo.Messages = slices.Clone(convo.Messages)
+		tokenizer.CountConversation(&convo)
+		c.report.Recounted++
+	}
+
+	c.report.Conversations++
+	c.report.Messages += len(convo.Messages)
+	c.report.Tokens += convo.Tokens
+
+	monthKey := monthOf(convo)
+	month, ok := c.months[monthKey]
+	if !ok {
+		month = &TokenMonth{Month: monthKey}
+		c.months[monthKey] = month
+	}
+	month.Conversations++
+	month.Tokens += convo.Tokens
+
+	seen := make(map[string]bool)
+	context := 0
+	for _, msg := range convo.Messages {
+		author := msg.Author
+		if author == "" {
+			author = "unknown"
+		}
+		c.report.TokensByRole[author] += msg.Tokens
+		if msg.Author == "assistant" {
+			name := msg.Model
+			if name == "" {
+				name = "unknown"
+			}
+			model := c.model(name)
+			if !seen[name] {
+				seen[name] = true
+				model.Conversations++
+			}
+			model.Replies++
+			model.InputTokens += context
+			model.OutputTokens += msg.Tokens
+			cost := replyCost(model.Price, context, msg.Tokens)
+			model.Cost += cost
+			month.Cost += cost
+		}
+		context += msg.Tokens
+	}
+}
+
+func (c *TokenCounter) model(name string) *TokenModel {
+	model, ok := c.models[name]
+	if !ok {
+		model = &TokenModel{Model: name, Encoding: tokenizer.ForModel(name).Name()}
+		model.Price, model.Priced = priceOf(c.prices, name)
+		c.models[name] = model
+	}
+	return model
+}
+
+func replyCost(price Price, input, output int) float64 {
+	return (float64(input)*price.Input + float64(output)*price.Output) / 1e6
+}
+
+// Report returns the totals counted so far: models by cost, then output
+// tokens, and months in order. Costs are rounded to a hundredth of a cent.
+func (c *TokenCounter) Report() TokenReport {
+	report := c.report
+	report.TokensByRole = maps.Clone(c.report.TokensByRole)
+	report.Models = make([]TokenModel, 0, len(c.models))
+	for _, model := range c.models {
+		m := *model
+		report.InputTokens += m.InputTokens
+		report.OutputTokens += m.OutputTokens
+		report.Cost += m.Cost
+		m.Cost = roundCost(m.Cost)
+		report.Models = append(report.Models, m)
+	}
+	report.Cost = roundCost(report.Cost)
+	sort.Slice(report.Models, func(i, j int) bool {
+		a, b := report.Models[i], report.Models[j]
+		switch {
+		case a.Cost != b.Cost:
+			return a.Cost > b.Cost
+		case a.OutputTokens != b.OutputTokens:
+			return a.OutputTokens > b.OutputTokens
+		default:
+			return a.Model < b.Model
+		}
+	})
+	report.Months = make([]TokenMonth, 0, len(c.months))
+	for _, month := range c.months {
+		m := *month
+		m.Cost = roundCost(m.Cost)
+		report.Months = append(report.Months, m)
+	}
+	sort.Slice(report.Months, func(i, j int) bool { return report.Months[i].Month < report.Months[j].Month })
+	return report
+}
+
+func roundCost(amount float64) float64 {
+	return math.Round(amount*1e4) / 1e4
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// ErrInvalidRef is returned for attachment refs that are not safe to use as
+// file names.
+var ErrInvalidRef = errors.New("invalid attachment ref")
+
+// AttachmentDir is where attachment blobs live, in the store's Dir.
+func (s *Store) AttachmentDir() string {
+	return filepath.Join(s.dir, "attachments")
+}
+
+// SaveAttachment copies the blob for ref into the attachment directory,
+// replacing any previous copy. It returns the number of bytes written, or a
+// *QuotaError (leaving any previous copy in place) when the blob is larger
+// than the store's limits allow, and a *SpaceError when the disk is too full
+// for it.
+func (s *Store) SaveAttachment(ref string, src io.Reader) (int64, error) {
+	path, err := s.attachmentPath(ref)
+	if err != nil {
+		return 0, err
+	}
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	if max := s.limits.MaxAttachmentBytes; max > 0 {
+		// One byte over is enough to know the blob is too big.
+		src = io.LimitReader(src, max+1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	if err := checkSpace(filepath.Dir(path), spaceHeadroom); err != nil {
+		return 0, err
+	}
+	n, err := writeFileAtomic(path, src, s.attachmentQuota(path))
+	return n, spaceFailure(err, filepath.Dir(path))
+}
+
+// OpenAttachment opens the stored blob for ref. It returns ErrNotFound when
+// the blob has not been imported.
+func (s *Store) OpenAttachment(ref string) (*os.File, error) {
+	path, err := s.attachmentPath(ref)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// OpenThumbnail opens the cached thumbnail of ref at width, returning
+// ErrNotFound when it has not been generated yet.
+func (s *Store) OpenThumbnail(ref string, width int) (*os.File, error) {
+	path, err := s.thumbnailPath(ref, width)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// SaveThumbnail caches a generated thumbnail of ref at width.
+func (s *Store) SaveThumbnail(ref string, width int, src io.Reader) error {
+	path, err := s.thumbnailPath(ref, width)
+	if err != nil {
+		return err
+	}
+	_, err = writeFileAtomic(path, src, nil)
+	return err
+}
+
+// FindAttachment returns the metadata recorded for ref by whichever
+// conversation references it.
+func (s *Store) FindAttachment(ref string) (models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, convo := range s.conversations {
+		for _, att := range convo.Attachments {
+			if att.Ref == ref {
+				return att, nil
+			}
+		}
+	}
+	return models.Attachment{}, ErrNotFound
+}
+
+// LocateAttachment returns the on-disk path of the stored blob for ref.
+func (s *Store) LocateAttachment(ref string) (string, bool) {
+	path, err := s.attachmentPath(ref)
+	if err != nil {
+		return "", false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// HasAttachment reports whether a blob for ref has been stored.
+func (s *Store) HasAttachment(ref string) bool {
+	path, err := s.attachmentPath(ref)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// carryAttachmentText keeps OCR text from a previous import so re-importing
+// does not force every image through OCR again.
+func carryAttachmentText(next, prev []models.Attachment) {
+	if len(prev) == 0 {
+		return
+	}
+	text := make(map[string]string, len(prev))
+	for _, att := range prev {
+		if att.Text != "" {
+			text[att.Ref] = att.Text
+		}
+	}
+	for i := range next {
+		if next[i].Text == "" {
+			next[i].Text = text[next[i].Ref]
+		}
+	}
+}
+
+func (s *Store) attachmentPath(ref string) (string, error) {
+	if !validRef(ref) {
+		return "", ErrInvalidRef
+	}
+	return filepath.Join(s.AttachmentDir(), ref), nil
+}
+
+func (s *Store) thumbnailPath(ref string, width int) (string, error) {
+	if !validRef(ref) {
+		return "", ErrInvalidRef
+	}
+	return filepath.Join(s.AttachmentDir(), "thumbs", fmt.Sprintf("%s-w%d.jpg", ref, width)), nil
+}
+
+// writeFileAtomic writes src to a temporary file in the destination directory
+// and renames it into place, so concurrent readers never see a partial file.
+// When check is non-nil it is given the size written and can veto the rename.
+func writeFileAtomic(path string, src io.Reader, check func(n int64) error) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := file.Name()
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	n, err := io.Copy(file, src)
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if check != nil {
+		if err := check(n); err != nil {
+			os.Remove(tmpPath)
+			return 0, err
+		}
+	}
+
+	return n, os.Rename(tmpPath, path)
+}
+
+func validRef(ref string) bool {
+	if ref == "" || ref == "." || ref == ".." || len(ref) > 200 {
+		return false
+	}
+	return strings.IndexFunc(ref, func(r rune) bool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return false
+		case r == '-' || r == '_' || r == '.':
+			return false
+		}
+		return true
+	}) < 0
+}
@@ -0,0 +1,775 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"zatGPT/internal/models"
+)
+
+// boltSchemaVersion is stored under "schema" in the meta bucket. Version 2
+// filled the index buckets in for files earlier builds left without them.
+const boltSchemaVersion = 2
+
+// boltTimeout is how long opening a bolt file waits for its file lock, which
+// a writer holds for as long as it has the file open.
+const boltTimeout = 5 * time.Second
+
+// boltReadWait is how long a read-only open waits for the file lock before
+// reading a copy of the file instead, and boltCopyAttempts how many copies
+// it takes before giving up on one the writer left alone.
+const (
+	boltReadWait     = 100 * time.Millisecond
+	boltCopyAttempts = 20
+)
+
+// The buckets of a bolt store. A conversation's record in bucketConversations
+// leaves out its messages, which live in a bucket of their own under
+// bucketMessages, keyed by position; the two index buckets hold empty values
+// under "<updatedAt>\x00<id>" and "<tag>\x00<id>" so a date range or a tag
+// can be walked in key order without decoding any conversation.
+var (
+	bucketMeta          = []byte("meta")
+	bucketConversations = []byte("conversations")
+	bucketMessages      = []byte("messages")
+	bucketByUpdated     = []byte("by_updated")
+	bucketByTag         = []byte("by_tag")
+	bucketTrash         = []byte("trash")
+	bucketLinkChecks    = []byte("link_checks")
+	bucketImports       = []byte("imports")
+	bucketSummaries     = []byte("summaries")
+	bucketEmbeddings    = []byte("embeddings")
+	bucketRevisions     = []byte("revisions")
+	bucketShares        = []byte("shares")
+	bucketSavedSearches = []byte("saved_searches")
+
+	boltBuckets = [][]byte{bucketMeta, bucketConversations, bucketMessages, bucketByUpdated, bucketByTag, bucketTrash, bucketLinkChecks, bucketImports, bucketSummaries, bucketEmbeddings, bucketRevisions, bucketShares, bucketSavedSearches}
+)
+
+// boltMagic is the little-endian meta page magic at offset 16 of every bolt
+// file.
+var boltMagic = []byte{0xed, 0xda, 0x0c, 0xed}
+
+// boltBackend keeps the store in a bbolt file: pure Go like the JSON store,
+// but a save only rewrites the records it touches, and appending a message
+// writes that message alone.
+type boltBackend struct {
+	path     string
+	readOnly bool
+	db       *bolt.DB
+}
+
+func openBolt(path string, readOnly bool) (*boltBackend, error) {
+	b := &boltBackend{path: path, readOnly: readOnly}
+	if readOnly {
+		// The file is opened just for Load, so a reader holds the shared
+		// file lock as briefly as it can.
+		return b, nil
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return nil, boltOpenError(path, err)
+	}
+	b.db = db
+	if err := b.db.Update(migrateBolt); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+func boltOpenError(path string, err error) error {
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("%s is held open by another process (only one process at a time can write a bolt store): %w", path, err)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+func migrateBolt(tx *bolt.Tx) error {
+	for _, name := range boltBuckets {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	meta := tx.Bucket(bucketMeta)
+	version := boltSchema(tx)
+	if version > boltSchemaVersion {
+		return fmt.Errorf("bolt schema v%d is newer than this build supports (v%d)", version, boltSchemaVersion)
+	}
+	if version < 2 {
+		if err := reindexBolt(tx); err != nil {
+			return err
+		}
+	}
+	return meta.Put([]byte("schema"), binary.BigEndian.AppendUint64(nil, boltSchemaVersion))
+}
+
+// boltSchema is the schema version stored in the file, 0 for a new one.
+func boltSchema(tx *bolt.Tx) uint64 {
+	meta := tx.Bucket(bucketMeta)
+	if meta == nil {
+		return 0
+	}
+	stored := meta.Get([]byte("schema"))
+	if len(stored) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(stored)
+}
+
+// reindexBolt rebuilds the index buckets from the conversations, for files
+// written by builds that dropped them or stopped keeping them up to date.
+func reindexBolt(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketByUpdated, bucketByTag} {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(bucketConversations).ForEach(func(_, data []byte) error {
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return err
+		}
+		return indexBoltConversation(tx, conversation)
+	})
+}
+
+// Load reads the file. Opened read-only while a server holds the file
+// lock, it reads a copy of the file taken between two of the server's
+// writes instead, so read-only commands work next to a live server.
+func (b *boltBackend) Load() (Contents, error) {
+	if b.db != nil {
+		return b.load(b.db)
+	}
+	if _, err := os.Stat(b.path); errors.Is(err, os.ErrNotExist) {
+		return Contents{}, nil
+	}
+	db, err := bolt.Open(b.path, 0o644, &bolt.Options{Timeout: boltReadWait, ReadOnly: true})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return b.loadCopy()
+	}
+	if err != nil {
+		return Contents{}, boltOpenError(b.path, err)
+	}
+	defer db.Close()
+	return b.load(db)
+}
+
+// loadCopy copies the file aside and loads the copy. A copy the writer
+// changed the file during is thrown away and taken again.
+func (b *boltBackend) loadCopy() (Contents, error) {
+	for range boltCopyAttempts {
+		path, err := copyUnchanged(b.path)
+		if errors.Is(err, errChangedWhileCopying) {
+			time.Sleep(boltReadWait)
+			continue
+		}
+		if err != nil {
+			return Contents{}, err
+		}
+		contents, err := loadBoltCopy(path, b.path)
+		os.Remove(path)
+		return contents, err
+	}
+	return Contents{}, fmt.Errorf("%s: the server holding it open kept writing while it was copied; try again", b.path)
+}
+
+func loadBoltCopy(path, name string) (Contents, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltTimeout, ReadOnly: true})
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s: %w", name, err)
+	}
+	defer db.Close()
+	return (&boltBackend{path: name, readOnly: true}).load(db)
+}
+
+// errChangedWhileCopying reports a copy that may mix two versions of the
+// file.
+var errChangedWhileCopying = errors.New("file changed while it was copied")
+
+// copyUnchanged copies the file at path to a temporary file and returns
+// its path, failing with errChangedWhileCopying when the file's size or
+// modification time moved during the copy.
+func copyUnchanged(path string) (string, error) {
+	before, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp("", "zatgpt-*.bolt")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		var after os.FileInfo
+		if after, err = os.Stat(path); err == nil && (after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime())) {
+			err = errChangedWhileCopying
+		}
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+func (b *boltBackend) load(db *bolt.DB) (Contents, error) {
+	var payload Contents
+	err := db.View(func(tx *bolt.Tx) error {
+		conversations, messages := tx.Bucket(bucketConversations), tx.Bucket(bucketMessages)
+		if conversations == nil {
+			return errors.New("not a zatGPT bolt store")
+		}
+		err := conversations.ForEach(func(id, data []byte) error {
+			var conversation models.Conversation
+			if err := json.Unmarshal(data, &conversation); err != nil {
+				return err
+			}
+			if err := loadBoltMessages(messages, id, &conversation); err != nil {
+				return err
+			}
+			payload.Conversations = append(payload.Conversations, conversation)
+			return nil
+		})
+		if err == nil {
+			err = loadBucket(tx, bucketTrash, &payload.Trash)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketLinkChecks, &payload.LinkChecks)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketImports, &payload.Imports)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketSummaries, &payload.Summaries)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketEmbeddings, &payload.Embeddings)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketRevisions, &payload.Revisions)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketShares, &payload.Shares)
+		}
+		if err == nil {
+			err = loadBucket(tx, bucketSavedSearches, &payload.SavedSearches)
+		}
+		return err
+	})
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s: %w", b.path, err)
+	}
+	return payload, nil
+}
+
+// loadBoltMessages appends the messages stored for the conversation id to
+// conversation.
+func loadBoltMessages(messages *bolt.Bucket, id []byte, conversation *models.Conversation) error {
+	bucket := messages.Bucket(id)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(_, data []byte) error {
+		var message models.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		conversation.Messages = append(conversation.Messages, message)
+		return nil
+	})
+}
+
+// loadBucket decodes every value of a bucket, in key order, onto records.
+func loadBucket[T any](tx *bolt.Tx, name []byte, records *[]T) error {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(_, data []byte) error {
+		var record T
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		*records = append(*records, record)
+		return nil
+	})
+}
+
+// Save applies change in one transaction. The size passed to check is the
+// file size the transaction would leave.
+func (b *boltBackend) Save(change Change, contents func() Contents, check func(size int64) error) (int64, error) {
+	if b.db == nil {
+		return 0, ErrReadOnly
+	}
+	var size int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if change.Replace {
+			for _, name := range boltBuckets[1:] {
+				if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+					return err
+				}
+			}
+			if err := migrateBolt(tx); err != nil {
+				return err
+			}
+			all := contents()
+			change = Change{
+				Conversations: all.Conversations,
+				LinkChecks:    all.LinkChecks,
+				Imports:       all.Imports,
+				Summaries:     all.Summaries,
+				Embeddings:    all.Embeddings,
+				Revisions:     all.Revisions,
+				Trashed:       all.Trash,
+				Shares:        all.Shares,
+				SavedSearches: all.SavedSearches,
+			}
+		}
+		if err := applyBoltChange(tx, change); err != nil {
+			return err
+		}
+		size = tx.Size()
+		if check != nil {
+			return check(size)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func applyBoltChange(tx *bolt.Tx, change Change) error {
+	trash := tx.Bucket(bucketTrash)
+	for _, conversation := range change.Conversations {
+		if err := putBoltConversation(tx, conversation); err != nil {
+			return err
+		}
+		if err := trash.Delete([]byte(conversation.ID)); err != nil {
+			return err
+		}
+	}
+	for _, conversation := range change.Trashed {
+		if err := putJSON(trash, []byte(conversation.ID), conversation); err != nil {
+			return err
+		}
+		if err := removeBoltConversation(tx, conversation.ID); err != nil {
+			return err
+		}
+	}
+	summaries := tx.Bucket(bucketSummaries)
+	embeddings := tx.Bucket(bucketEmbeddings)
+	revisions := tx.Bucket(bucketRevisions)
+	shares := tx.Bucket(bucketShares)
+	for _, id := range change.Deleted {
+		if err := removeBoltConversation(tx, id); err != nil {
+			return err
+		}
+		if err := trash.Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := deletePrefix(summaries, []byte(id+"\x00")); err != nil {
+			return err
+		}
+		if err := deletePrefix(embeddings, []byte(id+"\x00")); err != nil {
+			return err
+		}
+		if err := deletePrefix(revisions, []byte(id+"\x00")); err != nil {
+			return err
+		}
+		if err := deletePrefix(shares, []byte(id+"\x00")); err != nil {
+			return err
+		}
+	}
+	checks := tx.Bucket(bucketLinkChecks)
+	for _, check := range change.LinkChecks {
+		if err := putJSON(checks, []byte(check.URL), check); err != nil {
+			return err
+		}
+	}
+	imports := tx.Bucket(bucketImports)
+	for _, record := range change.Imports {
+		seq, err := imports.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := putJSON(imports, binary.BigEndian.AppendUint64(nil, seq), record); err != nil {
+			return err
+		}
+	}
+	for _, summary := range change.Summaries {
+		if err := putJSON(summaries, []byte(summary.ConversationID+"\x00"+summary.Method), summary); err != nil {
+			return err
+		}
+	}
+	for _, embedding := range change.Embeddings {
+		if err := putJSON(embeddings, []byte(embedding.ConversationID+"\x00"+embedding.Model), embedding); err != nil {
+			return err
+		}
+	}
+	for _, revision := range change.Revisions {
+		if err := putJSON(revisions, boltRevisionKey(revision.ConversationID, revision.Number), revision); err != nil {
+			return err
+		}
+		if dropped := revision.Number - MaxRevisions; dropped > 0 {
+			if err := revisions.Delete(boltRevisionKey(revision.ConversationID, dropped)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, share := range change.Shares {
+		if err := putJSON(shares, []byte(share.ConversationID+"\x00"+share.Token), share); err != nil {
+			return err
+		}
+	}
+	for _, share := range change.Unshared {
+		if err := shares.Delete([]byte(share.ConversationID + "\x00" + share.Token)); err != nil {
+			return err
+		}
+	}
+	searches := tx.Bucket(bucketSavedSearches)
+	for _, search := range change.SavedSearches {
+		if err := putJSON(searches, []byte(search.ID), search); err != nil {
+			return err
+		}
+	}
+	for _, id := range change.UnsavedSearches {
+		if err := searches.Delete([]byte(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putBoltConversation stores conversation, writing only the messages whose
+// encoding changed.
+func putBoltConversation(tx *bolt.Tx, conversation models.Conversation) error {
+	id := []byte(conversation.ID)
+	if err := unindexBoltConversation(tx, id); err != nil {
+		return err
+	}
+	record := conversation
+	record.Messages = nil
+	if err := putJSON(tx.Bucket(bucketConversations), id, record); err != nil {
+		return err
+	}
+	if err := indexBoltConversation(tx, conversation); err != nil {
+		return err
+	}
+
+	messages, err := tx.Bucket(bucketMessages).CreateBucketIfNotExists(id)
+	if err != nil {
+		return err
+	}
+	for i, message := range conversation.Messages {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		key := binary.BigEndian.AppendUint64(nil, uint64(i))
+		if bytes.Equal(messages.Get(key), data) {
+			continue
+		}
+		if err := messages.Put(key, data); err != nil {
+			return err
+		}
+	}
+	return deleteFrom(messages, binary.BigEndian.AppendUint64(nil, uint64(len(conversation.Messages))))
+}
+
+// removeBoltConversation deletes a conversation with its messages and index
+// keys; a missing one is not an error.
+func removeBoltConversation(tx *bolt.Tx, id string) error {
+	if err := unindexBoltConversation(tx, []byte(id)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketConversations).Delete([]byte(id)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketMessages).DeleteBucket([]byte(id)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	return nil
+}
+
+// updatedKey and tagKey are a conversation's keys in the index buckets.
+func updatedKey(conversation models.Conversation) []byte {
+	return []byte(sortableTime(conversation.UpdatedAt) + "\x00" + conversation.ID)
+}
+
+func tagKey(tag, id string) []byte {
+	return []byte(tag + "\x00" + id)
+}
+
+// indexBoltConversation adds conversation's keys to the index buckets.
+func indexBoltConversation(tx *bolt.Tx, conversation models.Conversation) error {
+	if err := tx.Bucket(bucketByUpdated).Put(updatedKey(conversation), []byte{}); err != nil {
+		return err
+	}
+	byTag := tx.Bucket(bucketByTag)
+	for _, tag := range conversation.Tags {
+		if err := byTag.Put(tagKey(tag, conversation.ID), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexBoltConversation removes the index keys of the conversation
+// stored under id, read from its current record.
+func unindexBoltConversation(tx *bolt.Tx, id []byte) error {
+	data := tx.Bucket(bucketConversations).Get(id)
+	if data == nil {
+		return nil
+	}
+	var previous models.Conversation
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketByUpdated).Delete(updatedKey(previous)); err != nil {
+		return err
+	}
+	byTag := tx.Bucket(bucketByTag)
+	for _, tag := range previous.Tags {
+		if err := byTag.Delete(tagKey(tag, previous.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putJSON stores the JSON encoding of value under key.
+func putJSON(bucket *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// deletePrefix deletes every key of bucket that starts with prefix.
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteFrom deletes every key of bucket from start on.
+func deleteFrom(bucket *bolt.Bucket, start []byte) error {
+	c := bucket.Cursor()
+	for k, _ := c.Seek(start); k != nil; k, _ = c.Seek(start) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// view runs fn in a read transaction. Opened read-only, the backend opens
+// the file just for fn; a file that does not exist yet is not read.
+func (b *boltBackend) view(fn func(tx *bolt.Tx) error) error {
+	db := b.db
+	if db == nil {
+		if _, err := os.Stat(b.path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		opened, err := bolt.Open(b.path, 0o644, &bolt.Options{Timeout: boltTimeout, ReadOnly: true})
+		if err != nil {
+			return boltOpenError(b.path, err)
+		}
+		defer opened.Close()
+		db = opened
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketConversations) == nil {
+			return errors.New("not a zatGPT bolt store")
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.path, err)
+	}
+	return nil
+}
+
+// List walks the index buckets for a date range or tags and decodes only
+// the records they lead to, which leave out the messages. A file opened
+// read-only from before schema version 2 may lack the indexes, so all of
+// its records are decoded instead.
+func (b *boltBackend) List(query Query) ([]models.Conversation, error) {
+	var listed []models.Conversation
+	err := b.view(func(tx *bolt.Tx) error {
+		conversations := tx.Bucket(bucketConversations)
+		add := func(data []byte) error {
+			var conversation models.Conversation
+			if err := json.Unmarshal(data, &conversation); err != nil {
+				return err
+			}
+			if query.Match(conversation) {
+				listed = append(listed, conversation)
+			}
+			return nil
+		}
+		ids, ok := boltIndexed(tx, query)
+		if !ok {
+			return conversations.ForEach(func(_, data []byte) error { return add(data) })
+		}
+		for id := range ids {
+			if data := conversations.Get([]byte(id)); data != nil {
+				if err := add(data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByUpdated(listed)
+	return listed, nil
+}
+
+// boltIndexed returns the IDs of the conversations the index buckets hold
+// for query's date range and every one of its tags, or false when query
+// uses neither or the file has no indexes to walk.
+func boltIndexed(tx *bolt.Tx, query Query) (map[string]bool, bool) {
+	if boltSchema(tx) < 2 || query.From.IsZero() && query.To.IsZero() && len(query.Tags) == 0 {
+		return nil, false
+	}
+	var found map[string]bool
+	keep := func(matched map[string]bool) {
+		if found == nil {
+			found = matched
+			return
+		}
+		for id := range found {
+			if !matched[id] {
+				delete(found, id)
+			}
+		}
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		var start, end []byte
+		if !query.From.IsZero() {
+			start = []byte(sortableTime(query.From))
+		}
+		if !query.To.IsZero() {
+			end = []byte(sortableTime(query.To))
+		}
+		matched := make(map[string]bool)
+		c := tx.Bucket(bucketByUpdated).Cursor()
+		for k, _ := c.Seek(start); k != nil; k, _ = c.Next() {
+			updated, id, _ := bytes.Cut(k, []byte{0})
+			if end != nil && bytes.Compare(updated, end) >= 0 {
+				break
+			}
+			matched[string(id)] = true
+		}
+		keep(matched)
+	}
+	for _, tag := range query.Tags {
+		prefix := tagKey(tag, "")
+		matched := make(map[string]bool)
+		c := tx.Bucket(bucketByTag).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			matched[string(k[len(prefix):])] = true
+		}
+		keep(matched)
+	}
+	return found, true
+}
+
+func (b *boltBackend) Get(id string) (models.Conversation, error) {
+	var conversation models.Conversation
+	found := false
+	err := b.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketConversations).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return err
+		}
+		return loadBoltMessages(tx.Bucket(bucketMessages), []byte(id), &conversation)
+	})
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if !found {
+		return models.Conversation{}, ErrNotFound
+	}
+	return conversation, nil
+}
+
+func (b *boltBackend) Upsert(conversations []models.Conversation) error {
+	_, err := b.Save(Change{Conversations: conversations}, nil, nil)
+	return err
+}
+
+func (b *boltBackend) Delete(ids []string) error {
+	_, err := b.Save(Change{Deleted: ids}, nil, nil)
+	return err
+}
+
+// Search decodes each conversation with its messages in turn, keeping
+// only those that match.
+func (b *boltBackend) Search(terms []string) ([]models.Conversation, error) {
+	terms = searchTerms(terms)
+	var found []models.Conversation
+	err := b.view(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		return tx.Bucket(bucketConversations).ForEach(func(id, data []byte) error {
+			var conversation models.Conversation
+			if err := json.Unmarshal(data, &conversation); err != nil {
+				return err
+			}
+			if err := loadBoltMessages(messages, id, &conversation); err != nil {
+				return err
+			}
+			if matchesTerms(conversation, terms) {
+				conversation.Messages = nil
+				found = append(found, conversation)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByUpdated(found)
+	return found, nil
+}
+
+func (b *boltBackend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
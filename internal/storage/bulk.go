@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// The actions Bulk applies.
+const (
+	BulkDelete  = "delete"
+	BulkTagging = "tag"
+	BulkArchive = "archive"
+	BulkExport  = "export"
+)
+
+// BulkActions lists the actions Bulk accepts.
+var BulkActions = []string{BulkDelete, BulkTagging, BulkArchive, BulkExport}
+
+// The outcome of a bulk action for one ID.
+const (
+	BulkOK        = "ok"
+	BulkUnchanged = "unchanged"
+	BulkNotFound  = "not_found"
+)
+
+// BulkOptions parameterise the tag and archive actions.
+type BulkOptions struct {
+	Add      []string
+	Remove   []string
+	Archived bool
+}
+
+// BulkItem is the outcome of a bulk action for one ID, in request order.
+type BulkItem struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// BulkResult reports a Bulk run. Exports carry the full records of the
+// conversations found.
+type BulkResult struct {
+	Action        string                `json:"action"`
+	Updated       int                   `json:"updated"`
+	Results       []BulkItem            `json:"results"`
+	Conversations []models.Conversation `json:"conversations,omitempty"`
+}
+
+// Bulk applies action to every conversation in ids under a single lock and
+// persists the outcome with a single save, so either every change lands or
+// none does. Unknown IDs are reported as not_found and do not fail the run;
+// a conversation the action leaves as it was is reported as unchanged.
+func (s *Store) Bulk(action string, ids []string, opts BulkOptions) (BulkResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := BulkResult{Action: action, Results: make([]BulkItem, 0, len(ids))}
+	found := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		item := BulkItem{ID: id, Status: BulkOK}
+		switch _, ok := s.conversations[id]; {
+		case !ok:
+			item.Status = BulkNotFound
+		case !seen[id]:
+			seen[id] = true
+			found = append(found, id)
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	switch action {
+	case BulkExport:
+		for _, id := range found {
+			result.Conversations = append(result.Conversations, s.conversations[id])
+		}
+		return result, nil
+	case BulkDelete:
+		if len(found) > 0 {
+			if err := s.trashLocked(found); err != nil {
+				return BulkResult{}, err
+			}
+		}
+		result.Updated = len(found)
+		return result, nil
+	case BulkTagging, BulkArchive:
+	default:
+		return BulkResult{}, fmt.Errorf("unknown bulk action %q", action)
+	}
+
+	if s.readOnly {
+		return BulkResult{}, ErrReadOnly
+	}
+	add, remove := NormalizeTags(opts.Add), NormalizeTags(opts.Remove)
+	now := time.Now().UTC()
+	changed := make(map[string]bool, len(found))
+	var change Change
+	var previous []models.Conversation
+	for _, id := range found {
+		convo := s.conversations[id]
+		next := convo
+		if action == BulkTagging {
+			next.Tags = applyTagChanges(convo.Tags, add, remove)
+			if equalTags(convo.Tags, next.Tags) {
+				continue
+			}
+		} else {
+			if convo.Archived == opts.Archived {
+				continue
+			}
+			next.Archived = opts.Archived
+		}
+		next.UpdatedAt = now
+		previous = append(previous, convo)
+		s.putLocked(next)
+		change.Conversations = append(change.Conversations, next)
+		changed[id] = true
+	}
+
+	if len(change.Conversations) > 0 {
+		if err := s.saveLocked(change); err != nil {
+			for _, convo := range previous {
+				s.putLocked(convo)
+			}
+			return BulkResult{}, err
+		}
+	}
+	for i, item := range result.Results {
+		if item.Status == BulkOK && !changed[item.ID] {
+			result.Results[i].Status = BulkUnchanged
+		}
+	}
+	result.Updated = len(change.Conversations)
+	return result, nil
+}
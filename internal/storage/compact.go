@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltCompactTxSize is how many bytes Compact copies into a bolt file per
+// transaction.
+const boltCompactTxSize = 64 << 20
+
+// CompactResult reports what Compact did to the store file.
+type CompactResult struct {
+	Engine string `json:"engine"`
+	// Encoding is the JSON store file's encoding afterwards.
+	Encoding string `json:"encoding,omitempty"`
+	Before   int64  `json:"before"`
+	After    int64  `json:"after"`
+}
+
+// Compact rewrites the store file as small as its engine allows. A JSON
+// store is rewritten in encoding, which stays for later saves; empty
+// picks EncodingCompact, or EncodingGzip for a file that already is. A
+// SQLite database is vacuumed and a bolt file copied into a fresh one,
+// dropping the free pages earlier writes left behind; encoding must then be
+// empty. A PostgreSQL store's tables are vacuumed too, which frees the
+// space for reuse without returning it to the system.
+func (s *Store) Compact(encoding string) (CompactResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := CompactResult{Engine: s.engine}
+	if s.readOnly {
+		return result, ErrReadOnly
+	}
+	if !ValidEncoding(encoding) {
+		return result, fmt.Errorf("unknown store encoding %q (want %s, %s or %s)", encoding, EncodingIndented, EncodingCompact, EncodingGzip)
+	}
+	size := func() int64 {
+		n, _ := fileSize(s.path)
+		return n
+	}
+	if b, ok := s.backend.(*postgresBackend); ok {
+		size = func() int64 {
+			n, _ := postgresSize(b.db)
+			return n
+		}
+	}
+	result.Before = size()
+
+	var err error
+	switch backend := s.backend.(type) {
+	case *jsonBackend:
+		previous := backend.encoding
+		switch {
+		case encoding != "":
+			backend.encoding = encoding
+		case previous != EncodingGzip:
+			backend.encoding = EncodingCompact
+		}
+		if err = s.saveLocked(Change{Replace: true}); err != nil {
+			backend.encoding = previous
+		}
+		result.Encoding = backend.encoding
+	case *sqliteBackend:
+		if encoding != "" {
+			return result, fmt.Errorf("the %s encoding only applies to %s stores, not %s", encoding, EngineJSON, s.engine)
+		}
+		_, err = backend.db.Exec("VACUUM")
+	case *boltBackend:
+		if encoding != "" {
+			return result, fmt.Errorf("the %s encoding only applies to %s stores, not %s", encoding, EngineJSON, s.engine)
+		}
+		err = backend.compact()
+	case *postgresBackend:
+		if encoding != "" {
+			return result, fmt.Errorf("the %s encoding only applies to %s stores, not %s", encoding, EngineJSON, s.engine)
+		}
+		err = backend.vacuum()
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.After = size()
+	s.fileBytes = result.After
+	return result, nil
+}
+
+// compact copies the bolt file into a new one, which bolt lays out without
+// free pages, and swaps it in.
+func (b *boltBackend) compact() error {
+	tmpPath := b.path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0o644, &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return err
+	}
+	err = bolt.Compact(dst, b.db, boltCompactTxSize)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := b.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	renameErr := os.Rename(tmpPath, b.path)
+	if renameErr != nil {
+		os.Remove(tmpPath)
+	}
+	// Reopen whichever file is in place, so the store stays usable.
+	db, err := bolt.Open(b.path, 0o644, &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		b.db = nil
+		return boltOpenError(b.path, err)
+	}
+	b.db = db
+	return renameErr
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+)
+
+// conversationStats works out the stats of convo's messages.
+func conversationStats(convo models.Conversation) *models.ConversationStats {
+	stats := &models.ConversationStats{MessagesByRole: make(map[string]int)}
+	var first, previous time.Time
+	var gap time.Duration
+	length := 0
+	for _, msg := range convo.Messages {
+		stats.MessagesByRole[msg.Author]++
+		if msg.Tokens > 0 {
+			if stats.TokensByRole == nil {
+				stats.TokensByRole = make(map[string]int)
+			}
+			stats.TokensByRole[msg.Author] += msg.Tokens
+			stats.Tokens += msg.Tokens
+		}
+		length += utf8.RuneCountInString(msg.Content)
+		if msg.Model != "" && msg.Author == models.AuthorAssistant && !slices.Contains(stats.Models, msg.Model) {
+			stats.Models = append(stats.Models, msg.Model)
+		}
+
+		if msg.CreatedAt.IsZero() {
+			continue
+		}
+		if first.IsZero() {
+			first = msg.CreatedAt
+		} else if wait := msg.CreatedAt.Sub(previous); wait > gap {
+			gap = wait
+		}
+		previous = msg.CreatedAt
+	}
+	if len(convo.Messages) > 0 {
+		stats.AverageLength = length / len(convo.Messages)
+	}
+	if !first.IsZero() && previous.After(first) {
+		stats.DurationSeconds = int64(previous.Sub(first) / time.Second)
+	}
+	stats.LongestGapSeconds = int64(gap / time.Second)
+	return stats
+}
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded,
+// or was issued for a different sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort keys accepted by ListOptions.Sort.
+const (
+	SortUpdatedAt    = "updatedAt"
+	SortCreatedAt    = "createdAt"
+	SortTitle        = "title"
+	SortMessageCount = "messageCount"
+)
+
+// SortKeys lists every sort key, for validation messages.
+var SortKeys = []string{SortUpdatedAt, SortCreatedAt, SortTitle, SortMessageCount}
+
+// Sort orders accepted by ListOptions.Order.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ListOptions selects a window of the conversation list. Sort defaults to
+// SortUpdatedAt; Order defaults to ascending for titles and descending for
+// everything else. A page starts after Cursor (from a previous page's
+// NextCursor) or, without one, after skipping Offset conversations. Limit
+// <= 0 returns the rest of the list. Tags, when set, keeps only the
+// conversations carrying every one of them; Archived and Starred, when
+// set, keep only the conversations whose flag matches. From (inclusive)
+// and To (exclusive) bound the day a conversation started, as in query's
+// after: and before:; UpdatedFrom (inclusive) and UpdatedTo (exclusive)
+// bound when it was last updated. HasRole keeps conversations with at least
+// one message by that author, and MinMessages those with at least that
+// many messages. Where, when set, narrows the list further.
+type ListOptions struct {
+	Sort        string
+	Order       string
+	Cursor      string
+	Offset      int
+	Limit       int
+	Tags        []string
+	Archived    *bool
+	Starred     *bool
+	From        time.Time
+	To          time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+	HasRole     string
+	MinMessages int
+	Where       func(models.Conversation) bool
+}
+
+// ValidSort reports whether sort is one of SortKeys or empty.
+func ValidSort(sort string) bool {
+	return sort == "" || slices.Contains(SortKeys, sort)
+}
+
+// Page is a window of conversations plus the cursor for the next window.
+// NextCursor is empty once the final page has been returned. Total counts
+// every conversation in the list, not just this window. MessageCounts
+// holds each listed conversation's message count by ID, as the
+// conversations come without their messages.
+type Page struct {
+	Conversations []models.Conversation
+	NextCursor    string
+	Total         int
+	MessageCounts map[string]int
+}
+
+// cursorKey is a position in the list: the sort value of the last item
+// returned, and its ID to break ties. Sort and Order are empty for the
+// default updatedAt-descending list, which keeps older cursors valid.
+type cursorKey struct {
+	Sort      string    `json:"s,omitempty"`
+	Order     string    `json:"o,omitempty"`
+	UpdatedAt time.Time `json:"u,omitzero"`
+	Title     string    `json:"t,omitempty"`
+	Count     int       `json:"n,omitempty"`
+	ID        string    `json:"i"`
+}
+
+// ListPage returns one window of the conversation list, sorted as opts
+// asks with the ID ascending between equal values. Because a cursor records
+// the last item's position rather than an offset, inserts made between
+// requests never cause items to be skipped or repeated; offsets are simpler
+// but can.
+//
+// A list narrowed by tags or by when conversations were updated is looked
+// up in the backend's indexes, except in a JSON store, which has none, and
+// a read-only one, whose backend may not be open; the conversations found
+// are then taken from memory, as for every other list.
+func (s *Store) ListPage(opts ListOptions) (Page, error) {
+	tags := NormalizeTags(opts.Tags)
+	query := Query{From: opts.UpdatedFrom, To: opts.UpdatedTo, Tags: tags}
+	s.mu.RLock()
+	candidates := s.conversations
+	if !query.IsEmpty() && s.engine != EngineJSON && !s.readOnly {
+		found, err := s.backend.List(query)
+		if err != nil {
+			s.mu.RUnlock()
+			return Page{}, err
+		}
+		candidates = make(map[string]models.Conversation, len(found))
+		for _, item := range found {
+			if current, ok := s.conversations[item.ID]; ok {
+				candidates[item.ID] = current
+			}
+		}
+	}
+	items := make([]models.Conversation, 0, len(candidates))
+	counts := make(map[string]int, len(candidates))
+	for _, item := range candidates {
+		if !query.Match(item) || !opts.matches(item) {
+			continue
+		}
+		counts[item.ID] = len(item.Messages)
+		item.Messages = nil
+		item.Tree = nil
+		item.Stats = nil
+		items = append(items, item)
+	}
+	s.mu.RUnlock()
+
+	return paginate(items, counts, opts)
+}
+
+// Match reports whether item passes every filter of opts, for narrowing
+// results ListPage does not produce, such as search hits.
+func (opts ListOptions) Match(item models.Conversation) bool {
+	return Query{From: opts.UpdatedFrom, To: opts.UpdatedTo, Tags: NormalizeTags(opts.Tags)}.Match(item) && opts.matches(item)
+}
+
+// matches applies the filters of opts other than Tags, UpdatedFrom and
+// UpdatedTo, which ListPage hands the backend.
+func (opts ListOptions) matches(item models.Conversation) bool {
+	if opts.Where != nil && !opts.Where(item) {
+		return false
+	}
+	if !matchFlag(opts.Archived, item.Archived) || !matchFlag(opts.Starred, item.Starred) {
+		return false
+	}
+	if len(item.Messages) < opts.MinMessages {
+		return false
+	}
+	if !opts.From.IsZero() || !opts.To.IsZero() {
+		started := startedAt(item)
+		if !opts.From.IsZero() && started.Before(opts.From) || !opts.To.IsZero() && !started.Before(opts.To) {
+			return false
+		}
+	}
+	if opts.HasRole != "" {
+		return slices.ContainsFunc(item.Messages, func(msg models.Message) bool { return msg.Author == opts.HasRole })
+	}
+	return true
+}
+
+// startedAt is the day a conversation started, falling back to CreatedAt
+// when DateStarted is missing or malformed.
+func startedAt(item models.Conversation) time.Time {
+	if t, err := time.Parse("2006-01-02", item.DateStarted); err == nil {
+		return t
+	}
+	return item.CreatedAt
+}
+
+// matchFlag reports whether value passes a filter that is either unset or
+// asks for exactly that value.
+func matchFlag(want *bool, value bool) bool {
+	return want == nil || *want == value
+}
+
+// paginate sorts items as opts asks and cuts out the window. counts holds
+// message counts by ID for SortMessageCount, as items come without
+// messages.
+func paginate(items []models.Conversation, counts map[string]int, opts ListOptions) (Page, error) {
+	order := listOrder{sort: opts.Sort, desc: opts.Order == OrderDesc, counts: counts}
+	if order.sort == "" {
+		order.sort = SortUpdatedAt
+	}
+	if opts.Order == "" {
+		order.desc = order.sort != SortTitle
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return order.compare(order.key(items[i]), order.key(items[j])) < 0
+	})
+
+	start := min(max(opts.Offset, 0), len(items))
+	if opts.Cursor != "" {
+		key, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if key.Sort != order.cursorSort() || key.Order != order.cursorOrder() {
+			return Page{}, ErrInvalidCursor
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			return order.compare(order.key(items[i]), key) > 0
+		})
+	}
+
+	end := len(items)
+	if opts.Limit > 0 && start+opts.Limit < end {
+		end = start + opts.Limit
+	}
+
+	page := Page{Conversations: items[start:end], Total: len(items), MessageCounts: counts}
+	if end < len(items) && end > start {
+		page.NextCursor = encodeCursor(order.key(items[end-1]))
+	}
+	return page, nil
+}
+
+// listOrder compares list positions for one sort.
+type listOrder struct {
+	sort   string
+	desc   bool
+	counts map[string]int
+}
+
+func (o listOrder) key(item models.Conversation) cursorKey {
+	key := cursorKey{Sort: o.cursorSort(), Order: o.cursorOrder(), ID: item.ID}
+	switch o.sort {
+	case SortUpdatedAt:
+		key.UpdatedAt = item.UpdatedAt
+	case SortCreatedAt:
+		key.UpdatedAt = item.CreatedAt
+	case SortTitle:
+		key.Title = item.Title
+	case SortMessageCount:
+		key.Count = o.counts[item.ID]
+	}
+	return key
+}
+
+// compare orders a before b by the sort value, then by ID ascending.
+func (o listOrder) compare(a, b cursorKey) int {
+	var c int
+	switch o.sort {
+	case SortTitle:
+		c = cmp.Or(strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), strings.Compare(a.Title, b.Title))
+	case SortMessageCount:
+		c = cmp.Compare(a.Count, b.Count)
+	default:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	if o.desc {
+		c = -c
+	}
+	return cmp.Or(c, strings.Compare(a.ID, b.ID))
+}
+
+// cursorSort and cursorOrder are what a cursor records for this order,
+// empty for the default so cursors issued before sorting existed still
+// decode to it.
+func (o listOrder) cursorSort() string {
+	if o.sort == SortUpdatedAt && o.desc {
+		return ""
+	}
+	return o.sort
+}
+
+func (o listOrder) cursorOrder() string {
+	switch {
+	case o.sort == SortUpdatedAt && o.desc:
+		return ""
+	case o.desc:
+		return OrderDesc
+	default:
+		return OrderAsc
+	}
+}
+
+func encodeCursor(key cursorKey) string {
+	raw, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string) (cursorKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return cursorKey{}, ErrInvalidCursor
+	}
+	var key cursorKey
+	if err := json.Unmarshal(raw, &key); err != nil || key.ID == "" {
+		return cursorKey{}, ErrInvalidCursor
+	}
+	return key, nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sort"
+
+	"zatGPT/internal/models"
+)
+
+// Embeddings returns the stored embeddings computed with model, only of
+// conversations outside the trash. The vectors are shared with the store
+// and must not be modified.
+func (s *Store) Embeddings(model string) []models.Embedding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]models.Embedding, 0, len(s.embeddings))
+	for _, embedding := range s.embeddings {
+		if _, live := s.conversations[embedding.ConversationID]; live && embedding.Model == model {
+			out = append(out, embedding)
+		}
+	}
+	return out
+}
+
+// StaleEmbeddings lists the conversations with no embedding from model, or
+// one computed before their last update, oldest update first.
+func (s *Store) StaleEmbeddings(model string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stale []models.Conversation
+	for id, item := range s.conversations {
+		embedding, ok := s.embeddings[summaryKey(id, model)]
+		if !ok || item.UpdatedAt.After(embedding.SourceUpdatedAt) {
+			stale = append(stale, models.Conversation{ID: id, UpdatedAt: item.UpdatedAt})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].UpdatedAt.Equal(stale[j].UpdatedAt) {
+			return stale[i].ID < stale[j].ID
+		}
+		return stale[i].UpdatedAt.Before(stale[j].UpdatedAt)
+	})
+	ids := make([]string, len(stale))
+	for i, item := range stale {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+// SaveEmbeddings stores computed embeddings in one save, each replacing the
+// conversation's earlier one from the same model. Embeddings of
+// conversations deleted while they were computed are dropped.
+func (s *Store) SaveEmbeddings(embeddings []models.Embedding) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change := Change{Embeddings: make([]models.Embedding, 0, len(embeddings))}
+	previous := make(map[string]models.Embedding, len(embeddings))
+	for _, embedding := range embeddings {
+		if _, ok := s.conversations[embedding.ConversationID]; !ok {
+			continue
+		}
+		key := summaryKey(embedding.ConversationID, embedding.Model)
+		if old, ok := s.embeddings[key]; ok {
+			previous[key] = old
+		}
+		s.embeddings[key] = embedding
+		change.Embeddings = append(change.Embeddings, embedding)
+	}
+	if len(change.Embeddings) == 0 {
+		return nil
+	}
+	if err := s.saveLocked(change); err != nil {
+		for _, embedding := range change.Embeddings {
+			key := summaryKey(embedding.ConversationID, embedding.Model)
+			if old, ok := previous[key]; ok {
+				s.embeddings[key] = old
+			} else {
+				delete(s.embeddings, key)
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+)
+
+// maxTypos is the most edits FuzzySearch lets a word of the query be from
+// a word of a title or summary.
+const maxTypos = 2
+
+// typosAllowed is how many edits (letters inserted, deleted, replaced or
+// swapped with the next) word may be from a match: none for words of up
+// to three letters, where one edit makes a different word, one up to seven
+// letters and maxTypos beyond.
+func typosAllowed(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n <= 3:
+		return 0
+	case n <= 7:
+		return 1
+	default:
+		return maxTypos
+	}
+}
+
+// FuzzySearch is Search over titles and summaries that forgives typos, for
+// conversations whose exact title is long forgotten: each word of the terms
+// matches the words of a title or summary up to typosAllowed edits away,
+// so "kubernets" finds "Kubernetes". Phrases are matched word by word.
+// A conversation must match every word; its score adds up, for each word,
+// the weighted count of its best match times maxTypos+1 less the typos,
+// so exact matches rank first. Hits are ordered and paged as in Search,
+// with MessageIDs empty and snippets taken from the title and summary.
+func (s *Store) FuzzySearch(ctx context.Context, searchTerms []string, match func(models.Conversation) bool, offset, limit int) ([]SearchHit, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	words, _ := parseTerms(searchTerms)
+	hits, matched, err := s.fuzzySearchLocked(ctx, words, match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(hits)
+	start, end := window(total, offset, limit)
+	page := make([]SearchHit, 0, end-start)
+	for _, hit := range hits[start:end] {
+		hit.Conversation.Messages = nil
+		page = append(page, describeHit(hit, matched, nil))
+	}
+	return page, total, nil
+}
+
+// fuzzySearchLocked returns every conversation whose title or summary holds
+// a close match for each word and that match accepts, in result order,
+// along with the words of titles and summaries that matched. Callers hold
+// s.mu for reading.
+func (s *Store) fuzzySearchLocked(ctx context.Context, words []string, match func(models.Conversation) bool) ([]SearchHit, []string, error) {
+	if len(words) == 0 {
+		return nil, nil, nil
+	}
+
+	index := s.indexLocked()
+	var scores map[string]int
+	seen := make(map[string]bool)
+	var matched []string
+	for i, word := range words {
+		query := []rune(word)
+		typos := typosAllowed(word)
+		found := make(map[string]int)
+		scanned := 0
+		for candidate, ids := range index.titles {
+			if scanned++; scanned%scanCheckEvery == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, nil, err
+				}
+			}
+			if abs(utf8.RuneCountInString(candidate)-len(query)) > typos {
+				continue
+			}
+			distance := editDistance(query, []rune(candidate), typos)
+			if distance > typos {
+				continue
+			}
+			if !seen[candidate] {
+				seen[candidate] = true
+				matched = append(matched, candidate)
+			}
+			for id, n := range ids {
+				found[id] = max(found[id], n*(maxTypos+1-distance))
+			}
+		}
+
+		if i == 0 {
+			scores = found
+		} else {
+			for id := range scores {
+				if n, ok := found[id]; ok {
+					scores[id] += n
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			return nil, nil, nil
+		}
+	}
+
+	var hits []SearchHit
+	for id, score := range scores {
+		conversation := s.conversations[id]
+		if match != nil && !match(conversation) {
+			continue
+		}
+		hits = append(hits, SearchHit{Conversation: conversation, Score: score})
+	}
+	sortHits(hits)
+	return hits, matched, nil
+}
+
+// editDistance returns the edit distance between a and b, counting a
+// pair of swapped letters as one edit (the optimal string alignment
+// distance), or limit+1 as soon as it is sure to be more than limit.
+func editDistance(a, b []rune, limit int) int {
+	if abs(len(a)-len(b)) > limit {
+		return limit + 1
+	}
+	// Three rows of the distance matrix: the one being filled and the two
+	// above it, which a swap looks back to.
+	older := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		best := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], older[j-2]+1)
+			}
+			best = min(best, current[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		older, previous, current = previous, current, older
+	}
+	return min(previous[len(b)], limit+1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+
+	"zatGPT/internal/models"
+)
+
+var ErrImportNotFound = errors.New("import not found")
+
+// Imports returns the import history, newest first.
+func (s *Store) Imports() []models.ImportRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := append([]models.ImportRecord(nil), s.imports...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].StartedAt.After(out[j].StartedAt)
+	})
+	return out
+}
+
+// ImportedHash reports the most recent import of a file with the given
+// content hash.
+func (s *Store) ImportedHash(hash string) (models.ImportRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.imports) - 1; i >= 0; i-- {
+		if s.imports[i].Hash == hash {
+			return s.imports[i], true
+		}
+	}
+	return models.ImportRecord{}, false
+}
+
+// RecordImport appends an entry to the import history.
+func (s *Store) RecordImport(record models.ImportRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.imports = append(s.imports, record)
+	return s.saveLocked(Change{Imports: []models.ImportRecord{record}})
+}
+
+// Import returns one import history entry by id.
+func (s *Store) Import(id string) (models.ImportRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.imports {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return models.ImportRecord{}, ErrImportNotFound
+}
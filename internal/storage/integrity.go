@@ -0,0 +1,442 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Kinds of IntegrityIssue.
+const (
+	// IssueDamagedFile: the store file does not decode; only what came
+	// before the damage was read.
+	IssueDamagedFile = "damaged-file"
+	// IssueMissingID: a conversation without an ID, which nothing can
+	// reach.
+	IssueMissingID = "missing-id"
+	// IssueDuplicateID: a conversation stored more than once, or both in
+	// the list and in the trash. Only one copy is ever loaded.
+	IssueDuplicateID = "duplicate-id"
+	// IssueMissingTimestamp: a message without a time in a conversation
+	// whose other messages have one, or a conversation without its
+	// createdAt or updatedAt.
+	IssueMissingTimestamp = "missing-timestamp"
+	// IssueOrphanedRecord: a summary, embedding, revision or share of a
+	// conversation the store no longer holds.
+	IssueOrphanedRecord = "orphaned-record"
+	// IssueOrphanedAsset: a file in the attachment directory that no
+	// attachment refers to, including temporary files left by interrupted
+	// writes. Files changed within orphanGrace are not reported.
+	IssueOrphanedAsset = "orphaned-asset"
+	// IssueMissingAsset: an attachment marked stored whose file is gone.
+	IssueMissingAsset = "missing-asset"
+)
+
+// orphanGrace is how old a file in the attachment directory must be before
+// CheckIntegrity counts it as orphaned. Attachments are written before the
+// conversations that refer to them are saved, and an import saving a JSON
+// store once at the end can leave its new files unreferenced for as long as
+// it runs.
+const orphanGrace = 24 * time.Hour
+
+// IntegrityIssue is one problem CheckIntegrity found.
+type IntegrityIssue struct {
+	Kind           string `json:"kind"`
+	ConversationID string `json:"conversationId,omitempty"`
+	MessageID      string `json:"messageId,omitempty"`
+	Ref            string `json:"ref,omitempty"`
+	Detail         string `json:"detail"`
+	// Repaired is set once a repair has dealt with the issue.
+	Repaired bool `json:"repaired,omitempty"`
+}
+
+// IntegrityReport is the outcome of CheckIntegrity.
+type IntegrityReport struct {
+	Path          string           `json:"path"`
+	Engine        string           `json:"engine"`
+	CheckedAt     time.Time        `json:"checkedAt"`
+	Conversations int              `json:"conversations"`
+	Attachments   int              `json:"attachments"`
+	Issues        []IntegrityIssue `json:"issues"`
+	Repaired      bool             `json:"repaired,omitempty"`
+	// Quarantine is the directory a repair moved what it took out of the
+	// store to: a damaged store file, the records it dropped (as a store
+	// file of their own) and orphaned attachment files.
+	Quarantine string `json:"quarantine,omitempty"`
+	// FreeBytes is the free space on the store's disk, when it can be told.
+	FreeBytes int64 `json:"freeBytes,omitempty"`
+}
+
+// OK reports whether the check found nothing left to repair.
+func (r IntegrityReport) OK() bool {
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckIntegrity reads the store back from its backend, as it would be
+// loaded, and from the attachment directory, and reports what is wrong
+// with them. With repair it also puts things right and saves the store:
+// duplicates keep their most recently updated copy (a listed one over a
+// trashed one), conversations without an ID and orphaned records are
+// dropped, missing message times are taken from the nearest message that
+// has one, attachments whose file is gone are marked not stored and
+// orphaned files are moved out. Nothing is deleted: what is taken out goes
+// to a new directory under the store's Dir, named in the report.
+func (s *Store) CheckIntegrity(repair bool) (IntegrityReport, error) {
+	if repair {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	if repair && s.readOnly {
+		return IntegrityReport{}, ErrReadOnly
+	}
+	report := IntegrityReport{Path: DisplayPath(s.path), Engine: s.engine, CheckedAt: time.Now().UTC(), Issues: []IntegrityIssue{}}
+	if s.engine != EnginePostgres {
+		if free, ok := freeSpace(filepath.Dir(s.path)); ok {
+			report.FreeBytes = free
+		}
+	}
+
+	// A damaged file is checked as loaded, which is what a repair writes.
+	var damage error
+	payload, err := s.backend.Load()
+	switch {
+	case err != nil && s.engine != EngineJSON:
+		return IntegrityReport{}, err
+	case err != nil:
+		damage = err
+		payload = s.contentsLocked()
+		report.Issues = append(report.Issues, IntegrityIssue{Kind: IssueDamagedFile, Detail: damageDetail(err)})
+	}
+
+	check := integrityCheck{report: &report}
+	fixed := check.contents(payload)
+	blobs, err := check.assets(s.AttachmentDir(), &fixed, damage == nil)
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+	report.Conversations = len(fixed.Conversations)
+	if !repair || len(report.Issues) == 0 {
+		return report, nil
+	}
+
+	quarantine := filepath.Join(s.dir, "quarantine", report.CheckedAt.Format("20060102-150405"))
+	if err := os.MkdirAll(quarantine, 0o755); err != nil {
+		return IntegrityReport{}, err
+	}
+	if damage != nil {
+		if err := copyFile(s.path, filepath.Join(quarantine, filepath.Base(s.path))); err != nil {
+			return IntegrityReport{}, fmt.Errorf("keeping the damaged store file: %w", err)
+		}
+	}
+	if check.dropped.any() {
+		if err := writeQuarantined(filepath.Join(quarantine, "records.json"), check.dropped); err != nil {
+			return IntegrityReport{}, err
+		}
+	}
+	if len(blobs) > 0 {
+		dir := filepath.Join(quarantine, "attachments")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return IntegrityReport{}, err
+		}
+		for _, name := range blobs {
+			if err := os.Rename(filepath.Join(s.AttachmentDir(), name), filepath.Join(dir, name)); err != nil {
+				return IntegrityReport{}, err
+			}
+		}
+		s.blobBytes, s.blobCount = 0, 0
+		s.measureAttachments()
+	}
+
+	previous := s.contentsLocked()
+	s.setContentsLocked(fixed)
+	if err := s.saveLocked(Change{Replace: true}); err != nil {
+		s.setContentsLocked(previous)
+		return IntegrityReport{}, err
+	}
+	for i := range report.Issues {
+		report.Issues[i].Repaired = true
+	}
+	report.Repaired = true
+	report.Quarantine = quarantine
+	return report, nil
+}
+
+// integrityCheck collects the issues of one CheckIntegrity and what a
+// repair would drop.
+type integrityCheck struct {
+	report  *IntegrityReport
+	dropped Contents
+}
+
+func (c *integrityCheck) add(issue IntegrityIssue) {
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+// contents checks payload and returns it repaired.
+func (c *integrityCheck) contents(payload Contents) Contents {
+	fixed := payload
+	fixed.Conversations = c.conversations(payload.Conversations, "")
+	fixed.Trash = c.conversations(payload.Trash, " in the trash")
+
+	listed := make(map[string]bool, len(fixed.Conversations))
+	for _, convo := range fixed.Conversations {
+		listed[convo.ID] = true
+	}
+	fixed.Trash = slices.DeleteFunc(fixed.Trash, func(convo models.Conversation) bool {
+		if !listed[convo.ID] {
+			return false
+		}
+		c.add(IntegrityIssue{Kind: IssueDuplicateID, ConversationID: convo.ID, Detail: "in the list and in the trash; the trashed copy is never loaded"})
+		c.dropped.Trash = append(c.dropped.Trash, convo)
+		return true
+	})
+
+	// From here on listed takes in the trash: records of trashed
+	// conversations are kept.
+	for _, convo := range fixed.Trash {
+		listed[convo.ID] = true
+	}
+	known := func(id string) bool { return listed[id] }
+	fixed.Summaries = orphans(c, fixed.Summaries, &c.dropped.Summaries, known, func(summary models.Summary) (string, string) {
+		return summary.ConversationID, "summary (" + summary.Method + ")"
+	})
+	fixed.Embeddings = orphans(c, fixed.Embeddings, &c.dropped.Embeddings, known, func(embedding models.Embedding) (string, string) {
+		return embedding.ConversationID, "embedding (" + embedding.Model + ")"
+	})
+	fixed.Revisions = orphans(c, fixed.Revisions, &c.dropped.Revisions, known, func(revision models.Revision) (string, string) {
+		return revision.ConversationID, fmt.Sprintf("revision %d", revision.Number)
+	})
+	fixed.Shares = orphans(c, fixed.Shares, &c.dropped.Shares, known, func(share models.Share) (string, string) {
+		return share.ConversationID, "share " + share.Token
+	})
+	return fixed
+}
+
+// conversations checks one list of conversations, returning it without
+// the copies a repair drops and with missing times filled in.
+func (c *integrityCheck) conversations(items []models.Conversation, where string) []models.Conversation {
+	kept := make([]models.Conversation, 0, len(items))
+	at := make(map[string]int, len(items))
+	for _, convo := range items {
+		if convo.ID == "" {
+			c.add(IntegrityIssue{Kind: IssueMissingID, Detail: fmt.Sprintf("conversation %q%s has no ID", convo.Title, where)})
+			c.dropConversation(convo, where)
+			continue
+		}
+		convo = c.timestamps(convo)
+		i, seen := at[convo.ID]
+		if !seen {
+			at[convo.ID] = len(kept)
+			kept = append(kept, convo)
+			continue
+		}
+		c.add(IntegrityIssue{Kind: IssueDuplicateID, ConversationID: convo.ID, Detail: "stored more than once" + where + "; the most recently updated copy is kept"})
+		if convo.UpdatedAt.After(kept[i].UpdatedAt) {
+			kept[i], convo = convo, kept[i]
+		}
+		c.dropConversation(convo, where)
+	}
+	return kept
+}
+
+func (c *integrityCheck) dropConversation(convo models.Conversation, where string) {
+	if where == "" {
+		c.dropped.Conversations = append(c.dropped.Conversations, convo)
+	} else {
+		c.dropped.Trash = append(c.dropped.Trash, convo)
+	}
+}
+
+// timestamps reports the times convo is missing and returns it with them
+// filled in: a message's from the nearest earlier message that has one, or
+// else the nearest later one, and the conversation's from its span.
+func (c *integrityCheck) timestamps(convo models.Conversation) models.Conversation {
+	timed := slices.IndexFunc(convo.Messages, func(msg models.Message) bool { return !msg.CreatedAt.IsZero() })
+	if timed >= 0 {
+		var messages []models.Message
+		for i, msg := range convo.Messages {
+			if !msg.CreatedAt.IsZero() {
+				continue
+			}
+			c.add(IntegrityIssue{Kind: IssueMissingTimestamp, ConversationID: convo.ID, MessageID: msg.ID, Detail: fmt.Sprintf("message %d has no time while others do", i+1)})
+			if messages == nil {
+				messages = slices.Clone(convo.Messages)
+			}
+			messages[i].CreatedAt = nearestTime(messages, i)
+		}
+		if messages != nil {
+			convo.Messages = messages
+		}
+	}
+
+	started, ended := convo.Span()
+	if convo.CreatedAt.IsZero() {
+		c.add(IntegrityIssue{Kind: IssueMissingTimestamp, ConversationID: convo.ID, Detail: "the conversation has no createdAt"})
+		convo.CreatedAt = firstTime(started, convo.UpdatedAt)
+	}
+	if convo.UpdatedAt.IsZero() {
+		c.add(IntegrityIssue{Kind: IssueMissingTimestamp, ConversationID: convo.ID, Detail: "the conversation has no updatedAt"})
+		convo.UpdatedAt = firstTime(ended, convo.CreatedAt)
+	}
+	return convo
+}
+
+// nearestTime is the time of the message nearest to messages[i] that has
+// one, looking back first.
+func nearestTime(messages []models.Message, i int) time.Time {
+	for j := i - 1; j >= 0; j-- {
+		if !messages[j].CreatedAt.IsZero() {
+			return messages[j].CreatedAt
+		}
+	}
+	for j := i + 1; j < len(messages); j++ {
+		if !messages[j].CreatedAt.IsZero() {
+			return messages[j].CreatedAt
+		}
+	}
+	return time.Time{}
+}
+
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// orphans returns records without those whose conversation is not known,
+// adding each to dropped and reporting it.
+func orphans[T any](c *integrityCheck, records []T, dropped *[]T, known func(id string) bool, describe func(T) (string, string)) []T {
+	return slices.DeleteFunc(slices.Clone(records), func(record T) bool {
+		id, what := describe(record)
+		if known(id) {
+			return false
+		}
+		c.add(IntegrityIssue{Kind: IssueOrphanedRecord, ConversationID: id, Detail: what + " of a conversation the store does not hold"})
+		*dropped = append(*dropped, record)
+		return true
+	})
+}
+
+// assets checks the attachment directory against the attachments of
+// payload, marking those whose file is gone as not stored, and, with
+// findOrphans, returns the names of the files no attachment refers to and
+// that have not changed within orphanGrace. A damaged store file may have
+// lost the attachments that refer to files, so it is not checked for
+// orphans.
+func (c *integrityCheck) assets(dir string, payload *Contents, findOrphans bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	files := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			files[entry.Name()] = true
+		}
+	}
+
+	referenced := make(map[string]bool)
+	for _, list := range []*[]models.Conversation{&payload.Conversations, &payload.Trash} {
+		for i := range *list {
+			convo := &(*list)[i]
+			var attachments []models.Attachment
+			for j, att := range convo.Attachments {
+				referenced[att.Ref] = true
+				if !att.Stored || files[att.Ref] {
+					continue
+				}
+				c.add(IntegrityIssue{Kind: IssueMissingAsset, ConversationID: convo.ID, MessageID: att.MessageID, Ref: att.Ref, Detail: "the attachment is marked stored but its file is gone"})
+				if attachments == nil {
+					attachments = slices.Clone(convo.Attachments)
+				}
+				attachments[j].Stored = false
+			}
+			if attachments != nil {
+				convo.Attachments = attachments
+			}
+			c.report.Attachments += len(convo.Attachments)
+		}
+	}
+
+	if !findOrphans {
+		return nil, nil
+	}
+	var orphaned []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !files[name] || referenced[name] {
+			continue
+		}
+		if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < orphanGrace {
+			continue
+		}
+		detail := "no attachment refers to this file"
+		if strings.HasSuffix(name, ".tmp") {
+			detail = "a temporary file left by an interrupted write"
+		}
+		c.add(IntegrityIssue{Kind: IssueOrphanedAsset, Ref: name, Detail: detail})
+		orphaned = append(orphaned, name)
+	}
+	return orphaned, nil
+}
+
+func (c Contents) any() bool {
+	return len(c.Conversations) > 0 || len(c.Trash) > 0 || len(c.Summaries) > 0 || len(c.Embeddings) > 0 || len(c.Revisions) > 0 || len(c.Shares) > 0
+}
+
+// damageDetail describes why a store file did not decode, with where when
+// the decoder told.
+func damageDetail(err error) string {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return fmt.Sprintf("the store file does not decode at byte %d: %v", syntax.Offset, err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return "the store file ends early, as after an interrupted write: " + err.Error()
+	}
+	return "the store file does not decode: " + err.Error()
+}
+
+// writeQuarantined writes the records a repair dropped as a store file of
+// their own, to take back from by hand.
+func writeQuarantined(path string, dropped Contents) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = writeContents(file, dropped, false)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = writeFileAtomic(dst, in, nil)
+	return err
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "zatGPT/internal/models"
+
+// LinkChecks returns a copy of every recorded link check keyed by URL.
+func (s *Store) LinkChecks() map[string]models.LinkCheck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]models.LinkCheck, len(s.linkChecks))
+	for url, check := range s.linkChecks {
+		out[url] = check
+	}
+	return out
+}
+
+// SaveLinkChecks records check results, replacing earlier results for the
+// same URLs, with a single save.
+func (s *Store) SaveLinkChecks(checks []models.LinkCheck) error {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, check := range checks {
+		s.linkChecks[check.URL] = check
+	}
+	return s.saveLocked(Change{LinkChecks: checks})
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the store's lock.
+var ErrLocked = errors.New("store is locked by another process")
+
+// ErrReadOnly is returned by writes to a store opened with ReadOnly.
+var ErrReadOnly = errors.New("store is open read-only")
+
+// Options tunes Open.
+//
+// A writable store takes an exclusive advisory lock on "<path>.lock" for as
+// long as it is open, because it keeps the archive in memory and would
+// overwrite changes made by anyone else. A PostgreSQL store takes none:
+// the servers sharing it coordinate through the database. LockWait is how long Open keeps
+// retrying while another process holds the lock; zero fails at once.
+// ReadOnly stores take no lock and reject writes, so exporters and reports
+// can run next to a live server. Limits caps what the store accepts.
+// Engine is EngineJSON, EngineSQLite, EngineBolt or EnginePostgres; empty
+// detects it (see Open). Encoding is how a JSON store file is written from
+// now on: EncodingIndented, EncodingCompact or EncodingGzip; empty keeps the
+// file's own, which is read whatever it is. Dir is where the files kept
+// beside the store, such as attachment blobs, go; empty is the store file's
+// directory, or DefaultDir for a PostgreSQL store. Salvage opens a JSON
+// store file that does not decode with what could be read before the
+// damage, instead of failing; such a store refuses writes until
+// CheckIntegrity repairs it.
+type Options struct {
+	LockWait time.Duration
+	ReadOnly bool
+	Limits   Limits
+	Engine   string
+	Encoding string
+	Dir      string
+	Salvage  bool
+}
+
+const lockPoll = 100 * time.Millisecond
+
+// fileLock is a held lock file.
+type fileLock struct {
+	file *os.File
+}
+
+func acquireLock(storePath string, wait time.Duration) (*fileLock, error) {
+	path := storePath + ".lock"
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err = tryLock(file)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			file.Close()
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			holder := lockHolder(path)
+			file.Close()
+			if wait > 0 {
+				return nil, fmt.Errorf("%w (%s; gave up after %s)", ErrLocked, holder, wait)
+			}
+			return nil, fmt.Errorf("%w (%s)", ErrLocked, holder)
+		}
+		time.Sleep(lockPoll)
+	}
+
+	// Record the holder for the error other processes will see.
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &fileLock{file: file}, nil
+}
+
+func lockHolder(path string) string {
+	raw, err := os.ReadFile(path)
+	if pid := strings.TrimSpace(string(raw)); err == nil && pid != "" {
+		return path + " is held by pid " + pid
+	}
+	return path + " is held"
+}
+
+func (l *fileLock) release() error {
+	if l == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	unlock(l.file)
+	return l.file.Close()
+}
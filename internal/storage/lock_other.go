@@ -0,0 +1,16 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// Advisory locking relies on flock; other platforms run without it.
+
+var errWouldBlock = errors.New("lock is held")
+
+func tryLock(*os.File) error { return nil }
+
+func unlock(*os.File) {}
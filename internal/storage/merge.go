@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"zatGPT/internal/duplicates"
+	"zatGPT/internal/models"
+)
+
+// MergeResult reports a Merge: the combined conversation, how many messages
+// it gained and the IDs moved to the trash.
+type MergeResult struct {
+	Conversation models.Conversation `json:"conversation"`
+	Added        int                 `json:"addedMessages"`
+	Trashed      []string            `json:"trashed"`
+}
+
+// Merge combines the conversations in ids into the first one (see
+// duplicates.Merge) and moves the others to the trash, in a single save.
+// The merged conversation keeps its content hash, so re-importing the
+// export it came from leaves the merge alone, and the trashed ones are
+// marked MergedInto so they stay in the trash when their exports are
+// imported again.
+func (s *Store) Merge(ids []string) (MergeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return MergeResult{}, ErrReadOnly
+	}
+	if len(ids) < 2 {
+		return MergeResult{}, errors.New("merge needs at least two conversations")
+	}
+	conversations := make([]models.Conversation, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		item, ok := s.conversations[id]
+		switch {
+		case !ok:
+			return MergeResult{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+		case seen[id]:
+			return MergeResult{}, fmt.Errorf("conversation %s is listed twice", id)
+		}
+		seen[id] = true
+		conversations = append(conversations, item)
+	}
+
+	merged, added := duplicates.Merge(conversations[0], conversations[1:]...)
+	merged.Tags = NormalizeTags(merged.Tags)
+	now := time.Now().UTC()
+	merged.UpdatedAt = now
+
+	change := Change{Conversations: []models.Conversation{merged}}
+	for _, item := range conversations[1:] {
+		item.DeletedAt, item.MergedInto = now, merged.ID
+		s.removeLocked(item.ID)
+		s.trash[item.ID] = item
+		change.Trashed = append(change.Trashed, item)
+	}
+	merged = s.putLocked(merged)
+	change.Conversations[0] = merged
+
+	err := s.saveCheckedLocked(change, func(size int64) error {
+		return s.checkSizeLocked(size, s.blobBytes)
+	})
+	if err != nil {
+		s.putLocked(conversations[0])
+		for _, item := range conversations[1:] {
+			delete(s.trash, item.ID)
+			s.putLocked(item)
+		}
+		return MergeResult{}, err
+	}
+	return MergeResult{Conversation: merged, Added: added, Trashed: ids[1:]}, nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/tokenizer"
+)
+
+// ErrMessageNotFound is returned for a message ID the conversation does not
+// hold.
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrMessageExists is returned when a message added to a conversation
+// reuses the ID of one it already holds.
+var ErrMessageExists = errors.New("message ID already in use")
+
+// AddMessage adds message to conversation id, right after the message
+// with ID after, or at the end when after is empty. A timestamped message
+// past the conversation's end moves the end to it, and starts a
+// conversation that has no start yet.
+func (s *Store) AddMessage(id string, message models.Message, after string) (models.Conversation, error) {
+	return s.editMessages(id, func(convo *models.Conversation) error {
+		if messageAt(convo.Messages, message.ID) >= 0 {
+			return ErrMessageExists
+		}
+		at := len(convo.Messages)
+		if after != "" {
+			if at = messageAt(convo.Messages, after) + 1; at == 0 {
+				return ErrMessageNotFound
+			}
+		}
+		convo.Messages = slices.Insert(convo.Messages, at, message)
+		// The tree describes the messages as imported.
+		convo.Tree = nil
+		if !message.CreatedAt.IsZero() {
+			extendSpan(convo, message.CreatedAt)
+		}
+		return nil
+	})
+}
+
+// EditMessage applies edit to message messageID of conversation id.
+func (s *Store) EditMessage(id, messageID string, edit func(message *models.Message)) (models.Conversation, error) {
+	return s.editMessages(id, func(convo *models.Conversation) error {
+		at := messageAt(convo.Messages, messageID)
+		if at < 0 {
+			return ErrMessageNotFound
+		}
+		edit(&convo.Messages[at])
+		return nil
+	})
+}
+
+// DeleteMessage removes message messageID from conversation id, along with
+// the attachments that point at it.
+func (s *Store) DeleteMessage(id, messageID string) (models.Conversation, error) {
+	return s.editMessages(id, func(convo *models.Conversation) error {
+		at := messageAt(convo.Messages, messageID)
+		if at < 0 {
+			return ErrMessageNotFound
+		}
+		convo.Messages = slices.Delete(convo.Messages, at, at+1)
+		convo.Attachments = slices.DeleteFunc(slices.Clone(convo.Attachments), func(attachment models.Attachment) bool {
+			return attachment.MessageID == messageID
+		})
+		if len(convo.Attachments) == 0 {
+			convo.Attachments = nil
+		}
+		convo.Tree = nil
+		return nil
+	})
+}
+
+// editMessages runs edit on a copy of conversation id's messages and
+// stores the result, with its tokens and topics picked again, keeping the
+// version it replaces as a revision. The ContentHash is left as the import
+// set it, so importing the same export again keeps the edit.
+func (s *Store) editMessages(id string, edit func(convo *models.Conversation) error) (models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Conversation{}, ErrReadOnly
+	}
+	previous, ok := s.conversations[id]
+	if !ok {
+		return models.Conversation{}, ErrNotFound
+	}
+
+	convo := previous
+	convo.Messages = slices.Clone(previous.Messages)
+	// Keep the span through the UpdatedAt bump below.
+	convo.StartedAt, convo.EndedAt = previous.Span()
+	if err := edit(&convo); err != nil {
+		return models.Conversation{}, err
+	}
+	tokenizer.CountConversation(&convo)
+	convo.Topics = s.topicsLocked().Extract(convo)
+	now := time.Now().UTC()
+	convo.UpdatedAt = now
+
+	var revisions []models.Revision
+	if revised(previous, convo) {
+		revisions = append(revisions, s.nextRevisionLocked(previous, nil, now))
+	}
+	if err := s.upsertManyLocked([]models.Conversation{convo}, revisions); err != nil {
+		return models.Conversation{}, err
+	}
+	return s.conversations[id], nil
+}
+
+// extendSpan widens the dates of convo to take in t.
+func extendSpan(convo *models.Conversation, t time.Time) {
+	t = t.UTC()
+	date := t.Format(time.DateOnly)
+	if convo.DateStarted == "" {
+		convo.DateStarted, convo.StartedAt = date, t
+	}
+	if convo.DateEnded == "" || date > convo.DateEnded || date == convo.DateEnded && t.After(convo.EndedAt) {
+		convo.DateEnded, convo.EndedAt = date, t
+	}
+}
+
+func messageAt(messages []models.Message, id string) int {
+	return slices.IndexFunc(messages, func(message models.Message) bool {
+		return message.ID == id
+	})
+}
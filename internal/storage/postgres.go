@@ -0,0 +1,735 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"zatGPT/internal/models"
+)
+
+// postgresMigrations hold the PostgreSQL schema, one file per version,
+// named after the version it brings the database to (001_init.sql). A
+// released migration is never edited; schema changes go in a new file.
+//
+//go:embed postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationLock is the advisory lock held while migrating, so that
+// servers starting together apply each migration once.
+const postgresMigrationLock = 0x7a617447
+
+// postgresTables are the tables holding the store's records.
+var postgresTables = []string{"conversations", "messages", "conversation_tags", "link_checks", "imports", "summaries", "embeddings", "revisions", "shares", "saved_searches"}
+
+// ErrStale is returned for a write to a PostgreSQL store made while
+// another server sharing the database has written changes this one has
+// not loaded yet; Refresh picks them up, and the write can then be tried
+// again.
+var ErrStale = errors.New("the store was changed by another server sharing its database; try again once it has been reloaded")
+
+// postgresBackend keeps the store in a PostgreSQL database that several
+// servers can share. Each still serves reads from memory: writes go to the
+// database in one transaction each, queued behind the other servers'
+// writes, and every server reloads the contents when Refresh finds that
+// another one has written. A server only writes on top of the latest
+// write it has loaded: records are written whole from memory, and
+// revision numbers counted there, so writing from stale contents would
+// undo what the other servers wrote. Such a write fails with ErrStale.
+type postgresBackend struct {
+	// name is the connection URL without its password, for messages.
+	name string
+	db   *sql.DB
+	// revision and modified are store_meta as of the latest load or write
+	// by this process.
+	revision uint64
+	modified time.Time
+}
+
+type postgresMigration struct {
+	version int
+	sql     string
+}
+
+// loadPostgresMigrations reads the embedded migrations in version order.
+func loadPostgresMigrations() ([]postgresMigration, error) {
+	entries, err := fs.ReadDir(postgresMigrations, "postgres")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]postgresMigration, 0, len(entries))
+	for _, entry := range entries {
+		number, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name does not start with its version", entry.Name())
+		}
+		data, err := fs.ReadFile(postgresMigrations, "postgres/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, postgresMigration{version: version, sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func openPostgres(dsn string, readOnly bool) (*postgresBackend, error) {
+	b := &postgresBackend{name: DisplayPath(dsn)}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	b.db = db
+
+	err = db.Ping()
+	if err == nil && !readOnly {
+		err = b.migrate()
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	return b, nil
+}
+
+// migrate brings the schema up to the latest embedded migration, all in
+// one transaction.
+func (b *postgresBackend) migrate() error {
+	migrations, err := loadPostgresMigrations()
+	if err != nil {
+		return err
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY, applied_at timestamptz NOT NULL DEFAULT now())"); err != nil {
+		return err
+	}
+	version, err := postgresSchemaVersion(tx, migrations)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.version <= version {
+			continue
+		}
+		if _, err := tx.Exec(migration.sql); err != nil {
+			return fmt.Errorf("migration %d: %w", migration.version, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", migration.version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// postgresSchemaVersion reports the latest migration applied to the
+// database, 0 for one never migrated, failing for a schema newer than
+// migrations.
+func postgresSchemaVersion(tx *sql.Tx, migrations []postgresMigration) (int, error) {
+	var exists bool
+	if err := tx.QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	if latest := migrations[len(migrations)-1].version; version > latest {
+		return 0, fmt.Errorf("database schema v%d is newer than this build supports (v%d)", version, latest)
+	}
+	return version, nil
+}
+
+// Load reads everything from one snapshot of the database, so writes by
+// other servers land either wholly or not at all.
+func (b *postgresBackend) Load() (Contents, error) {
+	payload, err := b.load()
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s: %w", b.name, err)
+	}
+	return payload, nil
+}
+
+func (b *postgresBackend) load() (Contents, error) {
+	var payload Contents
+	migrations, err := loadPostgresMigrations()
+	if err != nil {
+		return payload, err
+	}
+	tx, err := b.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return payload, err
+	}
+	defer tx.Rollback()
+
+	// A database opened read-only may never have been migrated; it is
+	// simply empty.
+	version, err := postgresSchemaVersion(tx, migrations)
+	if err != nil || version == 0 {
+		return payload, err
+	}
+	var revision uint64
+	var modified time.Time
+	if err := tx.QueryRow("SELECT revision, modified FROM store_meta").Scan(&revision, &modified); err != nil {
+		return payload, err
+	}
+
+	var all []models.Conversation
+	var trashed []bool
+	positions := make(map[string]int)
+	rows, err := tx.Query("SELECT id, trashed, data FROM conversations ORDER BY id")
+	if err != nil {
+		return payload, err
+	}
+	for rows.Next() {
+		var id string
+		var inTrash bool
+		var data []byte
+		if err := rows.Scan(&id, &inTrash, &data); err != nil {
+			rows.Close()
+			return payload, err
+		}
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			rows.Close()
+			return payload, fmt.Errorf("conversation %s: %w", id, err)
+		}
+		positions[id] = len(all)
+		all = append(all, conversation)
+		trashed = append(trashed, inTrash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return payload, err
+	}
+
+	rows, err = tx.Query("SELECT conversation_id, data FROM messages ORDER BY conversation_id, position")
+	if err != nil {
+		return payload, err
+	}
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return payload, err
+		}
+		var message models.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			rows.Close()
+			return payload, fmt.Errorf("conversation %s: %w", id, err)
+		}
+		i := positions[id]
+		all[i].Messages = append(all[i].Messages, message)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return payload, err
+	}
+
+	rows, err = tx.Query("SELECT conversation_id, tag FROM conversation_tags ORDER BY conversation_id, position")
+	if err != nil {
+		return payload, err
+	}
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			rows.Close()
+			return payload, err
+		}
+		i := positions[id]
+		all[i].Tags = append(all[i].Tags, tag)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return payload, err
+	}
+
+	for i, conversation := range all {
+		if trashed[i] {
+			payload.Trash = append(payload.Trash, conversation)
+		} else {
+			payload.Conversations = append(payload.Conversations, conversation)
+		}
+	}
+
+	err = loadRows(tx, "SELECT data FROM link_checks ORDER BY url", func(data []byte) error {
+		var check models.LinkCheck
+		if err := json.Unmarshal(data, &check); err != nil {
+			return err
+		}
+		payload.LinkChecks = append(payload.LinkChecks, check)
+		return nil
+	})
+	if err == nil {
+		err = loadRows(tx, "SELECT data FROM imports ORDER BY seq", func(data []byte) error {
+			var record models.ImportRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			payload.Imports = append(payload.Imports, record)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(tx, "SELECT data FROM summaries ORDER BY conversation_id, method", func(data []byte) error {
+			var summary models.Summary
+			if err := json.Unmarshal(data, &summary); err != nil {
+				return err
+			}
+			payload.Summaries = append(payload.Summaries, summary)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(tx, "SELECT data FROM embeddings ORDER BY conversation_id, model", func(data []byte) error {
+			var embedding models.Embedding
+			if err := json.Unmarshal(data, &embedding); err != nil {
+				return err
+			}
+			payload.Embeddings = append(payload.Embeddings, embedding)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(tx, "SELECT data FROM revisions ORDER BY conversation_id, number", func(data []byte) error {
+			var revision models.Revision
+			if err := json.Unmarshal(data, &revision); err != nil {
+				return err
+			}
+			payload.Revisions = append(payload.Revisions, revision)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(tx, "SELECT data FROM shares ORDER BY conversation_id, token", func(data []byte) error {
+			var share models.Share
+			if err := json.Unmarshal(data, &share); err != nil {
+				return err
+			}
+			payload.Shares = append(payload.Shares, share)
+			return nil
+		})
+	}
+	// Saved searches came with version 2, which a database opened
+	// read-only may lack.
+	if err == nil && version >= 2 {
+		err = loadRows(tx, "SELECT data FROM saved_searches ORDER BY id", func(data []byte) error {
+			var search models.SavedSearch
+			if err := json.Unmarshal(data, &search); err != nil {
+				return err
+			}
+			payload.SavedSearches = append(payload.SavedSearches, search)
+			return nil
+		})
+	}
+	if err != nil {
+		return Contents{}, err
+	}
+
+	b.revision, b.modified = revision, modified.UTC()
+	return payload, nil
+}
+
+// Save applies change in one transaction. It starts by counting the write
+// in store_meta, whose row lock queues other servers' writes until this
+// one commits, and gives up with ErrStale when the count shows a write by
+// another server that this one has not loaded. The size passed to check is that of the store's tables
+// before the transaction commits.
+func (b *postgresBackend) Save(change Change, contents func() Contents, check func(size int64) error) (int64, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var revision uint64
+	var modified time.Time
+	if err := tx.QueryRow("UPDATE store_meta SET revision = revision + 1, modified = now() RETURNING revision, modified").Scan(&revision, &modified); err != nil {
+		return 0, err
+	}
+	if revision != b.revision+1 {
+		return 0, ErrStale
+	}
+
+	if change.Replace {
+		if _, err := tx.Exec("TRUNCATE " + strings.Join(postgresTables, ", ") + " RESTART IDENTITY"); err != nil {
+			return 0, err
+		}
+		all := contents()
+		change = Change{
+			Conversations: all.Conversations,
+			LinkChecks:    all.LinkChecks,
+			Imports:       all.Imports,
+			Summaries:     all.Summaries,
+			Embeddings:    all.Embeddings,
+			Revisions:     all.Revisions,
+			Trashed:       all.Trash,
+			Shares:        all.Shares,
+			SavedSearches: all.SavedSearches,
+		}
+	}
+	if err := applyPostgresChange(tx, change); err != nil {
+		return 0, err
+	}
+
+	size, err := postgresSize(tx)
+	if err != nil {
+		return 0, err
+	}
+	if check != nil {
+		if err := check(size); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	b.revision, b.modified = revision, modified.UTC()
+	return size, nil
+}
+
+func applyPostgresChange(tx *sql.Tx, change Change) error {
+	for _, conversation := range change.Conversations {
+		if err := putPostgresConversation(tx, conversation, false); err != nil {
+			return err
+		}
+	}
+	for _, conversation := range change.Trashed {
+		if err := putPostgresConversation(tx, conversation, true); err != nil {
+			return err
+		}
+	}
+	if len(change.Deleted) > 0 {
+		// Messages and tags go with their conversations.
+		for _, table := range []string{"conversations", "summaries", "embeddings", "revisions", "shares"} {
+			column := "conversation_id"
+			if table == "conversations" {
+				column = "id"
+			}
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+column+" = ANY($1)", change.Deleted); err != nil {
+				return err
+			}
+		}
+	}
+	for _, check := range change.LinkChecks {
+		if err := putPostgresRow(tx, "INSERT INTO link_checks (url, data) VALUES ($1, $2) ON CONFLICT (url) DO UPDATE SET data = excluded.data", check, check.URL); err != nil {
+			return err
+		}
+	}
+	for _, record := range change.Imports {
+		if err := putPostgresRow(tx, "INSERT INTO imports (data) VALUES ($1)", record); err != nil {
+			return err
+		}
+	}
+	for _, summary := range change.Summaries {
+		if err := putPostgresRow(tx, "INSERT INTO summaries (conversation_id, method, data) VALUES ($1, $2, $3) ON CONFLICT (conversation_id, method) DO UPDATE SET data = excluded.data", summary, summary.ConversationID, summary.Method); err != nil {
+			return err
+		}
+	}
+	for _, embedding := range change.Embeddings {
+		if err := putPostgresRow(tx, "INSERT INTO embeddings (conversation_id, model, data) VALUES ($1, $2, $3) ON CONFLICT (conversation_id, model) DO UPDATE SET data = excluded.data", embedding, embedding.ConversationID, embedding.Model); err != nil {
+			return err
+		}
+	}
+	for _, revision := range change.Revisions {
+		if err := putPostgresRow(tx, "INSERT INTO revisions (conversation_id, number, data) VALUES ($1, $2, $3) ON CONFLICT (conversation_id, number) DO UPDATE SET data = excluded.data", revision, revision.ConversationID, revision.Number); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM revisions WHERE conversation_id = $1 AND number <= $2", revision.ConversationID, revision.Number-MaxRevisions); err != nil {
+			return err
+		}
+	}
+	for _, share := range change.Shares {
+		if err := putPostgresRow(tx, "INSERT INTO shares (token, conversation_id, data) VALUES ($1, $2, $3) ON CONFLICT (token) DO UPDATE SET conversation_id = excluded.conversation_id, data = excluded.data", share, share.Token, share.ConversationID); err != nil {
+			return err
+		}
+	}
+	for _, share := range change.Unshared {
+		if _, err := tx.Exec("DELETE FROM shares WHERE token = $1", share.Token); err != nil {
+			return err
+		}
+	}
+	for _, search := range change.SavedSearches {
+		if err := putPostgresRow(tx, "INSERT INTO saved_searches (id, data) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET data = excluded.data", search, search.ID); err != nil {
+			return err
+		}
+	}
+	if len(change.UnsavedSearches) > 0 {
+		if _, err := tx.Exec("DELETE FROM saved_searches WHERE id = ANY($1)", change.UnsavedSearches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putPostgresConversation writes conversation, in the trash when trashed,
+// replacing its messages and tags.
+func putPostgresConversation(tx *sql.Tx, conversation models.Conversation, trashed bool) error {
+	record := conversation
+	record.Messages, record.Tags = nil, nil
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO conversations (id, title, source, archived, starred, trashed, created_at, updated_at, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET title = excluded.title, source = excluded.source, archived = excluded.archived,
+			starred = excluded.starred, trashed = excluded.trashed, created_at = excluded.created_at,
+			updated_at = excluded.updated_at, data = excluded.data`,
+		conversation.ID, postgresText(conversation.Title), postgresText(conversation.Source), conversation.Archived,
+		conversation.Starred, trashed, conversation.CreatedAt, conversation.UpdatedAt, string(data))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM messages WHERE conversation_id = $1", conversation.ID); err != nil {
+		return err
+	}
+	if n := len(conversation.Messages); n > 0 {
+		ids, authors, modelNames, created, messages := make([]string, n), make([]string, n), make([]string, n), make([]time.Time, n), make([]string, n)
+		for i, message := range conversation.Messages {
+			data, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+			ids[i], authors[i], modelNames[i] = postgresText(message.ID), postgresText(message.Author), postgresText(message.Model)
+			created[i], messages[i] = message.CreatedAt, string(data)
+		}
+		_, err := tx.Exec(`INSERT INTO messages (conversation_id, position, id, author, model, created_at, data)
+			SELECT $1, m.position, m.id, m.author, m.model, m.created_at, m.data
+			FROM unnest($2::text[], $3::text[], $4::text[], $5::timestamptz[], $6::json[]) WITH ORDINALITY AS m(id, author, model, created_at, data, position)`,
+			conversation.ID, ids, authors, modelNames, created, messages)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM conversation_tags WHERE conversation_id = $1", conversation.ID); err != nil {
+		return err
+	}
+	if len(conversation.Tags) > 0 {
+		tags := make([]string, len(conversation.Tags))
+		for i, tag := range conversation.Tags {
+			tags[i] = postgresText(tag)
+		}
+		_, err := tx.Exec(`INSERT INTO conversation_tags (conversation_id, position, tag)
+			SELECT $1, t.position, t.tag FROM unnest($2::text[]) WITH ORDINALITY AS t(tag, position)`,
+			conversation.ID, tags)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putPostgresRow runs statement with keys followed by the JSON encoding of
+// value.
+func putPostgresRow(tx *sql.Tx, statement string, value any, keys ...any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(statement, append(keys, string(data))...)
+	return err
+}
+
+// postgresText drops NUL characters, which PostgreSQL text cannot hold.
+// Only the lookup columns need it: the JSON records escape them.
+func postgresText(s string) string {
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// postgresSize is the disk space of the store's tables, indexes included.
+func postgresSize(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}) (int64, error) {
+	var size int64
+	err := q.QueryRow("SELECT COALESCE(SUM(pg_total_relation_size(to_regclass(t))), 0)::bigint FROM unnest($1::text[]) AS t", postgresTables).Scan(&size)
+	return size, err
+}
+
+// stale reports whether another server sharing the database has written
+// since this process last loaded it.
+func (b *postgresBackend) stale() (bool, error) {
+	var revision uint64
+	if err := b.db.QueryRow("SELECT revision FROM store_meta").Scan(&revision); err != nil {
+		return false, err
+	}
+	return revision != b.revision, nil
+}
+
+// vacuum reclaims the space of deleted rows for reuse and refreshes the
+// planner's statistics, without locking other servers out as VACUUM FULL
+// would.
+func (b *postgresBackend) vacuum() error {
+	_, err := b.db.Exec("VACUUM (ANALYZE) " + strings.Join(postgresTables, ", "))
+	return err
+}
+
+// List walks the updated_at index and the tags table, reading each
+// conversation's tags along with it.
+func (b *postgresBackend) List(query Query) ([]models.Conversation, error) {
+	clauses := []string{"NOT c.trashed"}
+	var args []any
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		clauses = append(clauses, fmt.Sprintf("c.updated_at >= $%d", len(args)))
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		clauses = append(clauses, fmt.Sprintf("c.updated_at < $%d", len(args)))
+	}
+	for _, tag := range query.Tags {
+		args = append(args, postgresText(tag))
+		clauses = append(clauses, fmt.Sprintf("c.id IN (SELECT conversation_id FROM conversation_tags WHERE tag = $%d)", len(args)))
+	}
+	listed, err := b.listWhere(clauses, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	return listed, nil
+}
+
+// listWhere returns the conversations that pass every one of clauses, on
+// conversations c, with their tags and without their messages, most
+// recently updated first.
+func (b *postgresBackend) listWhere(clauses []string, args []any) ([]models.Conversation, error) {
+	rows, err := b.db.Query(`SELECT c.data,
+			COALESCE((SELECT json_agg(t.tag ORDER BY t.position) FROM conversation_tags t WHERE t.conversation_id = c.id), '[]')
+		FROM conversations c WHERE `+strings.Join(clauses, " AND ")+`
+		ORDER BY c.updated_at DESC, c.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var listed []models.Conversation
+	for rows.Next() {
+		var data, tags []byte
+		if err := rows.Scan(&data, &tags); err != nil {
+			return nil, err
+		}
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(tags, &conversation.Tags); err != nil {
+			return nil, err
+		}
+		if len(conversation.Tags) == 0 {
+			conversation.Tags = nil
+		}
+		listed = append(listed, conversation)
+	}
+	return listed, rows.Err()
+}
+
+// Get reads the conversation, its messages and its tags from one snapshot.
+func (b *postgresBackend) Get(id string) (models.Conversation, error) {
+	conversation, err := b.get(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return models.Conversation{}, fmt.Errorf("%s: %w", b.name, err)
+	}
+	return conversation, err
+}
+
+func (b *postgresBackend) get(id string) (models.Conversation, error) {
+	var conversation models.Conversation
+	tx, err := b.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return conversation, err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRow("SELECT data FROM conversations WHERE id = $1 AND NOT trashed", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return conversation, ErrNotFound
+	}
+	if err != nil {
+		return conversation, err
+	}
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return models.Conversation{}, fmt.Errorf("conversation %s: %w", id, err)
+	}
+	err = loadRows(tx, "SELECT data FROM messages WHERE conversation_id = $1 ORDER BY position", func(data []byte) error {
+		var message models.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		conversation.Messages = append(conversation.Messages, message)
+		return nil
+	}, id)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	rows, err := tx.Query("SELECT tag FROM conversation_tags WHERE conversation_id = $1 ORDER BY position", id)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return models.Conversation{}, err
+		}
+		conversation.Tags = append(conversation.Tags, tag)
+	}
+	return conversation, rows.Err()
+}
+
+// Upsert and Delete count as writes like any other, so a server sharing
+// the database reloads after them.
+func (b *postgresBackend) Upsert(conversations []models.Conversation) error {
+	_, err := b.Save(Change{Conversations: conversations}, nil, nil)
+	return err
+}
+
+func (b *postgresBackend) Delete(ids []string) error {
+	_, err := b.Save(Change{Deleted: ids}, nil, nil)
+	return err
+}
+
+// Search matches terms with ILIKE against the title, the summary in the
+// record and the content of each message.
+func (b *postgresBackend) Search(terms []string) ([]models.Conversation, error) {
+	clauses := []string{"NOT c.trashed"}
+	var args []any
+	for _, term := range searchTerms(terms) {
+		args = append(args, "%"+postgresLikeEscaper.Replace(postgresText(term))+"%")
+		n := len(args)
+		clauses = append(clauses, fmt.Sprintf(`(c.title ILIKE $%d OR c.data->>'summary' ILIKE $%d
+			OR EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.data->>'content' ILIKE $%d))`, n, n, n))
+	}
+	found, err := b.listWhere(clauses, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	return found, nil
+}
+
+// postgresLikeEscaper escapes the characters LIKE patterns treat
+// specially, with LIKE's default escape character.
+var postgresLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (b *postgresBackend) Close() error {
+	return b.db.Close()
+}
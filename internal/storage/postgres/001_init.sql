@@ -0,0 +1,87 @@
+-- Every record is kept as its JSON encoding, the one the JSON store file
+-- uses, next to the columns it is looked up, sorted and filtered by. A
+-- conversation's messages and tags have tables of their own; the trash is
+-- the conversations marked trashed.
+
+-- store_meta has a single row counting writes, so each server sharing the
+-- database can tell when another one has written.
+CREATE TABLE store_meta (
+	id       boolean PRIMARY KEY DEFAULT true CHECK (id),
+	revision bigint NOT NULL,
+	modified timestamptz NOT NULL
+);
+INSERT INTO store_meta (revision, modified) VALUES (0, now());
+
+CREATE TABLE conversations (
+	id         text PRIMARY KEY,
+	title      text NOT NULL,
+	source     text NOT NULL,
+	archived   boolean NOT NULL,
+	starred    boolean NOT NULL,
+	trashed    boolean NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL,
+	data       json NOT NULL
+);
+CREATE INDEX conversations_updated_at ON conversations (updated_at DESC) WHERE NOT trashed;
+CREATE INDEX conversations_created_at ON conversations (created_at) WHERE NOT trashed;
+CREATE INDEX conversations_source ON conversations (source) WHERE source <> '';
+
+CREATE TABLE messages (
+	conversation_id text NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+	position        integer NOT NULL,
+	id              text NOT NULL,
+	author          text NOT NULL,
+	model           text NOT NULL,
+	created_at      timestamptz NOT NULL,
+	data            json NOT NULL,
+	PRIMARY KEY (conversation_id, position)
+);
+CREATE INDEX messages_created_at ON messages (created_at);
+CREATE INDEX messages_model ON messages (model) WHERE model <> '';
+
+CREATE TABLE conversation_tags (
+	conversation_id text NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+	position        integer NOT NULL,
+	tag             text NOT NULL,
+	PRIMARY KEY (conversation_id, position)
+);
+CREATE INDEX conversation_tags_tag ON conversation_tags (tag);
+
+CREATE TABLE link_checks (
+	url  text PRIMARY KEY,
+	data json NOT NULL
+);
+
+CREATE TABLE imports (
+	seq  bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	data json NOT NULL
+);
+
+CREATE TABLE summaries (
+	conversation_id text NOT NULL,
+	method          text NOT NULL,
+	data            json NOT NULL,
+	PRIMARY KEY (conversation_id, method)
+);
+
+CREATE TABLE embeddings (
+	conversation_id text NOT NULL,
+	model           text NOT NULL,
+	data            json NOT NULL,
+	PRIMARY KEY (conversation_id, model)
+);
+
+CREATE TABLE revisions (
+	conversation_id text NOT NULL,
+	number          integer NOT NULL,
+	data            json NOT NULL,
+	PRIMARY KEY (conversation_id, number)
+);
+
+CREATE TABLE shares (
+	token           text PRIMARY KEY,
+	conversation_id text NOT NULL,
+	data            json NOT NULL
+);
+CREATE INDEX shares_conversation_id ON shares (conversation_id);
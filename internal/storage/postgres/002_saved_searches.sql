@@ -0,0 +1,7 @@
+-- Saved searches are named views over the conversations, kept like the
+-- other records as their JSON encoding.
+
+CREATE TABLE saved_searches (
+	id   text PRIMARY KEY,
+	data json NOT NULL
+);
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Query picks the conversations a Backend's List returns: those updated
+// from From (inclusive) to To (exclusive), either end open when zero, that
+// carry every one of Tags. The trash is never listed.
+type Query struct {
+	From time.Time
+	To   time.Time
+	Tags []string
+}
+
+// IsEmpty reports whether q matches every listed conversation.
+func (q Query) IsEmpty() bool {
+	return q.From.IsZero() && q.To.IsZero() && len(q.Tags) == 0
+}
+
+// Match reports whether conversation passes q.
+func (q Query) Match(conversation models.Conversation) bool {
+	if !q.From.IsZero() && conversation.UpdatedAt.Before(q.From) || !q.To.IsZero() && !conversation.UpdatedAt.Before(q.To) {
+		return false
+	}
+	return hasTags(conversation.Tags, q.Tags)
+}
+
+// sortableTimeLayout formats times in UTC at a fixed width, so that the
+// strings sort as the times do, for engines that index them as text.
+const sortableTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func sortableTime(t time.Time) string {
+	return t.UTC().Format(sortableTimeLayout)
+}
+
+// matchesTerms reports whether every term appears, ignoring case, in the
+// title, summary or a message of conversation, which is what a Backend's
+// Search looks for. terms must be lower case.
+func matchesTerms(conversation models.Conversation, terms []string) bool {
+	for _, term := range terms {
+		found := strings.Contains(strings.ToLower(conversation.Title), term) || strings.Contains(strings.ToLower(conversation.Summary), term) ||
+			slices.ContainsFunc(conversation.Messages, func(msg models.Message) bool {
+				return strings.Contains(strings.ToLower(msg.Content), term)
+			})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchTerms lower-cases terms and drops the empty ones.
+func searchTerms(terms []string) []string {
+	out := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			out = append(out, term)
+		}
+	}
+	return out
+}
+
+// sortByUpdated orders conversations most recently updated first, then by
+// ID, the order a Backend's List and Search return.
+func sortByUpdated(conversations []models.Conversation) {
+	sort.Slice(conversations, func(i, j int) bool {
+		if !conversations[i].UpdatedAt.Equal(conversations[j].UpdatedAt) {
+			return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+		}
+		return conversations[i].ID < conversations[j].ID
+	})
+}
+
+// withoutMessages returns conversations with their messages left out, as
+// List and Search return them.
+func withoutMessages(conversations []models.Conversation) []models.Conversation {
+	for i := range conversations {
+		conversations[i].Messages = nil
+	}
+	return conversations
+}
+
+// upsertContents returns contents with conversations written into the
+// list and out of the trash, for backends that rewrite everything.
+func upsertContents(contents Contents, conversations []models.Conversation) Contents {
+	written := make(map[string]models.Conversation, len(conversations))
+	for _, conversation := range conversations {
+		written[conversation.ID] = conversation
+	}
+	contents.Trash = slices.DeleteFunc(contents.Trash, func(conversation models.Conversation) bool {
+		_, ok := written[conversation.ID]
+		return ok
+	})
+	for i, conversation := range contents.Conversations {
+		if replacement, ok := written[conversation.ID]; ok {
+			contents.Conversations[i] = replacement
+			delete(written, conversation.ID)
+		}
+	}
+	for _, conversation := range conversations {
+		if _, ok := written[conversation.ID]; ok {
+			contents.Conversations = append(contents.Conversations, conversation)
+			delete(written, conversation.ID)
+		}
+	}
+	return contents
+}
+
+// deleteContents returns contents without the conversations ids names and
+// the records that belong to them, for backends that rewrite everything.
+func deleteContents(contents Contents, ids []string) Contents {
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	gone := func(conversation models.Conversation) bool { return deleted[conversation.ID] }
+	contents.Conversations = slices.DeleteFunc(contents.Conversations, gone)
+	contents.Trash = slices.DeleteFunc(contents.Trash, gone)
+	contents.Summaries = slices.DeleteFunc(contents.Summaries, func(summary models.Summary) bool { return deleted[summary.ConversationID] })
+	contents.Embeddings = slices.DeleteFunc(contents.Embeddings, func(embedding models.Embedding) bool { return deleted[embedding.ConversationID] })
+	contents.Revisions = slices.DeleteFunc(contents.Revisions, func(revision models.Revision) bool { return deleted[revision.ConversationID] })
+	contents.Shares = slices.DeleteFunc(contents.Shares, func(share models.Share) bool { return deleted[share.ConversationID] })
+	return contents
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"zatGPT/internal/models"
+)
+
+// ErrQuotaExceeded is matched (with errors.Is) by every *QuotaError.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Limits caps what a store accepts; zero fields are unlimited. There are no
+// user accounts yet, so the limits apply to the whole archive.
+// MaxStoreBytes counts the store file plus attachment blobs, but not cached
+// thumbnails, which can always be regenerated.
+type Limits struct {
+	MaxConversations   int   `json:"maxConversations,omitempty"`
+	MaxStoreBytes      int64 `json:"maxStoreBytes,omitempty"`
+	MaxAttachmentBytes int64 `json:"maxAttachmentBytes,omitempty"`
+}
+
+// QuotaError reports which limit a write would have exceeded. Nothing is
+// written when it is returned.
+type QuotaError struct {
+	// Limit is "conversations", "storeBytes" or "attachmentBytes".
+	Limit     string
+	Max       int64
+	Requested int64
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Limit {
+	case "conversations":
+		return fmt.Sprintf("%s: the archive is limited to %d conversations (this write would make %d)", ErrQuotaExceeded, e.Max, e.Requested)
+	case "attachmentBytes":
+		return fmt.Sprintf("%s: attachments are limited to %s each", ErrQuotaExceeded, FormatSize(e.Max))
+	default:
+		return fmt.Sprintf("%s: the archive is limited to %s (this write would make it %s)", ErrQuotaExceeded, FormatSize(e.Max), FormatSize(e.Requested))
+	}
+}
+
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// Usage reports how much of the store is in use, alongside its limits.
+type Usage struct {
+	Conversations   int    `json:"conversations"`
+	Attachments     int    `json:"attachments"`
+	StoreBytes      int64  `json:"storeBytes"`
+	AttachmentBytes int64  `json:"attachmentBytes"`
+	TotalBytes      int64  `json:"totalBytes"`
+	Limits          Limits `json:"limits"`
+}
+
+// Usage returns the current usage figures.
+func (s *Store) Usage() Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Usage{
+		Conversations:   len(s.conversations),
+		Attachments:     s.blobCount,
+		StoreBytes:      s.fileBytes,
+		AttachmentBytes: s.blobBytes,
+		TotalBytes:      s.fileBytes + s.blobBytes,
+		Limits:          s.limits,
+	}
+}
+
+// checkConversationsLocked rejects a write that would push the number of
+// conversations over the limit. Updates to existing conversations always
+// pass.
+func (s *Store) checkConversationsLocked(conversations []models.Conversation) error {
+	max := s.limits.MaxConversations
+	if max <= 0 {
+		return nil
+	}
+	added := make(map[string]bool)
+	for _, conversation := range conversations {
+		if _, ok := s.conversations[conversation.ID]; !ok {
+			added[conversation.ID] = true
+		}
+	}
+	if total := len(s.conversations) + len(added); len(added) > 0 && total > max {
+		return &QuotaError{Limit: "conversations", Max: int64(max), Requested: int64(total)}
+	}
+	return nil
+}
+
+// checkSizeLocked rejects growth past MaxStoreBytes. A write that does not
+// grow the store always passes, so an archive that is already over its limit
+// (say, after the limit was lowered) can still be edited down.
+func (s *Store) checkSizeLocked(fileBytes, blobBytes int64) error {
+	max := s.limits.MaxStoreBytes
+	if max <= 0 {
+		return nil
+	}
+	total := fileBytes + blobBytes
+	if total > max && total > s.fileBytes+s.blobBytes {
+		return &QuotaError{Limit: "storeBytes", Max: max, Requested: total}
+	}
+	return nil
+}
+
+// attachmentQuota returns the check SaveAttachment runs once a blob of n
+// bytes has been written to a temporary file, before it replaces path.
+func (s *Store) attachmentQuota(path string) func(n int64) error {
+	return func(n int64) error {
+		if max := s.limits.MaxAttachmentBytes; max > 0 && n > max {
+			return &QuotaError{Limit: "attachmentBytes", Max: max, Requested: n}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		previous, exists := fileSize(path)
+		if err := s.checkSizeLocked(s.fileBytes, s.blobBytes-previous+n); err != nil {
+			return err
+		}
+		if !exists {
+			s.blobCount++
+		}
+		s.blobBytes += n - previous
+		return nil
+	}
+}
+
+// measureAttachments totals the blobs already in the attachment directory.
+func (s *Store) measureAttachments() {
+	entries, err := os.ReadDir(s.AttachmentDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			s.blobCount++
+			s.blobBytes += info.Size()
+		}
+	}
+}
+
+func fileSize(path string) (int64, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize reads a byte count such as "500MB", "1.5GB" or "1048576". Units
+// are binary (1KB is 1024 bytes); an empty string is zero, meaning no limit.
+func ParseSize(raw string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	if value == "" {
+		return 0, nil
+	}
+	factor := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 500MB or 2GB)", raw)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// FormatSize renders a byte count in the largest unit it reaches.
+func FormatSize(n int64) string {
+	for _, unit := range sizeUnits {
+		if n >= unit.factor && unit.factor > 1 {
+			return strings.TrimSuffix(strconv.FormatFloat(float64(n)/float64(unit.factor), 'f', 1, 64), ".0") + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
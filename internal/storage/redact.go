@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"slices"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/redact"
+	"zatGPT/internal/tokenizer"
+)
+
+// Redact replaces what r finds in a conversation with placeholders and
+// returns the conversation with what was replaced this time. Its tokens
+// and topics are picked again; its kept revisions and cached summaries are
+// redacted too, since they hold the same text, and no revision of the
+// unredacted version is kept. The
+// ContentHash is left as the import set it, so importing the same export
+// again does not bring the redacted text back. Nothing is saved when
+// nothing was found.
+func (s *Store) Redact(id string, r *redact.Redactor) (models.Conversation, []models.Redaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Conversation{}, nil, ErrReadOnly
+	}
+	convo, ok := s.conversations[id]
+	if !ok {
+		return models.Conversation{}, nil, ErrNotFound
+	}
+
+	found := r.Conversation(&convo)
+	change := Change{}
+	if len(found) > 0 {
+		// Counting writes the messages' Tokens in place.
+		convo.Messages = slices.Clone(convo.Messages)
+		tokenizer.CountConversation(&convo)
+		convo.Topics = s.topicsLocked().Extract(convo)
+		convo.UpdatedAt = time.Now().UTC()
+		convo = s.putLocked(convo)
+		change.Conversations = []models.Conversation{convo}
+	}
+
+	var revisions []models.Revision
+	for i, revision := range s.revisions[id] {
+		if !r.Revision(&revision) {
+			continue
+		}
+		if revisions == nil {
+			revisions = slices.Clone(s.revisions[id])
+		}
+		revisions[i] = revision
+		change.Revisions = append(change.Revisions, revision)
+	}
+	if revisions != nil {
+		s.revisions[id] = revisions
+	}
+
+	for key, summary := range s.summaries {
+		if summary.ConversationID != id {
+			continue
+		}
+		text := r.Text(summary.Text, nil)
+		if text == summary.Text {
+			continue
+		}
+		summary.Text = text
+		s.summaries[key] = summary
+		change.Summaries = append(change.Summaries, summary)
+	}
+
+	if change.Conversations == nil && change.Revisions == nil && change.Summaries == nil {
+		return convo, found, nil
+	}
+	if err := s.saveLocked(change); err != nil {
+		return models.Conversation{}, nil, err
+	}
+	return convo, found, nil
+}
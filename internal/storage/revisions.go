@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"encoding/binary"
+	"slices"
+	"sort"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// MaxRevisions is how many earlier versions of a conversation the store
+// keeps; older ones are dropped as new ones arrive.
+const MaxRevisions = 20
+
+// Revisions returns the kept earlier versions of a conversation, oldest
+// first, followed by its current version, which carries the next number
+// and no ReplacedAt.
+func (s *Store) Revisions(id string) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.conversations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	kept := s.revisions[id]
+	out := make([]models.Revision, 0, len(kept)+1)
+	out = append(out, kept...)
+	current := models.Revision{ConversationID: id, Number: 1, Title: item.Title, Messages: item.Messages, UpdatedAt: item.UpdatedAt}
+	if len(kept) > 0 {
+		current.Number = kept[len(kept)-1].Number + 1
+	}
+	return append(out, current), nil
+}
+
+// revised reports whether next changes the title or the messages of
+// previous, the changes worth a revision.
+func revised(previous, next models.Conversation) bool {
+	return previous.Title != next.Title || !slices.EqualFunc(previous.Messages, next.Messages, func(a, b models.Message) bool {
+		return a.ID == b.ID && a.Author == b.Author && a.Content == b.Content
+	})
+}
+
+// nextRevisionLocked turns previous into the revision after its
+// conversation's latest, counting the pending ones not yet added.
+func (s *Store) nextRevisionLocked(previous models.Conversation, pending []models.Revision, now time.Time) models.Revision {
+	number := 1
+	if kept := s.revisions[previous.ID]; len(kept) > 0 {
+		number = kept[len(kept)-1].Number + 1
+	}
+	for _, revision := range pending {
+		if revision.ConversationID == previous.ID {
+			number = revision.Number + 1
+		}
+	}
+	return models.Revision{
+		ConversationID: previous.ID,
+		Number:         number,
+		Title:          previous.Title,
+		Messages:       slices.Clone(previous.Messages),
+		UpdatedAt:      previous.UpdatedAt,
+		ReplacedAt:     now,
+	}
+}
+
+// addRevisionsLocked appends revisions, in number order, to their
+// conversations' histories, dropping all but the last MaxRevisions.
+func (s *Store) addRevisionsLocked(revisions []models.Revision) {
+	for _, revision := range revisions {
+		kept := append(s.revisions[revision.ConversationID], revision)
+		if len(kept) > MaxRevisions {
+			kept = slices.Clone(kept[len(kept)-MaxRevisions:])
+		}
+		s.revisions[revision.ConversationID] = kept
+	}
+}
+
+// dropRevisionsLocked takes back revisions added by addRevisionsLocked
+// when their save failed. Revisions trimmed to make room are not restored.
+func (s *Store) dropRevisionsLocked(revisions []models.Revision) {
+	for _, revision := range revisions {
+		s.revisions[revision.ConversationID] = slices.DeleteFunc(s.revisions[revision.ConversationID], func(kept models.Revision) bool {
+			return kept.Number == revision.Number
+		})
+		if len(s.revisions[revision.ConversationID]) == 0 {
+			delete(s.revisions, revision.ConversationID)
+		}
+	}
+}
+
+func sortRevisions(revisions []models.Revision) {
+	sort.Slice(revisions, func(i, j int) bool {
+		a, b := revisions[i], revisions[j]
+		if a.ConversationID == b.ConversationID {
+			return a.Number < b.Number
+		}
+		return a.ConversationID < b.ConversationID
+	})
+}
+
+func boltRevisionKey(id string, number int) []byte {
+	return binary.BigEndian.AppendUint64([]byte(id+"\x00"), uint64(number))
+}
+
+// RevisionDiff is what changed between two versions of a conversation.
+// Messages are matched by ID: Added are only in To, Removed only in From,
+// and Changed in both with different content.
+type RevisionDiff struct {
+	From         int              `json:"from"`
+	To           int              `json:"to"`
+	TitleChanged bool             `json:"titleChanged"`
+	OldTitle     string           `json:"oldTitle,omitempty"`
+	NewTitle     string           `json:"newTitle,omitempty"`
+	Added        []models.Message `json:"added"`
+	Removed      []models.Message `json:"removed"`
+	Changed      []MessageChange  `json:"changed"`
+}
+
+// MessageChange is a message whose content differs between two versions.
+type MessageChange struct {
+	ID     string `json:"id"`
+	Author string `json:"author"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// DiffRevisions compares two versions of a conversation.
+func DiffRevisions(from, to models.Revision) RevisionDiff {
+	diff := RevisionDiff{
+		From:    from.Number,
+		To:      to.Number,
+		Added:   []models.Message{},
+		Removed: []models.Message{},
+		Changed: []MessageChange{},
+	}
+	if from.Title != to.Title {
+		diff.TitleChanged = true
+		diff.OldTitle = from.Title
+		diff.NewTitle = to.Title
+	}
+
+	before := make(map[string]models.Message, len(from.Messages))
+	for _, msg := range from.Messages {
+		before[msg.ID] = msg
+	}
+	after := make(map[string]bool, len(to.Messages))
+	for _, msg := range to.Messages {
+		after[msg.ID] = true
+		old, ok := before[msg.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, msg)
+		case old.Content != msg.Content:
+			diff.Changed = append(diff.Changed, MessageChange{ID: msg.ID, Author: msg.Author, Before: old.Content, After: msg.Content})
+		}
+	}
+	for _, msg := range from.Messages {
+		if !after[msg.ID] {
+			diff.Removed = append(diff.Removed, msg)
+		}
+	}
+	return diff
+}
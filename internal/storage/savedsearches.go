@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"zatGPT/internal/ids"
+	"zatGPT/internal/models"
+)
+
+// ErrSavedSearchNotFound is returned for a saved search ID the store does
+// not hold.
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// SavedSearches returns every saved search, by name.
+func (s *Store) SavedSearches() []models.SavedSearch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]models.SavedSearch, 0, len(s.savedSearches))
+	for _, search := range s.savedSearches {
+		out = append(out, search)
+	}
+	sortSavedSearches(out)
+	return out
+}
+
+// SavedSearch returns the saved search with id.
+func (s *Store) SavedSearch(id string) (models.SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search, ok := s.savedSearches[id]
+	if !ok {
+		return models.SavedSearch{}, ErrSavedSearchNotFound
+	}
+	return search, nil
+}
+
+// CreateSavedSearch stores search under a new ID, its tags normalised.
+func (s *Store) CreateSavedSearch(search models.SavedSearch) (models.SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.SavedSearch{}, ErrReadOnly
+	}
+	now := time.Now().UTC()
+	search.ID = ids.New()
+	normalizeSearchTags(&search)
+	search.CreatedAt, search.UpdatedAt = now, now
+	s.savedSearches[search.ID] = search
+	if err := s.saveLocked(Change{SavedSearches: []models.SavedSearch{search}}); err != nil {
+		delete(s.savedSearches, search.ID)
+		return models.SavedSearch{}, err
+	}
+	return search, nil
+}
+
+// UpdateSavedSearch replaces the name, query and filters of the saved
+// search with search.ID, keeping when it was created.
+func (s *Store) UpdateSavedSearch(search models.SavedSearch) (models.SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.SavedSearch{}, ErrReadOnly
+	}
+	previous, ok := s.savedSearches[search.ID]
+	if !ok {
+		return models.SavedSearch{}, ErrSavedSearchNotFound
+	}
+	normalizeSearchTags(&search)
+	search.CreatedAt, search.UpdatedAt = previous.CreatedAt, time.Now().UTC()
+	s.savedSearches[search.ID] = search
+	if err := s.saveLocked(Change{SavedSearches: []models.SavedSearch{search}}); err != nil {
+		s.savedSearches[search.ID] = previous
+		return models.SavedSearch{}, err
+	}
+	return search, nil
+}
+
+// DeleteSavedSearch removes the saved search with id.
+func (s *Store) DeleteSavedSearch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	search, ok := s.savedSearches[id]
+	if !ok {
+		return ErrSavedSearchNotFound
+	}
+	delete(s.savedSearches, id)
+	if err := s.saveLocked(Change{UnsavedSearches: []string{id}}); err != nil {
+		s.savedSearches[id] = search
+		return err
+	}
+	return nil
+}
+
+// normalizeSearchTags normalises the tags a saved search filters by,
+// leaving none as nil so its filters stay empty.
+func normalizeSearchTags(search *models.SavedSearch) {
+	if search.Filters.Tags = NormalizeTags(search.Filters.Tags); len(search.Filters.Tags) == 0 {
+		search.Filters.Tags = nil
+	}
+}
+
+// sortSavedSearches orders saved searches by name, then ID.
+func sortSavedSearches(searches []models.SavedSearch) {
+	sort.Slice(searches, func(i, j int) bool {
+		if searches[i].Name == searches[j].Name {
+			return searches[i].ID < searches[j].ID
+		}
+		return searches[i].Name < searches[j].Name
+	})
+}
@@ -0,0 +1,577 @@
+package storage
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+)
+
+// Search result fields.
+const (
+	FieldTitle   = "title"
+	FieldSummary = "summary"
+	FieldMessage = "message"
+)
+
+// maxHitSnippets caps the snippets returned per conversation; MessageIDs
+// still lists every matching message.
+const maxHitSnippets = 3
+
+// snippetRadius is roughly how many characters of context a snippet keeps
+// on each side of its first match.
+const snippetRadius = 80
+
+// SearchHit is one conversation found by Search. Conversation carries no
+// messages or tree, as in List.
+type SearchHit struct {
+	Conversation models.Conversation `json:"conversation"`
+	Score        int                 `json:"score"`
+	MessageIDs   []string            `json:"messageIds"`
+	Snippets     []SearchSnippet     `json:"snippets"`
+}
+
+// SearchSnippet is an excerpt around a match. Text is HTML-escaped with
+// every match wrapped in <mark>, so it can be inserted into a page as is.
+type SearchSnippet struct {
+	Field     string `json:"field"`
+	MessageID string `json:"messageId,omitempty"`
+	Text      string `json:"text"`
+}
+
+// Field weights: a match in the title counts for more than one in the
+// summary, which counts for more than one in a message.
+const (
+	titleWeight   = 3
+	summaryWeight = 2
+	messageWeight = 1
+)
+
+// searchIndex maps each word of a conversation's title, summary and
+// messages to the conversations containing it and a weighted count of its
+// occurrences, so Search neither reads conversations that lack a word nor
+// rescans the ones that have it to rank them. titles does the same for the
+// words of titles and summaries alone, the vocabulary FuzzySearch looks
+// through.
+type searchIndex struct {
+	postings map[string]map[string]int
+	titles   map[string]map[string]int
+}
+
+func newSearchIndex(conversations map[string]models.Conversation) *searchIndex {
+	index := &searchIndex{postings: make(map[string]map[string]int), titles: make(map[string]map[string]int)}
+	for _, conversation := range conversations {
+		index.add(conversation)
+	}
+	return index
+}
+
+func (x *searchIndex) add(conversation models.Conversation) {
+	addPostings(x.postings, conversation.ID, conversationWords(conversation))
+	addPostings(x.titles, conversation.ID, titleWords(conversation))
+}
+
+func (x *searchIndex) remove(conversation models.Conversation) {
+	removePostings(x.postings, conversation.ID, conversationWords(conversation))
+	removePostings(x.titles, conversation.ID, titleWords(conversation))
+}
+
+func addPostings(postings map[string]map[string]int, id string, words map[string]int) {
+	for word, score := range words {
+		ids, ok := postings[word]
+		if !ok {
+			ids = make(map[string]int)
+			postings[word] = ids
+		}
+		ids[id] = score
+	}
+}
+
+func removePostings(postings map[string]map[string]int, id string, words map[string]int) {
+	for word := range words {
+		ids := postings[word]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(postings, word)
+		}
+	}
+}
+
+// candidates returns the conversations containing every word, with the sum
+// of the words' weighted counts.
+func (x *searchIndex) candidates(words []string) map[string]int {
+	var smallest map[string]int
+	for _, word := range words {
+		ids := x.postings[word]
+		if len(ids) == 0 {
+			return nil
+		}
+		if smallest == nil || len(ids) < len(smallest) {
+			smallest = ids
+		}
+	}
+
+	out := make(map[string]int, len(smallest))
+	for id := range smallest {
+		score := 0
+		for _, word := range words {
+			n, ok := x.postings[word][id]
+			if !ok {
+				score = -1
+				break
+			}
+			score += n
+		}
+		if score >= 0 {
+			out[id] = score
+		}
+	}
+	return out
+}
+
+// conversationWords returns the weighted count of every word in the
+// conversation.
+func conversationWords(conversation models.Conversation) map[string]int {
+	words := titleWords(conversation)
+	for _, msg := range conversation.Messages {
+		addWords(words, msg.Content, messageWeight)
+	}
+	return words
+}
+
+// titleWords returns the weighted count of every word in the
+// conversation's title and summary.
+func titleWords(conversation models.Conversation) map[string]int {
+	words := make(map[string]int)
+	addWords(words, conversation.Title, titleWeight)
+	addWords(words, conversation.Summary, summaryWeight)
+	return words
+}
+
+func addWords(words map[string]int, text string, weight int) {
+	for _, span := range wordSpans(text) {
+		words[strings.ToLower(text[span[0]:span[1]])] += weight
+	}
+}
+
+// wordSpans returns the byte ranges of the runs of letters and digits in
+// text: the words the index and phrase matching work with.
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// searchWords splits text into its lower-case words.
+func searchWords(text string) []string {
+	spans := wordSpans(text)
+	words := make([]string, len(spans))
+	for i, span := range spans {
+		words[i] = strings.ToLower(text[span[0]:span[1]])
+	}
+	return words
+}
+
+// indexLocked returns the search index, building it on first use. Callers
+// hold s.mu for reading at least; the index is then only changed under the
+// write lock, when it exists.
+func (s *Store) indexLocked() *searchIndex {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if s.index == nil {
+		s.index = newSearchIndex(s.conversations)
+	}
+	return s.index
+}
+
+// putLocked stores conversation with its Stats worked out, keeping the
+// search index and topic corpus up to date, and returns it as stored.
+func (s *Store) putLocked(conversation models.Conversation) models.Conversation {
+	conversation.Stats = conversationStats(conversation)
+	previous, ok := s.conversations[conversation.ID]
+	if s.index != nil {
+		if ok {
+			s.index.remove(previous)
+		}
+		s.index.add(conversation)
+	}
+	if s.topics != nil {
+		if ok {
+			s.topics.Remove(previous)
+		}
+		s.topics.Add(conversation)
+	}
+	s.conversations[conversation.ID] = conversation
+	return conversation
+}
+
+// removeLocked deletes a conversation, keeping the search index and topic
+// corpus up to date.
+func (s *Store) removeLocked(id string) {
+	if previous, ok := s.conversations[id]; ok {
+		if s.index != nil {
+			s.index.remove(previous)
+		}
+		if s.topics != nil {
+			s.topics.Remove(previous)
+		}
+	}
+	delete(s.conversations, id)
+}
+
+// Search finds the conversations whose title, summary or messages contain
+// every term, matched case-insensitively as whole words; a term of several
+// words ("use terraform", or http.ListenAndServe) must appear as a phrase.
+// match, when non-nil, filters the results further. Hits are ordered by
+// score, the weighted number of occurrences of the words, then by UpdatedAt
+// descending; offset and limit select the window returned (limit <= 0
+// returns them all), along with the total number of hits. It stops with
+// ctx's error once ctx is done.
+func (s *Store) Search(ctx context.Context, searchTerms []string, match func(models.Conversation) bool, offset, limit int) ([]SearchHit, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	words, phrases := parseTerms(searchTerms)
+	hits, err := s.searchLocked(ctx, words, phrases, match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(hits)
+	start, end := window(total, offset, limit)
+	page := make([]SearchHit, 0, end-start)
+	for _, hit := range hits[start:end] {
+		page = append(page, describeHit(hit, words, phrases))
+	}
+	return page, total, nil
+}
+
+// MessageHit is one message found by SearchMessages. Offset and Length give
+// the first match within the message's content, in characters (Unicode
+// code points), so a viewer can scroll to it; Snippet is an excerpt around
+// it, highlighted as in SearchSnippet.
+type MessageHit struct {
+	ConversationID string `json:"conversationId"`
+	Title          string `json:"title"`
+	MessageID      string `json:"messageId,omitempty"`
+	Index          int    `json:"index"`
+	Author         string `json:"author"`
+	Snippet        string `json:"snippet"`
+	Offset         int    `json:"offset"`
+	Length         int    `json:"length"`
+}
+
+// SearchMessages is Search returning one hit per matching message rather
+// than per conversation: the conversations are found and ranked as in
+// Search, and each one's messages that contain a term follow in transcript
+// order. A conversation that matches only in its title or summary has no
+// message hits. offset and limit page through the messages.
+func (s *Store) SearchMessages(ctx context.Context, searchTerms []string, match func(models.Conversation) bool, offset, limit int) ([]MessageHit, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	words, phrases := parseTerms(searchTerms)
+	hits, err := s.searchLocked(ctx, words, phrases, match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	highlighted := highlightedTerms(words, phrases)
+	messages := []MessageHit{}
+	for i, hit := range hits {
+		if i%scanCheckEvery == scanCheckEvery-1 {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+		}
+		conversation := hit.Conversation
+		for i, msg := range conversation.Messages {
+			matches := findTerms(msg.Content, highlighted)
+			if len(matches) == 0 {
+				continue
+			}
+			first := matches[0]
+			messages = append(messages, MessageHit{
+				ConversationID: conversation.ID,
+				Title:          conversation.Title,
+				MessageID:      msg.ID,
+				Index:          i,
+				Author:         msg.Author,
+				Snippet:        highlight(msg.Content, matches),
+				Offset:         utf8.RuneCountInString(msg.Content[:first[0]]),
+				Length:         utf8.RuneCountInString(msg.Content[first[0]:first[1]]),
+			})
+		}
+	}
+
+	total := len(messages)
+	start, end := window(total, offset, limit)
+	return messages[start:end], total, nil
+}
+
+// parseTerms splits search terms into their words, and the words of the
+// terms that must match as phrases.
+func parseTerms(searchTerms []string) (words []string, phrases [][]string) {
+	for _, term := range searchTerms {
+		termWords := searchWords(term)
+		switch len(termWords) {
+		case 0:
+			continue
+		case 1:
+		default:
+			phrases = append(phrases, termWords)
+		}
+		words = append(words, termWords...)
+	}
+	return words, phrases
+}
+
+// searchLocked returns every conversation holding the words and phrases
+// that match accepts, in result order. Callers hold s.mu for reading.
+func (s *Store) searchLocked(ctx context.Context, words []string, phrases [][]string, match func(models.Conversation) bool) ([]SearchHit, error) {
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var hits []SearchHit
+	scanned := 0
+	for id, score := range s.indexLocked().candidates(words) {
+		if scanned++; scanned%scanCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		conversation := s.conversations[id]
+		if match != nil && !match(conversation) {
+			continue
+		}
+		// The index only knows the phrase's words are all there somewhere.
+		if len(phrases) > 0 && !hasPhrases(conversation, phrases) {
+			continue
+		}
+		hits = append(hits, SearchHit{Conversation: conversation, Score: score})
+	}
+	sortHits(hits)
+	return hits, nil
+}
+
+// sortHits orders hits by score, then by UpdatedAt descending.
+func sortHits(hits []SearchHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i].Conversation, hits[j].Conversation
+		switch {
+		case hits[i].Score != hits[j].Score:
+			return hits[i].Score > hits[j].Score
+		case !a.UpdatedAt.Equal(b.UpdatedAt):
+			return a.UpdatedAt.After(b.UpdatedAt)
+		default:
+			return a.ID < b.ID
+		}
+	})
+}
+
+// window returns the bounds of the results offset and limit select out of
+// total; limit <= 0 means all of them.
+func window(total, offset, limit int) (start, end int) {
+	start = min(max(offset, 0), total)
+	end = total
+	if limit > 0 {
+		end = min(start+limit, total)
+	}
+	return start, end
+}
+
+// searchField is one searchable text of a conversation.
+type searchField struct {
+	name, messageID, text string
+}
+
+func searchFields(conversation models.Conversation) []searchField {
+	fields := []searchField{
+		{name: FieldTitle, text: conversation.Title},
+		{name: FieldSummary, text: conversation.Summary},
+	}
+	for _, msg := range conversation.Messages {
+		fields = append(fields, searchField{name: FieldMessage, messageID: msg.ID, text: msg.Content})
+	}
+	return fields
+}
+
+func hasPhrases(conversation models.Conversation, phrases [][]string) bool {
+	found := make([]bool, len(phrases))
+	remaining := len(phrases)
+	for _, field := range searchFields(conversation) {
+		spans := wordSpans(field.text)
+		for i, phrase := range phrases {
+			if !found[i] && len(findPhrase(field.text, spans, phrase)) > 0 {
+				found[i] = true
+				remaining--
+			}
+		}
+		if remaining == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// findPhrase returns the byte ranges where the words of phrase appear one
+// after another in text, whose word spans are given.
+func findPhrase(text string, spans [][2]int, phrase []string) [][2]int {
+	var out [][2]int
+	for i := 0; i+len(phrase) <= len(spans); i++ {
+		matched := true
+		for k, word := range phrase {
+			span := spans[i+k]
+			if !strings.EqualFold(text[span[0]:span[1]], word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, [2]int{spans[i][0], spans[i+len(phrase)-1][1]})
+		}
+	}
+	return out
+}
+
+// describeHit fills in the matching messages and snippets of a hit. Every
+// word of the query is highlighted, and phrases as a whole.
+func describeHit(hit SearchHit, words []string, phrases [][]string) SearchHit {
+	highlighted := highlightedTerms(words, phrases)
+
+	hit.MessageIDs = []string{}
+	hit.Snippets = []SearchSnippet{}
+	for _, field := range searchFields(hit.Conversation) {
+		matches := findTerms(field.text, highlighted)
+		if len(matches) == 0 {
+			continue
+		}
+		if field.messageID != "" {
+			hit.MessageIDs = append(hit.MessageIDs, field.messageID)
+		}
+		if len(hit.Snippets) < maxHitSnippets {
+			hit.Snippets = append(hit.Snippets, SearchSnippet{
+				Field:     field.name,
+				MessageID: field.messageID,
+				Text:      highlight(field.text, matches),
+			})
+		}
+	}
+
+	hit.Conversation.Messages = nil
+	hit.Conversation.Tree = nil
+	hit.Conversation.Stats = nil
+	return hit
+}
+
+// highlightedTerms lists what a snippet marks: every word of the query on
+// its own, and each phrase as a whole.
+func highlightedTerms(words []string, phrases [][]string) [][]string {
+	highlighted := make([][]string, 0, len(words)+len(phrases))
+	for _, word := range words {
+		highlighted = append(highlighted, []string{word})
+	}
+	return append(highlighted, phrases...)
+}
+
+// findTerms returns the sorted, non-overlapping byte ranges of text that
+// match one of terms, or nil.
+func findTerms(text string, terms [][]string) [][2]int {
+	spans := wordSpans(text)
+	var matches [][2]int
+	for _, phrase := range terms {
+		matches = append(matches, findPhrase(text, spans, phrase)...)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	return mergeRanges(matches)
+}
+
+// mergeRanges sorts byte ranges and joins overlapping ones, so a word that
+// is also part of a highlighted phrase is marked once.
+func mergeRanges(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	out := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &out[len(out)-1]
+		if r[0] < last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// highlight cuts an excerpt around the first match and marks every match
+// inside it; matches must be sorted and must not overlap.
+func highlight(text string, matches [][2]int) string {
+	start := wordBoundary(text, matches[0][0]-snippetRadius, false)
+	end := wordBoundary(text, matches[0][1]+snippetRadius, true)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < pos || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// wordBoundary moves i outward to a nearby space, so an excerpt does not
+// start or end mid-word, or failing that to the start of a rune.
+func wordBoundary(text string, i int, forward bool) int {
+	const slack = 20
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(text) {
+		return len(text)
+	}
+	if forward {
+		if j := strings.IndexAny(text[i:min(i+slack, len(text))], " \n\t"); j >= 0 {
+			return i + j
+		}
+	} else {
+		from := max(i-slack, 0)
+		if j := strings.LastIndexAny(text[from:i], " \n\t"); j >= 0 {
+			return from + j + 1
+		}
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
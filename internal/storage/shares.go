@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"crypto/rand"
+	"errors"
+	"sort"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// ErrShareNotFound is returned for a share token that was never issued or
+// has been revoked.
+var ErrShareNotFound = errors.New("share not found")
+
+// ErrShareExpired is returned by SharedConversation for a share past its
+// expiry.
+var ErrShareExpired = errors.New("share expired")
+
+// Share creates a public link to conversation id that expires at expiresAt;
+// a zero expiresAt never expires. The token is 128 random bits.
+func (s *Store) Share(id string, expiresAt time.Time) (models.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Share{}, ErrReadOnly
+	}
+	if _, ok := s.conversations[id]; !ok {
+		return models.Share{}, ErrNotFound
+	}
+	share := models.Share{
+		Token:          rand.Text(),
+		ConversationID: id,
+		CreatedAt:      time.Now().UTC(),
+		ExpiresAt:      expiresAt.UTC(),
+	}
+	if expiresAt.IsZero() {
+		share.ExpiresAt = time.Time{}
+	}
+	s.shares[share.Token] = share
+	if err := s.saveLocked(Change{Shares: []models.Share{share}}); err != nil {
+		delete(s.shares, share.Token)
+		return models.Share{}, err
+	}
+	return share, nil
+}
+
+// Shares returns the shares of conversation id, or of every conversation
+// when id is empty, newest first. Expired shares are included until they
+// are revoked.
+func (s *Store) Shares(id string) []models.Share {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]models.Share, 0)
+	for _, share := range s.shares {
+		if id == "" || share.ConversationID == id {
+			out = append(out, share)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].Token < out[j].Token
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out
+}
+
+// SetShareExpiry moves the expiry of the share with token to expiresAt; a
+// zero expiresAt makes it never expire.
+func (s *Store) SetShareExpiry(token string, expiresAt time.Time) (models.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Share{}, ErrReadOnly
+	}
+	previous, ok := s.shares[token]
+	if !ok {
+		return models.Share{}, ErrShareNotFound
+	}
+	share := previous
+	share.ExpiresAt = time.Time{}
+	if !expiresAt.IsZero() {
+		share.ExpiresAt = expiresAt.UTC()
+	}
+	s.shares[token] = share
+	if err := s.saveLocked(Change{Shares: []models.Share{share}}); err != nil {
+		s.shares[token] = previous
+		return models.Share{}, err
+	}
+	return share, nil
+}
+
+// Unshare revokes the share with token.
+func (s *Store) Unshare(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	share, ok := s.shares[token]
+	if !ok {
+		return ErrShareNotFound
+	}
+	delete(s.shares, token)
+	if err := s.saveLocked(Change{Unshared: []models.Share{share}}); err != nil {
+		s.shares[token] = share
+		return err
+	}
+	return nil
+}
+
+// sortShares orders shares by conversation, then token, as the store
+// file keeps them.
+func sortShares(shares []models.Share) {
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].ConversationID == shares[j].ConversationID {
+			return shares[i].Token < shares[j].Token
+		}
+		return shares[i].ConversationID < shares[j].ConversationID
+	})
+}
+
+// SharedConversation returns the share with token and its conversation.
+// It fails with ErrShareNotFound for an unknown or revoked token, or one
+// whose conversation is in the trash, and with ErrShareExpired once the
+// share has expired.
+func (s *Store) SharedConversation(token string, now time.Time) (models.Share, models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	share, ok := s.shares[token]
+	if !ok {
+		return models.Share{}, models.Conversation{}, ErrShareNotFound
+	}
+	conversation, ok := s.conversations[share.ConversationID]
+	if !ok {
+		return models.Share{}, models.Conversation{}, ErrShareNotFound
+	}
+	if share.Expired(now) {
+		return share, models.Conversation{}, ErrShareExpired
+	}
+	return share, conversation, nil
+}
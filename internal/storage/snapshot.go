@@ -0,0 +1,600 @@
+package storage
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// SnapshotFormat and SnapshotVersion identify the snapshots this build
+// writes. Restoring accepts any version up to SnapshotVersion.
+const (
+	SnapshotFormat  = "zatgpt-snapshot"
+	SnapshotVersion = 1
+)
+
+// ErrInvalidSnapshot is returned for snapshots that fail verification.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+const (
+	manifestName      = "manifest.json"
+	snapshotStoreName = "store.json"
+	snapshotBlobDir   = "attachments/"
+)
+
+// SnapshotManifest describes a snapshot archive. Every other file in the
+// archive is listed with its size and SHA-256 so a damaged or tampered
+// snapshot is rejected before anything is restored.
+type SnapshotManifest struct {
+	Format        string         `json:"format"`
+	SchemaVersion int            `json:"schemaVersion"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	Conversations int            `json:"conversations"`
+	Attachments   int            `json:"attachments"`
+	Files         []SnapshotFile `json:"files"`
+}
+
+// SnapshotFile is one checksummed file inside a snapshot.
+type SnapshotFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// WriteSnapshot writes the store and its attachment blobs to w as a zip
+// archive with a manifest. Thumbnails are left out; they are regenerated on
+// demand.
+func (s *Store) WriteSnapshot(w io.Writer) (SnapshotManifest, error) {
+	manifest := SnapshotManifest{
+		Format:        SnapshotFormat,
+		SchemaVersion: SnapshotVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+	archive := zip.NewWriter(w)
+	add := func(name string, write func(io.Writer) (int64, error)) error {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+		sum := sha256.New()
+		n, err := write(io.MultiWriter(entry, sum))
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, SnapshotFile{Path: name, Size: n, SHA256: hex.EncodeToString(sum.Sum(nil))})
+		return nil
+	}
+
+	s.mu.RLock()
+	manifest.Conversations = len(s.conversations)
+	err := add(snapshotStoreName, s.encodeLocked)
+	s.mu.RUnlock()
+	if err != nil {
+		return manifest, err
+	}
+
+	entries, err := os.ReadDir(s.AttachmentDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return manifest, err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !validRef(entry.Name()) || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		file, err := s.OpenAttachment(entry.Name())
+		if err != nil {
+			return manifest, err
+		}
+		err = add(snapshotBlobDir+entry.Name(), func(w io.Writer) (int64, error) {
+			return io.Copy(w, file)
+		})
+		file.Close()
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Attachments++
+	}
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: manifest.CreatedAt})
+	if err != nil {
+		return manifest, err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, archive.Close()
+}
+
+// SaveSnapshot writes a snapshot to the file path, through a temporary
+// file next to it so a failed write never leaves a truncated snapshot
+// under that name.
+func (s *Store) SaveSnapshot(path string) (SnapshotManifest, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return SnapshotManifest{}, err
+	}
+	manifest, err := s.WriteSnapshot(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return manifest, err
+	}
+	return manifest, nil
+}
+
+// Snapshot is a verified snapshot ready to be restored. A plain store file
+// (a copy of conversations_store.json, in any encoding) is accepted too; it
+// has no manifest, so Checksummed is false and it carries no attachments.
+type Snapshot struct {
+	Manifest    SnapshotManifest
+	Checksummed bool
+
+	contents Contents
+	archive  *zip.ReadCloser
+	blobs    map[string]*zip.File
+}
+
+// OpenSnapshot reads and verifies the snapshot at path: its schema version,
+// every checksum, and the store file inside it. Close it when done.
+func OpenSnapshot(path string) (*Snapshot, error) {
+	archive, err := zip.OpenReader(path)
+	if errors.Is(err, zip.ErrFormat) {
+		return openStoreFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{archive: archive, blobs: make(map[string]*zip.File), Checksummed: true}
+	if err := snap.verify(); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return snap, nil
+}
+
+func openStoreFile(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	snap := &Snapshot{}
+	r, _, err := openContents(file)
+	if err == nil {
+		snap.contents, err = decodeContents(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: neither a snapshot archive nor a store file: %v", ErrInvalidSnapshot, err)
+	}
+	if err := checkConversations(snap.contents.Conversations); err != nil {
+		return nil, err
+	}
+	snap.Manifest.Conversations = len(snap.contents.Conversations)
+	if info, err := os.Stat(path); err == nil {
+		snap.Manifest.CreatedAt = info.ModTime().UTC()
+	}
+	return snap, nil
+}
+
+func (snap *Snapshot) verify() error {
+	files := make(map[string]*zip.File, len(snap.archive.File))
+	for _, file := range snap.archive.File {
+		if _, dup := files[file.Name]; dup {
+			return fmt.Errorf("%w: %s appears twice", ErrInvalidSnapshot, file.Name)
+		}
+		files[file.Name] = file
+	}
+
+	manifestFile, ok := files[manifestName]
+	if !ok {
+		return fmt.Errorf("%w: no %s", ErrInvalidSnapshot, manifestName)
+	}
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(rc).Decode(&snap.Manifest)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidSnapshot, err)
+	}
+	manifest := snap.Manifest
+	if manifest.Format != SnapshotFormat {
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidSnapshot, manifest.Format)
+	}
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > SnapshotVersion {
+		return fmt.Errorf("%w: schema version %d is not supported by this build (up to %d)", ErrInvalidSnapshot, manifest.SchemaVersion, SnapshotVersion)
+	}
+
+	listed := map[string]bool{manifestName: true}
+	for _, want := range manifest.Files {
+		file, ok := files[want.Path]
+		if !ok {
+			return fmt.Errorf("%w: %s is listed but missing", ErrInvalidSnapshot, want.Path)
+		}
+		if err := checkSnapshotFile(file, want); err != nil {
+			return err
+		}
+		listed[want.Path] = true
+
+		switch ref := strings.TrimPrefix(want.Path, snapshotBlobDir); {
+		case want.Path == snapshotStoreName:
+		case ref != want.Path && validRef(ref):
+			snap.blobs[ref] = file
+		default:
+			return fmt.Errorf("%w: unexpected file %s", ErrInvalidSnapshot, want.Path)
+		}
+	}
+	for name := range files {
+		if !listed[name] {
+			return fmt.Errorf("%w: %s is not listed in the manifest", ErrInvalidSnapshot, name)
+		}
+	}
+	if len(snap.blobs) != manifest.Attachments {
+		return fmt.Errorf("%w: manifest lists %d attachments, archive holds %d", ErrInvalidSnapshot, manifest.Attachments, len(snap.blobs))
+	}
+
+	storeFile, ok := files[snapshotStoreName]
+	if !ok || !listed[snapshotStoreName] {
+		return fmt.Errorf("%w: no %s", ErrInvalidSnapshot, snapshotStoreName)
+	}
+	rc, err = storeFile.Open()
+	if err != nil {
+		return err
+	}
+	snap.contents, err = decodeContents(bufio.NewReader(rc))
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("%w: unreadable %s: %v", ErrInvalidSnapshot, snapshotStoreName, err)
+	}
+	if err := checkConversations(snap.contents.Conversations); err != nil {
+		return err
+	}
+	if len(snap.contents.Conversations) != manifest.Conversations {
+		return fmt.Errorf("%w: manifest lists %d conversations, store file holds %d", ErrInvalidSnapshot, manifest.Conversations, len(snap.contents.Conversations))
+	}
+	return nil
+}
+
+func checkSnapshotFile(file *zip.File, want SnapshotFile) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	sum := sha256.New()
+	n, err := io.Copy(sum, rc)
+	if err != nil {
+		return fmt.Errorf("%w: reading %s: %v", ErrInvalidSnapshot, want.Path, err)
+	}
+	if n != want.Size || hex.EncodeToString(sum.Sum(nil)) != want.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidSnapshot, want.Path)
+	}
+	return nil
+}
+
+func checkConversations(conversations []models.Conversation) error {
+	seen := make(map[string]bool, len(conversations))
+	for _, conversation := range conversations {
+		if conversation.ID == "" {
+			return fmt.Errorf("%w: a conversation has no id", ErrInvalidSnapshot)
+		}
+		if seen[conversation.ID] {
+			return fmt.Errorf("%w: conversation %s appears twice", ErrInvalidSnapshot, conversation.ID)
+		}
+		seen[conversation.ID] = true
+	}
+	return nil
+}
+
+// Close releases the snapshot archive.
+func (snap *Snapshot) Close() error {
+	if snap.archive == nil {
+		return nil
+	}
+	return snap.archive.Close()
+}
+
+// RestoreChange names a conversation a restore touches.
+type RestoreChange struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// RestorePlan is what restoring a snapshot does to the store. Replacing
+// makes the store match the snapshot, so conversations only in the store
+// are Removed. Merging never removes anything: conversations only in the
+// store, or newer there, are Kept.
+type RestorePlan struct {
+	Merge       bool            `json:"merge"`
+	Added       []RestoreChange `json:"added"`
+	Changed     []RestoreChange `json:"changed"`
+	Removed     []RestoreChange `json:"removed"`
+	Kept        int             `json:"kept"`
+	Unchanged   int             `json:"unchanged"`
+	Attachments int             `json:"attachments"`
+}
+
+// PlanRestore reports what Restore would change without changing anything.
+func (s *Store) PlanRestore(snap *Snapshot, merge bool) RestorePlan {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	plan, _, _ := s.planRestoreLocked(snap, merge)
+	return plan
+}
+
+// Restore applies snapshot to the store, replacing its contents or, with
+// merge, combining them. The snapshot's attachment blobs are copied in
+// first; the store is then replaced in one save (a rename, or a SQLite,
+// bolt or PostgreSQL transaction), so an interrupted restore leaves the
+// previous store intact.
+func (s *Store) Restore(snap *Snapshot, merge bool) (RestorePlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return RestorePlan{}, ErrReadOnly
+	}
+	if _, err := s.refreshLocked(); err != nil {
+		return RestorePlan{}, err
+	}
+	plan, next, blobs := s.planRestoreLocked(snap, merge)
+
+	for _, ref := range blobs {
+		path, err := s.attachmentPath(ref)
+		if err != nil {
+			return plan, err
+		}
+		rc, err := snap.blobs[ref].Open()
+		if err != nil {
+			return plan, err
+		}
+		_, err = writeFileAtomic(path, rc, nil)
+		rc.Close()
+		if err != nil {
+			return plan, fmt.Errorf("restoring attachment %s: %w", ref, err)
+		}
+	}
+	s.blobBytes, s.blobCount = 0, 0
+	s.measureAttachments()
+
+	previous := s.contentsLocked()
+	s.setContentsLocked(next)
+	if err := s.saveLocked(Change{Replace: true}); err != nil {
+		s.setContentsLocked(previous)
+		return plan, err
+	}
+	return plan, nil
+}
+
+// planRestoreLocked works out the plan, the resulting store contents and
+// the attachment blobs to copy.
+func (s *Store) planRestoreLocked(snap *Snapshot, merge bool) (RestorePlan, Contents, []string) {
+	plan := RestorePlan{Merge: merge}
+	incoming := snap.contents
+	next := Contents{}
+
+	inSnapshot := make(map[string]bool, len(incoming.Conversations))
+	for _, conversation := range incoming.Conversations {
+		inSnapshot[conversation.ID] = true
+		change := RestoreChange{ID: conversation.ID, Title: conversation.Title}
+		current, exists := s.conversations[conversation.ID]
+		switch {
+		case !exists:
+			plan.Added = append(plan.Added, change)
+		case sameConversation(current, conversation):
+			plan.Unchanged++
+		case merge && !conversation.UpdatedAt.After(current.UpdatedAt):
+			plan.Kept++
+			conversation = current
+		default:
+			plan.Changed = append(plan.Changed, change)
+		}
+		next.Conversations = append(next.Conversations, conversation)
+	}
+	for id, current := range s.conversations {
+		if inSnapshot[id] {
+			continue
+		}
+		if merge {
+			plan.Kept++
+			next.Conversations = append(next.Conversations, current)
+		} else {
+			plan.Removed = append(plan.Removed, RestoreChange{ID: id, Title: current.Title})
+		}
+	}
+	sortChanges(plan.Added)
+	sortChanges(plan.Changed)
+	sortChanges(plan.Removed)
+
+	if merge {
+		next.LinkChecks = mergeLinkChecks(s.linkChecks, incoming.LinkChecks)
+		next.Imports = mergeImports(s.imports, incoming.Imports)
+		next.Summaries = mergeSummaries(s.summaries, incoming.Summaries)
+		next.Embeddings = mergeEmbeddings(s.embeddings, incoming.Embeddings)
+		next.Revisions = mergeRevisions(s.revisions, incoming.Revisions)
+		next.Trash = mergeTrash(s.trash, incoming.Trash)
+		next.SavedSearches = mergeSavedSearches(s.savedSearches, incoming.SavedSearches)
+	} else {
+		next.LinkChecks = incoming.LinkChecks
+		next.Imports = incoming.Imports
+		next.Summaries = incoming.Summaries
+		next.Embeddings = incoming.Embeddings
+		next.Revisions = incoming.Revisions
+		next.Trash = incoming.Trash
+		next.SavedSearches = incoming.SavedSearches
+	}
+	// Shares stay as they are: restoring an old backup must not bring back
+	// a link that was revoked since.
+	for _, share := range s.shares {
+		next.Shares = append(next.Shares, share)
+	}
+	sortShares(next.Shares)
+
+	var blobs []string
+	for ref, file := range snap.blobs {
+		if merge {
+			if path, err := s.attachmentPath(ref); err == nil {
+				if size, ok := fileSize(path); ok && size == int64(file.UncompressedSize64) {
+					continue
+				}
+			}
+		}
+		blobs = append(blobs, ref)
+	}
+	sort.Strings(blobs)
+	plan.Attachments = len(blobs)
+
+	return plan, next, blobs
+}
+
+// mergeTrash combines both trashes, keeping the later deletion of a
+// conversation in both. Restored contents drop any that are live again.
+func mergeTrash(current map[string]models.Conversation, incoming []models.Conversation) []models.Conversation {
+	merged := maps.Clone(current)
+	for _, item := range incoming {
+		if existing, ok := merged[item.ID]; !ok || item.DeletedAt.After(existing.DeletedAt) {
+			merged[item.ID] = item
+		}
+	}
+	out := make([]models.Conversation, 0, len(merged))
+	for _, item := range merged {
+		out = append(out, item)
+	}
+	return out
+}
+
+// mergeSavedSearches combines both sets of saved searches, keeping the
+// later edit of one held by both.
+func mergeSavedSearches(current map[string]models.SavedSearch, incoming []models.SavedSearch) []models.SavedSearch {
+	merged := maps.Clone(current)
+	for _, search := range incoming {
+		if existing, ok := merged[search.ID]; !ok || search.UpdatedAt.After(existing.UpdatedAt) {
+			merged[search.ID] = search
+		}
+	}
+	out := make([]models.SavedSearch, 0, len(merged))
+	for _, search := range merged {
+		out = append(out, search)
+	}
+	sortSavedSearches(out)
+	return out
+}
+
+func sameConversation(a, b models.Conversation) bool {
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
+
+func sortChanges(changes []RestoreChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Title == changes[j].Title {
+			return changes[i].ID < changes[j].ID
+		}
+		return changes[i].Title < changes[j].Title
+	})
+}
+
+// mergeLinkChecks keeps the most recent check of each URL.
+func mergeLinkChecks(current map[string]models.LinkCheck, incoming []models.LinkCheck) []models.LinkCheck {
+	merged := make(map[string]models.LinkCheck, len(current))
+	for url, check := range current {
+		merged[url] = check
+	}
+	for _, check := range incoming {
+		if existing, ok := merged[check.URL]; !ok || check.CheckedAt.After(existing.CheckedAt) {
+			merged[check.URL] = check
+		}
+	}
+	out := make([]models.LinkCheck, 0, len(merged))
+	for _, check := range merged {
+		out = append(out, check)
+	}
+	return out
+}
+
+// mergeImports adds the snapshot's history entries the store lacks.
+func mergeImports(current, incoming []models.ImportRecord) []models.ImportRecord {
+	seen := make(map[string]bool, len(current))
+	out := append([]models.ImportRecord(nil), current...)
+	for _, record := range current {
+		seen[record.ID] = true
+	}
+	for _, record := range incoming {
+		if !seen[record.ID] {
+			seen[record.ID] = true
+			out = append(out, record)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].StartedAt.Before(out[j].StartedAt)
+	})
+	return out
+}
+
+// mergeSummaries adds cached summaries the store lacks; stale ones are
+// regenerated on request anyway.
+func mergeSummaries(current map[string]models.Summary, incoming []models.Summary) []models.Summary {
+	out := make([]models.Summary, 0, len(current)+len(incoming))
+	for _, summary := range current {
+		out = append(out, summary)
+	}
+	for _, summary := range incoming {
+		if _, ok := current[summaryKey(summary.ConversationID, summary.Method)]; !ok {
+			out = append(out, summary)
+		}
+	}
+	return out
+}
+
+// mergeEmbeddings adds embeddings the store lacks; stale ones are
+// recomputed by the next indexing pass.
+func mergeEmbeddings(current map[string]models.Embedding, incoming []models.Embedding) []models.Embedding {
+	out := make([]models.Embedding, 0, len(current)+len(incoming))
+	for _, embedding := range current {
+		out = append(out, embedding)
+	}
+	for _, embedding := range incoming {
+		if _, ok := current[summaryKey(embedding.ConversationID, embedding.Model)]; !ok {
+			out = append(out, embedding)
+		}
+	}
+	return out
+}
+
+// mergeRevisions keeps the store's history of every conversation that has
+// one, taking the snapshot's for the others.
+func mergeRevisions(current map[string][]models.Revision, incoming []models.Revision) []models.Revision {
+	var out []models.Revision
+	for _, revisions := range current {
+		out = append(out, revisions...)
+	}
+	for _, revision := range incoming {
+		if _, ok := current[revision.ConversationID]; !ok {
+			out = append(out, revision)
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrNoSpace is matched (with errors.Is) by every *SpaceError.
+var ErrNoSpace = errors.New("not enough free disk space")
+
+// spaceHeadroom is left free on the store's disk by every write, so the
+// store never fills it to the last byte.
+const spaceHeadroom = 16 << 20
+
+// SpaceError reports a write refused, or cut short, for want of disk space.
+// A refused write leaves the store as it was.
+type SpaceError struct {
+	// Dir is the directory the write went to.
+	Dir string
+	// Needed and Free are in bytes; Needed is zero when the disk filled
+	// up during the write rather than before it.
+	Needed int64
+	Free   int64
+}
+
+func (e *SpaceError) Error() string {
+	if e.Needed == 0 {
+		return fmt.Sprintf("%s: the disk holding %s filled up during the write; free some space and try again", ErrNoSpace, e.Dir)
+	}
+	return fmt.Sprintf("%s: the write needs %s in %s, which has %s free; free some space or move the store to a larger disk", ErrNoSpace, FormatSize(e.Needed), e.Dir, FormatSize(e.Free))
+}
+
+func (e *SpaceError) Is(target error) bool { return target == ErrNoSpace }
+
+// checkSpaceLocked refuses a save the store's disk has no room for. A JSON
+// store writes its new file next to the old one before swapping them, so
+// it needs room for a whole copy; the databases write only what changed.
+// Disks whose free space cannot be told are not checked.
+func (s *Store) checkSpaceLocked() error {
+	if s.engine == EnginePostgres {
+		return nil
+	}
+	needed := int64(spaceHeadroom)
+	if s.engine == EngineJSON {
+		needed += s.fileBytes
+	}
+	return checkSpace(filepath.Dir(s.path), needed)
+}
+
+// checkSpace fails with a *SpaceError when dir has less than needed bytes
+// free.
+func checkSpace(dir string, needed int64) error {
+	free, ok := freeSpace(dir)
+	if !ok || free >= needed {
+		return nil
+	}
+	return &SpaceError{Dir: absDir(dir), Needed: needed, Free: free}
+}
+
+// spaceFailure turns an error from writing to dir that ran out of space
+// into a *SpaceError, leaving any other error as it is.
+func spaceFailure(err error, dir string) error {
+	if err == nil || !outOfSpace(err) {
+		return err
+	}
+	free, _ := freeSpace(dir)
+	return &SpaceError{Dir: absDir(dir), Free: free}
+}
+
+// absDir is dir made absolute where it can be, so errors say which disk.
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
@@ -0,0 +1,603 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+
+	"zatGPT/internal/models"
+)
+
+// sqliteSchemaVersion is stored in the database's user_version.
+const sqliteSchemaVersion = 7
+
+// sqliteSchema keeps each record as its JSON encoding, the same one the
+// JSON store file uses, keyed by the fields the store looks records up by.
+// A conversation's updated_at (in sortableTimeLayout) and its tags are
+// kept beside it as well, for List.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS conversations (
+	id         TEXT PRIMARY KEY,
+	updated_at TEXT NOT NULL DEFAULT '',
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS conversations_updated_at ON conversations (updated_at);
+CREATE TABLE IF NOT EXISTS conversation_tags (
+	conversation_id TEXT NOT NULL,
+	tag             TEXT NOT NULL,
+	PRIMARY KEY (conversation_id, tag)
+);
+CREATE INDEX IF NOT EXISTS conversation_tags_tag ON conversation_tags (tag);
+CREATE TABLE IF NOT EXISTS link_checks (
+	url  TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS imports (
+	seq  INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS summaries (
+	conversation_id TEXT NOT NULL,
+	method          TEXT NOT NULL,
+	data            TEXT NOT NULL,
+	PRIMARY KEY (conversation_id, method)
+);
+CREATE TABLE IF NOT EXISTS trash (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS embeddings (
+	conversation_id TEXT NOT NULL,
+	model           TEXT NOT NULL,
+	data            TEXT NOT NULL,
+	PRIMARY KEY (conversation_id, model)
+);
+CREATE TABLE IF NOT EXISTS revisions (
+	conversation_id TEXT NOT NULL,
+	number          INTEGER NOT NULL,
+	data            TEXT NOT NULL,
+	PRIMARY KEY (conversation_id, number)
+);
+CREATE TABLE IF NOT EXISTS shares (
+	token           TEXT PRIMARY KEY,
+	conversation_id TEXT NOT NULL,
+	data            TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS saved_searches (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+`
+
+// sqliteBackend keeps the store in a SQLite database, so a save writes only
+// the records it changes instead of the whole archive.
+type sqliteBackend struct {
+	path string
+	db   *sql.DB
+	// version is the database's schema version; one opened read-only may
+	// be older than sqliteSchemaVersion.
+	version int
+}
+
+func openSQLite(path string, readOnly bool) (*sqliteBackend, error) {
+	b := &sqliteBackend{path: path}
+	if readOnly {
+		// Opening a missing database read-only fails; it is simply empty.
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return b, nil
+		}
+	}
+
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	if readOnly {
+		query.Set("mode", "ro")
+	}
+	db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: path}).EscapedPath()+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// One connection: the store already serializes writes, and SQLite
+	// allows a single writer anyway.
+	db.SetMaxOpenConns(1)
+	b.db = db
+
+	err = db.QueryRow("PRAGMA user_version").Scan(&b.version)
+	if err == nil && !readOnly {
+		err = b.migrate()
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// migrate brings the schema up to sqliteSchemaVersion in one transaction.
+// Version 7 added the conversations' updated_at column and tags table,
+// which are filled in from the records.
+func (b *sqliteBackend) migrate() error {
+	if b.version > sqliteSchemaVersion {
+		return fmt.Errorf("database schema v%d is newer than this build supports (v%d)", b.version, sqliteSchemaVersion)
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if b.version > 0 && b.version < 7 {
+		if _, err := tx.Exec("ALTER TABLE conversations ADD COLUMN updated_at TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	if b.version > 0 && b.version < 7 {
+		var conversations []models.Conversation
+		err := loadRows(tx, "SELECT data FROM conversations", func(data []byte) error {
+			var conversation models.Conversation
+			if err := json.Unmarshal(data, &conversation); err != nil {
+				return err
+			}
+			conversations = append(conversations, conversation)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, conversation := range conversations {
+			if err := putSQLiteConversation(tx, conversation); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	b.version = sqliteSchemaVersion
+	return nil
+}
+
+func (b *sqliteBackend) Load() (Contents, error) {
+	var payload Contents
+	if b.db == nil {
+		return payload, nil
+	}
+	err := loadRows(b.db, "SELECT data FROM conversations", func(data []byte) error {
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return err
+		}
+		payload.Conversations = append(payload.Conversations, conversation)
+		return nil
+	})
+	if err == nil {
+		err = loadRows(b.db, "SELECT data FROM link_checks ORDER BY url", func(data []byte) error {
+			var check models.LinkCheck
+			if err := json.Unmarshal(data, &check); err != nil {
+				return err
+			}
+			payload.LinkChecks = append(payload.LinkChecks, check)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(b.db, "SELECT data FROM imports ORDER BY seq", func(data []byte) error {
+			var record models.ImportRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			payload.Imports = append(payload.Imports, record)
+			return nil
+		})
+	}
+	if err == nil {
+		err = loadRows(b.db, "SELECT data FROM summaries ORDER BY conversation_id, method", func(data []byte) error {
+			var summary models.Summary
+			if err := json.Unmarshal(data, &summary); err != nil {
+				return err
+			}
+			payload.Summaries = append(payload.Summaries, summary)
+			return nil
+		})
+	}
+	// Older databases opened read-only lack the tables added since: trash
+	// in version 2, embeddings in version 3, revisions in version 4, shares
+	// in version 5 and saved searches in version 6.
+	var version int
+	if err == nil {
+		err = b.db.QueryRow("PRAGMA user_version").Scan(&version)
+	}
+	if err == nil && version >= 2 {
+		err = loadRows(b.db, "SELECT data FROM trash", func(data []byte) error {
+			var conversation models.Conversation
+			if err := json.Unmarshal(data, &conversation); err != nil {
+				return err
+			}
+			payload.Trash = append(payload.Trash, conversation)
+			return nil
+		})
+	}
+	if err == nil && version >= 3 {
+		err = loadRows(b.db, "SELECT data FROM embeddings ORDER BY conversation_id, model", func(data []byte) error {
+			var embedding models.Embedding
+			if err := json.Unmarshal(data, &embedding); err != nil {
+				return err
+			}
+			payload.Embeddings = append(payload.Embeddings, embedding)
+			return nil
+		})
+	}
+	if err == nil && version >= 4 {
+		err = loadRows(b.db, "SELECT data FROM revisions ORDER BY conversation_id, number", func(data []byte) error {
+			var revision models.Revision
+			if err := json.Unmarshal(data, &revision); err != nil {
+				return err
+			}
+			payload.Revisions = append(payload.Revisions, revision)
+			return nil
+		})
+	}
+	if err == nil && version >= 5 {
+		err = loadRows(b.db, "SELECT data FROM shares ORDER BY conversation_id, token", func(data []byte) error {
+			var share models.Share
+			if err := json.Unmarshal(data, &share); err != nil {
+				return err
+			}
+			payload.Shares = append(payload.Shares, share)
+			return nil
+		})
+	}
+	if err == nil && version >= 6 {
+		err = loadRows(b.db, "SELECT data FROM saved_searches ORDER BY id", func(data []byte) error {
+			var search models.SavedSearch
+			if err := json.Unmarshal(data, &search); err != nil {
+				return err
+			}
+			payload.SavedSearches = append(payload.SavedSearches, search)
+			return nil
+		})
+	}
+	if err != nil {
+		return Contents{}, fmt.Errorf("%s: %w", b.path, err)
+	}
+	return payload, nil
+}
+
+// loadRows calls fn with the data column of each row query returns, from
+// a database or a transaction.
+func loadRows(db interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, query string, fn func(data []byte) error, args ...any) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Save applies change in one transaction. The size passed to check is the
+// database's page count at the end of the transaction, before it commits.
+func (b *sqliteBackend) Save(change Change, contents func() Contents, check func(size int64) error) (int64, error) {
+	if b.db == nil {
+		return 0, ErrReadOnly
+	}
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if change.Replace {
+		for _, table := range []string{"conversations", "conversation_tags", "link_checks", "imports", "summaries", "embeddings", "revisions", "trash", "shares", "saved_searches"} {
+			if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+				return 0, err
+			}
+		}
+		all := contents()
+		change = Change{
+			Conversations: all.Conversations,
+			LinkChecks:    all.LinkChecks,
+			Imports:       all.Imports,
+			Summaries:     all.Summaries,
+			Embeddings:    all.Embeddings,
+			Revisions:     all.Revisions,
+			Trashed:       all.Trash,
+			Shares:        all.Shares,
+			SavedSearches: all.SavedSearches,
+		}
+	}
+	if err := applyChange(tx, change); err != nil {
+		return 0, err
+	}
+
+	var pages, pageSize int64
+	if err := tx.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := tx.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	size := pages * pageSize
+	if check != nil {
+		if err := check(size); err != nil {
+			return 0, err
+		}
+	}
+	return size, tx.Commit()
+}
+
+func applyChange(tx *sql.Tx, change Change) error {
+	for _, conversation := range change.Conversations {
+		if err := putSQLiteConversation(tx, conversation); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM trash WHERE id = ?", conversation.ID); err != nil {
+			return err
+		}
+	}
+	for _, conversation := range change.Trashed {
+		if err := putRow(tx, "INSERT OR REPLACE INTO trash (id, data) VALUES (?, ?)", conversation, conversation.ID); err != nil {
+			return err
+		}
+		if err := removeSQLiteConversation(tx, conversation.ID); err != nil {
+			return err
+		}
+	}
+	for _, id := range change.Deleted {
+		if err := removeSQLiteConversation(tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM trash WHERE id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM summaries WHERE conversation_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM embeddings WHERE conversation_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM revisions WHERE conversation_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM shares WHERE conversation_id = ?", id); err != nil {
+			return err
+		}
+	}
+	for _, check := range change.LinkChecks {
+		if err := putRow(tx, "INSERT OR REPLACE INTO link_checks (url, data) VALUES (?, ?)", check, check.URL); err != nil {
+			return err
+		}
+	}
+	for _, record := range change.Imports {
+		if err := putRow(tx, "INSERT INTO imports (data) VALUES (?)", record); err != nil {
+			return err
+		}
+	}
+	for _, summary := range change.Summaries {
+		if err := putRow(tx, "INSERT OR REPLACE INTO summaries (conversation_id, method, data) VALUES (?, ?, ?)", summary, summary.ConversationID, summary.Method); err != nil {
+			return err
+		}
+	}
+	for _, embedding := range change.Embeddings {
+		if err := putRow(tx, "INSERT OR REPLACE INTO embeddings (conversation_id, model, data) VALUES (?, ?, ?)", embedding, embedding.ConversationID, embedding.Model); err != nil {
+			return err
+		}
+	}
+	for _, revision := range change.Revisions {
+		if err := putRow(tx, "INSERT OR REPLACE INTO revisions (conversation_id, number, data) VALUES (?, ?, ?)", revision, revision.ConversationID, revision.Number); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM revisions WHERE conversation_id = ? AND number <= ?", revision.ConversationID, revision.Number-MaxRevisions); err != nil {
+			return err
+		}
+	}
+	for _, share := range change.Shares {
+		if err := putRow(tx, "INSERT OR REPLACE INTO shares (token, conversation_id, data) VALUES (?, ?, ?)", share, share.Token, share.ConversationID); err != nil {
+			return err
+		}
+	}
+	for _, share := range change.Unshared {
+		if _, err := tx.Exec("DELETE FROM shares WHERE token = ?", share.Token); err != nil {
+			return err
+		}
+	}
+	for _, search := range change.SavedSearches {
+		if err := putRow(tx, "INSERT OR REPLACE INTO saved_searches (id, data) VALUES (?, ?)", search, search.ID); err != nil {
+			return err
+		}
+	}
+	for _, id := range change.UnsavedSearches {
+		if _, err := tx.Exec("DELETE FROM saved_searches WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putSQLiteConversation stores conversation with its updated_at and tags.
+func putSQLiteConversation(tx *sql.Tx, conversation models.Conversation) error {
+	if err := putRow(tx, "INSERT OR REPLACE INTO conversations (id, updated_at, data) VALUES (?, ?, ?)", conversation, conversation.ID, sortableTime(conversation.UpdatedAt)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM conversation_tags WHERE conversation_id = ?", conversation.ID); err != nil {
+		return err
+	}
+	for _, tag := range conversation.Tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO conversation_tags (conversation_id, tag) VALUES (?, ?)", conversation.ID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSQLiteConversation deletes a conversation with its tags; a missing
+// one is not an error.
+func removeSQLiteConversation(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("DELETE FROM conversations WHERE id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM conversation_tags WHERE conversation_id = ?", id)
+	return err
+}
+
+// putRow runs statement with keys followed by the JSON encoding of value.
+func putRow(tx *sql.Tx, statement string, value any, keys ...any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(statement, append(keys, string(data))...)
+	return err
+}
+
+// List walks the updated_at and tag indexes. A database opened read-only
+// from before version 7 has neither, so its records are filtered here
+// instead.
+func (b *sqliteBackend) List(query Query) ([]models.Conversation, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+	var clauses []string
+	var args []any
+	if b.version >= 7 {
+		if !query.From.IsZero() {
+			clauses, args = append(clauses, "updated_at >= ?"), append(args, sortableTime(query.From))
+		}
+		if !query.To.IsZero() {
+			clauses, args = append(clauses, "updated_at < ?"), append(args, sortableTime(query.To))
+		}
+		for _, tag := range query.Tags {
+			clauses, args = append(clauses, "id IN (SELECT conversation_id FROM conversation_tags WHERE tag = ?)"), append(args, tag)
+		}
+	}
+	var listed []models.Conversation
+	err := loadRows(b.db, "SELECT json_remove(data, '$.messages') FROM conversations"+sqlWhere(clauses), func(data []byte) error {
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return err
+		}
+		if query.Match(conversation) {
+			listed = append(listed, conversation)
+		}
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.path, err)
+	}
+	sortByUpdated(listed)
+	return listed, nil
+}
+
+func (b *sqliteBackend) Get(id string) (models.Conversation, error) {
+	if b.db == nil {
+		return models.Conversation{}, ErrNotFound
+	}
+	var data []byte
+	err := b.db.QueryRow("SELECT data FROM conversations WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Conversation{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Conversation{}, fmt.Errorf("%s: %w", b.path, err)
+	}
+	var conversation models.Conversation
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return models.Conversation{}, fmt.Errorf("%s: conversation %s: %w", b.path, id, err)
+	}
+	return conversation, nil
+}
+
+func (b *sqliteBackend) Upsert(conversations []models.Conversation) error {
+	_, err := b.Save(Change{Conversations: conversations}, nil, nil)
+	return err
+}
+
+func (b *sqliteBackend) Delete(ids []string) error {
+	_, err := b.Save(Change{Deleted: ids}, nil, nil)
+	return err
+}
+
+// Search narrows the records down in SQL with SQLite's JSON functions and
+// checks the rest here. SQLite's lower() only folds ASCII letters, so only
+// terms made of ASCII are looked for in SQL.
+func (b *sqliteBackend) Search(terms []string) ([]models.Conversation, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+	terms = searchTerms(terms)
+	var clauses []string
+	var args []any
+	for _, term := range terms {
+		if !isASCII(term) {
+			continue
+		}
+		clauses = append(clauses, `(instr(lower(json_extract(data, '$.title')), ?) > 0
+			OR instr(lower(json_extract(data, '$.summary')), ?) > 0
+			OR EXISTS (SELECT 1 FROM json_each(data, '$.messages') WHERE instr(lower(json_extract(value, '$.content')), ?) > 0))`)
+		args = append(args, term, term, term)
+	}
+	var found []models.Conversation
+	err := loadRows(b.db, "SELECT data FROM conversations"+sqlWhere(clauses), func(data []byte) error {
+		var conversation models.Conversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return err
+		}
+		if matchesTerms(conversation, terms) {
+			conversation.Messages = nil
+			found = append(found, conversation)
+		}
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.path, err)
+	}
+	sortByUpdated(found)
+	return found, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlWhere joins clauses into a WHERE clause, empty when there are none.
+func sqlWhere(clauses []string) string {
+	if len(clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(clauses, " AND ")
+}
+
+func (b *sqliteBackend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
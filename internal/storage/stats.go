@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+
+	"zatGPT/internal/stats"
+)
+
+// Stats aggregates every conversation outside the trash into the totals
+// of a dashboard. It stops with ctx's error once ctx is done.
+func (s *Store) Stats(ctx context.Context) (stats.Overview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counter := stats.NewOverviewCounter()
+	scanned := 0
+	for _, item := range s.conversations {
+		if scanned++; scanned%scanCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return stats.Overview{}, err
+			}
+		}
+		counter.Add(item)
+	}
+	return counter.Overview(), nil
+}
+
+// TokenStats totals the tokens of every conversation outside the trash and
+// prices them with prices (stats.DefaultPrices when nil). It stops with
+// ctx's error once ctx is done.
+func (s *Store) TokenStats(ctx context.Context, prices map[string]stats.Price) (stats.TokenReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counter := stats.NewTokenCounter(prices)
+	scanned := 0
+	for _, item := range s.conversations {
+		if scanned++; scanned%scanCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return stats.TokenReport{}, err
+			}
+		}
+		counter.Add(item)
+	}
+	return counter.Report(), nil
+}
@@ -1,8 +1,13 @@
 package storage
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,31 +15,181 @@ import (
 	"time"
 
 	"zatGPT/internal/models"
+	"zatGPT/internal/topics"
 )
 
 var ErrNotFound = errors.New("conversation not found")
 
-// Store manages conversation persistence backed by a JSON file.
+// Store manages conversation persistence backed by a JSON file (plain or
+// gzip-compressed) or, with EngineSQLite, EngineBolt or EnginePostgres, a
+// SQLite, bbolt or PostgreSQL database. Everything is held in memory; the
+// backend is written through on every change.
 type Store struct {
 	mu            sync.RWMutex
 	path          string
+	engine        string
+	backend       Backend
 	conversations map[string]models.Conversation
+	linkChecks    map[string]models.LinkCheck
+	imports       []models.ImportRecord
+	summaries     map[string]models.Summary
+	embeddings    map[string]models.Embedding
+	// revisions holds each conversation's kept earlier versions, oldest
+	// first.
+	revisions map[string][]models.Revision
+	// trash holds deleted conversations until they are restored or
+	// purged. An ID is never in both trash and conversations.
+	trash map[string]models.Conversation
+	// shares holds the public links to conversations, by token.
+	shares map[string]models.Share
+	// savedSearches holds the named searches, by ID.
+	savedSearches map[string]models.SavedSearch
+	// index serves Search. It is built on the first search, under indexMu,
+	// and then kept up to date by every write.
+	index   *searchIndex
+	indexMu sync.Mutex
+	// topics scores the topics of imported conversations. It is built on
+	// the first import and then kept up to date by every write, like
+	// index, but under mu.
+	topics *topics.Corpus
+	// modified is when the store's contents last changed, for HTTP
+	// Last-Modified headers.
+	modified time.Time
+	// revision goes up by one with every save, for HTTP ETags. It starts
+	// from the store file's modification time in nanoseconds, so it keeps
+	// increasing across restarts and writes by other processes.
+	revision uint64
+
+	readOnly bool
+	// salvage is Options.Salvage; salvaged is why the store file did not
+	// load whole, until a repair rewrites it.
+	salvage  bool
+	salvaged error
+	lock     *fileLock
+	// dir holds the files kept beside the store (see Options.Dir).
+	dir string
+
+	limits Limits
+	// fileBytes is the size of the store file; blobBytes and blobCount
+	// cover the attachment blobs. They back Usage and the size limit.
+	fileBytes int64
+	blobBytes int64
+	blobCount int
 }
 
-// New creates or loads a Store located at path.
+// New creates or loads a writable Store located at path, failing with
+// ErrLocked when another process has it open.
 func New(path string) (*Store, error) {
+	return Open(path, Options{})
+}
+
+// Open creates or loads a Store located at path. Unless opts.Engine says
+// otherwise, an existing store file is opened with the engine that wrote
+// it, and a new one is SQLite when path ends in .db, .sqlite or .sqlite3,
+// bolt when it ends in .bolt or .bbolt and JSON otherwise. A postgres://
+// connection URL opens a PostgreSQL store, migrating its schema first.
+func Open(path string, opts Options) (*Store, error) {
+	engine, err := detectEngine(path, opts.Engine)
+	if err != nil {
+		return nil, err
+	}
+	dir := opts.Dir
+	switch {
+	case dir != "":
+	case engine == EnginePostgres:
+		dir = DefaultDir
+	default:
+		dir = filepath.Dir(path)
+	}
 	s := &Store{
+		engine:        engine,
 		path:          path,
+		dir:           dir,
 		conversations: make(map[string]models.Conversation),
+		linkChecks:    make(map[string]models.LinkCheck),
+		summaries:     make(map[string]models.Summary),
+		embeddings:    make(map[string]models.Embedding),
+		revisions:     make(map[string][]models.Revision),
+		trash:         make(map[string]models.Conversation),
+		shares:        make(map[string]models.Share),
+		savedSearches: make(map[string]models.SavedSearch),
+		readOnly:      opts.ReadOnly,
+		salvage:       opts.Salvage,
+		limits:        opts.Limits,
 	}
 
-	if err := s.load(); err != nil {
+	if !opts.ReadOnly && engine != EnginePostgres {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		lock, err := acquireLock(path, opts.LockWait)
+		if err != nil {
+			return nil, err
+		}
+		s.lock = lock
+	}
+
+	s.backend, err = openBackend(path, engine, opts)
+	if err == nil {
+		err = s.load()
+	}
+	if err != nil {
+		if s.backend != nil {
+			s.backend.Close()
+		}
+		_ = s.lock.release()
 		return nil, err
 	}
+	s.measureAttachments()
 
 	return s, nil
 }
 
+// Close closes the backend and releases the store's lock. The store must
+// not be used afterwards.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.backend.Close()
+	lock := s.lock
+	s.lock = nil
+	if lockErr := lock.release(); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// Engine reports which storage engine holds the store.
+func (s *Store) Engine() string {
+	return s.engine
+}
+
+// SavesWhole reports whether every save rewrites the whole store, as the
+// JSON engine does, so bulk writers should save once rather than in
+// batches.
+func (s *Store) SavesWhole() bool {
+	return s.engine == EngineJSON
+}
+
+// Path is the store file, or the connection URL of a PostgreSQL store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Dir is where the files kept beside the store go: attachment blobs and,
+// by default, the server's backups.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Count returns the number of conversations outside the trash.
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.conversations)
+}
+
 // List returns all conversations sorted by UpdatedAt descending.
 func (s *Store) List() []models.Conversation {
 	s.mu.RLock()
@@ -44,6 +199,8 @@ func (s *Store) List() []models.Conversation {
 	for _, item := range s.conversations {
 		sanitized := item
 		sanitized.Messages = nil
+		sanitized.Tree = nil
+		sanitized.Stats = nil
 		items = append(items, sanitized)
 	}
 
@@ -57,6 +214,40 @@ func (s *Store) List() []models.Conversation {
 	return items
 }
 
+// scanCheckEvery is how many conversations a full scan visits between
+// checks of its context, so a cancelled request stops it early.
+const scanCheckEvery = 256
+
+// Find returns the full records (messages included) of every conversation
+// accepted by match, in List order. A nil match selects everything. It
+// stops with ctx's error once ctx is done.
+func (s *Store) Find(ctx context.Context, match func(models.Conversation) bool) ([]models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]models.Conversation, 0, len(s.conversations))
+	scanned := 0
+	for _, item := range s.conversations {
+		if scanned++; scanned%scanCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if match == nil || match(item) {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].UpdatedAt.Equal(items[j].UpdatedAt) {
+			return items[i].Title < items[j].Title
+		}
+		return items[i].UpdatedAt.After(items[j].UpdatedAt)
+	})
+
+	return items, nil
+}
+
 // Get fetches a conversation by id.
 func (s *Store) Get(id string) (models.Conversation, error) {
 	s.mu.RLock()
@@ -69,17 +260,203 @@ func (s *Store) Get(id string) (models.Conversation, error) {
 	return item, nil
 }
 
+// GetMany fetches the conversations with ids under one lock, by ID. IDs
+// the store does not hold are left out.
+func (s *Store) GetMany(ids []string) map[string]models.Conversation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string]models.Conversation, len(ids))
+	for _, id := range ids {
+		if item, ok := s.conversations[id]; ok {
+			out[id] = item
+		}
+	}
+	return out
+}
+
 // Upsert inserts or updates a conversation.
 func (s *Store) Upsert(conversation models.Conversation) error {
+	return s.UpsertBatch([]models.Conversation{conversation})
+}
+
+// UpsertBatch inserts or updates several conversations under one lock and
+// with a single save. Callers writing many conversations should use it
+// rather than Upsert in a loop: the JSON store rewrites its whole file on
+// every save. When the write would exceed the store's limits it fails with
+// a *QuotaError and none of the conversations are applied.
+func (s *Store) UpsertBatch(conversations []models.Conversation) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.upsertManyLocked(conversations, nil)
+}
+
+// UpsertChanged is UpsertBatch for imports. A conversation whose
+// ContentHash matches the stored version's is left as it is, so user edits
+// survive re-importing the same export, and nothing is saved when no
+// conversation changed. Conversations in the trash come back out of it,
+// keeping the user's edits when the export has not changed them, unless a
+// merge put them there. The version
+// a changed title or message list replaces is kept as a Revision. seen,
+// when non-nil, is called for each conversation once the write succeeded,
+// with the version it replaced (nil when it is new) and whether it was
+// written. When another server sharing a PostgreSQL store wrote first, it
+// reloads and tries once more, so imports can run next to live servers.
+func (s *Store) UpsertChanged(conversations []models.Conversation, seen func(previous *models.Conversation, incoming models.Conversation, changed bool)) error {
+	err := s.upsertChanged(conversations, seen)
+	if errors.Is(err, ErrStale) {
+		if _, err := s.Refresh(); err != nil {
+			return err
+		}
+		err = s.upsertChanged(conversations, seen)
+	}
+	return err
+}
+
+func (s *Store) upsertChanged(conversations []models.Conversation, seen func(previous *models.Conversation, incoming models.Conversation, changed bool)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	type outcome struct {
+		previous *models.Conversation
+		changed  bool
+	}
+	outcomes := make([]outcome, len(conversations))
+	batch := make(map[string]models.Conversation, len(conversations))
+	changed := make([]models.Conversation, 0, len(conversations))
+	var revisions []models.Revision
 	now := time.Now().UTC()
+	for i, conversation := range conversations {
+		previous, ok := batch[conversation.ID]
+		if !ok {
+			previous, ok = s.conversations[conversation.ID]
+		}
+		if ok {
+			outcomes[i].previous = &previous
+		}
+		// Importing a conversation again brings it back from the trash,
+		// as it was when deleted if the export has not changed it, so a
+		// re-import after DELETE /api/conversations restores the lot.
+		// Those a merge folded into another stay put.
+		trashed, restoring := s.trash[conversation.ID]
+		if restoring && !ok && trashed.MergedInto != "" {
+			continue
+		}
+		restoring = restoring && !ok
+		if restoring {
+			previous, ok = trashed, true
+		}
+		sameContent := ok && conversation.ContentHash != "" && conversation.ContentHash == previous.ContentHash
+		switch {
+		case sameContent && !restoring:
+			continue
+		case sameContent:
+			conversation = previous
+		case ok:
+			// Imports know nothing of the user's flags; don't let an
+			// updated export clear them.
+			conversation.Archived = conversation.Archived || previous.Archived
+			conversation.Starred = conversation.Starred || previous.Starred
+			if restoring && conversation.Tags == nil {
+				conversation.Tags = previous.Tags
+			}
+			conversation.Topics = s.topicsLocked().Extract(conversation)
+		default:
+			conversation.Topics = s.topicsLocked().Extract(conversation)
+		}
+		if ok && revised(previous, conversation) {
+			revisions = append(revisions, s.nextRevisionLocked(previous, revisions, now))
+		}
+		outcomes[i].changed = true
+		batch[conversation.ID] = conversation
+		changed = append(changed, conversation)
+	}
+
+	if len(changed) > 0 {
+		if err := s.upsertManyLocked(changed, revisions); err != nil {
+			return err
+		}
+	}
+	if seen != nil {
+		for i, conversation := range conversations {
+			seen(outcomes[i].previous, conversation, outcomes[i].changed)
+		}
+	}
+	return nil
+}
+
+// upsertManyLocked writes conversations, and revisions of the versions they
+// replace, in one save.
+func (s *Store) upsertManyLocked(conversations []models.Conversation, revisions []models.Revision) error {
+	if err := s.checkConversationsLocked(conversations); err != nil {
+		return err
+	}
+
+	previous := make(map[string]models.Conversation, len(conversations))
+	trashed := make(map[string]models.Conversation)
+	for _, conversation := range conversations {
+		if _, seen := previous[conversation.ID]; !seen {
+			previous[conversation.ID] = s.conversations[conversation.ID]
+		}
+		if item, ok := s.trash[conversation.ID]; ok {
+			trashed[item.ID] = item
+		}
+	}
+	rollback := func() {
+		for id, conversation := range previous {
+			if conversation.ID == "" {
+				s.removeLocked(id)
+			} else {
+				s.putLocked(conversation)
+			}
+		}
+		maps.Copy(s.trash, trashed)
+		s.dropRevisionsLocked(revisions)
+	}
+
+	now := time.Now().UTC()
+	change := Change{Conversations: make([]models.Conversation, 0, len(conversations)), Revisions: revisions}
+	s.addRevisionsLocked(revisions)
+	for _, conversation := range conversations {
+		// Writing a conversation takes it out of the trash.
+		delete(s.trash, conversation.ID)
+		change.Conversations = append(change.Conversations, s.upsertLocked(conversation, now))
+	}
+
+	err := s.saveCheckedLocked(change, func(size int64) error {
+		return s.checkSizeLocked(size, s.blobBytes)
+	})
+	if err != nil {
+		rollback()
+	}
+	return err
+}
+
+// upsertLocked stores conversation and returns the record as stored.
+func (s *Store) upsertLocked(conversation models.Conversation, now time.Time) models.Conversation {
+	conversation.DeletedAt, conversation.MergedInto = time.Time{}, ""
 	existing, exists := s.conversations[conversation.ID]
 	if exists {
 		if conversation.CreatedAt.IsZero() {
 			conversation.CreatedAt = existing.CreatedAt
 		}
+		// Tags are user-managed; keep them when a re-import supplies none.
+		if conversation.Tags == nil {
+			conversation.Tags = existing.Tags
+		}
+		// Topics are picked at import; an edit that sends none keeps them.
+		if conversation.Topics == nil {
+			conversation.Topics = existing.Topics
+		}
+		carryAttachmentText(conversation.Attachments, existing.Attachments)
 	} else if conversation.CreatedAt.IsZero() {
 		conversation.CreatedAt = now
 	}
@@ -88,31 +465,67 @@ func (s *Store) Upsert(conversation models.Conversation) error {
 		conversation.UpdatedAt = now
 	}
 
-	s.conversations[conversation.ID] = conversation
-	return s.saveLocked()
+	return s.putLocked(conversation)
 }
 
-// UpdateTitle updates the title of a conversation.
+// UpdateTitle updates the title of a conversation. The ContentHash is left
+// as the import set it, so importing the same export again keeps the new
+// title.
 func (s *Store) UpdateTitle(id, title string) (models.Conversation, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.readOnly {
+		return models.Conversation{}, ErrReadOnly
+	}
 	convo, ok := s.conversations[id]
 	if !ok {
 		return models.Conversation{}, ErrNotFound
 	}
 
 	convo.Title = title
+	// Keep the span through the UpdatedAt bump.
+	convo.StartedAt, convo.EndedAt = convo.Span()
 	convo.UpdatedAt = time.Now().UTC()
-	s.conversations[id] = convo
-
-	if err := s.saveLocked(); err != nil {
+	if err := s.upsertManyLocked([]models.Conversation{convo}, nil); err != nil {
 		return models.Conversation{}, err
 	}
 	return convo, nil
 }
 
-// Delete removes a conversation by id.
+// TitleUntitled gives the conversations titles maps by ID the titles they
+// are mapped to, in one save, and returns them. Those no longer held, and
+// those named since the titles were made (see models.Conversation.Untitled),
+// are left alone.
+func (s *Store) TitleUntitled(titles map[string]string) ([]models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	now := time.Now().UTC()
+	var changed []models.Conversation
+	for id, title := range titles {
+		convo, ok := s.conversations[id]
+		if !ok || !convo.Untitled() || title == convo.Title {
+			continue
+		}
+		convo.Title = title
+		convo.StartedAt, convo.EndedAt = convo.Span()
+		convo.UpdatedAt = now
+		changed = append(changed, convo)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := s.upsertManyLocked(changed, nil); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// Delete moves a conversation to the trash.
 func (s *Store) Delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -120,61 +533,240 @@ func (s *Store) Delete(id string) error {
 	if _, ok := s.conversations[id]; !ok {
 		return ErrNotFound
 	}
-
-	delete(s.conversations, id)
-	return s.saveLocked()
+	return s.trashLocked([]string{id})
 }
 
-// DeleteAll wipes the store.
+// DeleteAll moves every conversation to the trash.
 func (s *Store) DeleteAll() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.conversations = make(map[string]models.Conversation)
-	return s.saveLocked()
+	// Another server sharing a PostgreSQL store may have added
+	// conversations this one has not loaded yet.
+	if _, err := s.refreshLocked(); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(s.conversations))
+	for id := range s.conversations {
+		ids = append(ids, id)
+	}
+	return s.trashLocked(ids)
 }
 
 func (s *Store) load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
-		return err
+	if info, err := os.Stat(s.path); err == nil {
+		s.modified = info.ModTime().UTC()
+		s.revision = uint64(s.modified.UnixNano())
+		s.fileBytes = info.Size()
 	}
 
-	file, err := os.Open(s.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
+	payload, err := s.backend.Load()
+	if err != nil {
+		if !s.salvage || s.engine != EngineJSON {
+			return err
+		}
+		s.salvaged = err
+	}
+	s.setContentsLocked(payload)
+	if b, ok := s.backend.(*postgresBackend); ok {
+		s.syncPostgresLocked(b)
+	}
+
+	return nil
+}
+
+// syncPostgresLocked takes the revision, modification time and size of a
+// PostgreSQL store from the database, so every server sharing it reports
+// the same ETags.
+func (s *Store) syncPostgresLocked(b *postgresBackend) {
+	s.revision, s.modified = b.revision, b.modified
+	if size, err := postgresSize(b.db); err == nil {
+		s.fileBytes = size
+	}
+}
+
+// Refresh reloads a PostgreSQL store when another server sharing its
+// database has written to it since, reporting whether it did. Other stores
+// are never written by anyone else, so for them it does nothing.
+func (s *Store) Refresh() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked()
+}
+
+func (s *Store) refreshLocked() (bool, error) {
+	b, ok := s.backend.(*postgresBackend)
+	if !ok {
+		return false, nil
+	}
+	stale, err := b.stale()
+	if err != nil || !stale {
+		return false, err
 	}
+	payload, err := b.Load()
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer file.Close()
+	s.setContentsLocked(payload)
+	s.syncPostgresLocked(b)
+	return true, nil
+}
+
+func (s *Store) saveLocked(change Change) error {
+	return s.saveCheckedLocked(change, nil)
+}
 
-	var payload struct {
-		Conversations []models.Conversation `json:"conversations"`
+// saveCheckedLocked persists change. When check is non-nil it is given the
+// store's new size and can veto the write. A disk too full to take it fails
+// the write with a *SpaceError, before anything is written where the space
+// can be told.
+func (s *Store) saveCheckedLocked(change Change, check func(size int64) error) error {
+	if s.readOnly {
+		return ErrReadOnly
 	}
-	if err := json.NewDecoder(file).Decode(&payload); err != nil {
+	if s.salvaged != nil && !change.Replace {
+		return fmt.Errorf("the store file is damaged and holds only what could be read (%w); repair it before writing", s.salvaged)
+	}
+	if err := s.checkSpaceLocked(); err != nil {
 		return err
 	}
 
+	size, err := s.backend.Save(change, s.contentsLocked, check)
+	if err != nil {
+		if errors.Is(err, ErrStale) {
+			// Reload once the caller has undone its change in memory and
+			// let go of the lock, so trying again writes on top of what
+			// the other servers wrote.
+			go s.Refresh()
+		}
+		if s.engine == EnginePostgres {
+			return err
+		}
+		return spaceFailure(err, filepath.Dir(s.path))
+	}
+	s.salvaged = nil
+	s.fileBytes = size
+	if b, ok := s.backend.(*postgresBackend); ok {
+		s.revision, s.modified = b.revision, b.modified
+		return nil
+	}
+	s.modified = time.Now().UTC()
+	s.revision++
+	return nil
+}
+
+// Contents is everything a store holds, laid out as in the JSON store file.
+type Contents struct {
+	Conversations []models.Conversation `json:"conversations"`
+	LinkChecks    []models.LinkCheck    `json:"linkChecks,omitempty"`
+	Imports       []models.ImportRecord `json:"imports,omitempty"`
+	Summaries     []models.Summary      `json:"summaries,omitempty"`
+	Embeddings    []models.Embedding    `json:"embeddings,omitempty"`
+	Revisions     []models.Revision     `json:"revisions,omitempty"`
+	Trash         []models.Conversation `json:"trash,omitempty"`
+	Shares        []models.Share        `json:"shares,omitempty"`
+	SavedSearches []models.SavedSearch  `json:"savedSearches,omitempty"`
+}
+
+// setContentsLocked replaces everything held in memory with payload.
+func (s *Store) setContentsLocked(payload Contents) {
+	s.conversations = make(map[string]models.Conversation, len(payload.Conversations))
 	for _, item := range payload.Conversations {
+		item.Stats = conversationStats(item)
 		s.conversations[item.ID] = item
 	}
-
-	return nil
+	s.index = nil
+	s.topics = nil
+	s.linkChecks = make(map[string]models.LinkCheck, len(payload.LinkChecks))
+	for _, check := range payload.LinkChecks {
+		s.linkChecks[check.URL] = check
+	}
+	s.imports = payload.Imports
+	s.summaries = make(map[string]models.Summary, len(payload.Summaries))
+	for _, summary := range payload.Summaries {
+		s.summaries[summaryKey(summary.ConversationID, summary.Method)] = summary
+	}
+	s.embeddings = make(map[string]models.Embedding, len(payload.Embeddings))
+	for _, embedding := range payload.Embeddings {
+		s.embeddings[summaryKey(embedding.ConversationID, embedding.Model)] = embedding
+	}
+	s.revisions = make(map[string][]models.Revision)
+	sortRevisions(payload.Revisions)
+	s.addRevisionsLocked(payload.Revisions)
+	s.trash = make(map[string]models.Conversation, len(payload.Trash))
+	for _, item := range payload.Trash {
+		if _, live := s.conversations[item.ID]; !live {
+			s.trash[item.ID] = item
+		}
+	}
+	s.shares = make(map[string]models.Share, len(payload.Shares))
+	for _, share := range payload.Shares {
+		s.shares[share.Token] = share
+	}
+	s.savedSearches = make(map[string]models.SavedSearch, len(payload.SavedSearches))
+	for _, search := range payload.SavedSearches {
+		s.savedSearches[search.ID] = search
+	}
 }
 
-func (s *Store) saveLocked() error {
-	payload := struct {
-		Conversations []models.Conversation `json:"conversations"`
-	}{
+// contentsLocked collects the store's contents in file order.
+func (s *Store) contentsLocked() Contents {
+	payload := Contents{
 		Conversations: make([]models.Conversation, 0, len(s.conversations)),
+		LinkChecks:    make([]models.LinkCheck, 0, len(s.linkChecks)),
+		Imports:       s.imports,
 	}
-
 	for _, item := range s.conversations {
 		payload.Conversations = append(payload.Conversations, item)
 	}
+	for _, check := range s.linkChecks {
+		payload.LinkChecks = append(payload.LinkChecks, check)
+	}
+	sort.Slice(payload.LinkChecks, func(i, j int) bool {
+		return payload.LinkChecks[i].URL < payload.LinkChecks[j].URL
+	})
+	for _, summary := range s.summaries {
+		payload.Summaries = append(payload.Summaries, summary)
+	}
+	sort.Slice(payload.Summaries, func(i, j int) bool {
+		a, b := payload.Summaries[i], payload.Summaries[j]
+		if a.ConversationID == b.ConversationID {
+			return a.Method < b.Method
+		}
+		return a.ConversationID < b.ConversationID
+	})
+	for _, embedding := range s.embeddings {
+		payload.Embeddings = append(payload.Embeddings, embedding)
+	}
+	sort.Slice(payload.Embeddings, func(i, j int) bool {
+		a, b := payload.Embeddings[i], payload.Embeddings[j]
+		if a.ConversationID == b.ConversationID {
+			return a.Model < b.Model
+		}
+		return a.ConversationID < b.ConversationID
+	})
+	for _, revisions := range s.revisions {
+		payload.Revisions = append(payload.Revisions, revisions...)
+	}
+	sortRevisions(payload.Revisions)
+	for _, item := range s.trash {
+		payload.Trash = append(payload.Trash, item)
+	}
+	sort.Slice(payload.Trash, func(i, j int) bool {
+		return payload.Trash[i].ID < payload.Trash[j].ID
+	})
+	for _, share := range s.shares {
+		payload.Shares = append(payload.Shares, share)
+	}
+	sortShares(payload.Shares)
+	for _, search := range s.savedSearches {
+		payload.SavedSearches = append(payload.SavedSearches, search)
+	}
+	sortSavedSearches(payload.SavedSearches)
 
 	sort.Slice(payload.Conversations, func(i, j int) bool {
 		if payload.Conversations[i].UpdatedAt.Equal(payload.Conversations[j].UpdatedAt) {
@@ -183,22 +775,212 @@ func (s *Store) saveLocked() error {
 		return payload.Conversations[i].UpdatedAt.After(payload.Conversations[j].UpdatedAt)
 	})
 
-	tmpPath := s.path + ".tmp"
-	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	return payload
+}
+
+// encodeLocked writes the store's contents to w as an indented JSON store
+// file, returning the number of bytes written.
+func (s *Store) encodeLocked(w io.Writer) (int64, error) {
+	return writeContents(w, s.contentsLocked(), false)
+}
+
+// writeContents writes payload to w as a JSON store file, without any
+// whitespace when compact is set, returning the number of bytes written.
+func writeContents(w io.Writer, payload Contents, compact bool) (int64, error) {
+	buffered := bufio.NewWriter(w)
+	counter := &countingWriter{w: buffered}
+	if err := encodeContents(counter, payload, compact); err != nil {
+		return counter.n, err
+	}
+	return counter.n, buffered.Flush()
+}
+
+// decodeContents reads a store file, decoding conversations one at a time
+// for the same reason encodeContents writes them that way.
+func decodeContents(r io.Reader) (Contents, error) {
+	var payload Contents
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return payload, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return payload, err
+		}
+		switch token {
+		case "conversations":
+			token, err := decoder.Token()
+			if err != nil {
+				return payload, err
+			}
+			if token == nil {
+				continue
+			}
+			if token != json.Delim('[') {
+				return payload, fmt.Errorf("store file: conversations must be an array, found %v", token)
+			}
+			for decoder.More() {
+				var conversation models.Conversation
+				if err := decoder.Decode(&conversation); err != nil {
+					return payload, err
+				}
+				payload.Conversations = append(payload.Conversations, conversation)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return payload, err
+			}
+			continue
+		case "linkChecks":
+			err = decoder.Decode(&payload.LinkChecks)
+		case "imports":
+			err = decoder.Decode(&payload.Imports)
+		case "summaries":
+			err = decoder.Decode(&payload.Summaries)
+		case "embeddings":
+			err = decoder.Decode(&payload.Embeddings)
+		case "revisions":
+			err = decoder.Decode(&payload.Revisions)
+		case "trash":
+			err = decoder.Decode(&payload.Trash)
+		case "shares":
+			err = decoder.Decode(&payload.Shares)
+		case "savedSearches":
+			err = decoder.Decode(&payload.SavedSearches)
+		default:
+			var skip json.RawMessage
+			err = decoder.Decode(&skip)
+		}
+		if err != nil {
+			return payload, err
+		}
+	}
+	_, err := decoder.Token()
+	return payload, err
+}
+
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
 	if err != nil {
 		return err
 	}
+	if token != want {
+		return fmt.Errorf("store file: expected %v, found %v", want, token)
+	}
+	return nil
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(&payload); err != nil {
-		file.Close()
-		return err
+// jsonLayout is the punctuation encodeContents puts around the parts of a
+// store file.
+type jsonLayout struct {
+	open, first, next, close, field, end string
+	marshal                              func(v any, prefix string) ([]byte, error)
+}
+
+var (
+	indentedLayout = jsonLayout{
+		open: "{\n  \"conversations\": ", first: "[\n    ", next: ",\n    ", close: "\n  ]",
+		field: ",\n  %q: %s", end: "\n}\n",
+		marshal: func(v any, prefix string) ([]byte, error) { return json.MarshalIndent(v, prefix, "  ") },
+	}
+	compactLayout = jsonLayout{
+		open: "{\"conversations\":", first: "[", next: ",", close: "]",
+		field: ",%q:%s", end: "}\n",
+		marshal: func(v any, _ string) ([]byte, error) { return json.Marshal(v) },
 	}
+)
 
-	if err := file.Close(); err != nil {
+// encodeContents writes payload exactly as a json.Encoder would, indented
+// unless compact is set, but one conversation at a time: json.Encoder
+// renders the whole document in memory first, which for a large archive
+// costs more than the archive.
+func encodeContents(w io.Writer, payload Contents, compact bool) error {
+	layout := indentedLayout
+	if compact {
+		layout = compactLayout
+	}
+	if _, err := io.WriteString(w, layout.open); err != nil {
 		return err
 	}
+	if len(payload.Conversations) == 0 {
+		if _, err := io.WriteString(w, "[]"); err != nil {
+			return err
+		}
+	} else {
+		for i, conversation := range payload.Conversations {
+			sep := layout.next
+			if i == 0 {
+				sep = layout.first
+			}
+			data, err := layout.marshal(conversation, "    ")
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, layout.close); err != nil {
+			return err
+		}
+	}
+
+	fields := []struct {
+		name  string
+		value any
+		empty bool
+	}{
+		{"linkChecks", payload.LinkChecks, len(payload.LinkChecks) == 0},
+		{"imports", payload.Imports, len(payload.Imports) == 0},
+		{"summaries", payload.Summaries, len(payload.Summaries) == 0},
+		{"embeddings", payload.Embeddings, len(payload.Embeddings) == 0},
+		{"revisions", payload.Revisions, len(payload.Revisions) == 0},
+		{"trash", payload.Trash, len(payload.Trash) == 0},
+		{"shares", payload.Shares, len(payload.Shares) == 0},
+		{"savedSearches", payload.SavedSearches, len(payload.SavedSearches) == 0},
+	}
+	for _, field := range fields {
+		if field.empty {
+			continue
+		}
+		data, err := layout.marshal(field.value, "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, layout.field, field.name, data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, layout.end)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
 
-	return os.Rename(tmpPath, s.path)
+// Revision identifies the store's current contents: it changes with every
+// write and never repeats.
+func (s *Store) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
+
+// LastModified reports when the store last changed: the latest save, or the
+// file's modification time for a store that has only been loaded.
+func (s *Store) LastModified() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.modified
 }
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// CachedSummary returns the stored summary of a conversation generated with
+// method, if there is one.
+func (s *Store) CachedSummary(id, method string) (models.Summary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary, ok := s.summaries[summaryKey(id, method)]
+	return summary, ok
+}
+
+// SaveSummary caches a generated summary, replacing any earlier one for the
+// same conversation and method. It returns ErrNotFound when the conversation
+// was deleted while the summary was being generated.
+func (s *Store) SaveSummary(summary models.Summary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.conversations[summary.ConversationID]; !ok {
+		return ErrNotFound
+	}
+	s.summaries[summaryKey(summary.ConversationID, summary.Method)] = summary
+	return s.saveLocked(Change{Summaries: []models.Summary{summary}})
+}
+
+func summaryKey(id, method string) string {
+	return id + "\x00" + method
+}
+
+// UpdateSummary replaces the one-line summary of a conversation, as a
+// summarizer regenerates it. The ContentHash is left as the import set it,
+// so importing the same export again keeps the new summary.
+func (s *Store) UpdateSummary(id, summary string) (models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Conversation{}, ErrReadOnly
+	}
+	convo, ok := s.conversations[id]
+	if !ok {
+		return models.Conversation{}, ErrNotFound
+	}
+
+	convo.Summary = summary
+	// Keep the span through the UpdatedAt bump.
+	convo.StartedAt, convo.EndedAt = convo.Span()
+	convo.UpdatedAt = time.Now().UTC()
+	if err := s.upsertManyLocked([]models.Conversation{convo}, nil); err != nil {
+		return models.Conversation{}, err
+	}
+	return convo, nil
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// BulkTagResult summarises a BulkTag run.
+type BulkTagResult struct {
+	Matched int      `json:"matched"`
+	Updated int      `json:"updated"`
+	IDs     []string `json:"ids"`
+}
+
+// TagCount is a tag and the number of conversations carrying it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTags trims, lowercases, de-duplicates and sorts tags.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// BulkTag adds and removes tags on every conversation selected by ids or
+// accepted by match, persisting the result with a single save. When ids is
+// non-empty, match is ignored; unknown ids are skipped.
+func (s *Store) BulkTag(ids []string, match func(models.Conversation) bool, add, remove []string) (BulkTagResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	add = NormalizeTags(add)
+	remove = NormalizeTags(remove)
+
+	var targets []string
+	if len(ids) > 0 {
+		for _, id := range ids {
+			if _, ok := s.conversations[id]; ok {
+				targets = append(targets, id)
+			}
+		}
+	} else if match != nil {
+		for id, convo := range s.conversations {
+			if match(convo) {
+				targets = append(targets, id)
+			}
+		}
+	}
+	sort.Strings(targets)
+
+	result := BulkTagResult{Matched: len(targets), IDs: make([]string, 0, len(targets))}
+	var change Change
+	now := time.Now().UTC()
+	for _, id := range targets {
+		convo := s.conversations[id]
+		next := applyTagChanges(convo.Tags, add, remove)
+		if equalTags(convo.Tags, next) {
+			continue
+		}
+		convo.Tags = next
+		convo.UpdatedAt = now
+		s.conversations[id] = convo
+		change.Conversations = append(change.Conversations, convo)
+		result.Updated++
+		result.IDs = append(result.IDs, id)
+	}
+
+	if result.Updated == 0 {
+		return result, nil
+	}
+	if err := s.saveLocked(change); err != nil {
+		return BulkTagResult{}, err
+	}
+	return result, nil
+}
+
+// Tags counts the conversations carrying each tag, most used first and
+// then alphabetically.
+func (s *Store) Tags() []TagCount {
+	s.mu.RLock()
+	counts := make(map[string]int)
+	for _, convo := range s.conversations {
+		for _, tag := range convo.Tags {
+			counts[tag]++
+		}
+	}
+	s.mu.RUnlock()
+
+	out := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		out = append(out, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Tag < out[j].Tag
+	})
+	return out
+}
+
+// hasTags reports whether current carries every one of tags, which are
+// normalized like stored tags.
+func hasTags(current, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(current, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func applyTagChanges(current, add, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, tag := range remove {
+		drop[tag] = true
+	}
+
+	next := make([]string, 0, len(current)+len(add))
+	for _, tag := range append(append([]string{}, current...), add...) {
+		if !drop[strings.ToLower(tag)] {
+			next = append(next, tag)
+		}
+	}
+
+	next = NormalizeTags(next)
+	if len(next) == 0 {
+		return nil
+	}
+	return next
+}
+
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/topics"
+)
+
+// fillBatch is how many conversations FillTopics saves at a time, so
+// writers are not held up for the whole archive.
+const fillBatch = 200
+
+// topicsLocked returns the topic corpus, building it on first use.
+func (s *Store) topicsLocked() *topics.Corpus {
+	if s.topics == nil {
+		s.topics = topics.NewCorpus(s.conversations)
+	}
+	return s.topics
+}
+
+// Topics tallies the topics of the conversations outside the trash and
+// returns the top most common; top <= 0 returns them all. It also reports
+// how many conversations have topics.
+func (s *Store) Topics(top int) ([]topics.Topic, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]models.Conversation, 0, len(s.conversations))
+	for _, item := range s.conversations {
+		if len(item.Topics) > 0 {
+			items = append(items, models.Conversation{Topics: item.Topics})
+		}
+	}
+	return topics.Tally(items, top), len(items)
+}
+
+// FillTopics picks the topics of conversations that have none, such as
+// those imported before topics were, saving them in batches. Their
+// UpdatedAt is left alone. It returns how many conversations it changed.
+func (s *Store) FillTopics(ctx context.Context) (int, error) {
+	done := 0
+	skip := make(map[string]bool)
+	for {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		n, more, err := s.fillTopicsBatch(skip)
+		done += n
+		if err != nil || !more {
+			return done, err
+		}
+	}
+}
+
+// fillTopicsBatch fills the topics of up to fillBatch conversations,
+// oldest first, and reports whether any are left. Conversations without
+// text get no topics and are added to skip so the next batch moves on.
+func (s *Store) fillTopicsBatch(skip map[string]bool) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return 0, false, ErrReadOnly
+	}
+	var pending []models.Conversation
+	for id, item := range s.conversations {
+		if item.Topics == nil && !skip[id] {
+			pending = append(pending, item)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, false, nil
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+			return pending[i].ID < pending[j].ID
+		}
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	more := len(pending) > fillBatch
+	pending = pending[:min(len(pending), fillBatch)]
+
+	corpus := s.topicsLocked()
+	change := Change{Conversations: make([]models.Conversation, 0, len(pending))}
+	for _, item := range pending {
+		if item.Topics = corpus.Extract(item); item.Topics == nil {
+			skip[item.ID] = true
+			continue
+		}
+		change.Conversations = append(change.Conversations, item)
+	}
+	if len(change.Conversations) == 0 {
+		return 0, more, nil
+	}
+	for _, item := range change.Conversations {
+		s.conversations[item.ID] = item
+	}
+	if err := s.saveLocked(change); err != nil {
+		for _, item := range change.Conversations {
+			item.Topics = nil
+			s.conversations[item.ID] = item
+		}
+		return 0, false, err
+	}
+	return len(change.Conversations), more, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"maps"
+	"sort"
+	"time"
+
+	"zatGPT/internal/models"
+)
+
+// Trash returns the conversations in the trash without their messages,
+// most recently deleted first.
+func (s *Store) Trash() []models.Conversation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]models.Conversation, 0, len(s.trash))
+	for _, item := range s.trash {
+		item.Messages = nil
+		item.Tree = nil
+		item.Stats = nil
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].ID < items[j].ID
+		}
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items
+}
+
+// RestoreTrashed moves a conversation out of the trash and back into the
+// list, returning it as restored.
+func (s *Store) RestoreTrashed(id string) (models.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return models.Conversation{}, ErrReadOnly
+	}
+	item, ok := s.trash[id]
+	if !ok {
+		return models.Conversation{}, ErrNotFound
+	}
+	if err := s.checkConversationsLocked([]models.Conversation{item}); err != nil {
+		return models.Conversation{}, err
+	}
+
+	restored := item
+	restored.DeletedAt, restored.MergedInto = time.Time{}, ""
+	delete(s.trash, id)
+	restored = s.putLocked(restored)
+	if err := s.saveLocked(Change{Conversations: []models.Conversation{restored}}); err != nil {
+		s.removeLocked(id)
+		s.trash[id] = item
+		return models.Conversation{}, err
+	}
+	return restored, nil
+}
+
+// Purge deletes a conversation in the trash for good, along with its
+// summaries, embeddings, revisions and shares.
+func (s *Store) Purge(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.trash[id]; !ok {
+		return ErrNotFound
+	}
+	return s.purgeLocked([]string{id})
+}
+
+// PurgeTrash deletes for good every conversation moved to the trash before
+// cutoff, or the whole trash when cutoff is zero, and reports how many
+// went.
+func (s *Store) PurgeTrash(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, item := range s.trash {
+		if cutoff.IsZero() || item.DeletedAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	sort.Strings(ids)
+	if err := s.purgeLocked(ids); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// trashLocked moves the conversations with the given IDs to the trash.
+// Their summaries, embeddings, revisions and shares are kept until they
+// are purged; shares stop working meanwhile.
+func (s *Store) trashLocked(ids []string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	now := time.Now().UTC()
+	change := Change{Trashed: make([]models.Conversation, 0, len(ids))}
+	for _, id := range ids {
+		item := s.conversations[id]
+		item.DeletedAt = now
+		s.removeLocked(id)
+		s.trash[id] = item
+		change.Trashed = append(change.Trashed, item)
+	}
+	if err := s.saveLocked(change); err != nil {
+		for _, item := range change.Trashed {
+			delete(s.trash, item.ID)
+			item.DeletedAt = time.Time{}
+			s.putLocked(item)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) purgeLocked(ids []string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	purged := make(map[string]models.Conversation, len(ids))
+	for _, id := range ids {
+		purged[id] = s.trash[id]
+		delete(s.trash, id)
+	}
+	summaries := make(map[string]models.Summary)
+	for key, summary := range s.summaries {
+		if _, ok := purged[summary.ConversationID]; ok {
+			summaries[key] = summary
+			delete(s.summaries, key)
+		}
+	}
+	embeddings := make(map[string]models.Embedding)
+	for key, embedding := range s.embeddings {
+		if _, ok := purged[embedding.ConversationID]; ok {
+			embeddings[key] = embedding
+			delete(s.embeddings, key)
+		}
+	}
+	revisions := make(map[string][]models.Revision)
+	for id := range purged {
+		if kept, ok := s.revisions[id]; ok {
+			revisions[id] = kept
+			delete(s.revisions, id)
+		}
+	}
+	shares := make(map[string]models.Share)
+	for token, share := range s.shares {
+		if _, ok := purged[share.ConversationID]; ok {
+			shares[token] = share
+			delete(s.shares, token)
+		}
+	}
+	if err := s.saveLocked(Change{Deleted: ids}); err != nil {
+		maps.Copy(s.trash, purged)
+		maps.Copy(s.summaries, summaries)
+		maps.Copy(s.embeddings, embeddings)
+		maps.Copy(s.revisions, revisions)
+		maps.Copy(s.shares, shares)
+		return err
+	}
+	return nil
+}
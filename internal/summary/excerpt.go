@@ -0,0 +1,146 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/terms"
+)
+
+// maxExcerptLen bounds the summaries that quote the conversation, as the
+// importer bounds the first message it uses.
+const maxExcerptLen = 240
+
+// defaultSentences is how many sentences FirstSentences takes when N is
+// not set.
+const defaultSentences = 3
+
+// FirstMessage quotes the opening user message, or failing that the first
+// reply: the summary every import sets.
+type FirstMessage struct{}
+
+func (FirstMessage) Method() string { return "first-message" }
+
+func (FirstMessage) Summarize(_ context.Context, convo models.Conversation) (string, error) {
+	var reply string
+	for _, msg := range convo.Messages {
+		text := flatten(msg.Content)
+		switch {
+		case text == "":
+		case msg.Author == models.AuthorUser:
+			return truncate(text, maxExcerptLen), nil
+		case msg.Author == models.AuthorAssistant && reply == "":
+			reply = text
+		}
+	}
+	if reply == "" {
+		return convo.Summary, nil
+	}
+	return truncate(reply, maxExcerptLen), nil
+}
+
+// FirstSentences strings together the first N sentences the user wrote,
+// passing over greetings and other sentences too short to say anything,
+// so a conversation opened with "hi" is summed up by what came next. It
+// falls back to the assistant's sentences when the user's are all short.
+type FirstSentences struct {
+	N int
+}
+
+func (f FirstSentences) Method() string {
+	if f.N <= 0 || f.N == defaultSentences {
+		return "first-sentences"
+	}
+	return "first-sentences:" + strconv.Itoa(f.N)
+}
+
+func (f FirstSentences) Summarize(_ context.Context, convo models.Conversation) (string, error) {
+	n := f.N
+	if n <= 0 {
+		n = defaultSentences
+	}
+	for _, author := range []string{models.AuthorUser, models.AuthorAssistant} {
+		var picked []string
+		for _, msg := range convo.Messages {
+			if msg.Author != author {
+				continue
+			}
+			for _, sentence := range sentences(msg.Content) {
+				if picked = append(picked, sentence); len(picked) == n {
+					break
+				}
+			}
+			if len(picked) == n {
+				break
+			}
+		}
+		if len(picked) > 0 {
+			return truncate(strings.Join(picked, " "), maxSummaryLen), nil
+		}
+	}
+	return convo.Summary, nil
+}
+
+// LongestMessage quotes the longest message the user wrote, which tends to
+// be the one that explains what they were after.
+type LongestMessage struct{}
+
+func (LongestMessage) Method() string { return "longest-message" }
+
+func (LongestMessage) Summarize(_ context.Context, convo models.Conversation) (string, error) {
+	longest, length := "", 0
+	for _, msg := range convo.Messages {
+		if msg.Author != models.AuthorUser {
+			continue
+		}
+		text := flatten(msg.Content)
+		if n := utf8.RuneCountInString(text); n > length {
+			longest, length = text, n
+		}
+	}
+	if longest == "" {
+		return convo.Summary, nil
+	}
+	return truncate(longest, maxExcerptLen), nil
+}
+
+// flatten is text with its markup noise dropped and its whitespace
+// collapsed to single spaces.
+func flatten(text string) string {
+	return strings.Join(strings.Fields(terms.StripNoise(text)), " ")
+}
+
+// local resolves the names of the summarizers that run without a model:
+// "heuristic", "first-message", "first-sentences" (or "first-sentences:N")
+// and "longest-message".
+func local(spec string) (Summarizer, bool, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+	switch {
+	case spec == "heuristic":
+		return Heuristic{}, true, nil
+	case spec == "first-message":
+		return FirstMessage{}, true, nil
+	case spec == "longest-message":
+		return LongestMessage{}, true, nil
+	case name == "first-sentences" && !hasArg:
+		return FirstSentences{}, true, nil
+	case name == "first-sentences":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return nil, true, fmt.Errorf("invalid sentence count in %q (want first-sentences:N with N at least 1)", spec)
+		}
+		return FirstSentences{N: n}, true, nil
+	}
+	return nil, false, nil
+}
+
+// Local returns the summarizer named by a Method of one that runs without
+// a model, or false when method names none.
+func Local(method string) (Summarizer, bool) {
+	summarizer, ok, err := local(method)
+	return summarizer, ok && err == nil
+}
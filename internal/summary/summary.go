@@ -0,0 +1,249 @@
+// Package summary produces conversation summaries and titles, either
+// locally, with an extractive heuristic or an excerpt of the conversation,
+// or by asking an OpenAI-compatible chat model.
+package summary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+	"zatGPT/internal/terms"
+)
+
+// Summarizer condenses a conversation into a few sentences.
+type Summarizer interface {
+	Summarize(ctx context.Context, convo models.Conversation) (string, error)
+	// Method names the summarizer in cached results ("heuristic",
+	// "first-message", "first-sentences", "longest-message" or
+	// "llm:<model>").
+	Method() string
+}
+
+// New resolves a summarizer spec: "heuristic" (the default),
+// "first-message", "first-sentences" (or "first-sentences:N"),
+// "longest-message", or the http(s) URL of an OpenAI-compatible chat
+// completions endpoint.
+func New(spec, model, apiKey string) (Summarizer, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Heuristic{}, nil
+	}
+	if summarizer, ok, err := local(spec); ok {
+		return summarizer, err
+	}
+	switch {
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if model == "" {
+			return nil, fmt.Errorf("an LLM summarizer needs a model name")
+		}
+		return &LLM{
+			URL:    spec,
+			Model:  model,
+			APIKey: apiKey,
+			Client: &http.Client{Timeout: 2 * time.Minute, Transport: telemetry.Transport(nil)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown summarizer %q (want heuristic, first-message, first-sentences[:N], longest-message or an http(s) URL)", spec)
+	}
+}
+
+// Heuristic builds an extractive summary: the opening request followed by
+// the assistant sentences that best cover the conversation's frequent terms.
+type Heuristic struct{}
+
+const (
+	heuristicSentences = 3
+	maxSummaryLen      = 600
+)
+
+var sentenceEnd = regexp.MustCompile(`([.!?])\s+`)
+
+func (Heuristic) Method() string { return "heuristic" }
+
+func (Heuristic) Summarize(_ context.Context, convo models.Conversation) (string, error) {
+	freq := make(map[string]int)
+	for _, msg := range convo.Messages {
+		for _, word := range terms.Tokenize(msg.Content) {
+			freq[word]++
+		}
+	}
+
+	type candidate struct {
+		text  string
+		score float64
+		order int
+	}
+	var (
+		opening    string
+		candidates []candidate
+	)
+	for _, msg := range convo.Messages {
+		switch msg.Author {
+		case "user":
+			if opening == "" {
+				opening = firstSentence(msg.Content)
+			}
+		case "assistant":
+			for _, sentence := range sentences(msg.Content) {
+				words := terms.Tokenize(sentence)
+				if len(words) < 4 {
+					continue
+				}
+				score := 0.0
+				for _, word := range words {
+					score += float64(freq[word])
+				}
+				candidates = append(candidates, candidate{
+					text:  sentence,
+					score: score / math.Sqrt(float64(len(words))),
+					order: len(candidates),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	if len(candidates) > heuristicSentences {
+		candidates = candidates[:heuristicSentences]
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].order < candidates[j].order })
+
+	parts := make([]string, 0, len(candidates)+1)
+	if opening != "" {
+		parts = append(parts, "Asked: "+opening)
+	}
+	for _, c := range candidates {
+		parts = append(parts, c.text)
+	}
+	text := strings.Join(parts, " ")
+	if text == "" {
+		return convo.Summary, nil
+	}
+	return truncate(text, maxSummaryLen), nil
+}
+
+func sentences(text string) []string {
+	text = strings.Join(strings.Fields(terms.StripNoise(text)), " ")
+	marked := sentenceEnd.ReplaceAllString(text, "$1\x00")
+	var out []string
+	for _, s := range strings.Split(marked, "\x00") {
+		s = strings.TrimSpace(strings.TrimLeft(s, "-*#> "))
+		if len(s) >= 20 && len(s) <= 400 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func firstSentence(text string) string {
+	all := strings.Join(strings.Fields(terms.StripNoise(text)), " ")
+	if loc := sentenceEnd.FindStringIndex(all); loc != nil {
+		all = all[:loc[0]+1]
+	}
+	return truncate(all, 200)
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
+
+// LLM asks an OpenAI-compatible /v1/chat/completions endpoint (OpenAI,
+// Ollama, LM Studio, vLLM...) for the summary.
+type LLM struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+// maxTranscriptLen bounds the prompt so long chats fit small context windows.
+const maxTranscriptLen = 24000
+
+const llmInstructions = "Summarize this conversation between a user and an AI assistant in at most three sentences. Say what the user wanted and what they ended up with. Reply with the summary only."
+
+func (l *LLM) Method() string { return "llm:" + l.Model }
+
+func (l *LLM) Summarize(ctx context.Context, convo models.Conversation) (string, error) {
+	return l.complete(ctx, "summarizer", llmInstructions, transcript(convo, true))
+}
+
+// transcript is the conversation as the model is shown it, with its
+// title when withTitle is set.
+func transcript(convo models.Conversation, withTitle bool) string {
+	var transcript strings.Builder
+	if withTitle {
+		fmt.Fprintf(&transcript, "Title: %s\n\n", convo.Title)
+	}
+	for _, msg := range convo.Messages {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", msg.Author, msg.Content)
+		if transcript.Len() > maxTranscriptLen {
+			break
+		}
+	}
+	return truncate(transcript.String(), maxTranscriptLen)
+}
+
+// complete sends instructions and text to the model and returns its reply;
+// role names the model in errors.
+func (l *LLM) complete(ctx context.Context, role, instructions, text string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":       l.Model,
+		"temperature": 0.2,
+		"messages": []map[string]string{
+			{"role": "system", "content": instructions},
+			{"role": "user", "content": text},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+l.APIKey)
+	}
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%s returned %s: %s", role, resp.Status, strings.TrimSpace(string(snippet)))
+	}
+
+	var payload struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if len(payload.Choices) == 0 || strings.TrimSpace(payload.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("%s returned no text", role)
+	}
+	return strings.TrimSpace(payload.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,133 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/telemetry"
+)
+
+// Titler names a conversation.
+type Titler interface {
+	Title(ctx context.Context, convo models.Conversation) (string, error)
+	// Method names the titler ("heuristic" or "llm:<model>").
+	Method() string
+}
+
+// NewTitler resolves a titler spec: "heuristic" (the default) or the
+// http(s) URL of an OpenAI-compatible chat completions endpoint.
+func NewTitler(spec, model, apiKey string) (Titler, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "" || spec == "heuristic":
+		return HeuristicTitle{}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if model == "" {
+			return nil, fmt.Errorf("an LLM titler needs a model name")
+		}
+		return &LLM{
+			URL:    spec,
+			Model:  model,
+			APIKey: apiKey,
+			Client: &http.Client{Timeout: 2 * time.Minute, Transport: telemetry.Transport(nil)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown titler %q (want heuristic or an http(s) URL)", spec)
+	}
+}
+
+const (
+	// maxTitleWords and maxTitleLen bound the titles HeuristicTitle
+	// condenses a question to, and those a model replies with.
+	maxTitleWords = 10
+	maxTitleLen   = 80
+	// minTitleWords is how many words a sentence needs to name the
+	// conversation, so a greeting is passed over.
+	minTitleWords = 3
+)
+
+// titleFiller matches the greetings and polite openings a request is
+// condensed without: "Hi! Can you please explain..." is titled "Explain...",
+// and "I need to migrate..." "Migrate...".
+var titleFiller = regexp.MustCompile(`(?i)^(?:(?:hi|hello|hey|ok|okay|so|please|thanks)\b[\s,!.]*)*(?:(?:can|could|would|will) you(?: please)?\s+|i(?: need| want| would like|'d like)(?: you)? to\s+|(?:please )?help me(?: to)?\s+|please\s+)?`)
+
+// HeuristicTitle titles a conversation with the first thing the user asked,
+// condensed: the opening sentence of their first message that says
+// anything, with greetings and polite openings dropped, cut to a few
+// words. A conversation without one keeps its title.
+type HeuristicTitle struct{}
+
+func (HeuristicTitle) Method() string { return "heuristic" }
+
+func (HeuristicTitle) Title(_ context.Context, convo models.Conversation) (string, error) {
+	for _, msg := range convo.Messages {
+		if msg.Author != models.AuthorUser {
+			continue
+		}
+		if sentence := titleSentence(msg.Content); sentence != "" {
+			return condense(sentence), nil
+		}
+	}
+	return convo.Title, nil
+}
+
+// titleSentence is the first sentence of text long enough to name
+// anything, or "" when there is none.
+func titleSentence(text string) string {
+	marked := sentenceEnd.ReplaceAllString(flatten(text), "$1\x00")
+	for _, sentence := range strings.Split(marked, "\x00") {
+		sentence = strings.TrimSpace(strings.TrimLeft(sentence, "-*#> "))
+		if len(strings.Fields(titleFiller.ReplaceAllString(sentence, ""))) >= minTitleWords {
+			return sentence
+		}
+	}
+	return ""
+}
+
+// condense makes a title of a sentence: its filler and closing punctuation
+// dropped, its first letter capitalised, and cut to maxTitleWords words.
+func condense(sentence string) string {
+	sentence = strings.TrimSpace(titleFiller.ReplaceAllString(sentence, ""))
+	sentence = strings.TrimRight(sentence, "?.!:;, ")
+	words := strings.Fields(sentence)
+	cut := len(words) > maxTitleWords
+	if cut {
+		words = words[:maxTitleWords]
+	}
+	title := strings.Join(words, " ")
+	if first, size := utf8.DecodeRuneInString(title); size > 0 {
+		title = string(unicode.ToUpper(first)) + title[size:]
+	}
+	if cut {
+		title = strings.TrimRight(title, ",;:") + "…"
+	}
+	return truncate(title, maxTitleLen)
+}
+
+const llmTitleInstructions = "Write a title of at most eight words for this conversation between a user and an AI assistant, naming what the user was after. Reply with the title only, without quotes."
+
+// Title asks the model for a title, tidied of the quotes and labels models
+// tend to add.
+func (l *LLM) Title(ctx context.Context, convo models.Conversation) (string, error) {
+	reply, err := l.complete(ctx, "titler", llmTitleInstructions, transcript(convo, false))
+	if err != nil {
+		return "", err
+	}
+	title, _, _ := strings.Cut(reply, "\n")
+	title = strings.TrimSpace(title)
+	if label, rest, ok := strings.Cut(title, ":"); ok && strings.EqualFold(strings.TrimSpace(label), "title") {
+		title = strings.TrimSpace(rest)
+	}
+	title = strings.Trim(title, "\"'“”*# ")
+	if title == "" {
+		return "", fmt.Errorf("titler returned no title")
+	}
+	return truncate(title, maxTitleLen), nil
+}
@@ -0,0 +1,85 @@
+// Package terms tokenises message text into meaningful words, dropping
+// stopwords, code and URLs, for term statistics and extractive summaries.
+package terms
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	fencedCode = regexp.MustCompile("(?s)```.*?```")
+	inlineCode = regexp.MustCompile("`[^`\n]*`")
+	urlPattern = regexp.MustCompile(`https?://\S+`)
+)
+
+// StripNoise removes fenced and inline code and URLs, which would otherwise
+// dominate word counts.
+func StripNoise(text string) string {
+	text = fencedCode.ReplaceAllString(text, " ")
+	text = inlineCode.ReplaceAllString(text, " ")
+	return urlPattern.ReplaceAllString(text, " ")
+}
+
+// Tokenize lower-cases text and returns its words of at least three
+// letters that are not stopwords or numbers. Code and URLs are skipped.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(StripNoise(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '-'
+	})
+
+	out := make([]string, 0, len(fields))
+	for _, field := range fields {
+		word := strings.Trim(strings.ToLower(field), "'-")
+		word = strings.TrimSuffix(word, "'s")
+		if len([]rune(word)) < 3 || IsStopword(word) || !hasLetter(word) {
+			continue
+		}
+		out = append(out, word)
+	}
+	return out
+}
+
+func hasLetter(word string) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStopword reports whether a lower-case word carries no topic on its own.
+func IsStopword(word string) bool {
+	return stopwords[word]
+}
+
+var stopwords = func() map[string]bool {
+	words := strings.Fields(`
+		a about above after again against all also am an and any are aren't as at
+		be because been before being below between both but by can can't cannot
+		could couldn't did didn't do does doesn't doing don't down during each even
+		few for from further get gets getting give go going got had hadn't has
+		hasn't have haven't having he he'd he'll he's her here here's hers herself
+		him himself his how how's however i i'd i'll i'm i've if in into is isn't it
+		it's its itself just let's like make makes many may me might more most much
+		must mustn't my myself need no nor not now of off often on once one only or
+		other ought our ours ourselves out over own please really same say says
+		shan't she she'd she'll she's should shouldn't so some such sure than that
+		that's the their theirs them themselves then there there's these they
+		they'd they'll they're they've thing things this those though through to
+		too two under until up upon us use used using very via want was wasn't way
+		we we'd we'll we're we've well were weren't what what's when when's where
+		where's whether which while who who's whom why why's will with within
+		without won't would wouldn't yes yet you you'd you'll you're you've your
+		yours yourself yourselves
+		example here's let know see thanks thank okay ok also help following
+		below above first second new good great able want wants provide
+	`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
@@ -0,0 +1,98 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register decoders for the formats ChatGPT exports contain.
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MinWidth     = 16
+	MaxWidth     = 1024
+	DefaultWidth = 256
+
+	// MaxPixels bounds the images Generate decodes: a small file can
+	// claim dimensions whose pixels would not fit in memory.
+	MaxPixels = 50_000_000
+)
+
+var (
+	// ErrUnsupported is returned when the source is not a decodable image,
+	// or is one with no pixels.
+	ErrUnsupported = errors.New("unsupported image format")
+	// ErrTooLarge is returned for images of more than MaxPixels pixels.
+	ErrTooLarge = errors.New("image has too many pixels to thumbnail")
+)
+
+// ClampWidth keeps requested widths within [MinWidth, MaxWidth] and rounds
+// them to a multiple of 16 so the on-disk cache stays small.
+func ClampWidth(width int) int {
+	if width <= 0 {
+		return DefaultWidth
+	}
+	if width < MinWidth {
+		width = MinWidth
+	}
+	if width > MaxWidth {
+		width = MaxWidth
+	}
+	return (width + 15) / 16 * 16
+}
+
+// Generate decodes src and writes a JPEG scaled to width pixels wide,
+// preserving the aspect ratio. Images narrower than width are not upscaled.
+// Transparent areas are flattened onto white. The image's header is read
+// first, so one that is empty or over MaxPixels is refused before it is
+// decoded.
+func Generate(dst io.Writer, src io.Reader, width int) error {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(src, &header))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return ErrUnsupported
+		}
+		return err
+	}
+	switch {
+	case config.Width <= 0 || config.Height <= 0:
+		return ErrUnsupported
+	case int64(config.Width)*int64(config.Height) > MaxPixels:
+		return ErrTooLarge
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, src))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return ErrUnsupported
+		}
+		return err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return ErrUnsupported
+	}
+	if bounds.Dx() < width {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.ApproxBiLinear.Scale(canvas, canvas.Bounds(), img, bounds, draw.Over, nil)
+
+	return jpeg.Encode(dst, canvas, &jpeg.Options{Quality: 80})
+}
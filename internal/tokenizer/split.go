@@ -0,0 +1,240 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// The pre-tokenizers below match tiktoken's split patterns by hand, since
+// Go's regexp has no lookahead. cl100k_base splits with
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// and o200k_base with
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Each matcher returns the end of the match starting at i: alternatives
+// are tried in order and quantifiers are greedy, backtracking as the
+// regular expression would.
+
+func splitCl100k(text string, fn func(string)) {
+	for i := 0; i < len(text); {
+		end := matchCl100k(text, i)
+		fn(text[i:end])
+		i = end
+	}
+}
+
+func splitO200k(text string, fn func(string)) {
+	for i := 0; i < len(text); {
+		end := matchO200k(text, i)
+		fn(text[i:end])
+		i = end
+	}
+}
+
+func matchCl100k(s string, i int) int {
+	if end := contraction(s, i); end > i {
+		return end
+	}
+	r, size := runeAt(s, i)
+	if isOther(r) {
+		if next, _ := runeAt(s, i+size); unicode.IsLetter(next) {
+			return run(s, i+size, unicode.IsLetter)
+		}
+	}
+	if unicode.IsLetter(r) {
+		return run(s, i, unicode.IsLetter)
+	}
+	if end, ok := digits(s, i); ok {
+		return end
+	}
+	if end, ok := punctuation(s, i, isNewline); ok {
+		return end
+	}
+	return space(s, i)
+}
+
+func matchO200k(s string, i int) int {
+	r, size := runeAt(s, i)
+	for _, word := range []func(string, int) int{casedWord, capitalWord} {
+		if isOther(r) {
+			if end := word(s, i+size); end >= 0 {
+				return contraction(s, end)
+			}
+		}
+		if end := word(s, i); end >= 0 {
+			return contraction(s, end)
+		}
+	}
+	if end, ok := digits(s, i); ok {
+		return end
+	}
+	if end, ok := punctuation(s, i, func(r rune) bool { return isNewline(r) || r == '/' }); ok {
+		return end
+	}
+	return space(s, i)
+}
+
+// casedWord matches [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+
+// at i, or returns -1. When the greedy upper-case run leaves no lower-case
+// letter after it, it gives back one rune at a time.
+func casedWord(s string, i int) int {
+	var starts []int
+	j := i
+	for {
+		r, size := runeAt(s, j)
+		if !isUpperish(r) {
+			break
+		}
+		starts = append(starts, j)
+		j += size
+	}
+	starts = append(starts, j)
+	for k := len(starts) - 1; k >= 0; k-- {
+		if r, _ := runeAt(s, starts[k]); isLowerish(r) {
+			return run(s, starts[k], isLowerish)
+		}
+	}
+	return -1
+}
+
+// capitalWord matches [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*
+// at i, or returns -1.
+func capitalWord(s string, i int) int {
+	end := run(s, i, isUpperish)
+	if end == i {
+		return -1
+	}
+	return run(s, end, isLowerish)
+}
+
+// contraction matches (?i:'s|'t|'re|'ve|'m|'ll|'d) at i, returning i when
+// there is none; o200k_base words take one as an optional suffix.
+func contraction(s string, i int) int {
+	if i >= len(s) || s[i] != '\'' {
+		return i
+	}
+	r, size := runeAt(s, i+1)
+	switch foldASCII(r) {
+	case 's', 't', 'm', 'd':
+		return i + 1 + size
+	case 'r', 'v':
+		if next, _ := runeAt(s, i+1+size); foldASCII(next) == 'e' {
+			return i + 2 + size
+		}
+	case 'l':
+		if next, _ := runeAt(s, i+1+size); foldASCII(next) == 'l' {
+			return i + 2 + size
+		}
+	}
+	return i
+}
+
+// foldASCII lower-cases the letters of the contractions, as (?i) matches
+// them: ſ (long s) folds to s.
+func foldASCII(r rune) rune {
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return r + 'a' - 'A'
+	case r == 'ſ':
+		return 's'
+	}
+	return r
+}
+
+// digits matches \p{N}{1,3} at i.
+func digits(s string, i int) (int, bool) {
+	end := i
+	for n := 0; n < 3; n++ {
+		r, size := runeAt(s, end)
+		if !unicode.IsNumber(r) {
+			break
+		}
+		end += size
+	}
+	return end, end > i
+}
+
+// punctuation matches " ?[^\s\p{L}\p{N}]+" at i followed by any run of
+// runes trailing accepts.
+func punctuation(s string, i int, trailing func(rune) bool) (int, bool) {
+	j := i
+	if j < len(s) && s[j] == ' ' {
+		j++
+	}
+	if r, _ := runeAt(s, j); !isPunct(r) {
+		return i, false
+	}
+	return run(s, run(s, j, isPunct), trailing), true
+}
+
+// space matches \s*[\r\n]+, then \s+(?!\S), then \s+ at i. Every rune not
+// matched by an earlier alternative is white space, so one of them always
+// matches; a lone other rune is taken as is to guarantee progress.
+func space(s string, i int) int {
+	end := run(s, i, unicode.IsSpace)
+	if end == i {
+		_, size := runeAt(s, i)
+		return i + max(size, 1)
+	}
+	for j := end - 1; j >= i; j-- {
+		if s[j] == '\n' || s[j] == '\r' {
+			return j + 1
+		}
+	}
+	if end == len(s) {
+		return end
+	}
+	_, size := utf8.DecodeLastRuneInString(s[i:end])
+	if end-size > i {
+		return end - size
+	}
+	return end
+}
+
+// run returns the end of the run of runes accepted by in starting at i.
+func run(s string, i int, in func(rune) bool) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !in(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// runeAt decodes the rune at i, or returns -1 at the end of s.
+func runeAt(s string, i int) (rune, int) {
+	if i >= len(s) {
+		return -1, 0
+	}
+	return utf8.DecodeRuneInString(s[i:])
+}
+
+func isNewline(r rune) bool { return r == '\r' || r == '\n' }
+
+// isOther is [^\r\n\p{L}\p{N}].
+func isOther(r rune) bool {
+	return r >= 0 && !isNewline(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isPunct is [^\s\p{L}\p{N}].
+func isPunct(r rune) bool {
+	return r >= 0 && !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isUpperish is [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}].
+func isUpperish(r rune) bool {
+	return r >= 0 && unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish is [\p{Ll}\p{Lm}\p{Lo}\p{M}].
+func isLowerish(r rune) bool {
+	return r >= 0 && unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
@@ -0,0 +1,176 @@
+// Package tokenizer counts tokens the way OpenAI's tiktoken does, with the
+// cl100k_base (GPT-4, GPT-3.5) and o200k_base (GPT-4o and later)
+// vocabularies built in. Text is split into pieces by the encoding's
+// pre-tokenizer and each piece is byte-pair merged by rank; special tokens
+// such as <|endoftext|> count as ordinary text.
+package tokenizer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	loader "github.com/pkoukk/tiktoken-go-loader"
+
+	"zatGPT/internal/models"
+)
+
+// Encoding is a BPE vocabulary with its pre-tokenizer. Its ranks are loaded
+// on first use.
+type Encoding struct {
+	name  string
+	split func(text string, fn func(piece string))
+
+	once  sync.Once
+	ranks map[string]int
+}
+
+// The encodings, named as in tiktoken.
+var (
+	Cl100k = &Encoding{name: "cl100k_base", split: splitCl100k}
+	O200k  = &Encoding{name: "o200k_base", split: splitO200k}
+)
+
+// o200kModels are the model name prefixes that use o200k_base; other gpt-4
+// and gpt-3.5 models use cl100k_base. ChatGPT exports name GPT-4.5
+// "gpt-4-5" and GPT-3.5 "text-davinci-002-render-sha".
+var o200kModels = []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-4-5", "gpt-5", "gpt-oss", "o1", "o3", "o4"}
+
+var cl100kModels = []string{"gpt-4", "gpt-3.5", "gpt-35", "text-davinci-002-render"}
+
+// maxPiece bounds the bytes merged at a time. The merge is quadratic in the
+// piece length, so a piece longer than this (a run of thousands of letters
+// without a space) is counted in chunks, which can differ from tiktoken by
+// a token per chunk.
+const maxPiece = 4096
+
+// ForModel returns the encoding of an OpenAI model, by name as exports
+// record it. Models of other vendors and unknown ones (including "") get
+// o200k_base, which current ChatGPT models use, as an approximation.
+func ForModel(model string) *Encoding {
+	model = strings.ToLower(model)
+	for _, prefix := range o200kModels {
+		if strings.HasPrefix(model, prefix) {
+			return O200k
+		}
+	}
+	for _, prefix := range cl100kModels {
+		if strings.HasPrefix(model, prefix) {
+			return Cl100k
+		}
+	}
+	return O200k
+}
+
+// Name returns the encoding's tiktoken name.
+func (e *Encoding) Name() string { return e.name }
+
+// Count returns the number of tokens text encodes to.
+func (e *Encoding) Count(text string) int {
+	e.once.Do(e.load)
+	n := 0
+	e.split(text, func(piece string) {
+		for len(piece) > maxPiece {
+			cut := maxPiece
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			n += e.merge(piece[:cut])
+			piece = piece[cut:]
+		}
+		n += e.merge(piece)
+	})
+	return n
+}
+
+func (e *Encoding) load() {
+	ranks, err := loader.NewOfflineLoader().LoadTiktokenBpe(e.name + ".tiktoken")
+	if err != nil {
+		// The vocabularies are embedded in the binary; failing to read one
+		// is a build problem, not something a caller can handle.
+		panic(fmt.Sprintf("tokenizer: loading %s: %v", e.name, err))
+	}
+	e.ranks = ranks
+}
+
+// merge returns how many tokens piece byte-pair merges into: starting from
+// single bytes, the adjacent pair with the lowest rank (the leftmost on a
+// tie) is joined until no joined pair is in the vocabulary. As in tiktoken,
+// each part keeps the rank of joining it with the next one, so a merge only
+// looks up the pairs around it.
+func (e *Encoding) merge(piece string) int {
+	if piece == "" {
+		return 0
+	}
+	if _, ok := e.ranks[piece]; ok {
+		return 1
+	}
+
+	type part struct{ start, rank int }
+	// parts holds every part, then the end of the piece.
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: math.MaxInt}
+	}
+	rankAt := func(i int) int {
+		if i+2 < len(parts) {
+			if rank, ok := e.ranks[piece[parts[i].start:parts[i+2].start]]; ok {
+				return rank
+			}
+		}
+		return math.MaxInt
+	}
+	for i := range parts {
+		parts[i].rank = rankAt(i)
+	}
+	for {
+		best, at := math.MaxInt, -1
+		for i, p := range parts {
+			if p.rank < best {
+				best, at = p.rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = slices.Delete(parts, at+1, at+2)
+		parts[at].rank = rankAt(at)
+		if at > 0 {
+			parts[at-1].rank = rankAt(at - 1)
+		}
+	}
+	return len(parts) - 1
+}
+
+// CountConversation sets the Tokens of every message of conversation and
+// the conversation's total. Each message is counted with the encoding of
+// its model; one without a model, such as the user's prompts, with the
+// model of the next reply that names one, or failing that the last one
+// before it.
+func CountConversation(conversation *models.Conversation) {
+	messages := conversation.Messages
+	modelOf := make([]string, len(messages))
+	model := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Model != "" {
+			model = messages[i].Model
+		}
+		modelOf[i] = model
+	}
+	model = ""
+	total := 0
+	for i := range messages {
+		if messages[i].Model != "" {
+			model = messages[i].Model
+		}
+		if modelOf[i] == "" {
+			modelOf[i] = model
+		}
+		messages[i].Tokens = ForModel(modelOf[i]).Count(messages[i].Content)
+		total += messages[i].Tokens
+	}
+	conversation.Tokens = total
+}
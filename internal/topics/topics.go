@@ -0,0 +1,150 @@
+// Package topics picks the words that set a conversation apart from the
+// rest of the archive, by TF-IDF over its title and messages, and tallies
+// them across conversations.
+package topics
+
+import (
+	"math"
+	"sort"
+
+	"zatGPT/internal/models"
+	"zatGPT/internal/terms"
+)
+
+// PerConversation is how many topics Extract returns.
+const PerConversation = 8
+
+// titleWeight counts each word of the title as this many occurrences: a
+// title names what the conversation is about in a few words.
+const titleWeight = 3
+
+// Corpus holds the document frequencies of a set of conversations: how
+// many of them use each word. The zero value is not usable; call
+// NewCorpus.
+type Corpus struct {
+	docs int
+	df   map[string]int
+}
+
+// NewCorpus returns a Corpus of conversations.
+func NewCorpus(conversations map[string]models.Conversation) *Corpus {
+	c := &Corpus{df: make(map[string]int)}
+	for _, convo := range conversations {
+		c.Add(convo)
+	}
+	return c
+}
+
+// Add counts convo's words.
+func (c *Corpus) Add(convo models.Conversation) {
+	counts := termCounts(convo)
+	if len(counts) == 0 {
+		return
+	}
+	c.docs++
+	for word := range counts {
+		c.df[word]++
+	}
+}
+
+// Remove uncounts a conversation added before.
+func (c *Corpus) Remove(convo models.Conversation) {
+	counts := termCounts(convo)
+	if len(counts) == 0 {
+		return
+	}
+	c.docs--
+	for word := range counts {
+		if c.df[word]--; c.df[word] <= 0 {
+			delete(c.df, word)
+		}
+	}
+}
+
+// Extract returns convo's PerConversation highest-scoring words, best
+// first, scored as if convo were one more conversation of the corpus. A
+// word's score grows with the log of how often convo uses it and shrinks
+// the more conversations use it too. It returns nil for a conversation
+// without text.
+func (c *Corpus) Extract(convo models.Conversation) []string {
+	counts := termCounts(convo)
+	if len(counts) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		word  string
+		score float64
+	}
+	docs := float64(c.docs + 1)
+	candidates := make([]scored, 0, len(counts))
+	for word, count := range counts {
+		idf := math.Log((docs + 1) / float64(c.df[word]+1))
+		candidates = append(candidates, scored{word, (1 + math.Log(float64(count))) * idf})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score == candidates[j].score {
+			return candidates[i].word < candidates[j].word
+		}
+		return candidates[i].score > candidates[j].score
+	})
+
+	out := make([]string, 0, PerConversation)
+	for _, candidate := range candidates {
+		if len(out) == PerConversation || candidate.score <= 0 {
+			break
+		}
+		out = append(out, candidate.word)
+	}
+	return out
+}
+
+// termCounts counts the words of convo's title and of its user and
+// assistant messages; system prompts and tool output say little about the
+// topic.
+func termCounts(convo models.Conversation) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range terms.Tokenize(convo.Title) {
+		counts[word] += titleWeight
+	}
+	for _, msg := range convo.Messages {
+		if msg.Author != models.AuthorUser && msg.Author != models.AuthorAssistant {
+			continue
+		}
+		for _, word := range terms.Tokenize(msg.Content) {
+			counts[word]++
+		}
+	}
+	return counts
+}
+
+// Topic is one entry of Tally: a topic and how many conversations have it.
+type Topic struct {
+	Topic         string `json:"topic"`
+	Conversations int    `json:"conversations"`
+}
+
+// Tally counts the conversations carrying each topic and returns the top
+// most common, most common first; top <= 0 returns them all.
+func Tally(conversations []models.Conversation, top int) []Topic {
+	counts := make(map[string]int)
+	for _, convo := range conversations {
+		for _, topic := range convo.Topics {
+			counts[topic]++
+		}
+	}
+	out := make([]Topic, 0, len(counts))
+	for topic, n := range counts {
+		out = append(out, Topic{Topic: topic, Conversations: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Conversations == out[j].Conversations {
+			return out[i].Topic < out[j].Topic
+		}
+		return out[i].Conversations > out[j].Conversations
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+// Package webhook delivers event notifications as JSON POSTs to
+// user-configured URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"zatGPT/internal/telemetry"
+)
+
+// Event is the body of every delivery.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// Notifier posts events to every URL. When Secret is set each delivery is
+// signed with an HMAC-SHA256 of the body in the X-ZatGPT-Signature header
+// ("sha256=<hex>").
+type Notifier struct {
+	URLs   []string
+	Secret string
+	Client *http.Client
+}
+
+// New returns a Notifier for urls; it returns nil when urls is empty, and a
+// nil Notifier drops every event.
+func New(urls []string, secret string) *Notifier {
+	if len(urls) == 0 {
+		return nil
+	}
+	return &Notifier{
+		URLs:   urls,
+		Secret: secret,
+		Client: &http.Client{Timeout: 10 * time.Second, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// Send delivers one event to every URL, returning the joined errors of the
+// deliveries that failed.
+func (n *Notifier) Send(ctx context.Context, eventType string, data any) error {
+	if n == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(Event{Type: eventType, Time: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, url := range n.URLs {
+		if err := n.post(ctx, url, eventType, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(ctx context.Context, url, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zatGPT-webhook/1.0")
+	req.Header.Set("X-ZatGPT-Event", eventType)
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set("X-ZatGPT-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
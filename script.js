@@ -6,6 +6,7 @@ const clearAllButton = document.querySelector("#clear-all");
 const renameDialog = document.querySelector("#rename-dialog");
 const renameForm = document.querySelector("#rename-form");
 const renameInput = document.querySelector("#rename-input");
+const showArchivedToggle = document.querySelector("#show-archived");
 
 let conversations = [];
 let renameTargetId = null;
@@ -21,6 +22,7 @@ function wireEvents() {
   form.addEventListener("submit", handleFormSubmit);
   tableBody.addEventListener("click", handleTableClick);
   clearAllButton.addEventListener("click", handleClearAll);
+  showArchivedToggle.addEventListener("change", refreshConversations);
   renameForm.addEventListener("submit", handleRenameSubmit);
   renameForm.querySelector('button[value="cancel"]').addEventListener("click", () => {
     renameTargetId = null;
@@ -30,7 +32,9 @@ function wireEvents() {
 
 async function refreshConversations() {
   try {
-    const data = await fetchJSON(`${API_BASE}/conversations`);
+    // Archived conversations stay out of the list unless asked for.
+    const query = showArchivedToggle.checked ? "" : "?archived=false";
+    const data = await fetchJSON(`${API_BASE}/conversations${query}`);
     conversations = data.conversations ?? [];
     renderTable();
   } catch (error) {
@@ -77,6 +81,29 @@ function handleTableClick(event) {
     openRenameDialog(id);
   } else if (action === "view") {
     viewConversation(id);
+  } else if (action === "star") {
+    toggleFlag(id, "starred");
+  } else if (action === "archive") {
+    toggleFlag(id, "archived");
+  }
+}
+
+async function toggleFlag(id, flag) {
+  const conversation = conversations.find((item) => item.id === id);
+  if (!conversation) return;
+
+  try {
+    const updated = await fetchJSON(`${API_BASE}/conversations/${id}`, {
+      method: "PATCH",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ [flag]: !conversation[flag] }),
+    });
+    conversations = conversations
+      .map((item) => (item.id === updated.id ? updated : item))
+      .filter((item) => showArchivedToggle.checked || !item.archived);
+    renderTable();
+  } catch (error) {
+    showError("Unable to update conversation", error);
   }
 }
 
@@ -86,11 +113,14 @@ function viewConversation(id) {
 
 async function handleClearAll() {
   if (conversations.length === 0) return;
-  const confirmed = window.confirm("Delete all conversations? This cannot be undone.");
+  const confirmed = window.confirm("Move all conversations to the trash?");
   if (!confirmed) return;
 
   try {
-    await fetchJSON(`${API_BASE}/conversations`, { method: "DELETE" });
+    // The server only deletes everything when the token from a first
+    // call comes back, and backs the store up before it does.
+    const { confirmToken } = await fetchJSON(`${API_BASE}/conversations`, { method: "DELETE" });
+    await fetchJSON(`${API_BASE}/conversations?confirm=${encodeURIComponent(confirmToken)}`, { method: "DELETE" });
     conversations = [];
     renderTable();
   } catch (error) {
@@ -137,7 +167,7 @@ function openRenameDialog(id) {
 async function deleteConversation(id) {
   const conversation = conversations.find((item) => item.id === id);
   const confirmed = window.confirm(
-    `Delete "${conversation?.title ?? "this conversation"}"?`
+    `Move "${conversation?.title ?? "this conversation"}" to the trash?`
   );
   if (!confirmed) return;
 
@@ -175,6 +205,12 @@ function renderTable() {
     } else {
       titleCell.textContent = conversation.title;
     }
+    if (conversation.starred) {
+      titleCell.prepend("★ ");
+    }
+    if (conversation.archived) {
+      row.classList.add("archived");
+    }
     row.appendChild(titleCell);
 
     row.appendChild(createTextCell(conversation.dateStarted ? formatDate(conversation.dateStarted) : "–"));
@@ -184,50 +220,71 @@ function renderTable() {
     const actionCell = document.createElement("td");
     actionCell.classList.add("actions-col");
 
-    const viewButton = document.createElement("button");
-    viewButton.type = "button";
-    viewButton.className = "action-button";
-    viewButton.dataset.action = "view";
-    viewButton.dataset.id = conversation.id;
-    viewButton.textContent = "View";
-
-    const renameButton = document.createElement("button");
-    renameButton.type = "button";
-    renameButton.className = "action-button";
-    renameButton.dataset.action = "rename";
-    renameButton.dataset.id = conversation.id;
-    renameButton.textContent = "Rename";
-
-    const deleteButton = document.createElement("button");
-    deleteButton.type = "button";
-    deleteButton.className = "action-button danger";
-    deleteButton.dataset.action = "delete";
-    deleteButton.dataset.id = conversation.id;
-    deleteButton.textContent = "Delete";
-
-    actionCell.appendChild(viewButton);
-    actionCell.appendChild(renameButton);
-    actionCell.appendChild(deleteButton);
+    actionCell.appendChild(createActionButton("view", conversation.id, "View"));
+    actionCell.appendChild(createActionButton("star", conversation.id, conversation.starred ? "Unstar" : "Star"));
+    actionCell.appendChild(createActionButton("rename", conversation.id, "Rename"));
+    actionCell.appendChild(createActionButton("archive", conversation.id, conversation.archived ? "Unarchive" : "Archive"));
+    actionCell.appendChild(createActionButton("delete", conversation.id, "Delete", "danger"));
     row.appendChild(actionCell);
 
     tableBody.appendChild(row);
   });
 }
 
+// Servers started with -api-key answer 401 until the key is supplied; it
+// is kept in localStorage for fetches, and the server answers the first
+// fetch that sends it with an HttpOnly cookie for media elements.
+const API_KEY_STORAGE = "zatgpt-api-key";
+
+function withAPIKey(options) {
+  const key = localStorage.getItem(API_KEY_STORAGE);
+  if (!key) {
+    return options;
+  }
+  return { ...options, headers: { ...options.headers, Authorization: `Bearer ${key}` } };
+}
+
+function askForAPIKey() {
+  const key = window.prompt("This archive requires an API key:");
+  if (!key) {
+    return false;
+  }
+  localStorage.setItem(API_KEY_STORAGE, key);
+  return true;
+}
+
+// The API shows conversation dates in the zone the Time-Zone header names;
+// ask for the browser's, so a late-night chat lands on the right day.
+const TIME_ZONE = Intl.DateTimeFormat().resolvedOptions().timeZone;
+
+function withTimeZone(options) {
+  if (!TIME_ZONE) {
+    return options;
+  }
+  return { ...options, headers: { ...options.headers, "Time-Zone": TIME_ZONE } };
+}
+
 async function fetchJSON(url, options = {}) {
-  const response = await fetch(url, options);
+  options = withTimeZone(options);
+  let response = await fetch(url, withAPIKey(options));
+  if (response.status === 401 && askForAPIKey()) {
+    response = await fetch(url, withAPIKey(options));
+  }
   if (!response.ok) {
     let message = `${response.status} ${response.statusText}`;
+    let code;
     try {
       const data = await response.json();
       if (data && data.error) {
-        message = data.error;
+        message = data.error.message ?? data.error;
+        code = data.error.code;
       }
     } catch (error) {
       // ignore parse errors
     }
     const error = new Error(message);
     error.status = response.status;
+    error.code = code;
     throw error;
   }
 
@@ -251,10 +308,13 @@ function formatDate(dateString) {
   if (!dateString) return "";
   const date = new Date(dateString);
   if (Number.isNaN(date.valueOf())) return dateString;
+  // Dates arrive as the day in the browser's zone; a bare YYYY-MM-DD
+  // parses as UTC midnight, so format it in UTC to keep the day.
   return date.toLocaleDateString(undefined, {
     year: "numeric",
     month: "short",
     day: "numeric",
+    timeZone: "UTC",
   });
 }
 
@@ -264,6 +324,16 @@ function createTextCell(value) {
   return cell;
 }
 
+function createActionButton(action, id, label, variant) {
+  const button = document.createElement("button");
+  button.type = "button";
+  button.className = variant ? `action-button ${variant}` : "action-button";
+  button.dataset.action = action;
+  button.dataset.id = id;
+  button.textContent = label;
+  return button;
+}
+
 function compactText(value) {
   return value ? value.replace(/\s+/g, " ").trim() : "";
 }